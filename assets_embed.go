@@ -0,0 +1,16 @@
+//go:build embedassets
+
+package main
+
+import (
+	"embed"
+	"io/fs"
+)
+
+// embedded 编译进二进制的背景图和拼图mask（go build -tags embedassets）
+//
+//go:embed images mask
+var embedded embed.FS
+
+// embeddedAssets 资源文件系统，未配置 CAPTCHA_ASSETS 时使用
+var embeddedAssets fs.FS = embedded
@@ -0,0 +1,8 @@
+//go:build !embedassets
+
+package main
+
+import "io/fs"
+
+// embeddedAssets 默认构建不内嵌资源，背景图和mask从运行目录读取
+var embeddedAssets fs.FS
@@ -0,0 +1,108 @@
+// Package audit 输出验证审计记录，便于安全团队事后排查撞库、打码等攻击
+package audit
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Record 一次验证尝试的审计记录
+type Record struct {
+	Time      time.Time `json:"time"`                // 验证时间
+	CaptchaID string    `json:"captchaId"`           // 验证码ID
+	Success   bool      `json:"success"`             // 是否通过
+	Reason    string    `json:"reason,omitempty"`    // 失败原因
+	Diff      int       `json:"diff"`                // 与缺口位置的误差（像素）
+	LatencyMs float64   `json:"latencyMs"`           // 验证请求处理耗时（毫秒）
+	SolveMs   int64     `json:"solveMs,omitempty"`   // 从生成到提交验证的耗时（毫秒）
+	IPHash    string    `json:"ipHash,omitempty"`    // 客户端IP哈希（加盐，不记录明文IP）
+	RiskScore float64   `json:"riskScore"`           // 轨迹风险分
+	RiskFlags []string  `json:"riskFlags,omitempty"` // 命中的风险标记
+
+	RequestID         string `json:"requestId,omitempty"`         // 验证请求ID
+	GenerateRequestID string `json:"generateRequestId,omitempty"` // 生成该验证码的请求ID
+}
+
+// Sink 审计记录输出目标
+type Sink interface {
+	// Write 写入一条记录
+	Write(record *Record) error
+	// Close 刷新并关闭
+	Close() error
+}
+
+// HashIP 计算加盐的IP哈希（取前16字节），既能聚合同一来源又不泄露明文IP
+func HashIP(ip, salt string) string {
+	if ip == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(salt + ip))
+	return hex.EncodeToString(sum[:16])
+}
+
+// NewSink 根据配置创建输出目标，多个目标用逗号分隔：
+//   - stdout：标准输出，每行一条JSON
+//   - file:/path/to/audit.log：追加写入文件，每行一条JSON
+//   - http://... 或 https://...：异步POST到Webhook
+func NewSink(spec string) (Sink, error) {
+	var sinks []Sink
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		var sink Sink
+		var err error
+
+		switch {
+		case part == "":
+			continue
+		case part == "stdout":
+			sink = NewStdoutSink()
+		case strings.HasPrefix(part, "file:"):
+			sink, err = NewFileSink(strings.TrimPrefix(part, "file:"))
+		case strings.HasPrefix(part, "http://"), strings.HasPrefix(part, "https://"):
+			sink = NewWebhookSink(part)
+		default:
+			err = fmt.Errorf("unsupported audit sink: %s", part)
+		}
+
+		if err != nil {
+			for _, s := range sinks {
+				s.Close()
+			}
+			return nil, err
+		}
+		sinks = append(sinks, sink)
+	}
+
+	if len(sinks) == 1 {
+		return sinks[0], nil
+	}
+	return MultiSink(sinks), nil
+}
+
+// MultiSink 同时写入多个目标
+type MultiSink []Sink
+
+// Write 写入所有目标，返回第一个错误
+func (m MultiSink) Write(record *Record) error {
+	var firstErr error
+	for _, sink := range m {
+		if err := sink.Write(record); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// Close 关闭所有目标，返回第一个错误
+func (m MultiSink) Close() error {
+	var firstErr error
+	for _, sink := range m {
+		if err := sink.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
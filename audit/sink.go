@@ -0,0 +1,141 @@
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// WriterSink 将记录以JSON行写入 io.Writer
+type WriterSink struct {
+	mu     sync.Mutex
+	w      io.Writer
+	closer io.Closer
+}
+
+// NewStdoutSink 创建输出到标准输出的目标
+func NewStdoutSink() *WriterSink {
+	return &WriterSink{w: os.Stdout}
+}
+
+// NewFileSink 创建追加写入文件的目标
+func NewFileSink(path string) (*WriterSink, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o640)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit file: %w", err)
+	}
+	return &WriterSink{w: file, closer: file}, nil
+}
+
+// Write 写入一条JSON记录
+func (s *WriterSink) Write(record *Record) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, err = s.w.Write(line)
+	return err
+}
+
+// Close 关闭底层文件
+func (s *WriterSink) Close() error {
+	if s.closer != nil {
+		return s.closer.Close()
+	}
+	return nil
+}
+
+// WebhookSink 异步将记录POST到Webhook，队列满时丢弃记录，避免拖慢验证请求
+type WebhookSink struct {
+	url    string
+	client *http.Client
+	queue  chan *Record
+	done   chan struct{}
+
+	mu     sync.RWMutex
+	closed bool
+}
+
+// NewWebhookSink 创建Webhook目标
+func NewWebhookSink(url string) *WebhookSink {
+	s := &WebhookSink{
+		url:    url,
+		client: &http.Client{Timeout: 5 * time.Second},
+		queue:  make(chan *Record, 1024),
+		done:   make(chan struct{}),
+	}
+	go s.loop()
+	return s
+}
+
+// Write 将记录放入发送队列
+func (s *WebhookSink) Write(record *Record) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.closed {
+		return fmt.Errorf("audit webhook sink closed")
+	}
+
+	select {
+	case s.queue <- record:
+		return nil
+	default:
+		return fmt.Errorf("audit webhook queue full, record dropped")
+	}
+}
+
+// Close 发送完队列中剩余的记录后关闭
+func (s *WebhookSink) Close() error {
+	s.mu.Lock()
+	if !s.closed {
+		s.closed = true
+		close(s.queue)
+	}
+	s.mu.Unlock()
+
+	<-s.done
+	return nil
+}
+
+// loop 逐条发送队列中的记录
+func (s *WebhookSink) loop() {
+	defer close(s.done)
+
+	for record := range s.queue {
+		if err := s.post(record); err != nil {
+			slog.Error("failed to send audit record", "error", err)
+		}
+	}
+}
+
+// post 发送一条记录
+func (s *WebhookSink) post(record *Record) error {
+	body, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	resp, err := s.client.Post(s.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package captcha
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"time"
+)
+
+// Answer 验证码的正确答案，仅通过 RevealAnswer 向持有服务密钥的可信后端披露
+type Answer struct {
+	ID string `json:"id"`
+	X  int    `json:"x"` // 验证时应提交的X：缺口X坐标（画布坐标）加上轨道偏移
+	Y  int    `json:"y"` // 缺口Y坐标（画布坐标）
+	// Tolerance 服务验证时使用的允许误差（像素），已考虑生成时按难度收紧的容差
+	Tolerance int           `json:"tolerance"`
+	Status    CaptchaStatus `json:"status"`
+	ExpiresAt time.Time     `json:"expiresAt"`
+	// Signature 使用服务密钥对以上字段计算的 HMAC-SHA256（十六进制），经其他服务转发后可用 VerifyAnswer 校验
+	Signature string `json:"signature"`
+}
+
+// payload 参与签名的字段
+func (a *Answer) payload() string {
+	return fmt.Sprintf("%s|%d|%d|%d|%s|%d", a.ID, a.X, a.Y, a.Tolerance, a.Status, a.ExpiresAt.Unix())
+}
+
+// signAnswer 计算答案签名
+func signAnswer(secret string, a *Answer) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(a.payload()))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyAnswer 校验答案签名，确认答案由持有相同密钥的服务签发且未被篡改
+func VerifyAnswer(secret string, a *Answer) bool {
+	if secret == "" || a == nil {
+		return false
+	}
+	return hmac.Equal([]byte(signAnswer(secret, a)), []byte(a.Signature))
+}
+
+// RevealAnswer 向可信后端返回验证码的缺口位置，用于在服务端结合设备信号等实现自定义判定
+// secret 必须与 WithAnswerSecret 配置的密钥一致，未配置密钥或密钥错误时返回 ErrAnswerForbidden；
+// 披露答案不改变验证码状态，也不消耗验证次数
+func (s *CaptchaService) RevealAnswer(ctx context.Context, id, secret string) (*Answer, error) {
+	if s.answerSecret == "" || !hmac.Equal([]byte(secret), []byte(s.answerSecret)) {
+		return nil, ErrAnswerForbidden
+	}
+	store := s.Store()
+	if store == nil {
+		return nil, ErrNotInitialized
+	}
+	if err := s.checkID(id, s.captchaTTL(store)); err != nil {
+		return nil, ErrNotFound
+	}
+
+	data, exists, err := storeGet(ctx, store, id)
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to load captcha: %w", ErrUnavailable, err)
+	}
+	if !exists {
+		return nil, ErrNotFound
+	}
+	if !data.supportedFormat() {
+		return nil, ErrUnsupportedFormat
+	}
+
+	answer := &Answer{
+		ID:        id,
+		X:         data.PositionX + data.TrackOffset,
+		Y:         data.PositionY,
+		Tolerance: s.answerTolerance(data),
+		Status:    data.status(),
+		ExpiresAt: data.CreatedAt.Add(s.captchaTTL(store)),
+	}
+	answer.Signature = signAnswer(s.answerSecret, answer)
+
+	s.log().Info("captcha answer revealed", "id", id)
+	return answer, nil
+}
+
+// answerTolerance 验证该验证码时实际使用的误差：生成时按难度收紧的容差优先
+func (s *CaptchaService) answerTolerance(data *CaptchaData) int {
+	tolerance := s.tolerance
+	if data.Tolerance > 0 && data.Tolerance < tolerance {
+		tolerance = data.Tolerance
+	}
+	return tolerance
+}
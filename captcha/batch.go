@@ -0,0 +1,70 @@
+package captcha
+
+import (
+	"context"
+	"fmt"
+)
+
+// MaxVerifyBatch 一次批量验证最多包含的验证码数量
+const MaxVerifyBatch = 100
+
+// BatchStore 支持一次读取多个验证码的存储，远程存储（如 Redis）实现该接口后批量验证只需一次往返（如 MGET 或 pipeline）
+// VerifyBatch 优先使用该接口，存储未实现时逐个读取
+type BatchStore interface {
+	Store
+	// GetMulti 按 ids 的顺序返回验证码数据，不存在或已过期的为nil
+	GetMulti(ctx context.Context, ids []string) ([]*CaptchaData, error)
+}
+
+// prefetched 批量验证预先读取的验证码数据，data 为nil表示不存在或已过期
+type prefetched struct {
+	data *CaptchaData
+}
+
+// BatchVerifyResult 批量验证中一个验证码的结果，Result 和 Err 与 Verify 的返回值相同
+type BatchVerifyResult struct {
+	Result *VerifyResult
+	Err    error
+}
+
+// VerifyBatch 依次验证多个验证码，供缓冲了多个验证请求的网关使用，结果与 items 一一对应，每项与单独调用 Verify 相同
+// 存储实现 BatchStore 时先一次读取全部验证码，同一批中重复的ID只有第一次使用预先读取的数据，之后重新读取以看到前一次验证的结果；
+// 数量超过 MaxVerifyBatch 时返回 ErrBatchTooLarge
+func (s *CaptchaService) VerifyBatch(ctx context.Context, items []VerifyParams) ([]BatchVerifyResult, error) {
+	if len(items) > MaxVerifyBatch {
+		return nil, fmt.Errorf("%w: %d items, at most %d", ErrBatchTooLarge, len(items), MaxVerifyBatch)
+	}
+	store := s.Store()
+	if store == nil {
+		return nil, ErrNotInitialized
+	}
+
+	var pre []*CaptchaData
+	if bs, ok := store.(BatchStore); ok && len(items) > 1 {
+		ids := make([]string, len(items))
+		for i, item := range items {
+			ids[i] = item.ID
+		}
+		var err error
+		if pre, err = bs.GetMulti(ctx, ids); err != nil {
+			// 批量读取失败时逐个读取，由每一项各自报告存储错误
+			s.log().Warn("failed to prefetch captchas", "count", len(ids), "error", err)
+			pre = nil
+		}
+	}
+
+	results := make([]BatchVerifyResult, len(items))
+	seen := make(map[string]bool, len(items))
+	for i, params := range items {
+		if params.Tolerance == 0 {
+			params.Tolerance = s.tolerance
+		}
+		var p *prefetched
+		if pre != nil && !seen[params.ID] {
+			p = &prefetched{data: pre[i]}
+		}
+		seen[params.ID] = true
+		results[i].Result, results[i].Err = s.verifyAndLog(ctx, params, p)
+	}
+	return results, nil
+}
@@ -0,0 +1,154 @@
+package captcha
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// BurstConfig 突发流量下复用已渲染验证码的配置
+// 复用的验证码使用不同的ID，失败次数和状态分别存储；图片相同，但每个ID有各自的轨道偏移（SliderCaptcha.TrackOffset），
+// 一个验证码的答案不能用于另一个。同一张图片仍会展示给多个客户端，只应作为流量高峰时的容量保护，
+// 用 Window 和 MaxReuse 限制同一张图片的曝光范围
+type BurstConfig struct {
+	// Threshold 每秒生成数量超过该值时开始复用（0表示不启用）
+	Threshold int
+	// Window 同一验证码渲染后可被复用的时间窗口，默认 DefaultBurstWindow
+	Window time.Duration
+	// MaxReuse 同一验证码最多被复用的次数（不含首次），默认 DefaultBurstMaxReuse
+	MaxReuse int
+}
+
+const (
+	// DefaultBurstWindow 默认复用时间窗口
+	DefaultBurstWindow = 2 * time.Second
+	// DefaultBurstMaxReuse 默认最多复用次数
+	DefaultBurstMaxReuse = 10
+)
+
+// BurstStats 复用统计
+type BurstStats struct {
+	Rendered  uint64  `json:"rendered"`  // 重新渲染的验证码数量
+	Reused    uint64  `json:"reused"`    // 复用已渲染图片的验证码数量
+	ReuseRate float64 `json:"reuseRate"` // 复用数量占生成总数的比例
+}
+
+// burstOffsetStep 复用同一张图片的验证码之间轨道偏移的间隔（像素）：第 n 次复用的偏移在 [n*step, n*step+step/2) 内随机，
+// 任意两个偏移至少相差 step/2，大于常用容差的两倍，一个验证码的答案不会落在另一个的容差范围内
+const burstOffsetStep = 64
+
+// burstTrackOffset 第 use 次复用（从1开始）的轨道偏移，首次渲染的验证码不偏移
+func burstTrackOffset(use int, intn func(int) int) int {
+	return use*burstOffsetStep + intn(burstOffsetStep/2)
+}
+
+// burstEntry 可被复用的验证码
+type burstEntry struct {
+	challenge *renderedChallenge
+	createdAt time.Time
+	uses      int
+}
+
+// burstCache 突发流量复用缓存，按干扰缺口数量区分（不同难度的图片不能混用）
+type burstCache struct {
+	cfg BurstConfig
+
+	mu      sync.Mutex
+	second  int64 // 当前计数的秒
+	count   int   // 当前秒内的生成数量
+	entries map[int]*burstEntry
+
+	rendered atomic.Uint64
+	reused   atomic.Uint64
+}
+
+// newBurstCache 创建复用缓存，cfg.Threshold 不大于0时返回nil
+func newBurstCache(cfg BurstConfig) *burstCache {
+	if cfg.Threshold <= 0 {
+		return nil
+	}
+	if cfg.Window <= 0 {
+		cfg.Window = DefaultBurstWindow
+	}
+	if cfg.MaxReuse <= 0 {
+		cfg.MaxReuse = DefaultBurstMaxReuse
+	}
+	return &burstCache{cfg: cfg, entries: make(map[int]*burstEntry)}
+}
+
+// take 记录一次生成，流量超过阈值时返回可复用的验证码及这是第几次复用（从1开始），
+// 未启用、未超过阈值或没有可复用的验证码时返回nil
+func (b *burstCache) take(decoys int, opts GenerateOptions, now time.Time) (*renderedChallenge, int) {
+	if b == nil || opts.custom() {
+		return nil, 0
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if sec := now.Unix(); sec != b.second {
+		b.second, b.count = sec, 0
+	}
+	b.count++
+	if b.count <= b.cfg.Threshold {
+		return nil, 0
+	}
+
+	entry, exists := b.entries[decoys]
+	if !exists || entry.uses >= b.cfg.MaxReuse || now.Sub(entry.createdAt) > b.cfg.Window {
+		return nil, 0
+	}
+	entry.uses++
+	b.reused.Add(1)
+	return entry.challenge, entry.uses
+}
+
+// put 记录新渲染的验证码，供流量高峰时复用
+func (b *burstCache) put(decoys int, opts GenerateOptions, challenge *renderedChallenge, now time.Time) {
+	if b == nil {
+		return
+	}
+	b.rendered.Add(1)
+	if opts.custom() {
+		return
+	}
+	b.mu.Lock()
+	b.entries[decoys] = &burstEntry{challenge: challenge, createdAt: now}
+	b.mu.Unlock()
+}
+
+// reset 丢弃可复用的验证码（背景图更换后调用）
+func (b *burstCache) reset() {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	clear(b.entries)
+	b.mu.Unlock()
+}
+
+// stats 返回复用统计
+func (b *burstCache) stats() BurstStats {
+	if b == nil {
+		return BurstStats{}
+	}
+	stats := BurstStats{Rendered: b.rendered.Load(), Reused: b.reused.Load()}
+	if total := stats.Rendered + stats.Reused; total > 0 {
+		stats.ReuseRate = float64(stats.Reused) / float64(total)
+	}
+	return stats
+}
+
+// WithBurstCache 启用突发流量复用：每秒生成数量超过 cfg.Threshold 时，在 cfg.Window 内把同一张已渲染的图片
+// 分配给最多 cfg.MaxReuse 个新验证码，以降低渲染开销；默认不启用
+// 复用的验证码图片相同，靠各自的轨道偏移区分答案，前端必须把 trackOffset 加到提交的 X 上；
+// 仅作为流量高峰时的容量保护，不应长期开启
+func WithBurstCache(cfg BurstConfig) Option {
+	return func(s *CaptchaService) {
+		s.burst = newBurstCache(cfg)
+	}
+}
+
+// BurstStats 返回突发流量复用统计，未启用时全部为0
+func (s *CaptchaService) BurstStats() BurstStats {
+	return s.burst.stats()
+}
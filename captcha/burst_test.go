@@ -0,0 +1,65 @@
+package captcha
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+// TestBurstReuseAnswers 复用同一张图片的验证码各有不同的答案，一个验证码的答案不能通过另一个的验证
+func TestBurstReuseAnswers(t *testing.T) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, CanvasWidth, CanvasHeight))); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Unix(1700000000, 0)
+	s := NewCaptchaService(
+		WithFS(fstest.MapFS{"bg.png": {Data: buf.Bytes()}}),
+		WithBackgrounds("bg.png"),
+		WithClock(ClockFunc(func() time.Time { return now })),
+		WithBurstCache(BurstConfig{Threshold: 1}),
+	)
+	defer s.Close()
+
+	const count = 4
+	captchas := make([]*SliderCaptcha, count)
+	answers := make([]int, count)
+	for i := range captchas {
+		c, err := s.Generate()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i > 0 && c.Background != captchas[0].Background {
+			t.Fatalf("captcha %d was rendered again, want reused image", i)
+		}
+		data, ok := s.Store().Get(c.ID)
+		if !ok {
+			t.Fatalf("captcha %d missing from store", i)
+		}
+		if data.TrackOffset != c.TrackOffset {
+			t.Errorf("stored TrackOffset = %d, returned %d", data.TrackOffset, c.TrackOffset)
+		}
+		captchas[i], answers[i] = c, data.PositionX+data.TrackOffset
+	}
+	if stats := s.BurstStats(); stats.Reused != count-1 {
+		t.Fatalf("BurstStats().Reused = %d, want %d", stats.Reused, count-1)
+	}
+
+	for i, c := range captchas {
+		for j, x := range answers {
+			if i == j {
+				continue
+			}
+			result, _ := s.Verify(VerifyParams{ID: c.ID, X: x, Tolerance: DefaultTolerance})
+			if result.Success {
+				t.Errorf("captcha %d accepted the answer of captcha %d (x=%d)", i, j, x)
+			}
+		}
+		if result, _ := s.Verify(VerifyParams{ID: c.ID, X: answers[i], Tolerance: DefaultTolerance}); !result.Success {
+			t.Errorf("captcha %d rejected its own answer: %s", i, result.Reason)
+		}
+	}
+}
@@ -0,0 +1,24 @@
+package captcha
+
+import "time"
+
+// Clock 时间来源，测试时注入可控的时钟即可验证过期逻辑而无需等待
+type Clock interface {
+	Now() time.Time
+}
+
+// SystemClock 系统时钟
+type SystemClock struct{}
+
+// Now 返回当前系统时间
+func (SystemClock) Now() time.Time {
+	return time.Now()
+}
+
+// ClockFunc 函数形式的 Clock
+type ClockFunc func() time.Time
+
+// Now 调用函数本身
+func (f ClockFunc) Now() time.Time {
+	return f()
+}
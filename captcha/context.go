@@ -0,0 +1,80 @@
+package captcha
+
+import "context"
+
+// ContextStore 支持 context 的验证码存储，远程存储（如 Redis）实现该接口后可响应调用方的超时和取消
+// CaptchaService 的 *Context 方法优先使用该接口，存储未实现时在每次调用前检查 context 是否已结束
+type ContextStore interface {
+	Store
+	SetContext(ctx context.Context, id string, data *CaptchaData) error
+	GetContext(ctx context.Context, id string) (*CaptchaData, bool, error)
+	UpdateContext(ctx context.Context, id string, data *CaptchaData) error
+	IsExpiredContext(ctx context.Context, id string) (bool, error)
+	DeleteContext(ctx context.Context, id string) error
+}
+
+// storeSet 存储验证码数据
+func storeSet(ctx context.Context, store Store, id string, data *CaptchaData) error {
+	if cs, ok := store.(ContextStore); ok {
+		return cs.SetContext(ctx, id, data)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	store.Set(id, data)
+	return nil
+}
+
+// storeGet 获取验证码数据
+func storeGet(ctx context.Context, store Store, id string) (*CaptchaData, bool, error) {
+	if cs, ok := store.(ContextStore); ok {
+		return cs.GetContext(ctx, id)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, false, err
+	}
+	data, exists := store.Get(id)
+	return data, exists, nil
+}
+
+// storeUpdate 更新验证码数据，存储未实现 UpdateStore 时用 Set 写回
+func storeUpdate(ctx context.Context, store Store, id string, data *CaptchaData) error {
+	if cs, ok := store.(ContextStore); ok {
+		return cs.UpdateContext(ctx, id, data)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if us, ok := store.(UpdateStore); ok {
+		us.Update(id, data)
+		return nil
+	}
+	store.Set(id, data)
+	return nil
+}
+
+// storeIsExpired 判断验证码是否存在但已过期，存储未实现 ExpiryStore 时总是返回 false
+func storeIsExpired(ctx context.Context, store Store, id string) (bool, error) {
+	if cs, ok := store.(ContextStore); ok {
+		return cs.IsExpiredContext(ctx, id)
+	}
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	if es, ok := store.(ExpiryStore); ok {
+		return es.IsExpired(id), nil
+	}
+	return false, nil
+}
+
+// storeDelete 删除验证码数据
+func storeDelete(ctx context.Context, store Store, id string) error {
+	if cs, ok := store.(ContextStore); ok {
+		return cs.DeleteContext(ctx, id)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	store.Delete(id)
+	return nil
+}
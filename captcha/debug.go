@@ -0,0 +1,97 @@
+package captcha
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"strings"
+)
+
+// DebugAnswer 调试模式下随验证码返回的答案
+type DebugAnswer struct {
+	X         int `json:"x"`         // 验证时应提交的X：缺口X坐标（画布坐标）加上轨道偏移
+	Y         int `json:"y"`         // 缺口Y坐标（画布坐标）
+	Tolerance int `json:"tolerance"` // 验证时使用的允许误差（像素）
+	// Preview 滑块放在正确位置并用红框标出答案的合成图（data URI）
+	Preview string `json:"preview"`
+}
+
+// debugMarkColor 预览图中标出答案的颜色
+var debugMarkColor = color.RGBA{R: 255, A: 255}
+
+// DebugAnswer 返回验证码的答案和预览图，服务未通过 WithDebugAnswers 开启调试模式时返回 ErrAnswerForbidden
+// 不改变验证码状态，也不消耗验证次数
+func (s *CaptchaService) DebugAnswer(ctx context.Context, captcha *SliderCaptcha) (*DebugAnswer, error) {
+	if !s.debugAnswers {
+		return nil, ErrAnswerForbidden
+	}
+	store := s.Store()
+	if store == nil {
+		return nil, ErrNotInitialized
+	}
+
+	data, exists, err := storeGet(ctx, store, captcha.ID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to load captcha: %w", ErrUnavailable, err)
+	}
+	if !exists {
+		return nil, ErrNotFound
+	}
+	if !data.supportedFormat() {
+		return nil, ErrUnsupportedFormat
+	}
+
+	preview, err := debugPreview(captcha, data.PositionX, data.PositionY)
+	if err != nil {
+		return nil, err
+	}
+	return &DebugAnswer{
+		X:         data.PositionX + data.TrackOffset,
+		Y:         data.PositionY,
+		Tolerance: s.answerTolerance(data),
+		Preview:   preview,
+	}, nil
+}
+
+// debugPreview 把滑块图叠加到背景图的 (x, y) 处，并用红框标出滑块范围
+func debugPreview(captcha *SliderCaptcha, x, y int) (string, error) {
+	bg, err := decodeDataURI(captcha.Background)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode background: %w", err)
+	}
+	piece, err := decodeDataURI(captcha.Slider)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode slider: %w", err)
+	}
+
+	bounds := bg.Bounds()
+	preview := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(preview, preview.Bounds(), bg, bounds.Min, draw.Src)
+
+	pieceRect := image.Rect(x, y, x+piece.Bounds().Dx(), y+piece.Bounds().Dy())
+	draw.Draw(preview, pieceRect, piece, piece.Bounds().Min, draw.Over)
+
+	for px := pieceRect.Min.X; px < pieceRect.Max.X; px++ {
+		preview.Set(px, pieceRect.Min.Y, debugMarkColor)
+		preview.Set(px, pieceRect.Max.Y-1, debugMarkColor)
+	}
+	for py := pieceRect.Min.Y; py < pieceRect.Max.Y; py++ {
+		preview.Set(pieceRect.Min.X, py, debugMarkColor)
+		preview.Set(pieceRect.Max.X-1, py, debugMarkColor)
+	}
+
+	return ImageToBase64(preview, "png")
+}
+
+// decodeDataURI 解码 data:<type>;base64,<data> 格式的图片
+func decodeDataURI(uri string) (image.Image, error) {
+	header, payload, ok := strings.Cut(uri, ",")
+	if !ok || !strings.HasPrefix(header, "data:") || !strings.HasSuffix(header, ";base64") {
+		return nil, fmt.Errorf("not a base64 data uri")
+	}
+	img, _, err := image.Decode(base64.NewDecoder(base64.StdEncoding, strings.NewReader(payload)))
+	return img, err
+}
@@ -0,0 +1,250 @@
+package captcha
+
+import (
+	"sync"
+	"time"
+)
+
+// DifficultyLevel 验证码难度等级
+type DifficultyLevel int
+
+const (
+	DifficultyNormal   DifficultyLevel = iota // 正常
+	DifficultyElevated                        // 提升：缩小容差、增加一个干扰缺口
+	DifficultyHigh                            // 高：进一步缩小容差、两个干扰缺口、必须提交轨迹
+)
+
+// String 返回难度等级名称
+func (l DifficultyLevel) String() string {
+	switch l {
+	case DifficultyNormal:
+		return "normal"
+	case DifficultyElevated:
+		return "elevated"
+	case DifficultyHigh:
+		return "high"
+	default:
+		return "unknown"
+	}
+}
+
+// DifficultySettings 某个难度等级对应的验证参数
+type DifficultySettings struct {
+	Tolerance         int  // 允许误差（像素）
+	DecoyHoles        int  // 干扰缺口数量
+	RequireTrajectory bool // 是否必须提交拖动轨迹
+}
+
+// DifficultyPresets 各难度等级的验证参数
+var DifficultyPresets = map[DifficultyLevel]DifficultySettings{
+	DifficultyNormal:   {Tolerance: DefaultTolerance, DecoyHoles: 0, RequireTrajectory: false},
+	DifficultyElevated: {Tolerance: 4, DecoyHoles: 1, RequireTrajectory: false},
+	DifficultyHigh:     {Tolerance: 3, DecoyHoles: 2, RequireTrajectory: true},
+}
+
+// Settings 返回难度等级对应的验证参数
+func (l DifficultyLevel) Settings() DifficultySettings {
+	if settings, ok := DifficultyPresets[l]; ok {
+		return settings
+	}
+	return DifficultyPresets[DifficultyNormal]
+}
+
+// PassRateStats 滑动窗口内的通过率统计
+type PassRateStats struct {
+	Total    int     `json:"total"`    // 验证次数
+	Passed   int     `json:"passed"`   // 通过次数
+	PassRate float64 `json:"passRate"` // 通过率
+}
+
+// slidingWindow 按时间分桶的滑动窗口计数器
+type slidingWindow struct {
+	bucketSize time.Duration
+	passed     []int
+	total      []int
+	starts     []time.Time
+}
+
+func newSlidingWindow(window time.Duration, buckets int) *slidingWindow {
+	return &slidingWindow{
+		bucketSize: window / time.Duration(buckets),
+		passed:     make([]int, buckets),
+		total:      make([]int, buckets),
+		starts:     make([]time.Time, buckets),
+	}
+}
+
+// bucket 返回当前时间对应的桶下标，过期的桶会被清零
+func (w *slidingWindow) bucket(now time.Time) int {
+	start := now.Truncate(w.bucketSize)
+	i := int(start.UnixNano()/int64(w.bucketSize)) % len(w.total)
+	if !w.starts[i].Equal(start) {
+		w.starts[i] = start
+		w.passed[i] = 0
+		w.total[i] = 0
+	}
+	return i
+}
+
+func (w *slidingWindow) record(now time.Time, passed bool) {
+	i := w.bucket(now)
+	w.total[i]++
+	if passed {
+		w.passed[i]++
+	}
+}
+
+func (w *slidingWindow) stats(now time.Time) PassRateStats {
+	var stats PassRateStats
+	windowStart := now.Add(-w.bucketSize * time.Duration(len(w.total)))
+	for i := range w.total {
+		if w.starts[i].After(windowStart) {
+			stats.Total += w.total[i]
+			stats.Passed += w.passed[i]
+		}
+	}
+	if stats.Total > 0 {
+		stats.PassRate = float64(stats.Passed) / float64(stats.Total)
+	}
+	return stats
+}
+
+// lastActive 返回窗口最近一次记录所在桶的起始时间
+func (w *slidingWindow) lastActive() time.Time {
+	var last time.Time
+	for _, start := range w.starts {
+		if start.After(last) {
+			last = start
+		}
+	}
+	return last
+}
+
+// AdaptiveDifficulty 根据全局和单IP的滚动通过率自动调整难度
+// 人类用户的通过率通常在八九成，持续接近100%的通过率更像是脚本或打码平台
+type AdaptiveDifficulty struct {
+	mu sync.Mutex
+
+	GlobalMinSamples int // 全局统计生效的最少样本数
+	IPMinSamples     int // 单IP统计生效的最少样本数
+
+	ElevatedPassRate float64 // 通过率达到此值提升到 DifficultyElevated
+	HighPassRate     float64 // 通过率达到此值提升到 DifficultyHigh
+
+	window      time.Duration
+	buckets     int
+	global      *slidingWindow
+	perIP       map[string]*slidingWindow
+	lastCleanup time.Time
+}
+
+// NewAdaptiveDifficulty 创建自适应难度控制器
+// window: 统计窗口，buckets: 窗口分桶数
+func NewAdaptiveDifficulty(window time.Duration, buckets int) *AdaptiveDifficulty {
+	return &AdaptiveDifficulty{
+		GlobalMinSamples: 50,
+		IPMinSamples:     10,
+		ElevatedPassRate: 0.92,
+		HighPassRate:     0.97,
+		window:           window,
+		buckets:          buckets,
+		global:           newSlidingWindow(window, buckets),
+		perIP:            make(map[string]*slidingWindow),
+	}
+}
+
+// Record 记录一次验证结果
+func (d *AdaptiveDifficulty) Record(ip string, passed bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := TimeNow()
+	d.global.record(now, passed)
+
+	if ip != "" {
+		w, exists := d.perIP[ip]
+		if !exists {
+			w = newSlidingWindow(d.window, d.buckets)
+			d.perIP[ip] = w
+		}
+		w.record(now, passed)
+	}
+
+	d.cleanupLocked(now)
+}
+
+// Level 返回某个IP当前应使用的难度等级（取全局和单IP中较高者）
+func (d *AdaptiveDifficulty) Level(ip string) DifficultyLevel {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := TimeNow()
+	level := d.levelFor(d.global.stats(now), d.GlobalMinSamples)
+
+	if w, exists := d.perIP[ip]; exists {
+		if ipLevel := d.levelFor(w.stats(now), d.IPMinSamples); ipLevel > level {
+			level = ipLevel
+		}
+	}
+
+	return level
+}
+
+// GlobalLevel 返回全局难度等级
+func (d *AdaptiveDifficulty) GlobalLevel() DifficultyLevel {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return d.levelFor(d.global.stats(TimeNow()), d.GlobalMinSamples)
+}
+
+// GlobalStats 返回全局通过率统计
+func (d *AdaptiveDifficulty) GlobalStats() PassRateStats {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return d.global.stats(TimeNow())
+}
+
+// IPStats 返回某个IP的通过率统计
+func (d *AdaptiveDifficulty) IPStats(ip string) PassRateStats {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if w, exists := d.perIP[ip]; exists {
+		return w.stats(TimeNow())
+	}
+	return PassRateStats{}
+}
+
+// levelFor 根据通过率统计计算难度等级
+func (d *AdaptiveDifficulty) levelFor(stats PassRateStats, minSamples int) DifficultyLevel {
+	if stats.Total < minSamples {
+		return DifficultyNormal
+	}
+	switch {
+	case stats.PassRate >= d.HighPassRate:
+		return DifficultyHigh
+	case stats.PassRate >= d.ElevatedPassRate:
+		return DifficultyElevated
+	default:
+		return DifficultyNormal
+	}
+}
+
+// cleanupLocked 清理窗口内没有记录的IP，调用方需持有锁
+func (d *AdaptiveDifficulty) cleanupLocked(now time.Time) {
+	if now.Sub(d.lastCleanup) < d.window {
+		return
+	}
+	d.lastCleanup = now
+
+	for ip, w := range d.perIP {
+		if now.Sub(w.lastActive()) > d.window {
+			delete(d.perIP, ip)
+		}
+	}
+}
+
+// DefaultDifficulty 默认的自适应难度控制器，10分钟窗口
+var DefaultDifficulty = NewAdaptiveDifficulty(10*time.Minute, 10)
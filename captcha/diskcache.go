@@ -0,0 +1,184 @@
+package captcha
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DiskCacheConfig 远程背景图本地磁盘缓存的配置
+// 下载的原始文件按URL的哈希保存在 Dir 中，重启后直接读取，对象存储暂时不可用时已缓存的背景图照常加载；
+// 缓存不会校验远程内容是否变化，更新图片时应使用新的文件名
+type DiskCacheConfig struct {
+	// Dir 缓存目录，不存在时自动创建（为空表示不启用）
+	Dir string
+	// MaxBytes 缓存文件的总大小上限，超过后删除最久未使用的文件，默认 DefaultDiskCacheMaxBytes
+	MaxBytes int64
+}
+
+// DefaultDiskCacheMaxBytes 默认的磁盘缓存大小上限
+const DefaultDiskCacheMaxBytes int64 = 512 << 20
+
+// diskCacheTempPrefix 写入中的临时文件前缀，淘汰时忽略
+const diskCacheTempPrefix = ".tmp-"
+
+// diskCache 远程背景图的磁盘缓存，文件的修改时间记录最近一次使用
+type diskCache struct {
+	dir      string
+	maxBytes int64
+
+	// mu 串行化写入和淘汰
+	mu sync.Mutex
+}
+
+// newDiskCache 创建磁盘缓存，cfg.Dir 为空时返回nil
+func newDiskCache(cfg DiskCacheConfig) *diskCache {
+	if cfg.Dir == "" {
+		return nil
+	}
+	if cfg.MaxBytes <= 0 {
+		cfg.MaxBytes = DefaultDiskCacheMaxBytes
+	}
+	return &diskCache{dir: cfg.Dir, maxBytes: cfg.MaxBytes}
+}
+
+// path 返回 url 对应的缓存文件路径
+func (c *diskCache) path(url string) string {
+	sum := sha256.Sum256([]byte(url))
+	return filepath.Join(c.dir, hex.EncodeToString(sum[:]))
+}
+
+// read 读取 url 的缓存文件并标记为最近使用，未缓存时返回 false
+func (c *diskCache) read(url string) ([]byte, bool) {
+	path := c.path(url)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+	now := time.Now()
+	_ = os.Chtimes(path, now, now)
+	return data, true
+}
+
+// write 保存 url 的原始文件（先写临时文件再重命名，中断时不会留下不完整的缓存），然后按大小上限淘汰
+func (c *diskCache) write(url string, data []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := os.MkdirAll(c.dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create cache dir: %w", err)
+	}
+	tmp, err := os.CreateTemp(c.dir, diskCacheTempPrefix+"*")
+	if err != nil {
+		return fmt.Errorf("failed to create cache file: %w", err)
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), c.path(url))
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write cache file: %w", err)
+	}
+	return c.evict()
+}
+
+// remove 删除 url 的缓存文件（如文件已损坏）
+func (c *diskCache) remove(url string) {
+	os.Remove(c.path(url))
+}
+
+// evict 缓存文件总大小超过上限时，按最近使用时间从旧到新删除文件
+func (c *diskCache) evict() error {
+	entries, err := os.ReadDir(c.dir)
+	if err != nil {
+		return fmt.Errorf("failed to list cache dir: %w", err)
+	}
+	type cached struct {
+		name    string
+		size    int64
+		modTime time.Time
+	}
+	var files []cached
+	var total int64
+	for _, entry := range entries {
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), diskCacheTempPrefix) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, cached{entry.Name(), info.Size(), info.ModTime()})
+		total += info.Size()
+	}
+	if total <= c.maxBytes {
+		return nil
+	}
+
+	slices.SortFunc(files, func(a, b cached) int { return a.modTime.Compare(b.modTime) })
+	for _, f := range files {
+		if total <= c.maxBytes {
+			break
+		}
+		if err := os.Remove(filepath.Join(c.dir, f.name)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to evict cache file: %w", err)
+		}
+		total -= f.size
+	}
+	return nil
+}
+
+// downloadCached 加载远程背景图：已缓存时直接读取磁盘，否则下载后写入缓存
+// 缓存文件无法解码或与 WithBackgroundHashes 固定的哈希不一致时删除并重新下载；写入缓存失败只记录日志，不影响加载
+func (s *CaptchaService) downloadCached(ctx context.Context, url string) (image.Image, error) {
+	if data, ok := s.diskCache.read(url); ok {
+		err := s.checkBackgroundHash(url, data)
+		var img image.Image
+		if err == nil {
+			img, err = decodeImage(bytes.NewReader(data), s.imagePolicy)
+		}
+		if err == nil {
+			s.log().Debug("background image loaded from disk cache", "url", url)
+			return img, nil
+		}
+		s.log().Warn("discarding invalid cached background image", "url", url, "error", err)
+		s.diskCache.remove(url)
+	}
+
+	data, err := s.downloader().fetch(ctx, url, s.imagePolicy.MaxBytes)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkBackgroundHash(url, data); err != nil {
+		return nil, err
+	}
+	img, err := decodeImage(bytes.NewReader(data), s.imagePolicy)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.diskCache.write(url, data); err != nil {
+		s.log().Warn("failed to cache background image", "url", url, "error", err)
+	}
+	return img, nil
+}
+
+// WithDiskCache 把下载的远程背景图缓存到本地目录，重启时不再重新下载，对象存储暂时不可用时已缓存的背景图照常加载；
+// 本地文件和 WithFS 中的背景图不缓存，默认不启用
+func WithDiskCache(cfg DiskCacheConfig) Option {
+	return func(s *CaptchaService) {
+		s.diskCache = newDiskCache(cfg)
+	}
+}
@@ -0,0 +1,233 @@
+package captcha
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// 下载网络图片的默认参数
+const (
+	// DefaultDownloadTimeout 单次请求的超时时间
+	DefaultDownloadTimeout = 10 * time.Second
+	// DefaultDownloadRetries 失败后的重试次数
+	DefaultDownloadRetries = 2
+	// DefaultDownloadBackoff 第一次重试前的等待时间，之后每次翻倍
+	DefaultDownloadBackoff = 200 * time.Millisecond
+)
+
+// DownloadConfig 下载网络背景图的配置，同一服务的所有下载共用一个 http.Client 和连接池
+type DownloadConfig struct {
+	// Timeout 单次请求的超时时间，默认 DefaultDownloadTimeout
+	Timeout time.Duration
+	// Retries 网络错误、429 和 5xx 时的重试次数，默认 DefaultDownloadRetries，小于0表示不重试
+	Retries int
+	// Backoff 第一次重试前的等待时间，之后每次翻倍，默认 DefaultDownloadBackoff
+	Backoff time.Duration
+	// Header 每个请求附加的请求头（如访问私有OSS所需的鉴权头）
+	Header http.Header
+}
+
+// downloader 下载网络图片，失败时按指数退避重试
+type downloader struct {
+	client  *http.Client
+	retries int
+	backoff time.Duration
+	header  http.Header
+}
+
+// defaultDownloader DownloadImage 和未配置 WithDownload 的服务使用的下载器
+var defaultDownloader = newDownloader(DownloadConfig{})
+
+// newDownloader 按配置创建下载器，未设置的字段使用默认值
+func newDownloader(cfg DownloadConfig) *downloader {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = DefaultDownloadTimeout
+	}
+	if cfg.Retries == 0 {
+		cfg.Retries = DefaultDownloadRetries
+	}
+	if cfg.Backoff <= 0 {
+		cfg.Backoff = DefaultDownloadBackoff
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// 背景图通常来自同一个OSS域名，保留更多空闲连接供并发下载复用
+	transport.MaxIdleConnsPerHost = 16
+	return &downloader{
+		client:  &http.Client{Timeout: cfg.Timeout, Transport: transport},
+		retries: max(cfg.Retries, 0),
+		backoff: cfg.Backoff,
+		header:  cfg.Header.Clone(),
+	}
+}
+
+// fetch 下载网络图片的原始文件，最多读取 maxBytes+1 字节（超出部分由 decodeImage 拒绝）
+// 可重试的错误按 backoff、2*backoff、4*backoff... 等待后重试，ctx 结束时立即返回
+func (d *downloader) fetch(ctx context.Context, url string, maxBytes int64) ([]byte, error) {
+	wait := d.backoff
+	for attempt := 0; ; attempt++ {
+		data, err := d.fetchOnce(ctx, url, maxBytes)
+		if err == nil || attempt >= d.retries || !retryable(err) || ctx.Err() != nil {
+			if err != nil && attempt > 0 {
+				err = fmt.Errorf("%w (after %d attempts)", err, attempt+1)
+			}
+			return data, err
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, fmt.Errorf("failed to download image: %w", ctx.Err())
+		}
+		wait *= 2
+	}
+}
+
+// fetchOnce 发送一次请求
+func (d *downloader) fetchOnce(ctx context.Context, url string, maxBytes int64) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download image: %w", err)
+	}
+	for key, values := range d.header {
+		req.Header[key] = values
+	}
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download image: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, &statusError{code: resp.StatusCode}
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to download image: %w", err)
+	}
+	return data, nil
+}
+
+// statusError 下载时服务端返回了非200状态码
+type statusError struct {
+	code int
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("unexpected status code: %d", e.code)
+}
+
+// retryable 判断下载错误是否值得重试：429、5xx 和网络错误可以重试，其他状态码（如 403、404）和 ctx 结束不重试
+func retryable(err error) bool {
+	var status *statusError
+	if errors.As(err, &status) {
+		return status.code == http.StatusTooManyRequests || status.code >= 500
+	}
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// downloader 返回服务使用的下载器
+func (s *CaptchaService) downloader() *downloader {
+	if s.download != nil {
+		return s.download
+	}
+	return defaultDownloader
+}
+
+// downloadImage 下载并解码网络背景图
+func (s *CaptchaService) downloadImage(ctx context.Context, url string) (image.Image, error) {
+	data, err := s.downloader().fetch(ctx, url, s.imagePolicy.MaxBytes)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkBackgroundHash(url, data); err != nil {
+		return nil, err
+	}
+	return decodeImage(bytes.NewReader(data), s.imagePolicy)
+}
+
+// WithBackgroundHashes 固定远程背景图的内容：hashes 的键为背景图URL，值为文件的 SHA-256（十六进制），
+// 下载后（以及从 WithDiskCache 的磁盘缓存读取时）校验，不一致时拒绝该背景图并返回 ErrBackgroundTampered，
+// 防止CDN或存储桶被入侵后悄悄换成带标记、泄露缺口位置的图片。未列出的URL和本地文件不校验，默认不校验
+func WithBackgroundHashes(hashes map[string]string) Option {
+	return func(s *CaptchaService) {
+		s.backgroundHashes = make(map[string]string, len(hashes))
+		for url, sum := range hashes {
+			s.backgroundHashes[url] = strings.ToLower(sum)
+		}
+	}
+}
+
+// LoadBackgroundHashes 读取 sha256sum 格式的哈希清单，每行为十六进制哈希和背景图URL，以空白分隔，
+// # 开头的行和空行忽略，可直接用于 WithBackgroundHashes：
+//
+//	9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08  https://cdn.example.com/bg1.jpg
+func LoadBackgroundHashes(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open background hashes: %w", err)
+	}
+	defer f.Close()
+
+	hashes := make(map[string]string)
+	scanner := bufio.NewScanner(f)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("invalid background hashes line %d: expected hash and url", lineNo)
+		}
+		// sha256sum 的二进制模式在文件名前加 *
+		sum, url := fields[0], strings.TrimPrefix(fields[1], "*")
+		if b, err := hex.DecodeString(sum); err != nil || len(b) != sha256.Size {
+			return nil, fmt.Errorf("invalid background hashes line %d: not a sha256 hex digest", lineNo)
+		}
+		hashes[url] = strings.ToLower(sum)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read background hashes: %w", err)
+	}
+	return hashes, nil
+}
+
+// checkBackgroundHash 校验远程背景图的内容是否与 WithBackgroundHashes 固定的哈希一致，未固定的URL直接通过
+func (s *CaptchaService) checkBackgroundHash(url string, data []byte) error {
+	want, pinned := s.backgroundHashes[url]
+	if !pinned {
+		return nil
+	}
+	sum := sha256.Sum256(data)
+	if got := hex.EncodeToString(sum[:]); got != want {
+		s.log().Error("background image hash mismatch", "url", url, "expected", want, "actual", got)
+		return fmt.Errorf("%w: sha256 %s", ErrBackgroundTampered, got)
+	}
+	return nil
+}
+
+// WithDownload 设置下载网络背景图的超时、重试和附加请求头，未配置时使用默认参数（10秒超时、重试2次）
+func WithDownload(cfg DownloadConfig) Option {
+	return func(s *CaptchaService) {
+		s.download = newDownloader(cfg)
+	}
+}
@@ -0,0 +1,157 @@
+package captcha
+
+import (
+	"context"
+	"math"
+	"strconv"
+	"sync"
+	"sync/atomic"
+)
+
+// EncodedCacheConfig 编码结果缓存的配置
+// 按（来源地址、输出尺寸、格式）缓存编码好的图片，无缺口背景图（CleanBackground）等需要反复输出同一张图片的功能共用这一个缓存，
+// 不再各自缓存；总大小超过上限时淘汰最久未使用的
+type EncodedCacheConfig struct {
+	// MaxBytes 编码结果的总大小上限，默认 DefaultEncodedCacheMaxBytes
+	MaxBytes int64
+}
+
+// DefaultEncodedCacheMaxBytes 默认的编码结果缓存大小上限
+const DefaultEncodedCacheMaxBytes int64 = 32 << 20
+
+// EncodedCacheStats 编码结果缓存统计
+type EncodedCacheStats struct {
+	Hits    uint64  `json:"hits"`    // 命中缓存的次数
+	Misses  uint64  `json:"misses"`  // 未命中、重新编码的次数
+	Entries int     `json:"entries"` // 当前缓存的图片数量
+	Bytes   int64   `json:"bytes"`   // 当前缓存的图片总大小
+	HitRate float64 `json:"hitRate"` // 命中次数占查询总数的比例
+}
+
+// encodedKey 编码结果的缓存键
+type encodedKey struct {
+	url           string // 来源背景图地址
+	width, height int    // 输出尺寸
+	format        string // 格式和编码参数，如 "jpeg/85"
+}
+
+// String 返回合并并发编码时使用的键
+func (k encodedKey) String() string {
+	return k.format + " " + strconv.Itoa(k.width) + "x" + strconv.Itoa(k.height) + " " + k.url
+}
+
+// encodedCache 按总大小淘汰的编码结果LRU缓存
+type encodedCache struct {
+	maxBytes int64
+
+	mu      sync.Mutex
+	entries *lruCache[encodedKey, []byte]
+	bytes   int64
+
+	hits   atomic.Uint64
+	misses atomic.Uint64
+}
+
+// newEncodedCache 创建编码结果缓存
+func newEncodedCache(cfg EncodedCacheConfig) *encodedCache {
+	if cfg.MaxBytes <= 0 {
+		cfg.MaxBytes = DefaultEncodedCacheMaxBytes
+	}
+	// 按总大小淘汰，不限制数量
+	return &encodedCache{maxBytes: cfg.MaxBytes, entries: newLRUCache[encodedKey, []byte](math.MaxInt)}
+}
+
+// get 查询编码结果，未启用或未命中时返回 false
+func (c *encodedCache) get(key encodedKey) ([]byte, bool) {
+	if c == nil {
+		return nil, false
+	}
+	c.mu.Lock()
+	data, ok := c.entries.get(key)
+	c.mu.Unlock()
+	if !ok {
+		c.misses.Add(1)
+		return nil, false
+	}
+	c.hits.Add(1)
+	return data, true
+}
+
+// put 缓存编码结果，超过总大小上限时淘汰最久未使用的；单个结果超过上限时不缓存
+func (c *encodedCache) put(key encodedKey, data []byte) {
+	if c == nil || int64(len(data)) > c.maxBytes {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if old, ok := c.entries.get(key); ok {
+		c.bytes -= int64(len(old))
+	}
+	c.entries.put(key, data)
+	c.bytes += int64(len(data))
+	for c.bytes > c.maxBytes {
+		oldest, old, _ := c.entries.oldest()
+		c.entries.remove(oldest)
+		c.bytes -= int64(len(old))
+	}
+}
+
+// reset 丢弃全部缓存（背景图更换后调用）
+func (c *encodedCache) reset() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	c.entries.clear()
+	c.bytes = 0
+	c.mu.Unlock()
+}
+
+// stats 返回缓存统计
+func (c *encodedCache) stats() EncodedCacheStats {
+	if c == nil {
+		return EncodedCacheStats{}
+	}
+	c.mu.Lock()
+	entries, size := c.entries.len(), c.bytes
+	c.mu.Unlock()
+
+	stats := EncodedCacheStats{Hits: c.hits.Load(), Misses: c.misses.Load(), Entries: entries, Bytes: size}
+	if total := stats.Hits + stats.Misses; total > 0 {
+		stats.HitRate = float64(stats.Hits) / float64(total)
+	}
+	return stats
+}
+
+// encoded 返回 key 对应的编码结果，未缓存时调用 encode 编码并写入缓存，同一键的并发调用只编码一次；
+// 未启用缓存时每次都调用 encode
+func (s *CaptchaService) encoded(key encodedKey, encode func() ([]byte, error)) ([]byte, error) {
+	if s.encodedCache == nil {
+		return encode()
+	}
+	if data, ok := s.encodedCache.get(key); ok {
+		s.metrics.Count(MetricEncodedCache, 1, Label{"format", key.format}, Label{"result", "hit"})
+		return data, nil
+	}
+	s.metrics.Count(MetricEncodedCache, 1, Label{"format", key.format}, Label{"result", "miss"})
+	return s.encodes.do(context.Background(), key.String(), func() ([]byte, error) {
+		data, err := encode()
+		if err != nil {
+			return nil, err
+		}
+		s.encodedCache.put(key, data)
+		return data, nil
+	})
+}
+
+// WithEncodedCache 设置编码结果缓存的大小上限；启用 WithCleanJPEG 等需要缓存编码结果的功能时，未设置则使用默认上限
+func WithEncodedCache(cfg EncodedCacheConfig) Option {
+	return func(s *CaptchaService) {
+		s.encodedCache = newEncodedCache(cfg)
+	}
+}
+
+// EncodedCacheStats 返回编码结果缓存统计，未启用时全部为0
+func (s *CaptchaService) EncodedCacheStats() EncodedCacheStats {
+	return s.encodedCache.stats()
+}
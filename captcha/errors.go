@@ -0,0 +1,24 @@
+package captcha
+
+import "errors"
+
+// 生成和验证返回的错误，调用方可以通过 errors.Is 判断
+var (
+	ErrNotFound            = errors.New("captcha not found")                                  // 验证码不存在
+	ErrExpired             = errors.New("captcha expired")                                    // 验证码已过期
+	ErrAlreadyUsed         = errors.New("captcha already used")                               // 验证码已验证通过或已作废
+	ErrTooManyAttempts     = errors.New("too many verify attempts")                           // 尝试次数过多
+	ErrFingerprintMismatch = errors.New("captcha fingerprint mismatch")                       // 客户端指纹不一致
+	ErrIPMismatch          = errors.New("captcha client ip mismatch")                         // 客户端IP不一致
+	ErrSessionMismatch     = errors.New("captcha session mismatch")                           // 浏览器会话不一致
+	ErrUnavailable         = errors.New("captcha store unavailable")                          // 存储不可用或请求已超时、取消
+	ErrNotInitialized      = errors.New("captcha service not created with NewCaptchaService") // 服务未通过 NewCaptchaService 创建
+	ErrNoBackgrounds       = errors.New("no background images configured")                    // 没有可用的背景图
+	ErrInvalidOptions      = errors.New("invalid generate options")                           // GenerateOptions 指定的背景图、形状或位置无效
+	ErrAnswerForbidden     = errors.New("captcha answer disclosure forbidden")                // 未配置或未提供正确的答案披露密钥
+	ErrInvalidImage        = errors.New("invalid background image")                           // 背景图格式不支持、尺寸超限或无法解码
+	ErrBackgroundTampered  = errors.New("background hash mismatch")                           // 远程背景图内容与 WithBackgroundHashes 固定的哈希不一致
+	ErrUnsupportedFormat   = errors.New("captcha data format not supported")                  // 验证码由更新版本的服务生成，当前版本无法解析
+	ErrStaleChallenge      = errors.New("captcha issued too early")                           // 验证码在受保护操作开始前超过 WithMaxChallengeAge 就已生成
+	ErrBatchTooLarge       = errors.New("verify batch too large")                             // 批量验证的数量超过 MaxVerifyBatch
+)
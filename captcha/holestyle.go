@@ -0,0 +1,45 @@
+package captcha
+
+import "github.com/gpencil/photo_captcha/captcha/render"
+
+// HoleVariation 每个验证码随机选择缺口外观的范围，范围应保证人眼仍能轻松辨认缺口
+type HoleVariation struct {
+	// MinTint、MaxTint 白色遮罩强度的范围（0-1），见 render.HoleStyle.Tint
+	MinTint, MaxTint float64
+	// MinBlur、MaxBlur 缺口模糊半径的范围（像素），是否使用盒式模糊与服务的缺口模糊相同（见 WithFastBlur）
+	MinBlur, MaxBlur int
+	// BorderChance 缺口描边的概率（0-1）
+	BorderChance float64
+}
+
+// DefaultHoleVariation 默认的缺口外观随机范围，遮罩强度在默认值 render.DefaultHoleTint 上下浮动
+var DefaultHoleVariation = HoleVariation{
+	MinTint:      0.3,
+	MaxTint:      0.5,
+	MinBlur:      1,
+	MaxBlur:      3,
+	BorderChance: 0.5,
+}
+
+// WithHoleVariation 每个验证码在 v 的范围内随机选择缺口的遮罩强度、是否描边和模糊半径，干扰缺口与真实缺口外观相同，
+// 机器无法依赖固定的像素特征（如固定比例的白色遮罩）定位缺口；启用渲染缓存时命中缓存的验证码沿用缓存中的外观。默认不随机
+func WithHoleVariation(v HoleVariation) Option {
+	return func(s *CaptchaService) {
+		s.holeVariation = &v
+	}
+}
+
+// randomHoleStyle 在配置的范围内随机选择缺口外观
+func (s *CaptchaService) randomHoleStyle() render.HoleStyle {
+	v := s.holeVariation
+	const steps = 1000
+	style := render.HoleStyle{
+		Tint:   v.MinTint + (v.MaxTint-v.MinTint)*float64(s.intn(steps+1))/steps,
+		Border: float64(s.intn(steps)) < v.BorderChance*steps,
+		Blur:   render.Gaussian{Radius: v.MinBlur, Box: s.holeBlur.Box},
+	}
+	if v.MaxBlur > v.MinBlur {
+		style.Blur.Radius += s.intn(v.MaxBlur - v.MinBlur + 1)
+	}
+	return style
+}
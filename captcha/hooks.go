@@ -0,0 +1,80 @@
+package captcha
+
+import (
+	"context"
+	"sync"
+)
+
+// GenerateHook 验证码生成后调用，params 为生成参数
+type GenerateHook func(ctx context.Context, captcha *SliderCaptcha, params GenerateParams)
+
+// VerifyHook 每次验证后调用，result 总是非nil，err 与 Verify 的返回值一致
+type VerifyHook func(ctx context.Context, params VerifyParams, result *VerifyResult, err error)
+
+// ExpireHook 验证码过期后调用：验证时发现已过期，或服务创建的内存存储清理时删除了未使用的验证码
+// 清理时 ctx 为 context.Background()
+type ExpireHook func(ctx context.Context, id string)
+
+// hooks 生命周期回调
+type hooks struct {
+	mu       sync.RWMutex
+	generate []GenerateHook
+	verify   []VerifyHook
+	expire   []ExpireHook
+}
+
+// OnGenerate 注册验证码生成后的回调，可用于统计、自定义日志或风控信号
+// 回调在生成的协程中同步执行，耗时操作应自行异步处理；可在服务运行中注册
+func (s *CaptchaService) OnGenerate(fn GenerateHook) {
+	s.hooks.mu.Lock()
+	defer s.hooks.mu.Unlock()
+	s.hooks.generate = append(s.hooks.generate, fn)
+}
+
+// OnVerify 注册每次验证后的回调，包括验证码不存在、过期等失败的验证
+// 回调在验证的协程中同步执行，耗时操作应自行异步处理；可在服务运行中注册
+func (s *CaptchaService) OnVerify(fn VerifyHook) {
+	s.hooks.mu.Lock()
+	defer s.hooks.mu.Unlock()
+	s.hooks.verify = append(s.hooks.verify, fn)
+}
+
+// OnExpire 注册验证码过期的回调，使用 WithStore 传入的存储时只在验证时发现过期才会调用
+// 回调同步执行，耗时操作应自行异步处理；可在服务运行中注册
+func (s *CaptchaService) OnExpire(fn ExpireHook) {
+	s.hooks.mu.Lock()
+	defer s.hooks.mu.Unlock()
+	s.hooks.expire = append(s.hooks.expire, fn)
+}
+
+// fireGenerate 调用生成回调
+func (s *CaptchaService) fireGenerate(ctx context.Context, captcha *SliderCaptcha, params GenerateParams) {
+	s.hooks.mu.RLock()
+	fns := s.hooks.generate
+	s.hooks.mu.RUnlock()
+	for _, fn := range fns {
+		fn(ctx, captcha, params)
+	}
+}
+
+// fireVerify 调用验证回调
+func (s *CaptchaService) fireVerify(ctx context.Context, params VerifyParams, result *VerifyResult, err error) {
+	s.hooks.mu.RLock()
+	fns := s.hooks.verify
+	s.hooks.mu.RUnlock()
+	for _, fn := range fns {
+		fn(ctx, params, result, err)
+	}
+}
+
+// fireExpire 上报过期指标并调用过期回调
+func (s *CaptchaService) fireExpire(ctx context.Context, id string) {
+	s.metrics.Count(MetricExpired, 1)
+
+	s.hooks.mu.RLock()
+	fns := s.hooks.expire
+	s.hooks.mu.RUnlock()
+	for _, fn := range fns {
+		fn(ctx, id)
+	}
+}
@@ -0,0 +1,124 @@
+package captcha
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// idSignatureBytes 验证码ID中签名的长度（截取 HMAC-SHA256 的前16字节）
+const idSignatureBytes = 16
+
+// idClockSkew 判断签名ID是否过期时额外允许的误差：签发时间只精确到秒，且早于写入存储的时间
+const idClockSkew = time.Second
+
+// WithIDSecret 使用密钥签名验证码ID：ID 格式为 <uuid>.<签发时间>.<HMAC>，验证、状态查询、作废和答案披露
+// 先校验签名和签发时间，伪造、篡改或已过期的ID不访问存储直接返回不存在或已过期，减轻垃圾请求对存储的压力；
+// 多实例部署时所有实例必须使用相同的密钥，启用或更换密钥前签发的验证码会被视为不存在，需要不中断地更换密钥时使用 WithIDKeys。默认不签名
+func WithIDSecret(secret string) Option {
+	if secret == "" {
+		return WithIDKeys()
+	}
+	return WithIDKeys(SigningKey{Secret: secret})
+}
+
+// WithIDKeys 使用多把带ID的密钥签名验证码ID，ID 格式为 <uuid>.<签发时间>.<kid>.<HMAC>：第一把密钥用于签发，
+// 所有密钥都可用于校验。轮换时把新密钥放在最前，旧密钥保留到它签发的验证码全部过期（验证码有效期）后再移除，
+// 进行中的验证码不受影响。多实例部署时应先让所有实例都能校验新密钥，再切换签发密钥。不传密钥时不签名
+func WithIDKeys(keys ...SigningKey) Option {
+	return func(s *CaptchaService) {
+		s.idKeys.keys = nil
+		for _, key := range keys {
+			if key.Secret != "" {
+				s.idKeys.keys = append(s.idKeys.keys, key)
+			}
+		}
+	}
+}
+
+// WithIDKeyRotation 按 interval 周期自动轮换签名验证码ID的密钥：每个周期使用从配置的密钥派生的新密钥，kid 中带有周期序号，
+// 校验时使用ID签发时间所在周期的密钥，跨周期的验证码照常有效；某个周期的派生密钥泄露后也只能伪造该周期内签发、很快过期的ID。
+// 需要同时配置 WithIDSecret 或 WithIDKeys，所有实例的周期必须相同。默认不轮换
+func WithIDKeyRotation(interval time.Duration) Option {
+	return func(s *CaptchaService) {
+		if interval > 0 && interval < time.Second {
+			interval = time.Second
+		}
+		s.idKeys.interval = interval
+	}
+}
+
+// newCaptchaID 生成验证码ID，配置了签名密钥时附带签发时间、kid 和签名
+func (s *CaptchaService) newCaptchaID() string {
+	id := uuid.New().String()
+	if len(s.idKeys.keys) == 0 {
+		return id
+	}
+	issued := time.Unix(s.clock.Now().Unix(), 0)
+	kid, secret := s.idKeys.signingKey(issued)
+	payload := id + "." + strconv.FormatInt(issued.Unix(), 36)
+	if kid != "" {
+		payload += "." + kid
+	}
+	return payload + "." + signID(secret, payload)
+}
+
+// signID 计算ID的签名（URL安全的base64）
+func signID(secret []byte, payload string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil)[:idSignatureBytes])
+}
+
+// checkID 不访问存储校验验证码ID：签名不正确或 kid 未知时返回 ErrNotFound，签发时间超过有效期 ttl 时返回 ErrExpired，
+// 未配置签名密钥时总是返回nil
+func (s *CaptchaService) checkID(id string, ttl time.Duration) error {
+	if len(s.idKeys.keys) == 0 {
+		return nil
+	}
+	reason := "forged"
+	err := ErrNotFound
+	if payload, sig, ok := cutLast(id, "."); ok {
+		// payload 为 <uuid>.<签发时间>[.<kid>]
+		_, rest, _ := strings.Cut(payload, ".")
+		issuedAt, kid, _ := strings.Cut(rest, ".")
+		if sec, parseErr := strconv.ParseInt(issuedAt, 36, 64); parseErr == nil {
+			issued := time.Unix(sec, 0)
+			if secret, found := s.idKeys.lookup(kid, issued); found && hmac.Equal([]byte(sig), []byte(signID(secret, payload))) {
+				if s.clock.Now().Sub(issued) <= ttl+idClockSkew {
+					return nil
+				}
+				reason, err = "expired", ErrExpired
+			}
+		}
+	}
+	s.metrics.Count(MetricIDRejected, 1, Label{"reason", reason})
+	return err
+}
+
+// idIssuedAt 返回签名ID中的签发时间，未配置签名密钥或ID不带签发时间时返回 false；调用方需先用 checkID 校验签名
+func (s *CaptchaService) idIssuedAt(id string) (time.Time, bool) {
+	if len(s.idKeys.keys) == 0 {
+		return time.Time{}, false
+	}
+	_, rest, _ := strings.Cut(id, ".")
+	issuedAt, _, _ := strings.Cut(rest, ".")
+	sec, err := strconv.ParseInt(issuedAt, 36, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(sec, 0), true
+}
+
+// cutLast 在最后一个 sep 处把 s 分成两段
+func cutLast(s, sep string) (before, after string, found bool) {
+	if i := strings.LastIndex(s, sep); i >= 0 {
+		return s[:i], s[i+len(sep):], true
+	}
+	return s, "", false
+}
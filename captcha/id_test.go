@@ -0,0 +1,82 @@
+package captcha
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCheckID(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	clock := ClockFunc(func() time.Time { return now })
+	keys := []SigningKey{{ID: "k1", Secret: "secret-1"}}
+	const ttl = time.Minute
+
+	tests := []struct {
+		name      string
+		issuer    []Option
+		validator []Option
+		mutate    func(id string) string
+		advance   time.Duration
+		wantErr   error
+	}{
+		{name: "unsigned", issuer: []Option{}, validator: []Option{}},
+		{name: "secret", issuer: []Option{WithIDSecret("secret")}},
+		{name: "keys", issuer: []Option{WithIDKeys(keys...)}},
+		{name: "rotated key still verifies",
+			issuer:    []Option{WithIDKeys(keys...)},
+			validator: []Option{WithIDKeys(SigningKey{ID: "k2", Secret: "secret-2"}, keys[0])}},
+		{name: "removed key",
+			issuer:    []Option{WithIDKeys(keys...)},
+			validator: []Option{WithIDKeys(SigningKey{ID: "k2", Secret: "secret-2"})},
+			wantErr:   ErrNotFound},
+		{name: "unsigned id with keys", issuer: []Option{}, validator: []Option{WithIDKeys(keys...)}, wantErr: ErrNotFound},
+		{name: "tampered uuid", issuer: []Option{WithIDKeys(keys...)},
+			mutate: func(id string) string { return "00000000" + id[8:] }, wantErr: ErrNotFound},
+		{name: "tampered issued time", issuer: []Option{WithIDKeys(keys...)},
+			mutate: func(id string) string {
+				parts := strings.Split(id, ".")
+				parts[1] = "zzzzzz"
+				return strings.Join(parts, ".")
+			}, wantErr: ErrNotFound},
+		{name: "missing signature", issuer: []Option{WithIDKeys(keys...)},
+			mutate: func(id string) string { id, _, _ = cutLast(id, "."); return id }, wantErr: ErrNotFound},
+		{name: "expired", issuer: []Option{WithIDKeys(keys...)}, advance: ttl + idClockSkew + time.Second, wantErr: ErrExpired},
+		{name: "within clock skew", issuer: []Option{WithIDKeys(keys...)}, advance: ttl + idClockSkew},
+		{name: "periodic rotation across periods",
+			issuer:  []Option{WithIDKeys(keys...), WithIDKeyRotation(10 * time.Second)},
+			advance: 30 * time.Second},
+		{name: "periodic rotation other period kid",
+			issuer: []Option{WithIDKeys(keys...), WithIDKeyRotation(10 * time.Second)},
+			mutate: func(id string) string {
+				parts := strings.Split(id, ".")
+				parts[2] = "k1-0"
+				return strings.Join(parts, ".")
+			}, wantErr: ErrNotFound},
+		{name: "rotation disabled on validator",
+			issuer:    []Option{WithIDKeys(keys...), WithIDKeyRotation(10 * time.Second)},
+			validator: []Option{WithIDKeys(keys...)},
+			wantErr:   ErrNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now = time.Unix(1700000000, 0)
+			issuer := NewCaptchaService(append([]Option{WithClock(clock)}, tt.issuer...)...)
+			validator := issuer
+			if tt.validator != nil {
+				validator = NewCaptchaService(append([]Option{WithClock(clock)}, tt.validator...)...)
+			}
+
+			id := issuer.newCaptchaID()
+			if tt.mutate != nil {
+				id = tt.mutate(id)
+			}
+			now = now.Add(tt.advance)
+
+			if err := validator.checkID(id, ttl); !errors.Is(err, tt.wantErr) {
+				t.Errorf("checkID(%q) = %v, want %v", id, err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -1,21 +1,30 @@
 package captcha
 
 import (
+	"bytes"
+	"context"
 	"encoding/base64"
 	"fmt"
 	"image"
-	"image/color"
+	"image/draw"
+	_ "image/gif"
 	"image/jpeg"
 	_ "image/jpeg"
 	"image/png"
 	_ "image/png"
-	"net/http"
+	"io"
+	"io/fs"
 	"os"
+	"slices"
 	"strings"
-	"time"
+	"sync"
+
+	"github.com/gpencil/photo_captcha/captcha/render"
 )
 
-// BackgroundURLs 背景图列表（支持本地文件路径）
+// BackgroundURLs 未通过 WithBackgrounds 或 SetBackgrounds 配置背景图的服务使用的背景图列表（支持本地文件路径）
+//
+// Deprecated: 使用 NewCaptchaService(WithBackgrounds(...))
 var BackgroundURLs = []string{
 	//"images/image1.jpg",
 	//"images/image2.jpg",
@@ -31,112 +40,372 @@ var BackgroundURLs = []string{
 	"https://lunalab-res.oss-cn-hangzhou.aliyuncs.com/ttsVoice/captcha/image10.jpg",
 }
 
+// backgroundMu 保护读取 BackgroundURLs
+var backgroundMu sync.RWMutex
+
+// defaultBackgrounds 返回 BackgroundURLs 的副本
+func defaultBackgrounds() []string {
+	backgroundMu.RLock()
+	defer backgroundMu.RUnlock()
+	return append([]string(nil), BackgroundURLs...)
+}
+
+// Backgrounds 返回默认服务当前背景图列表的副本
+//
+// Deprecated: 使用 CaptchaService.Backgrounds
+func Backgrounds() []string {
+	return defaultService.Backgrounds()
+}
+
+// SetBackgrounds 替换默认服务的背景图列表，可在服务运行中调用
+//
+// Deprecated: 使用 CaptchaService.SetBackgrounds
+func SetBackgrounds(urls []string) {
+	if err := defaultService.SetBackgrounds(urls); err != nil {
+		Logger().Error("failed to set backgrounds", "error", err)
+	}
+}
+
 // DownloadImage 下载或加载图片（支持本地文件和网络URL）
 func DownloadImage(pathOrURL string) (image.Image, error) {
+	return DownloadImageContext(context.Background(), pathOrURL)
+}
+
+// DownloadImageContext 下载或加载图片，ctx 结束时中止下载，按 DefaultImagePolicy 检查
+func DownloadImageContext(ctx context.Context, pathOrURL string) (image.Image, error) {
+	return loadImage(ctx, defaultDownloader, DefaultImagePolicy(), pathOrURL)
+}
+
+// loadImage 用下载器 d 下载网络图片或读取本地文件，按 policy 检查后解码
+func loadImage(ctx context.Context, d *downloader, policy ImagePolicy, pathOrURL string) (image.Image, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
 	// 判断是本地文件还是网络URL
-	if strings.HasPrefix(pathOrURL, "http://") || strings.HasPrefix(pathOrURL, "https://") {
+	if isURL(pathOrURL) {
 		// 网络图片
-		client := &http.Client{
-			Timeout: 10 * time.Second,
-		}
-
-		resp, err := client.Get(pathOrURL)
+		data, err := d.fetch(ctx, pathOrURL, policy.MaxBytes)
 		if err != nil {
-			return nil, fmt.Errorf("failed to download image: %w", err)
+			return nil, err
 		}
-		defer resp.Body.Close()
+		return decodeImage(bytes.NewReader(data), policy)
+	}
 
-		if resp.StatusCode != http.StatusOK {
-			return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
-		}
+	// 本地文件
+	file, err := os.Open(pathOrURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open image file: %w", err)
+	}
+	defer file.Close()
 
-		img, _, err := image.Decode(resp.Body)
-		if err != nil {
-			return nil, fmt.Errorf("failed to decode image: %w", err)
-		}
+	return decodeImage(file, policy)
+}
+
+// LoadImageFS 从 fsys 中加载图片（如 embed.FS、zip 包），与本地文件一样按 DefaultImagePolicy 检查
+func LoadImageFS(fsys fs.FS, name string) (image.Image, error) {
+	return loadImageFS(fsys, name, DefaultImagePolicy())
+}
 
-		return img, nil
+// loadImageFS 从 fsys 中加载图片，按 policy 检查后解码
+func loadImageFS(fsys fs.FS, name string, policy ImagePolicy) (image.Image, error) {
+	file, err := fsys.Open(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open image file: %w", err)
+	}
+	defer file.Close()
+
+	return decodeImage(file, policy)
+}
+
+// isURL 是否为网络图片地址
+func isURL(pathOrURL string) bool {
+	return strings.HasPrefix(pathOrURL, "http://") || strings.HasPrefix(pathOrURL, "https://")
+}
+
+// 图片解码策略的默认值
+const (
+	// DefaultMaxImageBytes 图片文件的最大字节数
+	DefaultMaxImageBytes int64 = 32 << 20
+	// DefaultMaxImagePixels 图片的最大像素数（宽x高）
+	DefaultMaxImagePixels = 40_000_000
+	// DefaultMaxImageDimension 图片的最大宽度和高度（像素）
+	DefaultMaxImageDimension = 16384
+)
+
+// ImagePolicy 图片解码策略，对服务加载的所有图片（背景图和预制的拼图mask，无论来自网络、本地文件还是 WithFS）一致生效，
+// 防止异常的图片（如解压炸弹）耗尽内存；为零的字段使用默认值
+type ImagePolicy struct {
+	// MaxBytes 图片文件的最大字节数，默认 DefaultMaxImageBytes
+	MaxBytes int64
+	// MaxPixels 图片的最大像素数（宽x高），解码前根据图片头部检查，默认 DefaultMaxImagePixels
+	MaxPixels int
+	// MaxDimension 图片的最大宽度和高度（像素），解码前根据图片头部检查，拒绝像素数不大但极端细长的图片，默认 DefaultMaxImageDimension
+	MaxDimension int
+	// Formats 允许的图片格式（image.Decode 返回的格式名称），默认 jpeg 和 png。本包注册了 jpeg、png、gif 的解码器，
+	// 其他格式（如 webp）需要在程序中注册解码器（import _ "golang.org/x/image/webp"）后再加入列表
+	Formats []string
+}
+
+// DefaultImagePolicy 返回默认的图片解码策略
+func DefaultImagePolicy() ImagePolicy {
+	return ImagePolicy{
+		MaxBytes:     DefaultMaxImageBytes,
+		MaxPixels:    DefaultMaxImagePixels,
+		MaxDimension: DefaultMaxImageDimension,
+		Formats:      []string{"jpeg", "png"},
+	}
+}
+
+// withDefaults 返回为零的字段替换为默认值后的策略，Formats 复制一份，调用方之后修改原切片不影响服务
+func (p ImagePolicy) withDefaults() ImagePolicy {
+	def := DefaultImagePolicy()
+	if p.MaxBytes <= 0 {
+		p.MaxBytes = def.MaxBytes
+	}
+	if p.MaxPixels <= 0 {
+		p.MaxPixels = def.MaxPixels
+	}
+	if p.MaxDimension <= 0 {
+		p.MaxDimension = def.MaxDimension
+	}
+	if len(p.Formats) == 0 {
+		p.Formats = def.Formats
 	} else {
-		// 本地文件
-		file, err := os.Open(pathOrURL)
-		if err != nil {
-			return nil, fmt.Errorf("failed to open image file: %w", err)
+		p.Formats = slices.Clone(p.Formats)
+	}
+	return p
+}
+
+// WithImagePolicy 设置服务加载背景图和拼图mask的解码策略，默认 DefaultImagePolicy
+//
+//	svc := captcha.NewCaptchaService(captcha.WithImagePolicy(captcha.ImagePolicy{MaxBytes: 8 << 20, Formats: []string{"jpeg"}}))
+func WithImagePolicy(policy ImagePolicy) Option {
+	return func(s *CaptchaService) {
+		s.imagePolicy = policy.withDefaults()
+	}
+}
+
+// Decode 按策略读取并解码图片，超过限制或格式不在 Formats 中时返回 ErrInvalidImage（实现 render.Decoder）
+func (p ImagePolicy) Decode(r io.Reader) (image.Image, error) {
+	return decodeLimited(r, p)
+}
+
+// 编译期检查接口实现
+var _ render.Decoder = ImagePolicy{}
+
+// decodeImage 读取并解码背景图：按 policy 检查后解码，
+// 解码后把少见的颜色模型和非零原点的图片转换为 RGBA，失败时返回 ErrInvalidImage
+func decodeImage(r io.Reader, policy ImagePolicy) (image.Image, error) {
+	img, err := decodeLimited(r, policy)
+	if err != nil {
+		return nil, err
+	}
+	return normalizeImage(img), nil
+}
+
+// decodeLimited 读取并解码图片：按 policy 限制文件大小，解码前检查格式、像素数和边长，失败时返回 ErrInvalidImage
+func decodeLimited(r io.Reader, policy ImagePolicy) (img image.Image, err error) {
+	policy = policy.withDefaults()
+	data, err := io.ReadAll(io.LimitReader(r, policy.MaxBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read image: %w", err)
+	}
+	if int64(len(data)) > policy.MaxBytes {
+		return nil, fmt.Errorf("%w: larger than %d bytes", ErrInvalidImage, policy.MaxBytes)
+	}
+
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidImage, err)
+	}
+	if !slices.Contains(policy.Formats, format) {
+		return nil, fmt.Errorf("%w: format %q not allowed", ErrInvalidImage, format)
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 || cfg.Width > policy.MaxPixels/cfg.Height {
+		return nil, fmt.Errorf("%w: size %dx%d exceeds %d pixels", ErrInvalidImage, cfg.Width, cfg.Height, policy.MaxPixels)
+	}
+	if cfg.Width > policy.MaxDimension || cfg.Height > policy.MaxDimension {
+		return nil, fmt.Errorf("%w: size %dx%d exceeds %d pixels per side", ErrInvalidImage, cfg.Width, cfg.Height, policy.MaxDimension)
+	}
+
+	// 解码器遇到构造异常的数据时不应让整个服务崩溃
+	defer func() {
+		if r := recover(); r != nil {
+			img, err = nil, fmt.Errorf("%w: decoder panic: %v", ErrInvalidImage, r)
 		}
-		defer file.Close()
+	}()
+	img, _, err = image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidImage, err)
+	}
+	return img, nil
+}
 
-		img, _, err := image.Decode(file)
-		if err != nil {
-			return nil, fmt.Errorf("failed to decode image: %w", err)
+// normalizeImage 把 CMYK、16位、调色板等颜色模型或原点不为 (0, 0) 的图片转换为 RGBA，
+// 常见的 RGBA、NRGBA、YCbCr 和灰度图保持不变
+func normalizeImage(img image.Image) image.Image {
+	bounds := img.Bounds()
+	if bounds.Min == (image.Point{}) {
+		switch img.(type) {
+		case *image.RGBA, *image.NRGBA, *image.YCbCr, *image.Gray:
+			return img
 		}
+	}
+	rgba := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(rgba, rgba.Bounds(), img, bounds.Min, draw.Src)
+	return rgba
+}
+
+// DefaultBackgroundScale 预加载背景图默认最大为画布的倍数
+const DefaultBackgroundScale = 4
 
-		return img, nil
+// fitImage 图片超过 maxWidth x maxHeight 时按比例缩小到刚好放入该范围，否则原样返回
+func fitImage(img image.Image, maxWidth, maxHeight int) image.Image {
+	bounds := img.Bounds()
+	if maxWidth <= 0 || maxHeight <= 0 || (bounds.Dx() <= maxWidth && bounds.Dy() <= maxHeight) {
+		return img
 	}
+	ratio := min(float64(maxWidth)/float64(bounds.Dx()), float64(maxHeight)/float64(bounds.Dy()))
+	width := max(int(float64(bounds.Dx())*ratio), 1)
+	height := max(int(float64(bounds.Dy())*ratio), 1)
+	return render.Resize(img, width, height)
 }
 
-// ImageToBase64 将图片转换为base64字符串
-func ImageToBase64(img image.Image, format string) (string, error) {
-	var buf []byte
-	var err error
-
-	switch format {
-	case "png":
-		buf, err = encodePNG(img)
-	case "jpeg", "jpg":
-		buf, err = encodeJPEG(img)
+// imageBytes 图片像素数据占用的内存（字节）
+func imageBytes(img image.Image) int64 {
+	switch img := img.(type) {
+	case nil:
+		return 0
+	case *image.RGBA:
+		return int64(len(img.Pix))
+	case *image.NRGBA:
+		return int64(len(img.Pix))
+	case *image.Gray:
+		return int64(len(img.Pix))
+	case *image.YCbCr:
+		return int64(len(img.Y) + len(img.Cb) + len(img.Cr))
 	default:
-		buf, err = encodePNG(img)
-		format = "png"
+		return int64(img.Bounds().Dx()) * int64(img.Bounds().Dy()) * 4
 	}
+}
 
-	if err != nil {
-		return "", err
+// imagesBytes 一组图片占用的内存（字节）
+func imagesBytes(images []image.Image) int64 {
+	var total int64
+	for _, img := range images {
+		total += imageBytes(img)
 	}
+	return total
+}
+
+// ImageToBase64 将图片转换为base64字符串（data URI）
+// 图片编码后直接经 base64 编码器写入按上次同尺寸结果预分配的缓冲区，不产生中间的字节切片和字符串副本
+func ImageToBase64(img image.Image, format string) (string, error) {
+	return imageToBase64(img, format, png.DefaultCompression)
+}
 
-	base64Str := base64.StdEncoding.EncodeToString(buf)
+// imageToBase64 将图片转换为base64字符串，PNG按 level 压缩
+func imageToBase64(img image.Image, format string, level png.CompressionLevel) (string, error) {
+	encode := func(w io.Writer, img image.Image) error { return encodePNG(w, img, level) }
 	mimeType := "image/png"
 	if format == "jpeg" || format == "jpg" {
+		encode = encodeJPEG
 		mimeType = "image/jpeg"
 	}
 
-	return fmt.Sprintf("data:%s;base64,%s", mimeType, base64Str), nil
+	size := encodedSizeKey{mimeType: mimeType, bounds: img.Bounds().Size()}
+	var sb strings.Builder
+	sb.Grow(encodedSizes.get(size))
+	sb.WriteString("data:" + mimeType + ";base64,")
+
+	w := base64.NewEncoder(base64.StdEncoding, &sb)
+	if err := encode(w, img); err != nil {
+		return "", err
+	}
+	if err := w.Close(); err != nil {
+		return "", err
+	}
+
+	encodedSizes.put(size, sb.Len())
+	return sb.String(), nil
+}
+
+// encodePNG 按 level 编码为PNG格式，编码器的缓冲区在各次编码之间复用
+func encodePNG(w io.Writer, img image.Image, level png.CompressionLevel) error {
+	encoder := png.Encoder{CompressionLevel: level, BufferPool: pngBuffers}
+	return encoder.Encode(w, img)
+}
+
+// pngBufferPool 复用PNG编码器的缓冲区（压缩器和行缓冲），避免每次编码重新分配
+type pngBufferPool struct {
+	pool sync.Pool
 }
 
-// encodePNG 编码为PNG格式
-func encodePNG(img image.Image) ([]byte, error) {
-	buf := make([]byte, 0)
-	w := &writerBuffer{buf: buf}
+// pngBuffers 所有PNG编码共用的缓冲池
+var pngBuffers = &pngBufferPool{}
 
-	encoder := png.Encoder{CompressionLevel: png.DefaultCompression}
-	err := encoder.Encode(w, img)
+// Get 取出缓冲区，池为空时返回nil（编码器会新建）
+func (p *pngBufferPool) Get() *png.EncoderBuffer {
+	b, _ := p.pool.Get().(*png.EncoderBuffer)
+	return b
+}
 
-	return w.buf, err
+// Put 归还缓冲区
+func (p *pngBufferPool) Put(b *png.EncoderBuffer) {
+	p.pool.Put(b)
 }
 
 // encodeJPEG 编码为JPEG格式
-func encodeJPEG(img image.Image) ([]byte, error) {
-	buf := make([]byte, 0)
-	w := &writerBuffer{buf: buf}
+func encodeJPEG(w io.Writer, img image.Image) error {
+	return jpeg.Encode(w, img, &jpeg.Options{Quality: 90})
+}
 
-	err := jpeg.Encode(w, img, &jpeg.Options{Quality: 90})
+// encodedSizeKey 按格式和图片尺寸区分编码结果的长度
+type encodedSizeKey struct {
+	mimeType string
+	bounds   image.Point
+}
 
-	return w.buf, err
+// maxEncodedSizes 最多记录的尺寸数量，验证码图片通常只有背景图和拼图块两种尺寸
+const maxEncodedSizes = 64
+
+// encodedSizeHints 记录各尺寸上次编码后的 data URI 长度，作为下次编码的预分配大小
+type encodedSizeHints struct {
+	mu    sync.Mutex
+	sizes map[encodedSizeKey]int
 }
 
-// writerBuffer 实现io.Writer接口的缓冲区
-type writerBuffer struct {
-	buf []byte
+// encodedSizes ImageToBase64 使用的预分配大小
+var encodedSizes = &encodedSizeHints{sizes: make(map[encodedSizeKey]int)}
+
+// get 返回预分配大小（多留1/8余量），没有记录时返回0
+func (h *encodedSizeHints) get(key encodedSizeKey) int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	n := h.sizes[key]
+	return n + n/8
 }
 
-func (w *writerBuffer) Write(p []byte) (n int, err error) {
-	w.buf = append(w.buf, p...)
-	return len(p), nil
+// put 记录编码后的长度，记录已满时不再添加新的尺寸
+func (h *encodedSizeHints) put(key encodedSizeKey, n int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.sizes[key]; ok || len(h.sizes) < maxEncodedSizes {
+		h.sizes[key] = n
+	}
 }
 
 // GenerateCaptchaImages 生成验证码图片
 func GenerateCaptchaImages(bgImage image.Image, x, y int, shape *PuzzleShape) (bgWithHole string, sliderPiece string, err error) {
-	// 先将图片缩放到目标尺寸（350x200）
-	targetWidth := 350
-	targetHeight := 200
+	return generateCaptchaImages(bgImage, x, y, shape, 0)
+}
+
+// generateCaptchaImages 生成验证码图片，decoys 为干扰缺口数量
+func generateCaptchaImages(bgImage image.Image, x, y int, shape *PuzzleShape, decoys int) (bgWithHole string, sliderPiece string, err error) {
+	// 先将图片缩放到目标尺寸（CanvasWidth x CanvasHeight）
+	targetWidth := CanvasWidth
+	targetHeight := CanvasHeight
 	resizedImage := ResizeImage(bgImage, targetWidth, targetHeight)
 
 	// 根据缩放比例调整缺口位置
@@ -145,11 +414,15 @@ func GenerateCaptchaImages(bgImage image.Image, x, y int, shape *PuzzleShape) (b
 	scaledX := int(float64(x) * scaleX)
 	scaledY := int(float64(y) * scaleY)
 
-	// 创建带缺口的背景图
-	holeImage := CreatePuzzleHole(resizedImage, scaledX, scaledY, shape)
+	// 创建带缺口的背景图，真实缺口和干扰缺口共用同一份缺口效果
+	overlay := render.NewHoleOverlay(GeneratePuzzleMask(shape), render.HoleBlur)
+	holeImage := overlay.Punch(resizedImage, scaledX, scaledY)
+	if decoys > 0 {
+		addDecoyHoles(holeImage, scaledX, overlay, decoys, secureIntn)
+	}
 
 	// 提取拼图块
-	pieceImage := ExtractPuzzlePiece(resizedImage, scaledX, scaledY, shape)
+	pieceImage := ExtractPuzzlePieceWithMask(resizedImage, scaledX, scaledY, overlay.Mask())
 
 	// 转换为base64
 	bgBase64, err := ImageToBase64(holeImage, "png")
@@ -167,71 +440,5 @@ func GenerateCaptchaImages(bgImage image.Image, x, y int, shape *PuzzleShape) (b
 
 // ResizeImage 缩放图片到指定尺寸（使用双线性插值，更平滑）
 func ResizeImage(src image.Image, width, height int) image.Image {
-	// 创建目标尺寸的图像
-	dst := image.NewRGBA(image.Rect(0, 0, width, height))
-
-	// 使用双线性插值进行缩放
-	srcBounds := src.Bounds()
-	srcW := srcBounds.Dx()
-	srcH := srcBounds.Dy()
-
-	for y := 0; y < height; y++ {
-		for x := 0; x < width; x++ {
-			// 计算源图像中的对应位置（浮点坐标）
-			srcX := float64(x) * float64(srcW) / float64(width)
-			srcY := float64(y) * float64(srcH) / float64(height)
-
-			// 双线性插值
-			x0 := int(srcX)
-			y0 := int(srcY)
-			x1 := x0 + 1
-			y1 := y0 + 1
-
-			// 边界检查
-			if x1 >= srcW {
-				x1 = srcW - 1
-			}
-			if y1 >= srcH {
-				y1 = srcH - 1
-			}
-
-			// 获取四个邻近像素
-			c00 := src.At(x0, y0)
-			c01 := src.At(x0, y1)
-			c10 := src.At(x1, y0)
-			c11 := src.At(x1, y1)
-
-			// 计算插值权重
-			fx := srcX - float64(x0)
-			fy := srcY - float64(y0)
-
-			// 双线性插值混合
-			r00, g00, b00, a00 := c00.RGBA()
-			r01, g01, b01, a01 := c01.RGBA()
-			r10, g10, b10, a10 := c10.RGBA()
-			r11, g11, b11, a11 := c11.RGBA()
-
-			// 混合权重 (0-65535)
-			wx := uint32(fx * 65535)
-			wy := uint32(fy * 65535)
-			wx_inv := 65535 - wx
-			wy_inv := 65535 - wy
-
-			// 双线性插值
-			r := (r00*wx_inv+r10*wx)/65535*wy_inv/65535 + (r01*wx_inv+r11*wx)/65535*wy/65535
-			g := (g00*wx_inv+g10*wx)/65535*wy_inv/65535 + (g01*wx_inv+g11*wx)/65535*wy/65535
-			b := (b00*wx_inv+b10*wx)/65535*wy_inv/65535 + (b01*wx_inv+b11*wx)/65535*wy/65535
-			a := (a00*wx_inv+a10*wx)/65535*wy_inv/65535 + (a01*wx_inv+a11*wx)/65535*wy/65535
-
-			// 转换为8位并设置像素
-			dst.SetRGBA(x, y, color.RGBA{
-				R: uint8(r >> 8),
-				G: uint8(g >> 8),
-				B: uint8(b >> 8),
-				A: uint8(a >> 8),
-			})
-		}
-	}
-
-	return dst
+	return render.Resize(src, width, height)
 }
@@ -0,0 +1,68 @@
+package captcha
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"math/rand"
+	"testing"
+
+	"github.com/gpencil/photo_captcha/captcha/render"
+)
+
+// FuzzDecodeRender 背景图从解码、缩放到渲染的完整流程，任何输入都不应导致 panic
+//
+//	go test -fuzz=FuzzDecodeRender ./captcha
+func FuzzDecodeRender(f *testing.F) {
+	// 模糊测试中限制像素数，避免单个输入耗时过长
+	policy := ImagePolicy{MaxPixels: 1 << 20}.withDefaults()
+
+	for _, img := range fuzzSeedImages() {
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, img); err != nil {
+			f.Fatal(err)
+		}
+		f.Add(buf.Bytes())
+
+		buf.Reset()
+		if err := jpeg.Encode(&buf, img, nil); err != nil {
+			f.Fatal(err)
+		}
+		f.Add(buf.Bytes())
+	}
+	f.Add([]byte("GIF89a"))
+	f.Add([]byte{})
+
+	overlay := render.NewHoleOverlay(render.GenerateMask(render.Star, PuzzleWidth, PuzzleHeight), render.HoleBlur)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		img, err := decodeImage(bytes.NewReader(data), policy)
+		if err != nil {
+			return
+		}
+		if b := img.Bounds(); b.Min != (image.Point{}) || b.Dx() <= 0 || b.Dy() <= 0 {
+			t.Fatalf("decoded image has invalid bounds %v", b)
+		}
+
+		resized := ResizeImage(img, CanvasWidth, CanvasHeight)
+		if _, _, err := renderCaptchaImages(resized, 100, 50, overlay, render.PieceBlur, 1, rand.New(rand.NewSource(1)).Intn, png.DefaultCompression, nil); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+// fuzzSeedImages 不同颜色模型和尺寸的种子图片
+func fuzzSeedImages() []image.Image {
+	rgba := image.NewRGBA(image.Rect(0, 0, 40, 30))
+	gray16 := image.NewGray16(image.Rect(0, 0, 3, 500))
+	paletted := image.NewPaletted(image.Rect(0, 0, 1, 1), color.Palette{color.Black, color.White})
+	nrgba64 := image.NewNRGBA64(image.Rect(0, 0, 64, 64))
+	for y := 0; y < 64; y++ {
+		for x := 0; x < 64; x++ {
+			nrgba64.Set(x, y, color.NRGBA64{uint16(x << 10), uint16(y << 10), 0x8000, uint16((x + y) << 9)})
+			rgba.Set(x, y, color.RGBA{uint8(x * 6), uint8(y * 8), 0, 255})
+		}
+	}
+	return []image.Image{rgba, gray16, paletted, nrgba64}
+}
@@ -0,0 +1,107 @@
+package captcha
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/gif"
+	"image/png"
+	"testing"
+	"testing/fstest"
+)
+
+func TestDecodeLimited(t *testing.T) {
+	encode := func(width, height int, enc func(*bytes.Buffer, image.Image) error) []byte {
+		var buf bytes.Buffer
+		if err := enc(&buf, image.NewRGBA(image.Rect(0, 0, width, height))); err != nil {
+			t.Fatal(err)
+		}
+		return buf.Bytes()
+	}
+	pngEnc := func(buf *bytes.Buffer, img image.Image) error { return png.Encode(buf, img) }
+	gifEnc := func(buf *bytes.Buffer, img image.Image) error { return gif.Encode(buf, img, nil) }
+	small := encode(40, 30, pngEnc)
+
+	tests := []struct {
+		name    string
+		data    []byte
+		policy  ImagePolicy
+		wantErr bool
+	}{
+		{"default", small, ImagePolicy{}, false},
+		{"too many bytes", small, ImagePolicy{MaxBytes: int64(len(small) - 1)}, true},
+		{"too many pixels", small, ImagePolicy{MaxPixels: 40*30 - 1}, true},
+		{"too long side", encode(100, 2, pngEnc), ImagePolicy{MaxDimension: 99}, true},
+		{"format not allowed", encode(4, 4, gifEnc), ImagePolicy{}, true},
+		{"format allowed", encode(4, 4, gifEnc), ImagePolicy{Formats: []string{"gif"}}, false},
+		{"png not in formats", small, ImagePolicy{Formats: []string{"jpeg"}}, true},
+		{"garbage", []byte("not an image"), ImagePolicy{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := decodeLimited(bytes.NewReader(tt.data), tt.policy)
+			if tt.wantErr != (err != nil) {
+				t.Fatalf("decodeLimited() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrInvalidImage) {
+				t.Errorf("decodeLimited() error = %v, want %v", err, ErrInvalidImage)
+			}
+		})
+	}
+}
+
+// TestWithImagePolicy 每个服务使用自己的解码策略，修改传入的格式列表不影响已创建的服务
+func TestWithImagePolicy(t *testing.T) {
+	formats := []string{"jpeg"}
+	strict := NewCaptchaService(WithImagePolicy(ImagePolicy{Formats: formats}))
+	formats[0] = "png"
+	lenient := NewCaptchaService()
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 4, 4))); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := decodeImage(bytes.NewReader(buf.Bytes()), strict.imagePolicy); !errors.Is(err, ErrInvalidImage) {
+		t.Errorf("strict service decoded png: %v", err)
+	}
+	if _, err := decodeImage(bytes.NewReader(buf.Bytes()), lenient.imagePolicy); err != nil {
+		t.Errorf("default service rejected png: %v", err)
+	}
+}
+
+// TestLoadMaskFilePolicy 预制mask与背景图一样按服务的解码策略检查
+func TestLoadMaskFilePolicy(t *testing.T) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewAlpha(image.Rect(0, 0, 200, 200))); err != nil {
+		t.Fatal(err)
+	}
+	fsys := fstest.MapFS{"mask/star.png": {Data: buf.Bytes()}}
+
+	tests := []struct {
+		name    string
+		policy  ImagePolicy
+		wantErr bool
+	}{
+		{"default", DefaultImagePolicy(), false},
+		{"too many pixels", ImagePolicy{MaxPixels: 200*200 - 1}, true},
+		{"too long side", ImagePolicy{MaxDimension: 199}, true},
+		{"png not allowed", ImagePolicy{Formats: []string{"jpeg"}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mask, err := loadMaskFile(fsys, "mask/star.png", tt.policy)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidImage) {
+					t.Errorf("loadMaskFile() error = %v, want %v", err, ErrInvalidImage)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if b := mask.Bounds(); b.Dx() != PuzzleWidth || b.Dy() != PuzzleHeight {
+				t.Errorf("mask size = %v, want %dx%d", b, PuzzleWidth, PuzzleHeight)
+			}
+		})
+	}
+}
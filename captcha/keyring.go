@@ -0,0 +1,62 @@
+package captcha
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"strconv"
+	"time"
+)
+
+// SigningKey 签名验证码ID的密钥，ID（kid）随签名写入验证码ID，校验时据此选择密钥，ID 会出现在URL中，应只包含字母、数字、- 和 _
+type SigningKey struct {
+	ID     string
+	Secret string
+}
+
+// keyRing 签名验证码ID的密钥集合：第一把密钥用于签发，所有密钥都可用于校验；
+// interval 大于0时实际使用的密钥按周期从配置的密钥派生，kid 为 <密钥ID>-<周期序号>
+type keyRing struct {
+	keys     []SigningKey
+	interval time.Duration
+}
+
+// signingKey 返回签发时间为 issued 的ID使用的 kid 和密钥
+func (r *keyRing) signingKey(issued time.Time) (string, []byte) {
+	return r.derive(r.keys[0], issued)
+}
+
+// lookup 返回 kid 对应的密钥；按周期派生时 kid 中的周期必须与签发时间 issued 所在的周期一致，
+// 即使某个周期的派生密钥泄露，也只能伪造签发时间在该周期内（很快会过期）的ID
+func (r *keyRing) lookup(kid string, issued time.Time) ([]byte, bool) {
+	keyID := kid
+	if r.interval > 0 {
+		var period string
+		var found bool
+		if keyID, period, found = cutLast(kid, "-"); !found || period != r.period(issued) {
+			return nil, false
+		}
+	}
+	for _, key := range r.keys {
+		if key.ID == keyID {
+			_, secret := r.derive(key, issued)
+			return secret, true
+		}
+	}
+	return nil, false
+}
+
+// derive 返回 key 在 issued 时使用的 kid 和密钥
+func (r *keyRing) derive(key SigningKey, issued time.Time) (string, []byte) {
+	if r.interval <= 0 {
+		return key.ID, []byte(key.Secret)
+	}
+	kid := key.ID + "-" + r.period(issued)
+	mac := hmac.New(sha256.New, []byte(key.Secret))
+	mac.Write([]byte("captcha-id-key:" + kid))
+	return kid, mac.Sum(nil)
+}
+
+// period 返回 t 所在周期的序号（36进制）
+func (r *keyRing) period(t time.Time) string {
+	return strconv.FormatInt(t.Unix()/int64(r.interval/time.Second), 36)
+}
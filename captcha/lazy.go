@@ -0,0 +1,97 @@
+package captcha
+
+import (
+	"context"
+	"image"
+	"sync"
+)
+
+// WithLazyBackgrounds 初始化时不下载背景图，每张背景图第一次被选中时才下载并预计算，之后缓存在内存中
+// 适合背景图很多、启动时间敏感的部署；冷启动时大量请求同时选中同一张背景图只会下载一次，其余请求等待并共享结果，
+// 下载失败时这些请求都返回错误，下次选中时重试
+func WithLazyBackgrounds() Option {
+	return func(s *CaptchaService) {
+		s.lazyBackgrounds = true
+	}
+}
+
+// placeholderBackgrounds 为每个地址创建尚未下载的背景图
+func placeholderBackgrounds(urls []string) ([]image.Image, []*preparedBackground) {
+	prepared := make([]*preparedBackground, len(urls))
+	for i, u := range urls {
+		prepared[i] = &preparedBackground{url: u}
+	}
+	return make([]image.Image, len(urls)), prepared
+}
+
+// loadLazyBackground 下载并预计算尚未下载的背景图，替换列表中同一地址的全部占位
+// 同一地址的并发调用只下载一次；下载不随单个请求的 ctx 取消，ctx 结束时调用方不再等待
+func (s *CaptchaService) loadLazyBackground(ctx context.Context, p *preparedBackground) (*preparedBackground, error) {
+	return s.downloads.do(ctx, p.url, func() (*preparedBackground, error) {
+		images, err := s.loadBackgroundImages(context.WithoutCancel(ctx), []string{p.url})
+		if err != nil {
+			return nil, err
+		}
+		loaded := s.prepareBackgrounds(images)[0]
+		loaded.url = p.url
+
+		// 复制后整体替换，已经取得旧列表的调用方不受影响；写锁保证与 SetBackgrounds 等其他替换不会互相覆盖，
+		// 期间 SetBackgrounds 换掉了列表时只替换新列表中同一地址的占位
+		s.mu.Lock()
+		cur := s.loadedBackgrounds()
+		set := &backgroundSet{
+			images:   append([]image.Image(nil), cur.images...),
+			prepared: append([]*preparedBackground(nil), cur.prepared...),
+		}
+		for i, bg := range set.prepared {
+			if !bg.loaded() && bg.url == p.url {
+				set.images[i], set.prepared[i] = loaded.source, loaded
+			}
+		}
+		s.backgrounds.Store(set)
+		s.mu.Unlock()
+		return loaded, nil
+	})
+}
+
+// flightGroup 合并对同一个键的并发调用：已有调用进行中时，后来的调用方等待并共享其结果（与 x/sync/singleflight 相同）
+type flightGroup[V any] struct {
+	mu    sync.Mutex
+	calls map[string]*flightCall[V]
+}
+
+// flightCall 进行中的一次调用
+type flightCall[V any] struct {
+	done chan struct{}
+	val  V
+	err  error
+}
+
+// do 对 key 执行 fn，key 已有调用进行中时等待其结果；ctx 结束时返回 ctx.Err()，但不会中止进行中的调用
+func (g *flightGroup[V]) do(ctx context.Context, key string, fn func() (V, error)) (V, error) {
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = make(map[string]*flightCall[V])
+	}
+	c, ok := g.calls[key]
+	if !ok {
+		c = &flightCall[V]{done: make(chan struct{})}
+		g.calls[key] = c
+		go func() {
+			c.val, c.err = fn()
+			g.mu.Lock()
+			delete(g.calls, key)
+			g.mu.Unlock()
+			close(c.done)
+		}()
+	}
+	g.mu.Unlock()
+
+	select {
+	case <-c.done:
+		return c.val, c.err
+	case <-ctx.Done():
+		var zero V
+		return zero, ctx.Err()
+	}
+}
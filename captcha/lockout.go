@@ -0,0 +1,334 @@
+package captcha
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LockoutStore 失败计数和封禁记录的存储接口
+// 默认使用内存实现；多实例部署时可使用 Redis 实现让封禁在集群内共享
+type LockoutStore interface {
+	// IncrFailures 增加失败计数，返回窗口内的累计失败次数（窗口从第一次失败开始计算）
+	IncrFailures(key string, window time.Duration) (int, error)
+	// ResetFailures 清空失败计数
+	ResetFailures(key string) error
+	// Ban 封禁到指定时间
+	Ban(key string, until time.Time) error
+	// BannedUntil 返回封禁截止时间，未封禁时返回 false
+	BannedUntil(key string) (time.Time, bool, error)
+}
+
+// LockoutBanStore 支持列出和解除封禁的封禁存储，内置的内存和 Redis 实现都支持
+type LockoutBanStore interface {
+	LockoutStore
+	// Bans 返回键以 prefix 开头的所有未过期封禁及其截止时间
+	Bans(prefix string) (map[string]time.Time, error)
+	// Unban 解除封禁，返回封禁是否存在
+	Unban(key string) (bool, error)
+}
+
+// ErrBansUnsupported 封禁存储未实现 LockoutBanStore，无法列出或解除封禁
+var ErrBansUnsupported = errors.New("lockout store does not support listing bans")
+
+// LockoutBan 一条生效中的封禁
+type LockoutBan struct {
+	Kind  string    // 封禁维度：ip 或 fingerprint
+	Value string    // 被封禁的IP或指纹
+	Until time.Time // 封禁截止时间
+}
+
+// LockoutEvent 封禁事件
+type LockoutEvent struct {
+	Kind     string    // 封禁维度：ip 或 fingerprint
+	Value    string    // 被封禁的IP或指纹
+	Failures int       // 触发封禁时窗口内的失败次数
+	Until    time.Time // 封禁截止时间
+}
+
+// 封禁维度
+const (
+	LockoutKindIP          = "ip"
+	LockoutKindFingerprint = "fingerprint"
+)
+
+// Lockout 暴力破解封禁：同一IP或指纹在窗口内失败达到上限后，在一段时间内禁止生成和验证
+type Lockout struct {
+	store LockoutStore
+
+	MaxFailures int           // 窗口内允许的最大失败次数
+	Window      time.Duration // 失败计数窗口
+	BanDuration time.Duration // 封禁时长
+
+	// OnBan 触发封禁时的回调（可选）
+	OnBan func(event LockoutEvent)
+	// Clock 计算封禁截止时间的时间来源（可选，默认系统时钟）
+	Clock Clock
+}
+
+// NewLockout 创建封禁控制器
+func NewLockout(store LockoutStore, maxFailures int, window, banDuration time.Duration) *Lockout {
+	return &Lockout{
+		store:       store,
+		MaxFailures: maxFailures,
+		Window:      window,
+		BanDuration: banDuration,
+	}
+}
+
+// now 返回当前时间
+func (l *Lockout) now() time.Time {
+	if l.Clock != nil {
+		return l.Clock.Now()
+	}
+	return TimeNow()
+}
+
+// RecordFailure 记录一次验证失败，达到上限时封禁对应的IP和/或指纹
+func (l *Lockout) RecordFailure(ip, fingerprint string) error {
+	for _, source := range lockoutSources(ip, fingerprint) {
+		failures, err := l.store.IncrFailures(lockoutFailureKey(source.kind, source.value), l.Window)
+		if err != nil {
+			return fmt.Errorf("failed to record lockout failure: %w", err)
+		}
+		if failures < l.MaxFailures {
+			continue
+		}
+
+		until := l.now().Add(l.BanDuration)
+		if err := l.store.Ban(lockoutBanKey(source.kind, source.value), until); err != nil {
+			return fmt.Errorf("failed to ban %s: %w", source.kind, err)
+		}
+		if err := l.store.ResetFailures(lockoutFailureKey(source.kind, source.value)); err != nil {
+			return fmt.Errorf("failed to reset lockout failures: %w", err)
+		}
+
+		event := LockoutEvent{
+			Kind:     source.kind,
+			Value:    source.value,
+			Failures: failures,
+			Until:    until,
+		}
+		Logger().Warn("lockout ban",
+			"kind", event.Kind, "value", event.Value, "failures", event.Failures, "until", event.Until)
+		if l.OnBan != nil {
+			l.OnBan(event)
+		}
+	}
+	return nil
+}
+
+// Banned 检查IP或指纹是否处于封禁中，返回最晚的封禁截止时间
+func (l *Lockout) Banned(ip, fingerprint string) (bool, time.Time, error) {
+	var latest time.Time
+	for _, source := range lockoutSources(ip, fingerprint) {
+		until, banned, err := l.store.BannedUntil(lockoutBanKey(source.kind, source.value))
+		if err != nil {
+			return false, time.Time{}, fmt.Errorf("failed to check lockout: %w", err)
+		}
+		if banned && until.After(latest) {
+			latest = until
+		}
+	}
+	return !latest.IsZero(), latest, nil
+}
+
+// Bans 返回所有生效中的封禁，按截止时间先后排列；存储未实现 LockoutBanStore 时返回 ErrBansUnsupported
+func (l *Lockout) Bans() ([]LockoutBan, error) {
+	store, ok := l.store.(LockoutBanStore)
+	if !ok {
+		return nil, ErrBansUnsupported
+	}
+	prefix := lockoutBanKey("", "")
+	entries, err := store.Bans(prefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list bans: %w", err)
+	}
+
+	bans := make([]LockoutBan, 0, len(entries))
+	for key, until := range entries {
+		kind, value, found := strings.Cut(strings.TrimPrefix(key, prefix), ":")
+		if !found {
+			continue
+		}
+		bans = append(bans, LockoutBan{Kind: kind, Value: value, Until: until})
+	}
+	sort.Slice(bans, func(i, j int) bool { return bans[i].Until.Before(bans[j].Until) })
+	return bans, nil
+}
+
+// Unban 提前解除IP或指纹的封禁并清空其失败计数，返回封禁是否存在；存储未实现 LockoutBanStore 时返回 ErrBansUnsupported
+func (l *Lockout) Unban(kind, value string) (bool, error) {
+	store, ok := l.store.(LockoutBanStore)
+	if !ok {
+		return false, ErrBansUnsupported
+	}
+	existed, err := store.Unban(lockoutBanKey(kind, value))
+	if err != nil {
+		return false, fmt.Errorf("failed to unban %s: %w", kind, err)
+	}
+	if err := store.ResetFailures(lockoutFailureKey(kind, value)); err != nil {
+		return false, fmt.Errorf("failed to reset lockout failures: %w", err)
+	}
+	if existed {
+		Logger().Info("lockout ban lifted", "kind", kind, "value", value)
+	}
+	return existed, nil
+}
+
+// lockoutSource 封禁维度和值
+type lockoutSource struct {
+	kind  string
+	value string
+}
+
+// lockoutSources 返回需要检查的非空维度
+func lockoutSources(ip, fingerprint string) []lockoutSource {
+	sources := make([]lockoutSource, 0, 2)
+	if ip != "" {
+		sources = append(sources, lockoutSource{kind: LockoutKindIP, value: ip})
+	}
+	if fingerprint != "" {
+		sources = append(sources, lockoutSource{kind: LockoutKindFingerprint, value: fingerprint})
+	}
+	return sources
+}
+
+func lockoutFailureKey(kind, value string) string {
+	return "lockout:failures:" + kind + ":" + value
+}
+
+// lockoutBanKey 封禁记录的键，kind 和 value 都为空时即所有封禁键的公共前缀
+func lockoutBanKey(kind, value string) string {
+	if kind == "" {
+		return "lockout:ban:"
+	}
+	return "lockout:ban:" + kind + ":" + value
+}
+
+// failureCounter 内存中的失败计数
+type failureCounter struct {
+	count   int
+	resetAt time.Time
+}
+
+// MemoryLockoutStore 内存封禁存储实现（仅在单实例内生效）
+type MemoryLockoutStore struct {
+	mu       sync.Mutex
+	failures map[string]*failureCounter
+	bans     map[string]time.Time
+	clock    Clock
+	// lastCleanup 上次清理过期记录的时间
+	lastCleanup time.Time
+}
+
+// NewMemoryLockoutStore 创建内存封禁存储
+func NewMemoryLockoutStore() *MemoryLockoutStore {
+	return &MemoryLockoutStore{
+		failures: make(map[string]*failureCounter),
+		bans:     make(map[string]time.Time),
+		clock:    SystemClock{},
+	}
+}
+
+// SetClock 替换时间来源（测试时使用可控的时钟）
+func (m *MemoryLockoutStore) SetClock(clock Clock) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.clock = clock
+}
+
+// IncrFailures 增加失败计数
+func (m *MemoryLockoutStore) IncrFailures(key string, window time.Duration) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.clock.Now()
+	m.cleanupLocked(now)
+
+	counter, exists := m.failures[key]
+	if !exists || now.After(counter.resetAt) {
+		counter = &failureCounter{resetAt: now.Add(window)}
+		m.failures[key] = counter
+	}
+	counter.count++
+
+	return counter.count, nil
+}
+
+// ResetFailures 清空失败计数
+func (m *MemoryLockoutStore) ResetFailures(key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.failures, key)
+	return nil
+}
+
+// Ban 封禁到指定时间
+func (m *MemoryLockoutStore) Ban(key string, until time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.bans[key] = until
+	return nil
+}
+
+// BannedUntil 返回封禁截止时间
+func (m *MemoryLockoutStore) BannedUntil(key string) (time.Time, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	until, exists := m.bans[key]
+	if !exists || m.clock.Now().After(until) {
+		return time.Time{}, false, nil
+	}
+	return until, true, nil
+}
+
+// Bans 返回键以 prefix 开头的所有未过期封禁
+func (m *MemoryLockoutStore) Bans(prefix string) (map[string]time.Time, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.clock.Now()
+	bans := make(map[string]time.Time)
+	for key, until := range m.bans {
+		if strings.HasPrefix(key, prefix) && !now.After(until) {
+			bans[key] = until
+		}
+	}
+	return bans, nil
+}
+
+// Unban 解除封禁
+func (m *MemoryLockoutStore) Unban(key string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	until, exists := m.bans[key]
+	delete(m.bans, key)
+	return exists && !m.clock.Now().After(until), nil
+}
+
+// cleanupLocked 每分钟最多清理一次过期的计数和封禁，调用方需持有锁
+func (m *MemoryLockoutStore) cleanupLocked(now time.Time) {
+	if now.Sub(m.lastCleanup) < time.Minute {
+		return
+	}
+	m.lastCleanup = now
+
+	for key, counter := range m.failures {
+		if now.After(counter.resetAt) {
+			delete(m.failures, key)
+		}
+	}
+	for key, until := range m.bans {
+		if now.After(until) {
+			delete(m.bans, key)
+		}
+	}
+}
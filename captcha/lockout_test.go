@@ -0,0 +1,110 @@
+package captcha
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestLockout(t *testing.T) {
+	const (
+		maxFailures = 3
+		window      = time.Minute
+		banDuration = 10 * time.Minute
+	)
+
+	tests := []struct {
+		name       string
+		failures   int
+		advance    time.Duration // 每次失败之间经过的时间
+		after      time.Duration // 最后一次失败后经过的时间
+		ip, fp     string        // 检查封禁时使用的IP和指纹
+		wantBanned bool
+		wantEvents int
+	}{
+		{name: "below limit", failures: maxFailures - 1, ip: "1.2.3.4", fp: "fp"},
+		{name: "at limit", failures: maxFailures, ip: "1.2.3.4", fp: "fp", wantBanned: true, wantEvents: 2},
+		{name: "ban by ip only", failures: maxFailures, ip: "1.2.3.4", wantBanned: true, wantEvents: 2},
+		{name: "ban by fingerprint only", failures: maxFailures, fp: "fp", wantBanned: true, wantEvents: 2},
+		{name: "other source", failures: maxFailures, ip: "5.6.7.8", fp: "other", wantEvents: 2},
+		{name: "failures spread over windows", failures: maxFailures, advance: window, ip: "1.2.3.4", fp: "fp"},
+		{name: "ban expired", failures: maxFailures, after: banDuration + time.Second, ip: "1.2.3.4", fp: "fp", wantEvents: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := time.Unix(1700000000, 0)
+			clock := ClockFunc(func() time.Time { return now })
+			store := NewMemoryLockoutStore()
+			store.SetClock(clock)
+			l := NewLockout(store, maxFailures, window, banDuration)
+			l.Clock = clock
+			var events []LockoutEvent
+			l.OnBan = func(event LockoutEvent) { events = append(events, event) }
+
+			for range tt.failures {
+				if err := l.RecordFailure("1.2.3.4", "fp"); err != nil {
+					t.Fatal(err)
+				}
+				now = now.Add(tt.advance)
+			}
+			now = now.Add(tt.after)
+
+			banned, until, err := l.Banned(tt.ip, tt.fp)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if banned != tt.wantBanned {
+				t.Errorf("Banned(%q, %q) = %v, want %v", tt.ip, tt.fp, banned, tt.wantBanned)
+			}
+			if banned && !until.After(now) {
+				t.Errorf("Banned() until = %v, want after %v", until, now)
+			}
+			if len(events) != tt.wantEvents {
+				t.Errorf("OnBan called %d times, want %d", len(events), tt.wantEvents)
+			}
+		})
+	}
+}
+
+func TestLockoutUnban(t *testing.T) {
+	l := NewLockout(NewMemoryLockoutStore(), 1, time.Minute, time.Minute)
+	if err := l.RecordFailure("1.2.3.4", "fp"); err != nil {
+		t.Fatal(err)
+	}
+
+	bans, err := l.Bans()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bans) != 2 {
+		t.Fatalf("Bans() = %v, want ip and fingerprint bans", bans)
+	}
+
+	if existed, err := l.Unban(LockoutKindIP, "1.2.3.4"); err != nil || !existed {
+		t.Fatalf("Unban() = %v, %v, want true", existed, err)
+	}
+	if banned, _, _ := l.Banned("1.2.3.4", ""); banned {
+		t.Error("ip still banned after Unban()")
+	}
+	if banned, _, _ := l.Banned("", "fp"); !banned {
+		t.Error("fingerprint ban lifted together with ip")
+	}
+	if existed, err := l.Unban(LockoutKindIP, "1.2.3.4"); err != nil || existed {
+		t.Errorf("second Unban() = %v, %v, want false", existed, err)
+	}
+}
+
+// plainLockoutStore 只实现 LockoutStore，不支持列出和解除封禁
+type plainLockoutStore struct {
+	LockoutStore
+}
+
+func TestLockoutBansUnsupported(t *testing.T) {
+	l := NewLockout(plainLockoutStore{NewMemoryLockoutStore()}, 1, time.Minute, time.Minute)
+	if _, err := l.Bans(); !errors.Is(err, ErrBansUnsupported) {
+		t.Errorf("Bans() error = %v, want %v", err, ErrBansUnsupported)
+	}
+	if _, err := l.Unban(LockoutKindIP, "1.2.3.4"); !errors.Is(err, ErrBansUnsupported) {
+		t.Errorf("Unban() error = %v, want %v", err, ErrBansUnsupported)
+	}
+}
@@ -0,0 +1,26 @@
+package captcha
+
+import (
+	"log/slog"
+	"sync/atomic"
+)
+
+// logger 包级函数（默认服务）和共享组件（封禁、预制mask加载）使用的日志，未设置时使用 slog.Default()
+// 通过 NewCaptchaService 创建的服务使用 WithLogger 设置的日志
+var logger atomic.Pointer[slog.Logger]
+
+// SetLogger 设置包级日志，传入nil时丢弃所有包级日志（嵌入其他服务时可用于静默）
+func SetLogger(l *slog.Logger) {
+	if l == nil {
+		l = slog.New(slog.DiscardHandler)
+	}
+	logger.Store(l)
+}
+
+// Logger 返回包级日志
+func Logger() *slog.Logger {
+	if l := logger.Load(); l != nil {
+		return l
+	}
+	return slog.Default()
+}
@@ -0,0 +1,106 @@
+package captcha
+
+import "time"
+
+// Metrics 监控指标接口，服务在生成、验证和过期时上报指标，嵌入的应用可接入已有的监控系统
+// captcha/statsd 提供 StatsD 实现，Prometheus 实现见 promadapter 模块；同一指标每次上报的标签名相同
+type Metrics interface {
+	// Count 计数器增加 delta
+	Count(name string, delta float64, labels ...Label)
+	// Observe 记录一次直方图观测值（耗时单位为秒）
+	Observe(name string, value float64, labels ...Label)
+	// Gauge 设置仪表盘当前值
+	Gauge(name string, value float64, labels ...Label)
+}
+
+// Label 指标标签
+type Label struct {
+	Name  string
+	Value string
+}
+
+// 服务上报的指标
+const (
+	// MetricGenerated 生成的验证码数量，标签 difficulty、source（render、pregenerated、reused、cached）
+	MetricGenerated = "captcha_generated_total"
+	// MetricGenerateDuration 生成耗时（秒），标签 source
+	MetricGenerateDuration = "captcha_generate_duration_seconds"
+	// MetricGenerateStageDuration 生成各阶段的耗时（秒），标签 stage：select_background（选择背景图、缺口位置和形状）、
+	// render_hole（打缺口和干扰缺口）、render_piece（切拼图块）、encode（PNG编码）、store（写入存储）；
+	// 前四个阶段只在实际渲染时上报（含预生成），store 每次生成都上报
+	MetricGenerateStageDuration = "captcha_generate_stage_duration_seconds"
+	// MetricVerified 验证次数，标签 result（success、failure）、reason
+	MetricVerified = "captcha_verified_total"
+	// MetricSolveDuration 从生成到提交验证的耗时（秒），标签 result
+	MetricSolveDuration = "captcha_solve_duration_seconds"
+	// MetricExpired 过期未使用的验证码数量
+	MetricExpired = "captcha_expired_total"
+	// MetricEncodedCache 编码结果缓存的查询次数，标签 format（如 jpeg/85）、result（hit、miss）
+	MetricEncodedCache = "captcha_encoded_cache_total"
+	// MetricIDRejected 启用 WithIDSecret 时未访问存储直接拒绝的验证码ID数，标签 reason（forged、expired）
+	MetricIDRejected = "captcha_id_rejected_total"
+	// MetricTokenReplay 有效期内再次提交已兑换令牌的次数（令牌重放）
+	MetricTokenReplay = "captcha_token_replay_total"
+	// MetricRiskyIP 启用 WithIPReputation 时查询到不良信誉的次数（生成和验证各计一次），标签 reputation（suspicious、malicious）
+	MetricRiskyIP = "captcha_risky_ip_total"
+	// MetricPregeneratedReady 预生成池中可用的验证码数量，标签 decoys
+	MetricPregeneratedReady = "captcha_pregenerated_ready"
+)
+
+// 生成验证码的来源
+const (
+	sourceRender       = "render"
+	sourcePregenerated = "pregenerated"
+	sourceReused       = "reused"
+	sourceCached       = "cached"
+)
+
+// 生成流程的阶段
+const (
+	stageSelectBackground = "select_background"
+	stageRenderHole       = "render_hole"
+	stageRenderPiece      = "render_piece"
+	stageEncode           = "encode"
+	stageStore            = "store"
+)
+
+// stageTimer 依次记录生成流程各阶段的耗时，nil 表示不记录（未配置 WithMetrics 时省去计时和标签的开销）
+type stageTimer struct {
+	metrics Metrics
+	last    time.Time
+}
+
+// newStageTimer 从当前时刻开始计时，未配置监控指标时返回nil
+func newStageTimer(m Metrics) *stageTimer {
+	if _, ok := m.(nopMetrics); ok || m == nil {
+		return nil
+	}
+	return &stageTimer{metrics: m, last: time.Now()}
+}
+
+// mark 上报从上一阶段结束（或开始计时）到现在的耗时，作为 stage 阶段的耗时
+func (t *stageTimer) mark(stage string) {
+	if t == nil {
+		return
+	}
+	now := time.Now()
+	t.metrics.Observe(MetricGenerateStageDuration, now.Sub(t.last).Seconds(), Label{"stage", stage})
+	t.last = now
+}
+
+// nopMetrics 不上报任何指标
+type nopMetrics struct{}
+
+func (nopMetrics) Count(string, float64, ...Label)   {}
+func (nopMetrics) Observe(string, float64, ...Label) {}
+func (nopMetrics) Gauge(string, float64, ...Label)   {}
+
+// WithMetrics 设置监控指标上报，默认不上报
+func WithMetrics(m Metrics) Option {
+	return func(s *CaptchaService) {
+		if m == nil {
+			m = nopMetrics{}
+		}
+		s.metrics = m
+	}
+}
@@ -0,0 +1,65 @@
+package captcha
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+)
+
+var (
+	// TokenHeader RequireTokenHandler 读取令牌的请求头
+	TokenHeader = "X-Captcha-Token"
+	// TokenField RequireTokenHandler 读取令牌的表单字段（请求头为空时使用）
+	TokenField = "captcha_token"
+)
+
+// captchaIDKey 令牌校验通过后，验证码ID保存在请求 context 中的键
+type captchaIDKey struct{}
+
+// TokenFromRequest 从 TokenHeader 请求头或 TokenField 表单字段读取令牌
+func TokenFromRequest(r *http.Request) string {
+	if token := r.Header.Get(TokenHeader); token != "" {
+		return token
+	}
+	return r.PostFormValue(TokenField)
+}
+
+// CaptchaIDFromContext 返回 RequireTokenHandler 校验通过的验证码ID
+func CaptchaIDFromContext(ctx context.Context) (string, bool) {
+	captchaID, ok := ctx.Value(captchaIDKey{}).(string)
+	return captchaID, ok
+}
+
+// RequireTokenHandler 要求请求携带该服务签发的令牌，用于保护登录、注册等接口（net/http 中间件）：
+//
+//	mux.Handle("/login", svc.RequireTokenHandler(loginHandler))
+//
+// 令牌校验后即被消费，验证码ID通过 CaptchaIDFromContext 读取；
+// 缺少令牌或令牌无效、已使用、已过期时返回 403。Gin 应用使用 ginadapter.RequireToken
+func (s *CaptchaService) RequireTokenHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := TokenFromRequest(r)
+		if token == "" {
+			writeForbidden(w, "captcha token required")
+			return
+		}
+
+		captchaID, ok, _ := s.ValidateTokenContext(r.Context(), token)
+		if !ok {
+			writeForbidden(w, "invalid captcha token")
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), captchaIDKey{}, captchaID)))
+	})
+}
+
+// writeForbidden 输出 403 响应，格式与 HTTP 服务一致
+func writeForbidden(w http.ResponseWriter, message string) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(http.StatusForbidden)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"code":    403,
+		"message": message,
+	})
+}
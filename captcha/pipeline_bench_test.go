@@ -0,0 +1,144 @@
+package captcha
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"strconv"
+	"testing"
+	"testing/fstest"
+
+	"github.com/gpencil/photo_captcha/captcha/render"
+)
+
+// 图片流水线每次操作的内存分配次数上限，TestAllocationTargets 会检查实际次数不超过上限
+// 修改渲染、缩放或编码流程导致超出时，应先确认新增的分配是否必要，再连同 README 中的表格一起调整
+const (
+	// generateAllocTarget 生成一个验证码（打缺口、切拼图块、PNG编码、写入内存存储）
+	generateAllocTarget = 40
+	// verifyAllocTarget 验证一个验证码（读取、校验并删除存储中的数据）
+	verifyAllocTarget = 16
+	// resizeAllocTarget 把预加载尺寸的背景图缩放到画布尺寸
+	resizeAllocTarget = 4
+	// holeAllocTarget 在画布上打缺口并切出拼图块，不含编码
+	holeAllocTarget = 12
+)
+
+// benchBackground 预加载尺寸（画布的 DefaultBackgroundScale 倍）的渐变背景图
+func benchBackground() *image.RGBA {
+	w, h := int(CanvasWidth*DefaultBackgroundScale), int(CanvasHeight*DefaultBackgroundScale)
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.SetRGBA(x, y, color.RGBA{uint8(x), uint8(y), uint8(x ^ y), 255})
+		}
+	}
+	return img
+}
+
+// newBenchService 从内存文件系统加载 benchBackground 并完成初始化的服务
+func newBenchService(tb testing.TB) *CaptchaService {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, benchBackground()); err != nil {
+		tb.Fatal(err)
+	}
+	fsys := fstest.MapFS{"bg.png": {Data: buf.Bytes()}}
+	s := NewCaptchaService(WithFS(fsys), WithBackgrounds("bg.png"))
+	if err := s.Init(); err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() { s.Close() })
+	return s
+}
+
+// benchVerify 写入一个答案已知的验证码并按正确位置验证
+func benchVerify(tb testing.TB, s *CaptchaService, id string) {
+	s.Store().Set(id, &CaptchaData{ID: id, Format: DataFormatVersion, PositionX: 120, PositionY: 60, CreatedAt: TimeNow()})
+	result, err := s.Verify(VerifyParams{ID: id, X: 120})
+	if err != nil || !result.Success {
+		tb.Fatalf("verify failed: %+v, %v", result, err)
+	}
+}
+
+// BenchmarkGenerate 生成验证码的完整流程
+func BenchmarkGenerate(b *testing.B) {
+	s := newBenchService(b)
+	b.ReportAllocs()
+	for b.Loop() {
+		if _, err := s.Generate(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkVerify 验证验证码的完整流程（含写入存储）
+func BenchmarkVerify(b *testing.B) {
+	s := newBenchService(b)
+	b.ReportAllocs()
+	i := 0
+	for b.Loop() {
+		benchVerify(b, s, "bench-"+strconv.Itoa(i))
+		i++
+	}
+}
+
+// BenchmarkResize 把预加载尺寸的背景图缩放到画布尺寸
+func BenchmarkResize(b *testing.B) {
+	bg := benchBackground()
+	b.ReportAllocs()
+	for b.Loop() {
+		ResizeImage(bg, CanvasWidth, CanvasHeight)
+	}
+}
+
+// BenchmarkHole 在画布上打缺口并切出拼图块，即每个请求在编码前的渲染工作（缺口效果按形状预先计算）
+func BenchmarkHole(b *testing.B) {
+	canvas := ResizeImage(benchBackground(), CanvasWidth, CanvasHeight)
+	overlay := render.NewHoleOverlay(NewCaptchaService().newPuzzleMask(PuzzleTypeStar), render.HoleBlur)
+	b.ReportAllocs()
+	for b.Loop() {
+		overlay.Punch(canvas, 120, 60)
+		ExtractPuzzlePieceWithMask(canvas, 120, 60, overlay.Mask())
+	}
+}
+
+// TestAllocationTargets 检查各基准测试的每次内存分配次数不超过文件开头记录的上限
+func TestAllocationTargets(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping allocation targets in short mode")
+	}
+	s := newBenchService(t)
+	bg := benchBackground()
+	canvas := ResizeImage(bg, CanvasWidth, CanvasHeight)
+	overlay := render.NewHoleOverlay(s.newPuzzleMask(PuzzleTypeStar), render.HoleBlur)
+	i := 0
+
+	cases := []struct {
+		name   string
+		target float64
+		run    func()
+	}{
+		{"Generate", generateAllocTarget, func() {
+			if _, err := s.Generate(); err != nil {
+				t.Fatal(err)
+			}
+		}},
+		{"Verify", verifyAllocTarget, func() {
+			benchVerify(t, s, "alloc-"+strconv.Itoa(i))
+			i++
+		}},
+		{"Resize", resizeAllocTarget, func() { ResizeImage(bg, CanvasWidth, CanvasHeight) }},
+		{"Hole", holeAllocTarget, func() {
+			overlay.Punch(canvas, 120, 60)
+			ExtractPuzzlePieceWithMask(canvas, 120, 60, overlay.Mask())
+		}},
+	}
+	for _, c := range cases {
+		allocs := testing.AllocsPerRun(20, c.run)
+		t.Logf("%s: %.0f allocs/op (target %.0f)", c.name, allocs, c.target)
+		if allocs > c.target {
+			t.Errorf("%s: %.0f allocs/op exceeds target %.0f", c.name, allocs, c.target)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package captcha
+
+import "image"
+
+// PlacementMode 缺口位置的随机范围
+type PlacementMode int
+
+const (
+	// PlacementCenter 在图片中心附近随机（X方向 ±25%，Y方向 ±15%，默认）
+	PlacementCenter PlacementMode = iota
+	// PlacementFullCanvas 在整个画布（去掉边距）内随机，缺口位置没有可供脚本利用的先验分布
+	PlacementFullCanvas
+)
+
+// String 返回随机范围名称
+func (m PlacementMode) String() string {
+	switch m {
+	case PlacementCenter:
+		return "center"
+	case PlacementFullCanvas:
+		return "full"
+	default:
+		return "unknown"
+	}
+}
+
+// Placement 缺口位置配置，Margin 和 Exclude 只在 PlacementFullCanvas 下生效
+type Placement struct {
+	Mode PlacementMode
+	// Margin 缺口与画布边缘的最小距离（像素）
+	Margin int
+	// Exclude 缺口不能与之重叠的区域（画布坐标），如放置了提示文字的区域
+	// 滑块起始位置（画布左侧一个拼图块宽度加边距的区域）总是被排除，无需配置
+	Exclude []image.Rectangle
+}
+
+// WithPlacement 设置缺口位置的随机范围，默认 PlacementCenter
+//
+//	captcha.WithPlacement(captcha.Placement{Mode: captcha.PlacementFullCanvas, Margin: 10})
+func WithPlacement(p Placement) Option {
+	return func(s *CaptchaService) {
+		s.placement = p
+	}
+}
+
+// fullCanvasPosition 在整个画布内随机一个缺口位置（画布坐标），与排除区域重叠时返回false
+func (s *CaptchaService) fullCanvasPosition(pieceWidth, pieceHeight int) (int, int, bool) {
+	margin := max(s.placement.Margin, 0)
+	// 缺口不能与滑块起始位置重叠
+	minX := pieceWidth + margin
+	maxX := CanvasWidth - margin - pieceWidth
+	minY := margin
+	maxY := CanvasHeight - margin - pieceHeight
+	if maxX < minX || maxY < minY {
+		return 0, 0, false
+	}
+
+	x := s.intn(maxX-minX+1) + minX
+	y := s.intn(maxY-minY+1) + minY
+	hole := image.Rect(x, y, x+pieceWidth, y+pieceHeight)
+	for _, zone := range s.placement.Exclude {
+		if hole.Overlaps(zone) {
+			return x, y, false
+		}
+	}
+	return x, y, true
+}
@@ -0,0 +1,156 @@
+package captcha
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// pregenRetryDelay 预生成失败（如背景图加载失败）后的重试间隔
+var pregenRetryDelay = time.Second
+
+// pregenPool 预生成验证码池：后台协程保持每种干扰缺口数量各有 size 个渲染完成的验证码，
+// 生成时直接取出，只需分配ID和写入存储
+type pregenPool struct {
+	svc *CaptchaService
+	// queues 按干扰缺口数量区分的队列（不同难度的图片不能混用）
+	queues map[int]chan *renderedChallenge
+	// wake 有验证码被取出时唤醒补充协程
+	wake chan struct{}
+
+	ctx      context.Context
+	cancel   context.CancelFunc
+	wg       sync.WaitGroup
+	stopOnce sync.Once
+}
+
+// newPregenPool 创建预生成池并启动 workers 个补充协程
+func newPregenPool(svc *CaptchaService, size, workers int) *pregenPool {
+	if workers <= 0 {
+		workers = 1
+	}
+	p := &pregenPool{
+		svc:    svc,
+		queues: make(map[int]chan *renderedChallenge),
+		wake:   make(chan struct{}, workers),
+	}
+	for _, settings := range DifficultyPresets {
+		if _, exists := p.queues[settings.DecoyHoles]; !exists {
+			p.queues[settings.DecoyHoles] = make(chan *renderedChallenge, size)
+		}
+	}
+	p.ctx, p.cancel = context.WithCancel(context.Background())
+
+	for i := 0; i < workers; i++ {
+		p.wg.Add(1)
+		go p.run()
+	}
+	return p
+}
+
+// take 取出一个预生成的验证码，池为空、未启用或选项指定了背景图、形状、位置、尺寸时返回nil
+func (p *pregenPool) take(decoys int, opts GenerateOptions) *renderedChallenge {
+	if p == nil || opts.custom() {
+		return nil
+	}
+	queue, exists := p.queues[decoys]
+	if !exists {
+		return nil
+	}
+
+	select {
+	case challenge := <-queue:
+		p.svc.metrics.Gauge(MetricPregeneratedReady, float64(len(queue)), Label{"decoys", strconv.Itoa(decoys)})
+		p.notify()
+		return challenge
+	default:
+		p.notify()
+		p.svc.log().Debug("pregenerated captcha pool empty", "decoys", decoys)
+		return nil
+	}
+}
+
+// notify 唤醒一个补充协程
+func (p *pregenPool) notify() {
+	select {
+	case p.wake <- struct{}{}:
+	default:
+	}
+}
+
+// run 补充协程：填满所有队列后等待唤醒
+func (p *pregenPool) run() {
+	defer p.wg.Done()
+	for {
+		filled, err := p.fill()
+		if err != nil {
+			p.svc.log().Warn("failed to pregenerate captcha", "error", err)
+			select {
+			case <-time.After(pregenRetryDelay):
+				continue
+			case <-p.ctx.Done():
+				return
+			}
+		}
+		if filled {
+			continue
+		}
+
+		select {
+		case <-p.wake:
+		case <-p.ctx.Done():
+			return
+		}
+	}
+}
+
+// fill 为第一个未满的队列渲染一个验证码，所有队列都已满时返回false
+func (p *pregenPool) fill() (bool, error) {
+	for decoys, queue := range p.queues {
+		if len(queue) >= cap(queue) {
+			continue
+		}
+		challenge, err := p.svc.render(p.ctx, GenerateOptions{}, decoys)
+		if err != nil {
+			return false, err
+		}
+		select {
+		case queue <- challenge:
+			p.svc.metrics.Gauge(MetricPregeneratedReady, float64(len(queue)), Label{"decoys", strconv.Itoa(decoys)})
+		default:
+			// 其他协程已填满
+		}
+		return true, nil
+	}
+	return false, nil
+}
+
+// drain 丢弃已预生成的验证码（背景图更换后调用）
+func (p *pregenPool) drain() {
+	if p == nil {
+		return
+	}
+	for _, queue := range p.queues {
+		for len(queue) > 0 {
+			select {
+			case <-queue:
+			default:
+			}
+		}
+	}
+	for range cap(p.wake) {
+		p.notify()
+	}
+}
+
+// stop 停止补充协程并等待退出
+func (p *pregenPool) stop() {
+	if p == nil {
+		return
+	}
+	p.stopOnce.Do(func() {
+		p.cancel()
+		p.wg.Wait()
+	})
+}
@@ -0,0 +1,133 @@
+package captcha
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"strconv"
+
+	"github.com/gpencil/photo_captcha/captcha/render"
+)
+
+// preparedBackground 加载背景图时预先计算的数据，生成验证码时只需打缺口、切拼图块和编码
+type preparedBackground struct {
+	// url 背景图地址，WithLazyBackgrounds 时用于首次选中时下载
+	url string
+	// source 预加载的背景图（已按 backgroundScale 缩小）
+	source image.Image
+	// canvas 缩放到画布尺寸的背景图
+	canvas image.Image
+	// luminance 画布每个像素的亮度，用于选择纹理足够的缺口位置
+	luminance *render.LuminanceMap
+}
+
+// loaded 是否已下载（WithLazyBackgrounds 时首次选中前为 false）
+func (p *preparedBackground) loaded() bool {
+	return p.source != nil
+}
+
+// bytes 预计算数据占用的内存（字节），不含原图
+func (p *preparedBackground) bytes() int64 {
+	if !p.loaded() {
+		return 0
+	}
+	return imageBytes(p.canvas) + int64(len(p.luminance.Pix))*4
+}
+
+// loadPrepared 加载并预计算 urls 中的背景图，WithLazyBackgrounds 时只创建占位、不下载
+func (s *CaptchaService) loadPrepared(ctx context.Context, urls []string) ([]image.Image, []*preparedBackground, error) {
+	if s.lazyBackgrounds {
+		images, prepared := placeholderBackgrounds(urls)
+		return images, prepared, nil
+	}
+	images, err := s.loadBackgroundImages(ctx, urls)
+	if err != nil {
+		return nil, nil, err
+	}
+	prepared := s.prepareBackgrounds(images)
+	for i, p := range prepared {
+		p.url = urls[i]
+	}
+	return images, prepared, nil
+}
+
+// prepareBackgrounds 为每张背景图预先计算画布和亮度
+func (s *CaptchaService) prepareBackgrounds(images []image.Image) []*preparedBackground {
+	prepared := make([]*preparedBackground, 0, len(images))
+	for _, img := range images {
+		canvas := ResizeImage(img, CanvasWidth, CanvasHeight)
+		prepared = append(prepared, &preparedBackground{
+			source:    img,
+			canvas:    canvas,
+			luminance: render.Luminance(canvas),
+		})
+	}
+	return prepared
+}
+
+// backgroundSet 一次加载的背景图列表，创建后不再修改：重新加载或延迟下载完成时复制出新列表整体替换（写时复制），
+// 已经取得旧列表的调用方不受影响
+type backgroundSet struct {
+	images []image.Image
+	// prepared 与 images 一一对应的预计算数据（画布、亮度等）
+	prepared []*preparedBackground
+}
+
+// emptyBackgrounds 尚未初始化时的空列表
+var emptyBackgrounds = &backgroundSet{}
+
+// loadedBackgrounds 返回当前的背景图列表，不加锁，未初始化时返回空列表
+func (s *CaptchaService) loadedBackgrounds() *backgroundSet {
+	if set := s.backgrounds.Load(); set != nil {
+		return set
+	}
+	return emptyBackgrounds
+}
+
+// preparedBytes 一组背景图预计算数据占用的内存（字节）
+func preparedBytes(prepared []*preparedBackground) int64 {
+	var total int64
+	for _, p := range prepared {
+		total += p.bytes()
+	}
+	return total
+}
+
+// WithCleanJPEG 启用 CleanBackground：把画布尺寸、没有缺口的背景图编码为指定质量（1-100）的JPEG，
+// 供验证通过后展示完整图片等场景使用；编码结果保存在编码结果缓存中（见 WithEncodedCache），默认不启用
+func WithCleanJPEG(quality int) Option {
+	return func(s *CaptchaService) {
+		s.cleanJPEGQuality = quality
+	}
+}
+
+// CleanBackground 返回第 index 张背景图（与 Backgrounds() 的顺序一致）画布尺寸、没有缺口的JPEG，
+// 首次调用时编码，之后从编码结果缓存中取用；未初始化、背景图尚未下载、序号超出范围或未启用 WithCleanJPEG 时返回 false
+func (s *CaptchaService) CleanBackground(index int) ([]byte, bool) {
+	if s.cleanJPEGQuality <= 0 {
+		return nil, false
+	}
+	var p *preparedBackground
+	if prepared := s.loadedBackgrounds().prepared; index >= 0 && index < len(prepared) {
+		p = prepared[index]
+	}
+	if p == nil || !p.loaded() {
+		return nil, false
+	}
+
+	key := encodedKey{url: p.url, width: CanvasWidth, height: CanvasHeight, format: "jpeg/" + strconv.Itoa(s.cleanJPEGQuality)}
+	data, err := s.encoded(key, func() ([]byte, error) {
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, p.canvas, &jpeg.Options{Quality: s.cleanJPEGQuality}); err != nil {
+			return nil, fmt.Errorf("failed to encode background %d: %w", index, err)
+		}
+		return buf.Bytes(), nil
+	})
+	if err != nil {
+		s.log().Warn("failed to encode clean background", "index", index, "error", err)
+		return nil, false
+	}
+	return data, true
+}
@@ -0,0 +1,66 @@
+package captcha
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// InitStatus 初始化进度，在后台初始化时用于报告就绪状态（如 /readyz）
+type InitStatus struct {
+	// Ready 已完成初始化，生成验证码不再需要等待加载背景图
+	Ready bool
+	// Loaded 已加载的背景图数量（WithLazyBackgrounds 时初始化不下载，始终为0）
+	Loaded int
+	// Total 需要加载的背景图数量，尚未开始初始化时为0
+	Total int
+	// Err 最近一次初始化失败的原因，SetBackgrounds 后清除
+	Err error
+}
+
+// initProgress 初始化进度，不使用服务的读写锁，初始化进行中也可以随时读取
+type initProgress struct {
+	ready  atomic.Bool
+	loaded atomic.Int64
+	total  atomic.Int64
+
+	mu  sync.Mutex
+	err error
+}
+
+// begin 开始初始化，需要加载 total 张背景图
+func (p *initProgress) begin(total int) {
+	p.loaded.Store(0)
+	p.total.Store(int64(total))
+}
+
+// advance 记录一张背景图加载完成，初始化完成后（如 SetBackgrounds 重新加载）不再计数
+func (p *initProgress) advance() {
+	if !p.ready.Load() {
+		p.loaded.Add(1)
+	}
+}
+
+// setErr 记录初始化失败的原因（nil 表示清除）
+func (p *initProgress) setErr(err error) {
+	p.mu.Lock()
+	p.err = err
+	p.mu.Unlock()
+}
+
+// status 返回当前进度
+func (p *initProgress) status() InitStatus {
+	p.mu.Lock()
+	err := p.err
+	p.mu.Unlock()
+	return InitStatus{
+		Ready:  p.ready.Load(),
+		Loaded: int(p.loaded.Load()),
+		Total:  int(p.total.Load()),
+		Err:    err,
+	}
+}
+
+// InitStatus 返回初始化进度，不会等待进行中的初始化，可以在另一个协程调用 WarmUp 的同时轮询
+func (s *CaptchaService) InitStatus() InitStatus {
+	return s.progress.status()
+}
@@ -0,0 +1,25 @@
+package captcha
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"math"
+)
+
+// secureIntn 使用 crypto/rand 返回 [0, n) 的均匀随机数，n 必须大于0
+// 缺口位置和形状决定了答案，用可预测的随机源（如以时间为种子的 math/rand）时攻击者可以缩小缺口位置的范围
+func secureIntn(n int) int {
+	if n <= 0 {
+		panic("captcha: invalid argument to secureIntn")
+	}
+	// 拒绝落在最后一段不完整区间内的值，避免取模偏差
+	bound := uint64(n)
+	limit := math.MaxUint64 - math.MaxUint64%bound
+	var buf [8]byte
+	for {
+		rand.Read(buf[:])
+		if v := binary.LittleEndian.Uint64(buf[:]); v < limit {
+			return int(v % bound)
+		}
+	}
+}
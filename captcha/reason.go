@@ -0,0 +1,43 @@
+package captcha
+
+// FailureReason 验证失败原因（机器可读）
+type FailureReason string
+
+const (
+	ReasonNone                FailureReason = ""                     // 验证通过
+	ReasonNotFound            FailureReason = "not_found"            // 验证码不存在
+	ReasonExpired             FailureReason = "expired"              // 验证码已过期
+	ReasonWrongPosition       FailureReason = "wrong_position"       // 位置不正确
+	ReasonTooFast             FailureReason = "too_fast"             // 拖动过快
+	ReasonTooManyAttempts     FailureReason = "too_many_attempts"    // 尝试次数过多
+	ReasonFingerprintMismatch FailureReason = "fingerprint_mismatch" // 客户端指纹不一致
+	ReasonIPMismatch          FailureReason = "ip_mismatch"          // 客户端IP不一致
+	ReasonRiskRejected        FailureReason = "risk_rejected"        // 轨迹风险过高
+	ReasonAlreadyUsed         FailureReason = "already_used"         // 验证码已验证通过或已作废
+	ReasonSessionMismatch     FailureReason = "session_mismatch"     // 浏览器会话不一致
+	ReasonUnavailable         FailureReason = "unavailable"          // 存储暂不可用或请求已超时、取消
+	ReasonUnsupportedFormat   FailureReason = "unsupported_format"   // 验证码由更新版本的服务生成
+	ReasonStaleChallenge      FailureReason = "stale_challenge"      // 验证码在受保护操作开始前过早生成（疑似批量预先打码）
+)
+
+// FailureReasons 所有验证失败原因
+var FailureReasons = []FailureReason{
+	ReasonNotFound,
+	ReasonExpired,
+	ReasonWrongPosition,
+	ReasonTooFast,
+	ReasonTooManyAttempts,
+	ReasonFingerprintMismatch,
+	ReasonIPMismatch,
+	ReasonRiskRejected,
+	ReasonAlreadyUsed,
+	ReasonSessionMismatch,
+	ReasonUnavailable,
+	ReasonUnsupportedFormat,
+	ReasonStaleChallenge,
+}
+
+// Retryable 判断该失败原因下是否可以继续使用同一个验证码重试
+func (r FailureReason) Retryable() bool {
+	return r == ReasonWrongPosition || r == ReasonTooFast || r == ReasonRiskRejected
+}
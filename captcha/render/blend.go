@@ -0,0 +1,31 @@
+//go:build !fastblend || !(amd64 || arm64)
+
+package render
+
+// blendRow 就地叠加一行遮罩层：pix 为画布上一行连续像素（RGBA），layer 为对应的处理方式，
+// tint 为白色遮罩的查表结果，border 为 false 时边缘也只加白色遮罩
+func blendRow(pix, layer []uint8, tint *[3][256]uint8, border bool) {
+	for i, l := range layer {
+		p := pix[i*4 : i*4+4 : i*4+4]
+		switch {
+		case l == layerTint, l == layerEdge && !border:
+			p[0], p[1], p[2], p[3] = tint[0][p[0]], tint[1][p[1]], tint[2][p[2]], 255
+		case l == layerEdge:
+			p[0], p[1], p[2], p[3] = holeBorder.R, holeBorder.G, holeBorder.B, holeBorder.A
+		}
+	}
+}
+
+// tintRow 把一行像素叠加遮罩层后写入模糊用的行缓冲：line 按 RGB 存储，已复制原始像素，只改写mask内的像素
+func tintRow(line, pix, layer []uint8, tint *[3][256]uint8, border bool) {
+	for i, l := range layer {
+		c := pix[i*4 : i*4+3 : i*4+3]
+		d := line[i*3 : i*3+3 : i*3+3]
+		switch {
+		case l == layerTint, l == layerEdge && !border:
+			d[0], d[1], d[2] = tint[0][c[0]], tint[1][c[1]], tint[2][c[2]]
+		case l == layerEdge:
+			d[0], d[1], d[2] = holeBorder.R, holeBorder.G, holeBorder.B
+		}
+	}
+}
@@ -0,0 +1,92 @@
+//go:build fastblend && (amd64 || arm64)
+
+package render
+
+import (
+	"encoding/binary"
+	"unsafe"
+)
+
+// 使用 -tags fastblend 编译时启用的快速遮罩层实现：把每个像素当作一个 uint32 直接读写 Pix（不做边界检查），
+// 每次处理4个像素，4个像素都在mask外时整体跳过。结果与默认实现完全相同，只用于小端、允许非对齐访问的 amd64 和 arm64，
+// 其他平台即使指定了标签也使用默认实现
+
+// borderWord 描边颜色对应的像素值（小端，R 在最低字节）
+var borderWord = uint32(holeBorder.R) | uint32(holeBorder.G)<<8 | uint32(holeBorder.B)<<16 | uint32(holeBorder.A)<<24
+
+// tintWord 返回叠加白色遮罩后的像素值，alpha 固定为255
+func tintWord(tint *[3][256]uint8, v uint32) uint32 {
+	return uint32(tint[0][uint8(v)]) | uint32(tint[1][uint8(v>>8)])<<8 | uint32(tint[2][uint8(v>>16)])<<16 | 0xff<<24
+}
+
+// blendRow 就地叠加一行遮罩层：pix 为画布上一行连续像素（RGBA），layer 为对应的处理方式，
+// tint 为白色遮罩的查表结果，border 为 false 时边缘也只加白色遮罩
+func blendRow(pix, layer []uint8, tint *[3][256]uint8, border bool) {
+	n := len(layer)
+	if n == 0 {
+		return
+	}
+	_ = pix[n*4-1]
+	base := unsafe.Pointer(unsafe.SliceData(pix))
+	blend := func(i int) {
+		p := (*uint32)(unsafe.Add(base, i*4))
+		switch l := layer[i]; {
+		case l == layerTint, l == layerEdge && !border:
+			*p = tintWord(tint, *p)
+		case l == layerEdge:
+			*p = borderWord
+		}
+	}
+
+	i := 0
+	for ; i+4 <= n; i += 4 {
+		if binary.LittleEndian.Uint32(layer[i:]) == 0 {
+			continue
+		}
+		blend(i)
+		blend(i + 1)
+		blend(i + 2)
+		blend(i + 3)
+	}
+	for ; i < n; i++ {
+		blend(i)
+	}
+}
+
+// tintRow 把一行像素叠加遮罩层后写入模糊用的行缓冲：line 按 RGB 存储，已复制原始像素，只改写mask内的像素
+func tintRow(line, pix, layer []uint8, tint *[3][256]uint8, border bool) {
+	n := len(layer)
+	if n == 0 {
+		return
+	}
+	_, _ = pix[n*4-1], line[n*3-1]
+	src := unsafe.Pointer(unsafe.SliceData(pix))
+	dst := unsafe.Pointer(unsafe.SliceData(line))
+	apply := func(i int) {
+		var v uint32
+		switch l := layer[i]; {
+		case l == layerTint, l == layerEdge && !border:
+			v = tintWord(tint, *(*uint32)(unsafe.Add(src, i*4)))
+		case l == layerEdge:
+			v = borderWord
+		default:
+			return
+		}
+		d := (*[3]uint8)(unsafe.Add(dst, i*3))
+		d[0], d[1], d[2] = uint8(v), uint8(v>>8), uint8(v>>16)
+	}
+
+	i := 0
+	for ; i+4 <= n; i += 4 {
+		if binary.LittleEndian.Uint32(layer[i:]) == 0 {
+			continue
+		}
+		apply(i)
+		apply(i + 1)
+		apply(i + 2)
+		apply(i + 3)
+	}
+	for ; i < n; i++ {
+		apply(i)
+	}
+}
@@ -0,0 +1,174 @@
+package render
+
+import (
+	"image"
+	"math"
+)
+
+// Gaussian 高斯模糊参数，先水平后垂直各做一次一维卷积，耗时与半径成正比（二维卷积与半径的平方成正比）
+type Gaussian struct {
+	// Radius 核半径（像素），核大小为 2*Radius+1，不大于0时不模糊
+	Radius int
+	// Sigma 标准差，不大于0时为 Radius/2
+	Sigma float64
+	// Box 用三次盒式模糊近似，每个像素的耗时与半径无关，适合小容器、ARM设备等CPU受限的环境，边缘过渡略生硬
+	Box bool
+}
+
+// DefaultGaussian 默认的模糊参数，与两次3x3高斯模糊的强度相当
+var DefaultGaussian = Gaussian{Radius: 2, Sigma: 1}
+
+// HoleBlur、PieceBlur PunchHole 模糊缺口区域、CutPiece 模糊拼图块使用的参数，默认 DefaultGaussian
+// 半径越大边缘越柔和，但缺口轮廓也越不明显；应在启动时设置，不能与渲染并发修改。
+// captcha.CaptchaService 在创建时复制这两个值，之后各服务使用自己的副本（见 captcha.WithFastBlur）
+var (
+	HoleBlur  = DefaultGaussian
+	PieceBlur = DefaultGaussian
+)
+
+// sigma 返回标准差
+func (g Gaussian) sigma() float64 {
+	if g.Sigma > 0 {
+		return g.Sigma
+	}
+	return float64(g.Radius) / 2
+}
+
+// kernel 返回归一化的一维高斯核，下标为 [offset+Radius]
+func (g Gaussian) kernel() []float64 {
+	sigma := g.sigma()
+	weights := make([]float64, 2*g.Radius+1)
+	sum := 0.0
+	for i := range weights {
+		d := float64(i - g.Radius)
+		weights[i] = math.Exp(-d * d / (2 * sigma * sigma))
+		sum += weights[i]
+	}
+	for i := range weights {
+		weights[i] /= sum
+	}
+	return weights
+}
+
+// BlurHole 对画布上 (x, y) 处的缺口区域应用高斯模糊，让缺口边缘更平滑；邻域超出画布时使用边界像素
+func BlurHole(result *image.RGBA, mask *image.Alpha, offsetX, offsetY int, g Gaussian) {
+	if g.Radius <= 0 {
+		return
+	}
+	m := alphaView(mask)
+	width, height := result.Rect.Dx(), result.Rect.Dy()
+	region := image.Rect(offsetX, offsetY, offsetX+m.w, offsetY+m.h).Intersect(image.Rect(0, 0, width, height))
+	if region.Empty() {
+		return
+	}
+	if g.Box {
+		boxBlurHole(result, m, offsetX, offsetY, region, g)
+		return
+	}
+	kernel := g.kernel()
+	r := g.Radius
+
+	// 水平方向：对缺口所在列、上下各扩展半径的行做一维卷积，结果按 RGB 存入 rows
+	minY := max(region.Min.Y-r, 0)
+	maxY := min(region.Max.Y+r, height)
+	rowStride := region.Dx() * 3
+	rows := make([]float64, (maxY-minY)*rowStride)
+	for y := minY; y < maxY; y++ {
+		line := result.Pix[y*result.Stride:]
+		for x := region.Min.X; x < region.Max.X; x++ {
+			var sumR, sumG, sumB float64
+			for k, weight := range kernel {
+				c := line[min(max(x+k-r, 0), width-1)*4:]
+				sumR += float64(c[0]) * weight
+				sumG += float64(c[1]) * weight
+				sumB += float64(c[2]) * weight
+			}
+			t := rows[(y-minY)*rowStride+(x-region.Min.X)*3:]
+			t[0], t[1], t[2] = sumR, sumG, sumB
+		}
+	}
+
+	// 垂直方向：只写回mask内的像素
+	for y := region.Min.Y; y < region.Max.Y; y++ {
+		for x := region.Min.X; x < region.Max.X; x++ {
+			if m.at(x-offsetX, y-offsetY) == 0 {
+				continue
+			}
+			var sumR, sumG, sumB float64
+			for k, weight := range kernel {
+				t := rows[(min(max(y+k-r, 0), height-1)-minY)*rowStride+(x-region.Min.X)*3:]
+				sumR += t[0] * weight
+				sumG += t[1] * weight
+				sumB += t[2] * weight
+			}
+			i := y*result.Stride + x*4
+			p := result.Pix[i : i+4 : i+4]
+			p[0], p[1], p[2], p[3] = roundByte(sumR), roundByte(sumG), roundByte(sumB), 255
+		}
+	}
+}
+
+// Blur 对拼图块mask内的像素应用高斯模糊，mask外的像素按黑色计入，使边缘略微变暗、与背景过渡更柔和；透明区域保持透明
+func Blur(piece *image.RGBA, mask *image.Alpha, g Gaussian) {
+	if g.Radius <= 0 {
+		return
+	}
+	pix, stride, m := pieceView(piece, mask)
+	if g.Box {
+		boxBlurPiece(pix, stride, m, g)
+		return
+	}
+	kernel := g.kernel()
+	r := g.Radius
+
+	// 水平方向：只累加同一行mask内的像素
+	rowStride := m.w * 3
+	rows := make([]float64, m.h*rowStride)
+	for py := 0; py < m.h; py++ {
+		for px := 0; px < m.w; px++ {
+			if m.at(px, py) == 0 {
+				continue
+			}
+			var sumR, sumG, sumB float64
+			for k, weight := range kernel {
+				nx := px + k - r
+				if nx < 0 || nx >= m.w || m.at(nx, py) == 0 {
+					continue
+				}
+				c := pix[py*stride+nx*4:]
+				sumR += float64(c[0]) * weight
+				sumG += float64(c[1]) * weight
+				sumB += float64(c[2]) * weight
+			}
+			t := rows[py*rowStride+px*3:]
+			t[0], t[1], t[2] = sumR, sumG, sumB
+		}
+	}
+
+	// 垂直方向：同样只使用mask内的像素
+	for py := 0; py < m.h; py++ {
+		for px := 0; px < m.w; px++ {
+			if m.at(px, py) == 0 {
+				continue
+			}
+			var sumR, sumG, sumB float64
+			for k, weight := range kernel {
+				ny := py + k - r
+				if ny < 0 || ny >= m.h || m.at(px, ny) == 0 {
+					continue
+				}
+				t := rows[ny*rowStride+px*3:]
+				sumR += t[0] * weight
+				sumG += t[1] * weight
+				sumB += t[2] * weight
+			}
+			p := pix[py*stride+px*4:]
+			p[0], p[1], p[2], p[3] = roundByte(sumR), roundByte(sumG), roundByte(sumB), 255
+		}
+	}
+}
+
+// roundByte 四舍五入并限制在0-255范围内
+func roundByte(v float64) uint8 {
+	return clamp255(int(v + 0.5))
+}
@@ -0,0 +1,194 @@
+package render
+
+import (
+	"image"
+	"math"
+)
+
+// boxPasses 近似高斯模糊的盒式模糊次数
+const boxPasses = 3
+
+// boxRadii 返回 boxPasses 次盒式模糊逼近标准差 sigma 的高斯模糊时各次的半径（宽度取相邻的两个奇数，使方差之和最接近 sigma²）
+func boxRadii(sigma float64) []int {
+	n := float64(boxPasses)
+	ideal := math.Sqrt(12*sigma*sigma/n + 1)
+	lower := int(ideal)
+	if lower%2 == 0 {
+		lower--
+	}
+	upper := lower + 2
+	wl := float64(lower)
+	m := int(math.Round((12*sigma*sigma - n*wl*wl - 4*n*wl - 3*n) / (-4*wl - 4)))
+
+	radii := make([]int, boxPasses)
+	for i := range radii {
+		width := upper
+		if i < m {
+			width = lower
+		}
+		radii[i] = (width - 1) / 2
+	}
+	return radii
+}
+
+// boxBuffer 按 RGB 存储的图像，inside 为 nil 时所有像素都参与模糊，否则 inside 之外的像素按黑色计入
+// 各次模糊都用整数运算并四舍五入到 uint8，缓冲区小、不依赖浮点性能
+type boxBuffer struct {
+	pix    []uint8
+	tmp    []uint8
+	w, h   int
+	inside []bool // 按行存储，下标为 y*w+x
+}
+
+// newBoxBuffer 创建 w x h 的缓冲区
+func newBoxBuffer(w, h int) *boxBuffer {
+	return &boxBuffer{pix: make([]uint8, w*h*3), tmp: make([]uint8, w*h*3), w: w, h: h}
+}
+
+// blur 依次按 radii 做水平、垂直两个方向的盒式模糊
+// inside 为 nil 时超出缓冲区的邻域使用边界像素，否则按黑色计入
+// 每次一维模糊的结果转置写出，再对转置后的图像做同样的一维模糊即完成垂直方向，两次都按行顺序读取
+func (b *boxBuffer) blur(radii []int) {
+	clamp := b.inside == nil
+	var insideT []bool
+	if !clamp {
+		insideT = make([]bool, len(b.inside))
+		for y := 0; y < b.h; y++ {
+			for x := 0; x < b.w; x++ {
+				insideT[x*b.h+y] = b.inside[y*b.w+x]
+			}
+		}
+	}
+	for _, r := range radii {
+		if r <= 0 {
+			continue
+		}
+		boxRows(b.pix, b.tmp, b.w, b.h, r, insideT)
+		boxRows(b.tmp, b.pix, b.h, b.w, r, b.inside)
+	}
+}
+
+// boxRows 对 w x h 的 src 逐行做半径 r 的滑动平均，结果转置写入 dst（h x w）
+// insideT 为 nil 时超出行范围的邻域使用边界值；否则按0计入，且 dst 中 insideT 之外的像素写为黑色
+func boxRows(src, dst []uint8, w, h, r int, insideT []bool) {
+	clamp := insideT == nil
+	// 用乘法和移位代替除以窗口宽度
+	const shift = 16
+	mul := ((1 << shift) + r) / (2*r + 1)
+	half := 1 << (shift - 1)
+	last := (w - 1) * 3
+
+	for y := 0; y < h; y++ {
+		row := src[y*w*3 : (y+1)*w*3]
+		var sumR, sumG, sumB int
+		for i := -r; i <= r; i++ {
+			j := i * 3
+			switch {
+			case i >= 0 && i < w:
+			case !clamp:
+				continue
+			case i < 0:
+				j = 0
+			default:
+				j = last
+			}
+			sumR += int(row[j])
+			sumG += int(row[j+1])
+			sumB += int(row[j+2])
+		}
+
+		o := y
+		for x := 0; x < w; x++ {
+			if clamp || insideT[o] {
+				dst[o*3] = uint8((sumR*mul + half) >> shift)
+				dst[o*3+1] = uint8((sumG*mul + half) >> shift)
+				dst[o*3+2] = uint8((sumB*mul + half) >> shift)
+			} else {
+				dst[o*3], dst[o*3+1], dst[o*3+2] = 0, 0, 0
+			}
+			o += h
+
+			// 窗口右移：加入 x+r+1，移出 x-r
+			if i := x + r + 1; i < w {
+				j := i * 3
+				sumR += int(row[j])
+				sumG += int(row[j+1])
+				sumB += int(row[j+2])
+			} else if clamp {
+				sumR += int(row[last])
+				sumG += int(row[last+1])
+				sumB += int(row[last+2])
+			}
+			if i := x - r; i >= 0 {
+				j := i * 3
+				sumR -= int(row[j])
+				sumG -= int(row[j+1])
+				sumB -= int(row[j+2])
+			} else if clamp {
+				sumR -= int(row[0])
+				sumG -= int(row[1])
+				sumB -= int(row[2])
+			}
+		}
+	}
+}
+
+// boxBlurHole BlurHole 的盒式模糊近似：读取缺口区域及其外围各次半径之和的像素，模糊后只写回mask内的像素
+func boxBlurHole(result *image.RGBA, m alphaGrid, offsetX, offsetY int, region image.Rectangle, g Gaussian) {
+	radii := boxRadii(g.sigma())
+	reach := 0
+	for _, r := range radii {
+		reach += r
+	}
+	area := region.Inset(-reach).Intersect(result.Rect.Sub(result.Rect.Min))
+
+	b := newBoxBuffer(area.Dx(), area.Dy())
+	for y := 0; y < b.h; y++ {
+		line := result.Pix[(area.Min.Y+y)*result.Stride+area.Min.X*4:]
+		for x := 0; x < b.w; x++ {
+			i := (y*b.w + x) * 3
+			b.pix[i], b.pix[i+1], b.pix[i+2] = line[x*4], line[x*4+1], line[x*4+2]
+		}
+	}
+	b.blur(radii)
+
+	for y := region.Min.Y; y < region.Max.Y; y++ {
+		for x := region.Min.X; x < region.Max.X; x++ {
+			if m.at(x-offsetX, y-offsetY) == 0 {
+				continue
+			}
+			t := b.pix[((y-area.Min.Y)*b.w+x-area.Min.X)*3:]
+			p := result.Pix[y*result.Stride+x*4:]
+			p[0], p[1], p[2], p[3] = t[0], t[1], t[2], 255
+		}
+	}
+}
+
+// boxBlurPiece Blur 的盒式模糊近似：只使用mask内的像素，mask外按黑色计入
+func boxBlurPiece(pix []uint8, stride int, m alphaGrid, g Gaussian) {
+	b := newBoxBuffer(m.w, m.h)
+	b.inside = make([]bool, m.w*m.h)
+	for y := 0; y < b.h; y++ {
+		for x := 0; x < b.w; x++ {
+			if m.at(x, y) == 0 {
+				continue
+			}
+			b.inside[y*b.w+x] = true
+			c := pix[y*stride+x*4:]
+			i := (y*b.w + x) * 3
+			b.pix[i], b.pix[i+1], b.pix[i+2] = c[0], c[1], c[2]
+		}
+	}
+	b.blur(boxRadii(g.sigma()))
+
+	for y := 0; y < b.h; y++ {
+		for x := 0; x < b.w; x++ {
+			if !b.inside[y*b.w+x] {
+				continue
+			}
+			t := b.pix[(y*b.w+x)*3:]
+			p := pix[y*stride+x*4:]
+			p[0], p[1], p[2], p[3] = t[0], t[1], t[2], 255
+		}
+	}
+}
@@ -0,0 +1,389 @@
+package render
+
+import (
+	"image"
+	"image/color"
+	"math"
+)
+
+// 滤镜直接读写 Pix，坐标相对图片左上角；拼图块与mask尺寸不一致时只处理两者重叠的部分
+
+// alphaGrid mask 的 Pix 视图，坐标相对 mask 左上角
+type alphaGrid struct {
+	pix    []uint8
+	stride int
+	w, h   int
+}
+
+// alphaView 返回 mask 的 Pix 视图
+func alphaView(mask *image.Alpha) alphaGrid {
+	return alphaGrid{pix: mask.Pix, stride: mask.Stride, w: mask.Rect.Dx(), h: mask.Rect.Dy()}
+}
+
+// clip 把视图限制在 w x h 以内（拼图块小于mask时，超出拼图块的部分视为mask外）
+func (g alphaGrid) clip(w, h int) alphaGrid {
+	g.w = min(g.w, w)
+	g.h = min(g.h, h)
+	return g
+}
+
+// at 返回 (x, y) 处的不透明度，调用方需保证坐标在范围内
+func (g alphaGrid) at(x, y int) uint8 {
+	return g.pix[y*g.stride+x]
+}
+
+// edge 判断mask内的像素是否在边缘：8邻域中有mask外或超出范围的像素
+func (g alphaGrid) edge(x, y int) bool {
+	for dy := -1; dy <= 1; dy++ {
+		for dx := -1; dx <= 1; dx++ {
+			if dx == 0 && dy == 0 {
+				continue
+			}
+			nx := x + dx
+			ny := y + dy
+			if nx < 0 || nx >= g.w || ny < 0 || ny >= g.h {
+				return true
+			}
+			if g.at(nx, ny) == 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// transparentNeighbors 计算8邻域中范围内的透明像素数量
+func (g alphaGrid) transparentNeighbors(x, y int) int {
+	count := 0
+	for dy := -1; dy <= 1; dy++ {
+		for dx := -1; dx <= 1; dx++ {
+			if dx == 0 && dy == 0 {
+				continue
+			}
+			nx := x + dx
+			ny := y + dy
+			if nx >= 0 && nx < g.w && ny >= 0 && ny < g.h {
+				if g.at(nx, ny) == 0 {
+					count++
+				}
+			}
+		}
+	}
+	return count
+}
+
+// pieceView 拼图块的Pix、行跨度以及与拼图块重叠的mask视图
+func pieceView(piece *image.RGBA, mask *image.Alpha) ([]uint8, int, alphaGrid) {
+	return piece.Pix, piece.Stride, alphaView(mask).clip(piece.Rect.Dx(), piece.Rect.Dy())
+}
+
+// isWhite 判断像素是否为白色边框（pix 为 R、G、B 起始的切片）
+func isWhite(pix []uint8) bool {
+	return pix[0] == 255 && pix[1] == 255 && pix[2] == 255
+}
+
+// antiAliasWeights、diagonalWeights 抗锯齿和斜边平滑按距离衰减的权重，下标为 [dy+r][dx+r]
+var (
+	antiAliasWeights = distanceWeights(3, func(distance float64) float64 {
+		return 1.0 / math.Pow(distance+1.0, 1.5) // 使用更强的衰减
+	})
+	diagonalWeights = distanceWeights(2, func(distance float64) float64 {
+		return 1.0 / (distance + 1.0)
+	})
+)
+
+// distanceWeights 预先计算半径 r 内各偏移的权重
+func distanceWeights(r int, weight func(distance float64) float64) [][]float64 {
+	weights := make([][]float64, 2*r+1)
+	for dy := -r; dy <= r; dy++ {
+		weights[dy+r] = make([]float64, 2*r+1)
+		for dx := -r; dx <= r; dx++ {
+			weights[dy+r][dx+r] = weight(math.Sqrt(float64(dx*dx + dy*dy)))
+		}
+	}
+	return weights
+}
+
+// Lighten 用白色遮罩覆盖画布上 (x, y) 处mask覆盖的区域，形成缺口
+func Lighten(img *image.RGBA, mask *image.Alpha, x, y int) {
+	m := alphaView(mask)
+	width, height := img.Rect.Dx(), img.Rect.Dy()
+	for py := 0; py < m.h; py++ {
+		targetY := y + py
+		if targetY < 0 || targetY >= height {
+			continue
+		}
+		for px := 0; px < m.w; px++ {
+			targetX := x + px
+			if targetX < 0 || targetX >= width || m.at(px, py) == 0 {
+				continue
+			}
+
+			i := targetY*img.Stride + targetX*4
+			p := img.Pix[i : i+4 : i+4]
+			p[0], p[1], p[2], p[3] = tintTable[0][p[0]], tintTable[1][p[1]], tintTable[2][p[2]], 255
+		}
+	}
+}
+
+// holeBorder 缺口描边的颜色
+var holeBorder = color.RGBA{R: 0, G: 0, B: 0, A: 0} // 降低边框不透明度，0去掉边框 150更明显 80更淡 lcq1
+
+// OutlineHole 为画布上 (x, y) 处的缺口描边
+func OutlineHole(result *image.RGBA, mask *image.Alpha, x, y int) {
+	m := alphaView(mask)
+	width, height := result.Rect.Dx(), result.Rect.Dy()
+	for py := 0; py < m.h; py++ {
+		for px := 0; px < m.w; px++ {
+			if m.at(px, py) == 0 || !m.edge(px, py) {
+				continue
+			}
+			targetX := x + px
+			targetY := y + py
+			if targetX >= 0 && targetX < width && targetY >= 0 && targetY < height {
+				// 在边缘添加黑色描边
+				i := targetY*result.Stride + targetX*4
+				p := result.Pix[i : i+4 : i+4]
+				p[0], p[1], p[2], p[3] = holeBorder.R, holeBorder.G, holeBorder.B, holeBorder.A
+			}
+		}
+	}
+}
+
+// Border 为拼图块添加白色边框并做抗锯齿处理
+func Border(piece *image.RGBA, mask *image.Alpha) {
+	pix, stride, m := pieceView(piece, mask)
+	for py := 0; py < m.h; py++ {
+		for px := 0; px < m.w; px++ {
+			if m.at(px, py) > 0 && m.edge(px, py) {
+				i := py*stride + px*4
+				pix[i], pix[i+1], pix[i+2], pix[i+3] = 255, 255, 255, 255
+			}
+		}
+	}
+
+	// 进行抗锯齿处理
+	AntiAlias(piece, mask)
+}
+
+// AntiAlias 对拼图块边缘进行抗锯齿处理（超强平滑版）
+func AntiAlias(piece *image.RGBA, mask *image.Alpha) {
+	pix, stride, m := pieceView(piece, mask)
+
+	// 第一遍：对边缘的非白色像素进行强力抗锯齿
+	for py := 0; py < m.h; py++ {
+		for px := 0; px < m.w; px++ {
+			if m.at(px, py) == 0 {
+				continue
+			}
+			transparentNeighbors := m.transparentNeighbors(px, py)
+			if transparentNeighbors == 0 {
+				continue
+			}
+			current := pix[py*stride+px*4:]
+			// 如果是纯白色边框，跳过
+			if isWhite(current) {
+				continue
+			}
+
+			// 收集周围非白色像素，扩大范围到3像素
+			var sumR, sumG, sumB uint32
+			var totalWeight float64
+			for dy := -3; dy <= 3; dy++ {
+				ny := py + dy
+				if ny < 0 || ny >= m.h {
+					continue
+				}
+				for dx := -3; dx <= 3; dx++ {
+					nx := px + dx
+					if (dx == 0 && dy == 0) || nx < 0 || nx >= m.w || m.at(nx, ny) == 0 {
+						continue
+					}
+					c := pix[ny*stride+nx*4:]
+					// 跳过白色边框像素
+					if isWhite(c) {
+						continue
+					}
+					// 距离加权，越近权重越高
+					weight := antiAliasWeights[dy+3][dx+3]
+					sumR += uint32(float64(c[0]) * weight)
+					sumG += uint32(float64(c[1]) * weight)
+					sumB += uint32(float64(c[2]) * weight)
+					totalWeight += weight
+				}
+			}
+
+			if totalWeight > 0 {
+				// 计算加权平均
+				avgR := sumR / uint32(totalWeight)
+				avgG := sumG / uint32(totalWeight)
+				avgB := sumB / uint32(totalWeight)
+
+				// 根据边缘位置决定混合比例，提高到50%-90%
+				mixRatio := 0.5 + float64(transparentNeighbors)/9.0*0.4
+
+				current[0] = uint8(float64(current[0])*(1-mixRatio) + float64(avgR)*mixRatio)
+				current[1] = uint8(float64(current[1])*(1-mixRatio) + float64(avgG)*mixRatio)
+				current[2] = uint8(float64(current[2])*(1-mixRatio) + float64(avgB)*mixRatio)
+				current[3] = 255
+			}
+		}
+	}
+
+	// 第二遍：对斜边进行额外平滑（针对梯形）
+	smoothDiagonalEdges(pix, stride, m)
+
+	// 第三遍：全局轻微平滑，消除残留的锯齿
+	globalSmooth(pix, stride, m)
+}
+
+// globalSmooth 对所有非边框像素进行轻微的全局平滑
+func globalSmooth(pix []uint8, stride int, m alphaGrid) {
+	for py := 1; py < m.h-1; py++ {
+		for px := 1; px < m.w-1; px++ {
+			if m.at(px, py) == 0 {
+				continue
+			}
+			current := pix[py*stride+px*4:]
+			// 跳过白色边框
+			if isWhite(current) {
+				continue
+			}
+
+			// 收集周围像素进行轻微平滑
+			var sumR, sumG, sumB uint32
+			var count uint32
+			for dy := -1; dy <= 1; dy++ {
+				for dx := -1; dx <= 1; dx++ {
+					nx := px + dx
+					ny := py + dy
+					if m.at(nx, ny) == 0 {
+						continue
+					}
+					c := pix[ny*stride+nx*4:]
+					if !isWhite(c) {
+						sumR += uint32(c[0])
+						sumG += uint32(c[1])
+						sumB += uint32(c[2])
+						count++
+					}
+				}
+			}
+
+			if count > 0 {
+				avgR := sumR / count
+				avgG := sumG / count
+				avgB := sumB / count
+
+				// 只做轻微平滑（20%混合）
+				current[0] = uint8(float64(current[0])*0.8 + float64(avgR)*0.2)
+				current[1] = uint8(float64(current[1])*0.8 + float64(avgG)*0.2)
+				current[2] = uint8(float64(current[2])*0.8 + float64(avgB)*0.2)
+				current[3] = 255
+			}
+		}
+	}
+}
+
+// smoothDiagonalEdges 对斜边进行额外的平滑处理
+func smoothDiagonalEdges(pix []uint8, stride int, m alphaGrid) {
+	for py := 1; py < m.h-1; py++ {
+		for px := 1; px < m.w-1; px++ {
+			if m.at(px, py) == 0 {
+				continue
+			}
+			current := pix[py*stride+px*4:]
+			// 跳过白色边框
+			if isWhite(current) {
+				continue
+			}
+
+			// 检查是否在斜边附近（水平和垂直方向都有透明像素），不是斜边则跳过
+			hasHorizontalTransparent := m.at(px-1, py) == 0 || m.at(px+1, py) == 0
+			hasVerticalTransparent := m.at(px, py-1) == 0 || m.at(px, py+1) == 0
+			if !hasHorizontalTransparent || !hasVerticalTransparent {
+				continue
+			}
+
+			// 收集更大范围的像素进行额外平滑
+			var sumR, sumG, sumB uint32
+			var totalWeight float64
+			for dy := -2; dy <= 2; dy++ {
+				ny := py + dy
+				if ny < 0 || ny >= m.h {
+					continue
+				}
+				for dx := -2; dx <= 2; dx++ {
+					nx := px + dx
+					if (dx == 0 && dy == 0) || nx < 0 || nx >= m.w || m.at(nx, ny) == 0 {
+						continue
+					}
+					c := pix[ny*stride+nx*4:]
+					if !isWhite(c) {
+						weight := diagonalWeights[dy+2][dx+2]
+						sumR += uint32(float64(c[0]) * weight)
+						sumG += uint32(float64(c[1]) * weight)
+						sumB += uint32(float64(c[2]) * weight)
+						totalWeight += weight
+					}
+				}
+			}
+
+			if totalWeight > 0 {
+				avgR := sumR / uint32(totalWeight)
+				avgG := sumG / uint32(totalWeight)
+				avgB := sumB / uint32(totalWeight)
+
+				// 对斜边像素进行更强的平滑（60%混合）
+				current[0] = uint8(float64(current[0])*0.4 + float64(avgR)*0.6)
+				current[1] = uint8(float64(current[1])*0.4 + float64(avgG)*0.6)
+				current[2] = uint8(float64(current[2])*0.4 + float64(avgB)*0.6)
+				current[3] = 255
+			}
+		}
+	}
+}
+
+// Highlight 提高拼图块边缘内侧的亮度，增加立体感
+func Highlight(piece *image.RGBA, mask *image.Alpha) {
+	pix, stride, m := pieceView(piece, mask)
+
+	// 对边缘内侧像素添加轻微的高光效果
+	for py := 0; py < m.h; py++ {
+		for px := 0; px < m.w; px++ {
+			if m.at(px, py) == 0 {
+				continue
+			}
+			transparentNeighbors := m.transparentNeighbors(px, py)
+			if transparentNeighbors == 0 {
+				continue
+			}
+			current := pix[py*stride+px*4:]
+			// 跳过白色边框
+			if isWhite(current) {
+				continue
+			}
+
+			// 根据透明邻居数量调整高光强度
+			// 边缘越明显（透明邻居越多），高光越强
+			highlightRatio := 0.05 + float64(transparentNeighbors)/9.0*0.15
+
+			// 提高亮度，增加高光效果
+			current[0] = clamp255(int(float64(current[0]) * (1 + highlightRatio)))
+			current[1] = clamp255(int(float64(current[1]) * (1 + highlightRatio)))
+			current[2] = clamp255(int(float64(current[2]) * (1 + highlightRatio)))
+			current[3] = 255
+		}
+	}
+}
+
+// clamp255 限制值在0-255范围内
+func clamp255(v int) uint8 {
+	if v < 0 {
+		return 0
+	}
+	if v > 255 {
+		return 255
+	}
+	return uint8(v)
+}
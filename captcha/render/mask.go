@@ -0,0 +1,247 @@
+package render
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"io/fs"
+	"math"
+	"os"
+)
+
+// Shape 判断点 (x, y) 是否在 width x height 的拼图形状内
+type Shape func(x, y, width, height int) bool
+
+// 内置形状，与 captcha 包的拼图形状一致（预制mask图片加载失败时的程序生成版本）
+var (
+	Triangle  Shape = insideTriangle
+	Hexagon   Shape = insideHexagon
+	Trapezoid Shape = insideTrapezoid
+	Star      Shape = insideStar
+)
+
+// GenerateMask 按形状生成 width x height 的mask，形状内完全不透明，形状外完全透明
+func GenerateMask(shape Shape, width, height int) *image.Alpha {
+	mask := image.NewAlpha(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			if shape(x, y, width, height) {
+				mask.SetAlpha(x, y, color.Alpha{A: 255})
+			}
+		}
+	}
+	return mask
+}
+
+// Decoder 按调用方的限制读取并解码图片（如 captcha.ImagePolicy），超过限制时返回错误
+// mask通常是几百字节的PNG，解码后却可能占用上百MB，解码器应在解码前根据图片头部检查尺寸
+type Decoder interface {
+	Decode(r io.Reader) (image.Image, error)
+}
+
+// LoadMask 用 dec 解码PNG等图片文件作为mask并缩放到 width x height，保留原始alpha值（保持抗锯齿效果）
+//
+//	mask, err := render.LoadMask(captcha.DefaultImagePolicy(), "mask/star.png", 70, 70)
+func LoadMask(dec Decoder, filename string, width, height int) (*image.Alpha, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+	return decodeMask(dec, file, width, height)
+}
+
+// LoadMaskFS 从 fsys 中加载mask（如 embed.FS、zip 包），其余与 LoadMask 相同
+func LoadMaskFS(dec Decoder, fsys fs.FS, name string, width, height int) (*image.Alpha, error) {
+	file, err := fsys.Open(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+	return decodeMask(dec, file, width, height)
+}
+
+// decodeMask 用 dec 解码mask图片并缩放到 width x height
+func decodeMask(dec Decoder, r io.Reader, width, height int) (*image.Alpha, error) {
+	img, err := dec.Decode(r)
+	if err != nil {
+		return nil, err
+	}
+	return MaskFromImage(img, width, height), nil
+}
+
+// MaskFromImage 把已解码的图片缩放到 width x height 并取其alpha值作为mask
+func MaskFromImage(img image.Image, width, height int) *image.Alpha {
+	resized := Resize(img, width, height)
+	mask := image.NewAlpha(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			_, _, _, a := resized.At(x, y).RGBA()
+			// 0-65535 转为 0-255
+			mask.SetAlpha(x, y, color.Alpha{A: uint8(a >> 8)})
+		}
+	}
+	return mask
+}
+
+// ScaleMask 按最近邻缩放mask
+func ScaleMask(mask *image.Alpha, width, height int) *image.Alpha {
+	srcWidth, srcHeight := mask.Rect.Dx(), mask.Rect.Dy()
+	scaled := image.NewAlpha(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			scaled.SetAlpha(x, y, mask.AlphaAt(x*srcWidth/width, y*srcHeight/height))
+		}
+	}
+	return scaled
+}
+
+// insideTriangle 三角形（等腰三角形，顶点朝上，尖锐）
+func insideTriangle(x, y, width, height int) bool {
+	centerX := width / 2
+	marginBottom := 8 // 下边距
+	marginSide := 8   // 左右边距
+
+	// 检查边界
+	if x < marginSide || x >= width-marginSide {
+		return false
+	}
+	if y >= height-marginBottom {
+		return false
+	}
+
+	// 三角形高度（从顶部到下边距）
+	triangleHeight := float64(height - marginBottom)
+
+	// 当前y在三角形中的相对位置（0到1）
+	relativeY := float64(y) / triangleHeight
+
+	// 顶点宽度为0（真正的尖锐顶点）
+	topWidth := 0.0
+	bottomWidth := float64(width - 2*marginSide)
+
+	// 根据y坐标计算当前宽度
+	currentWidth := topWidth + (bottomWidth-topWidth)*relativeY
+
+	// 计算x到中心的距离
+	dx := float64(x - centerX)
+
+	return math.Abs(dx) <= currentWidth/2
+}
+
+// insideHexagon 六边形（6条直边的平顶正六边形）
+func insideHexagon(x, y, width, height int) bool {
+	centerX := width / 2
+	centerY := height / 2
+	radius := float64(width/2 - 10)
+
+	// 将坐标转换为相对于中心的坐标
+	px := float64(x - centerX)
+	py := float64(y - centerY)
+
+	// 平顶六边形的6个顶点（从右上开始，顺时针）
+	// 平顶六边形的顶点角度: 0°, 60°, 120°, 180°, 240°, 300°
+	vertices := []struct{ x, y float64 }{
+		{radius, 0},                               // 右
+		{radius / 2, radius * math.Sqrt(3) / 2},   // 右下
+		{-radius / 2, radius * math.Sqrt(3) / 2},  // 左下
+		{-radius, 0},                              // 左
+		{-radius / 2, -radius * math.Sqrt(3) / 2}, // 左上
+		{radius / 2, -radius * math.Sqrt(3) / 2},  // 右上
+	}
+
+	// 使用叉积法检查点是否在多边形内
+	// 对于每条边，检查点是否在边的内侧
+	inside := true
+	for i := 0; i < 6; i++ {
+		j := (i + 1) % 6
+		// 边从 vertices[i] 到 vertices[j]
+		// 计算边的向量
+		edgeX := vertices[j].x - vertices[i].x
+		edgeY := vertices[j].y - vertices[i].y
+
+		// 计算从顶点到测试点的向量
+		pointX := px - vertices[i].x
+		pointY := py - vertices[i].y
+
+		// 计算叉积 (2D cross product)
+		cross := edgeX*pointY - edgeY*pointX
+
+		// 对于逆时针定义的多边形，如果点在内部，所有叉积应该 >= 0（或全部 <= 0）
+		if cross < 0 {
+			inside = false
+			break
+		}
+	}
+
+	return inside
+}
+
+// insideTrapezoid 梯形（倒置版，上窄下宽的等腰梯形）
+func insideTrapezoid(x, y, width, height int) bool {
+	centerX := width / 2
+	centerY := height / 2
+
+	// 梯形参数
+	trapezoidHeight := float64(height - 20) // 梯形高度
+	topWidth := float64(width - 40)         // 上底宽度（较窄）
+	bottomWidth := float64(width - 20)      // 下底宽度（更宽）
+
+	dx := float64(x - centerX)
+	dy := float64(y - centerY)
+
+	// 检查是否在高度范围内
+	if math.Abs(dy) > trapezoidHeight/2 {
+		return false
+	}
+
+	// 根据y坐标计算当前宽度
+	// y从 centerY-trapezoidHeight/2 到 centerY+trapezoidHeight/2
+	normalizedY := (dy + trapezoidHeight/2) / trapezoidHeight // 0到1
+	currentWidth := topWidth + (bottomWidth-topWidth)*normalizedY
+
+	return math.Abs(dx) <= currentWidth/2
+}
+
+// insideStar 五角星
+func insideStar(x, y, width, height int) bool {
+	centerX := width / 2
+	centerY := height / 2
+
+	outerRadius := float64(width/2 - 8) // 外半径
+	innerRadius := outerRadius * 0.4    // 内半径（五角星的凹陷）
+
+	dx := float64(x - centerX)
+	dy := float64(y - centerY)
+	dist := math.Sqrt(dx*dx + dy*dy)
+
+	// 转换为极坐标
+	angle := math.Atan2(dy, dx)
+	if angle < 0 {
+		angle += 2 * math.Pi
+	}
+
+	// 五角星有5个角，每个角间隔72度（2π/5）
+	segmentAngle := 2 * math.Pi / 5 // 72度
+	halfAngle := math.Pi / 5        // 36度（半扇区）
+
+	// 归一化角度，使其从第一个角开始（-π/2）
+	normalizedAngle := angle + math.Pi/2
+	if normalizedAngle < 0 {
+		normalizedAngle += 2 * math.Pi
+	}
+	if normalizedAngle >= 2*math.Pi {
+		normalizedAngle -= 2 * math.Pi
+	}
+
+	// 计算在扇区内的位置
+	segmentIndex := int(normalizedAngle / segmentAngle)
+	angleInSegment := normalizedAngle - float64(segmentIndex)*segmentAngle
+
+	// 根据角度位置计算最大距离
+	centerOffset := math.Abs(angleInSegment - halfAngle)
+	maxDist := innerRadius + (outerRadius-innerRadius)*(1-centerOffset/halfAngle)
+
+	return dist <= maxDist
+}
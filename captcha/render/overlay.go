@@ -0,0 +1,179 @@
+package render
+
+import (
+	"image"
+	"image/draw"
+)
+
+// 缺口遮罩层中每个mask像素的处理方式
+const (
+	layerOutside uint8 = iota // mask外，保持原样
+	layerTint                 // 白色遮罩
+	layerEdge                 // 描边（holeBorder）
+)
+
+// DefaultHoleTint 默认的白色遮罩强度
+const DefaultHoleTint = 0.4
+
+// tintTable 默认强度白色遮罩的查表结果，与背景内容无关，只计算一次
+var tintTable = newTintTable(DefaultHoleTint)
+
+// newTintTable 计算强度为 strength 的白色遮罩查表结果，下标为 [通道][原值]；红色通道的强度为 1.25 倍，遮罩略偏暖
+func newTintTable(strength float64) *[3][256]uint8 {
+	var t [3][256]uint8
+	weights := [3]float64{strength * 1.25, strength, strength}
+	for c, w := range weights {
+		w = min(max(w, 0), 1)
+		for v := range 256 {
+			t[c][v] = uint8(float64(v)*(1-w) + 255*w)
+		}
+	}
+	return &t
+}
+
+// HoleStyle 缺口的外观，每个验证码使用不同的外观时机器无法依赖固定的像素特征（如固定比例的白色遮罩）定位缺口
+type HoleStyle struct {
+	// Tint 白色遮罩的强度（0-1），越大缺口越白、越明显，默认 DefaultHoleTint
+	Tint float64
+	// Border 是否在缺口边缘描边，不描边时边缘与内部一样只加白色遮罩
+	Border bool
+	// Blur 缺口区域的模糊参数
+	Blur Gaussian
+}
+
+// HoleOverlay 预先计算的缺口效果：白色遮罩和描边的位置只取决于mask，模糊核只取决于模糊参数，与背景内容无关
+// 每种形状创建一次后可以在任意背景图、任意位置反复使用（并发安全），打缺口时在一次模糊中同时完成遮罩、描边和模糊，
+// 结果与依次调用 Lighten、OutlineHole、BlurHole 完全相同
+type HoleOverlay struct {
+	mask   *image.Alpha
+	layer  []uint8 // 每个mask像素的处理方式，按行存储，下标为 y*w+x
+	w, h   int
+	blur   Gaussian
+	kernel []float64 // 高斯模糊的一维核（盒式模糊或不模糊时为nil）
+	tint   *[3][256]uint8
+	border bool
+}
+
+// NewHoleOverlay 按mask和模糊参数预先计算缺口效果，使用默认强度的白色遮罩并描边
+func NewHoleOverlay(mask *image.Alpha, g Gaussian) *HoleOverlay {
+	m := alphaView(mask)
+	o := &HoleOverlay{mask: mask, layer: make([]uint8, m.w*m.h), w: m.w, h: m.h, blur: g, tint: tintTable, border: true}
+	for y := 0; y < m.h; y++ {
+		for x := 0; x < m.w; x++ {
+			switch {
+			case m.at(x, y) == 0:
+			case m.edge(x, y):
+				o.layer[y*m.w+x] = layerEdge
+			default:
+				o.layer[y*m.w+x] = layerTint
+			}
+		}
+	}
+	if g.Radius > 0 && !g.Box {
+		o.kernel = g.kernel()
+	}
+	return o
+}
+
+// WithStyle 返回使用另一种外观的缺口效果，共用已经计算好的mask处理方式，适合每个验证码随机选择外观
+func (o *HoleOverlay) WithStyle(style HoleStyle) *HoleOverlay {
+	styled := *o
+	styled.tint = newTintTable(style.Tint)
+	styled.border = style.Border
+	styled.blur = style.Blur
+	styled.kernel = nil
+	if style.Blur.Radius > 0 && !style.Blur.Box {
+		styled.kernel = style.Blur.kernel()
+	}
+	return &styled
+}
+
+// Mask 返回创建时使用的mask
+func (o *HoleOverlay) Mask() *image.Alpha {
+	return o.mask
+}
+
+// Punch 返回在 (x, y) 处打出缺口的背景图副本，不修改 bg
+func (o *HoleOverlay) Punch(bg image.Image, x, y int) *image.RGBA {
+	result := image.NewRGBA(bg.Bounds())
+	draw.Draw(result, result.Bounds(), bg, bg.Bounds().Min, draw.Src)
+	o.Apply(result, x, y)
+	return result
+}
+
+// Apply 在画布上 (x, y) 处打出缺口
+func (o *HoleOverlay) Apply(img *image.RGBA, x, y int) {
+	width, height := img.Rect.Dx(), img.Rect.Dy()
+	region := image.Rect(x, y, x+o.w, y+o.h).Intersect(image.Rect(0, 0, width, height))
+	if region.Empty() {
+		return
+	}
+	if o.kernel == nil {
+		// 盒式模糊需要读取整块区域，先就地叠加遮罩层再模糊
+		o.blend(img, x, y, region)
+		if o.blur.Radius > 0 {
+			boxBlurHole(img, alphaView(o.mask), x, y, region, o.blur)
+		}
+		return
+	}
+	r := o.blur.Radius
+
+	// 水平方向：每行先把遮罩层叠加到缺口左右各扩展半径的像素上（不写回画布），再做一维卷积，结果按 RGB 存入 rows
+	minX, maxX := max(region.Min.X-r, 0), min(region.Max.X+r, width)
+	minY, maxY := max(region.Min.Y-r, 0), min(region.Max.Y+r, height)
+	line := make([]uint8, (maxX-minX)*3)
+	rowStride := region.Dx() * 3
+	rows := make([]float64, (maxY-minY)*rowStride)
+	for py := minY; py < maxY; py++ {
+		src := img.Pix[py*img.Stride:]
+		for px := minX; px < maxX; px++ {
+			c := src[px*4 : px*4+3 : px*4+3]
+			l := line[(px-minX)*3 : (px-minX)*3+3 : (px-minX)*3+3]
+			l[0], l[1], l[2] = c[0], c[1], c[2]
+		}
+		if my := py - y; my >= 0 && my < o.h {
+			from, to := max(x, minX), min(x+o.w, maxX)
+			if from < to {
+				tintRow(line[(from-minX)*3:(to-minX)*3], src[from*4:to*4], o.layer[my*o.w+from-x:my*o.w+to-x], o.tint, o.border)
+			}
+		}
+		for px := region.Min.X; px < region.Max.X; px++ {
+			var sumR, sumG, sumB float64
+			for k, weight := range o.kernel {
+				c := line[(min(max(px+k-r, 0), width-1)-minX)*3:]
+				sumR += float64(c[0]) * weight
+				sumG += float64(c[1]) * weight
+				sumB += float64(c[2]) * weight
+			}
+			t := rows[(py-minY)*rowStride+(px-region.Min.X)*3:]
+			t[0], t[1], t[2] = sumR, sumG, sumB
+		}
+	}
+
+	// 垂直方向：只写回mask内的像素
+	for py := region.Min.Y; py < region.Max.Y; py++ {
+		for px := region.Min.X; px < region.Max.X; px++ {
+			if o.layer[(py-y)*o.w+px-x] == layerOutside {
+				continue
+			}
+			var sumR, sumG, sumB float64
+			for k, weight := range o.kernel {
+				t := rows[(min(max(py+k-r, 0), height-1)-minY)*rowStride+(px-region.Min.X)*3:]
+				sumR += t[0] * weight
+				sumG += t[1] * weight
+				sumB += t[2] * weight
+			}
+			i := py*img.Stride + px*4
+			p := img.Pix[i : i+4 : i+4]
+			p[0], p[1], p[2], p[3] = roundByte(sumR), roundByte(sumG), roundByte(sumB), 255
+		}
+	}
+}
+
+// blend 就地叠加遮罩层：mask内的像素加白色遮罩，边缘描黑
+func (o *HoleOverlay) blend(img *image.RGBA, x, y int, region image.Rectangle) {
+	for py := region.Min.Y; py < region.Max.Y; py++ {
+		row, l := py*img.Stride, (py-y)*o.w-x
+		blendRow(img.Pix[row+region.Min.X*4:row+region.Max.X*4], o.layer[l+region.Min.X:l+region.Max.X], o.tint, o.border)
+	}
+}
@@ -0,0 +1,242 @@
+// Package render 拼图验证码的图像处理工具：生成mask、缩放背景图、在背景图上打出缺口、按mask切出拼图块，
+// 以及边框、抗锯齿、高光、模糊等滤镜
+//
+// captcha 包使用同样的函数生成滑块验证码，需要定制验证码样式（如不同的缺口效果、旋转拼图）时可以直接组合这些函数：
+//
+//	mask := render.GenerateMask(render.Star, 60, 60)
+//	canvas := render.Resize(background, 350, 200)
+//	bg := render.PunchHole(canvas, x, y, mask)
+//	piece := render.CutPiece(canvas, x, y, mask)
+package render
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"runtime"
+	"sync"
+)
+
+// PunchHole 返回在 (x, y) 处打出缺口的背景图副本：白色遮罩、描边并模糊缺口边缘，不修改 bg
+// 同一mask反复打缺口时应使用 NewHoleOverlay 预先计算一次，再调用 HoleOverlay.Punch
+func PunchHole(bg image.Image, x, y int, mask *image.Alpha) *image.RGBA {
+	return NewHoleOverlay(mask, HoleBlur).Punch(bg, x, y)
+}
+
+// CutPiece 按mask从背景图 (x, y) 处切出拼图块：添加白色边框、高光并按 PieceBlur 模糊边缘，mask 外的像素透明
+func CutPiece(bg image.Image, x, y int, mask *image.Alpha) *image.RGBA {
+	return CutPieceWithBlur(bg, x, y, mask, PieceBlur)
+}
+
+// CutPieceWithBlur 与 CutPiece 相同，但按 blur 模糊边缘，不读取 PieceBlur
+func CutPieceWithBlur(bg image.Image, x, y int, mask *image.Alpha, blur Gaussian) *image.RGBA {
+	piece := image.NewRGBA(image.Rect(0, 0, mask.Rect.Dx(), mask.Rect.Dy()))
+
+	// 背景图通常是 Resize 得到的 *image.RGBA，直接复制 Pix；其他类型逐像素转换
+	src, fast := bg.(*image.RGBA)
+	fast = fast && src.Rect.Min == image.Point{}
+	bounds := bg.Bounds()
+	m := alphaView(mask)
+	for py := 0; py < m.h; py++ {
+		srcY := y + py
+		if srcY < 0 || srcY >= bounds.Dy() {
+			continue
+		}
+		for px := 0; px < m.w; px++ {
+			srcX := x + px
+			if m.at(px, py) == 0 || srcX < 0 || srcX >= bounds.Dx() {
+				continue
+			}
+			i := py*piece.Stride + px*4
+			if fast {
+				j := srcY*src.Stride + srcX*4
+				copy(piece.Pix[i:i+4], src.Pix[j:j+4])
+				continue
+			}
+			c := color.RGBAModel.Convert(bg.At(srcX, srcY)).(color.RGBA)
+			piece.Pix[i], piece.Pix[i+1], piece.Pix[i+2], piece.Pix[i+3] = c.R, c.G, c.B, c.A
+		}
+	}
+
+	Border(piece, mask)
+	Highlight(piece, mask)
+	Blur(piece, mask, blur)
+	return piece
+}
+
+// Resize 缩放图片到指定尺寸（使用双线性插值，更平滑）
+func Resize(src image.Image, width, height int) image.Image {
+	// 创建目标尺寸的图像
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+
+	// 使用双线性插值进行缩放
+	srcBounds := src.Bounds()
+	srcW := srcBounds.Dx()
+	srcH := srcBounds.Dy()
+
+	at := resizeSource{img: src}
+	if rgba, ok := src.(*image.RGBA); ok && srcBounds.Min == (image.Point{}) {
+		at.rgba = rgba
+	}
+
+	// 按水平条带并发缩放，每个协程负责连续的若干行；目标图较小时协程开销大于收益，直接在当前协程完成
+	bands := min(runtime.GOMAXPROCS(0), width*height/resizeBandPixels, height)
+	if bands <= 1 {
+		resizeBand(dst, at, srcW, srcH, 0, height)
+		return dst
+	}
+	var wg sync.WaitGroup
+	for i := range bands {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resizeBand(dst, at, srcW, srcH, height*i/bands, height*(i+1)/bands)
+		}()
+	}
+	wg.Wait()
+	return dst
+}
+
+// resizeBandPixels 并发缩放时每个条带至少包含的目标像素数
+const resizeBandPixels = 32 * 1024
+
+// resizeSource 读取缩放源图像素的16位分量，原点为 (0, 0) 的 RGBA 图片直接读 Pix，避免 At 每次把颜色装箱为接口
+type resizeSource struct {
+	img  image.Image
+	rgba *image.RGBA // 非nil时使用快速路径
+}
+
+// at 返回 (x, y) 处像素的16位分量
+func (s resizeSource) at(x, y int) (r, g, b, a uint32) {
+	if s.rgba == nil {
+		return s.img.At(x, y).RGBA()
+	}
+	p := s.rgba.Pix[y*s.rgba.Stride+x*4:]
+	return uint32(p[0]) * 0x101, uint32(p[1]) * 0x101, uint32(p[2]) * 0x101, uint32(p[3]) * 0x101
+}
+
+// resizeBand 按双线性插值计算 dst 中 [y0, y1) 行的像素
+func resizeBand(dst *image.RGBA, src resizeSource, srcW, srcH, y0, y1 int) {
+	width, height := dst.Rect.Dx(), dst.Rect.Dy()
+	for y := y0; y < y1; y++ {
+		for x := 0; x < width; x++ {
+			// 计算源图像中的对应位置（浮点坐标）
+			srcX := float64(x) * float64(srcW) / float64(width)
+			srcY := float64(y) * float64(srcH) / float64(height)
+
+			// 双线性插值
+			x0 := int(srcX)
+			y0 := int(srcY)
+			x1 := x0 + 1
+			y1 := y0 + 1
+
+			// 边界检查
+			if x1 >= srcW {
+				x1 = srcW - 1
+			}
+			if y1 >= srcH {
+				y1 = srcH - 1
+			}
+
+			// 获取四个邻近像素
+			r00, g00, b00, a00 := src.at(x0, y0)
+			r01, g01, b01, a01 := src.at(x0, y1)
+			r10, g10, b10, a10 := src.at(x1, y0)
+			r11, g11, b11, a11 := src.at(x1, y1)
+
+			// 计算插值权重
+			fx := srcX - float64(x0)
+			fy := srcY - float64(y0)
+
+			// 混合权重 (0-65535)
+			wx := uint32(fx * 65535)
+			wy := uint32(fy * 65535)
+			wxInv := 65535 - wx
+			wyInv := 65535 - wy
+
+			// 双线性插值
+			r := (r00*wxInv+r10*wx)/65535*wyInv/65535 + (r01*wxInv+r11*wx)/65535*wy/65535
+			g := (g00*wxInv+g10*wx)/65535*wyInv/65535 + (g01*wxInv+g11*wx)/65535*wy/65535
+			b := (b00*wxInv+b10*wx)/65535*wyInv/65535 + (b01*wxInv+b11*wx)/65535*wy/65535
+			a := (a00*wxInv+a10*wx)/65535*wyInv/65535 + (a01*wxInv+a11*wx)/65535*wy/65535
+
+			// 转换为8位并设置像素
+			dst.SetRGBA(x, y, color.RGBA{
+				R: uint8(r >> 8),
+				G: uint8(g >> 8),
+				B: uint8(b >> 8),
+				A: uint8(a >> 8),
+			})
+		}
+	}
+}
+
+// Texture 返回区域 r 的纹理强度：相邻像素亮度差的平均值（0-255），天空、墙面等平坦区域接近0
+// r 超出图片的部分被忽略，为减少计算量每隔一个像素采样
+func Texture(img image.Image, r image.Rectangle) float64 {
+	r = r.Intersect(img.Bounds())
+	if r.Dx() < 2 || r.Dy() < 2 {
+		return 0
+	}
+
+	var sum float64
+	var count int
+	for y := r.Min.Y; y < r.Max.Y-1; y += 2 {
+		for x := r.Min.X; x < r.Max.X-1; x += 2 {
+			l := luminance(img.At(x, y))
+			sum += math.Abs(l-luminance(img.At(x+1, y))) + math.Abs(l-luminance(img.At(x, y+1)))
+			count += 2
+		}
+	}
+	return sum / float64(count)
+}
+
+// LuminanceMap 图片每个像素的亮度（0-255），预先计算后可以反复查询不同区域的纹理强度
+type LuminanceMap struct {
+	Pix    []float32
+	Stride int
+	Rect   image.Rectangle
+}
+
+// Luminance 计算图片每个像素的亮度
+func Luminance(img image.Image) *LuminanceMap {
+	b := img.Bounds()
+	m := &LuminanceMap{Pix: make([]float32, b.Dx()*b.Dy()), Stride: b.Dx(), Rect: b}
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		row := m.Pix[(y-b.Min.Y)*m.Stride:]
+		for x := b.Min.X; x < b.Max.X; x++ {
+			row[x-b.Min.X] = float32(luminance(img.At(x, y)))
+		}
+	}
+	return m
+}
+
+// at 返回 (x, y) 处的亮度
+func (m *LuminanceMap) at(x, y int) float64 {
+	return float64(m.Pix[(y-m.Rect.Min.Y)*m.Stride+x-m.Rect.Min.X])
+}
+
+// Texture 与 Texture 函数相同的区域纹理强度，直接读取预先计算的亮度
+func (m *LuminanceMap) Texture(r image.Rectangle) float64 {
+	r = r.Intersect(m.Rect)
+	if r.Dx() < 2 || r.Dy() < 2 {
+		return 0
+	}
+
+	var sum float64
+	var count int
+	for y := r.Min.Y; y < r.Max.Y-1; y += 2 {
+		for x := r.Min.X; x < r.Max.X-1; x += 2 {
+			l := m.at(x, y)
+			sum += math.Abs(l-m.at(x+1, y)) + math.Abs(l-m.at(x, y+1))
+			count += 2
+		}
+	}
+	return sum / float64(count)
+}
+
+// luminance 像素亮度（0-255）
+func luminance(c color.Color) float64 {
+	r, g, b, _ := c.RGBA()
+	return (0.299*float64(r) + 0.587*float64(g) + 0.114*float64(b)) / 257
+}
@@ -0,0 +1,111 @@
+package render
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"testing"
+)
+
+// benchCanvas 画布尺寸的渐变背景
+func benchCanvas() *image.RGBA {
+	canvas := image.NewRGBA(image.Rect(0, 0, 350, 200))
+	for y := 0; y < 200; y++ {
+		for x := 0; x < 350; x++ {
+			canvas.SetRGBA(x, y, color.RGBA{uint8(x), uint8(y), uint8(x ^ y), 255})
+		}
+	}
+	return canvas
+}
+
+// BenchmarkPunchHole 在画布上打出缺口（遮罩、描边和缺口模糊）
+func BenchmarkPunchHole(b *testing.B) {
+	canvas := benchCanvas()
+	mask := GenerateMask(Star, 70, 70)
+	b.ReportAllocs()
+	for b.Loop() {
+		PunchHole(canvas, 150, 60, mask)
+	}
+}
+
+// BenchmarkHoleOverlay 在画布上就地打出缺口（不含复制画布），对比 -tags fastblend 时遮罩层的耗时
+func BenchmarkHoleOverlay(b *testing.B) {
+	mask := GenerateMask(Star, 70, 70)
+	for _, g := range []Gaussian{{}, HoleBlur, {Radius: HoleBlur.Radius, Box: true}} {
+		b.Run(fmt.Sprintf("radius=%d,box=%t", g.Radius, g.Box), func(b *testing.B) {
+			canvas := benchCanvas()
+			overlay := NewHoleOverlay(mask, g)
+			b.ReportAllocs()
+			for b.Loop() {
+				overlay.Apply(canvas, 150, 60)
+			}
+		})
+	}
+}
+
+// BenchmarkCutPiece 切出拼图块（边框、抗锯齿、高光和模糊）
+func BenchmarkCutPiece(b *testing.B) {
+	canvas := benchCanvas()
+	mask := GenerateMask(Star, 70, 70)
+	b.ReportAllocs()
+	for b.Loop() {
+		CutPiece(canvas, 150, 60, mask)
+	}
+}
+
+// BenchmarkBlur 不同半径的拼图块模糊，耗时应随半径线性增长
+func BenchmarkBlur(b *testing.B) {
+	canvas := benchCanvas()
+	mask := GenerateMask(Star, 70, 70)
+	for _, radius := range []int{1, 2, 4, 8} {
+		b.Run(fmt.Sprintf("radius=%d", radius), func(b *testing.B) {
+			piece := CutPiece(canvas, 150, 60, mask)
+			b.ReportAllocs()
+			for b.Loop() {
+				Blur(piece, mask, Gaussian{Radius: radius})
+			}
+		})
+	}
+}
+
+// BenchmarkBlurBox 对比高斯模糊与三次盒式模糊近似的耗时，盒式模糊额外报告与高斯模糊结果的平均像素差（diff/px）
+func BenchmarkBlurBox(b *testing.B) {
+	canvas := benchCanvas()
+	mask := GenerateMask(Star, 70, 70)
+	for _, radius := range []int{2, 8, 16} {
+		exact := CutPiece(canvas, 150, 60, mask)
+		Blur(exact, mask, Gaussian{Radius: radius})
+
+		for _, box := range []bool{false, true} {
+			name := fmt.Sprintf("gaussian/radius=%d", radius)
+			if box {
+				name = fmt.Sprintf("box/radius=%d", radius)
+			}
+			b.Run(name, func(b *testing.B) {
+				g := Gaussian{Radius: radius, Box: box}
+				piece := CutPiece(canvas, 150, 60, mask)
+				Blur(piece, mask, g)
+				diff := meanDiff(exact, piece)
+
+				b.ReportAllocs()
+				for b.Loop() {
+					Blur(piece, mask, g)
+				}
+				b.ReportMetric(diff, "diff/px")
+			})
+		}
+	}
+}
+
+// meanDiff 两张同尺寸图片 RGB 通道的平均绝对差
+func meanDiff(a, b *image.RGBA) float64 {
+	total := 0
+	for i := range a.Pix {
+		if i%4 == 3 {
+			continue
+		}
+		d := int(a.Pix[i]) - int(b.Pix[i])
+		total += max(d, -d)
+	}
+	return float64(total) / float64(len(a.Pix)/4*3)
+}
@@ -0,0 +1,212 @@
+package captcha
+
+import (
+	"container/list"
+	"sync"
+	"sync/atomic"
+)
+
+// RenderCacheConfig 渲染结果缓存的配置
+// 背景图很少的部署中，同一背景图、形状和相近位置的验证码会反复出现，缓存渲染好的背景图和滑块图可以跳过整个渲染流程
+// 启用后缺口位置按 Bucket 取整，可能的答案只有 背景图数 x 形状数 x 位置桶数 种，Bucket 越大命中率越高、答案空间越小
+type RenderCacheConfig struct {
+	// Size 最多缓存的渲染结果数量，超过后淘汰最久未使用的（0表示不启用）
+	Size int
+	// Bucket 随机缺口位置取整的步长（像素），默认 DefaultRenderCacheBucket
+	Bucket int
+}
+
+// DefaultRenderCacheBucket 默认的位置取整步长（像素）
+const DefaultRenderCacheBucket = 8
+
+// RenderCacheStats 渲染缓存统计
+type RenderCacheStats struct {
+	Hits    uint64  `json:"hits"`    // 命中缓存的渲染次数
+	Misses  uint64  `json:"misses"`  // 未命中、重新渲染的次数
+	Entries int     `json:"entries"` // 当前缓存的渲染结果数量
+	HitRate float64 `json:"hitRate"` // 命中次数占渲染总数的比例
+}
+
+// renderKey 渲染结果的缓存键，bg 为预加载的背景图本身，更换背景图后旧的键不会再被命中
+type renderKey struct {
+	bg          *preparedBackground
+	shape       PuzzleType
+	x, y        int
+	pieceWidth  int
+	pieceHeight int
+	decoys      int
+}
+
+// renderCache 渲染结果缓存，渲染好的图片按 renderKey 缓存
+type renderCache struct {
+	bucket int
+
+	mu      sync.Mutex
+	results *lruCache[renderKey, *renderedChallenge]
+
+	hits   atomic.Uint64
+	misses atomic.Uint64
+}
+
+// newRenderCache 创建渲染缓存，cfg.Size 不大于0时返回nil
+func newRenderCache(cfg RenderCacheConfig) *renderCache {
+	if cfg.Size <= 0 {
+		return nil
+	}
+	if cfg.Bucket <= 0 {
+		cfg.Bucket = DefaultRenderCacheBucket
+	}
+	return &renderCache{
+		bucket:  cfg.Bucket,
+		results: newLRUCache[renderKey, *renderedChallenge](cfg.Size),
+	}
+}
+
+// quantize 把随机的缺口位置向下取整到 bucket 的倍数，未启用时原样返回
+func (c *renderCache) quantize(x, y int) (int, int) {
+	if c == nil {
+		return x, y
+	}
+	return x - x%c.bucket, y - y%c.bucket
+}
+
+// get 查询渲染结果，未启用或未命中时返回nil
+func (c *renderCache) get(key renderKey) *renderedChallenge {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	challenge, ok := c.results.get(key)
+	c.mu.Unlock()
+	if !ok {
+		c.misses.Add(1)
+		return nil
+	}
+	c.hits.Add(1)
+	return challenge
+}
+
+// put 缓存渲染结果
+func (c *renderCache) put(key renderKey, challenge *renderedChallenge) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	c.results.put(key, challenge)
+	c.mu.Unlock()
+}
+
+// reset 丢弃全部缓存（背景图更换后调用）
+func (c *renderCache) reset() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	c.results.clear()
+	c.mu.Unlock()
+}
+
+// stats 返回缓存统计
+func (c *renderCache) stats() RenderCacheStats {
+	if c == nil {
+		return RenderCacheStats{}
+	}
+	c.mu.Lock()
+	entries := c.results.len()
+	c.mu.Unlock()
+
+	stats := RenderCacheStats{Hits: c.hits.Load(), Misses: c.misses.Load(), Entries: entries}
+	if total := stats.Hits + stats.Misses; total > 0 {
+		stats.HitRate = float64(stats.Hits) / float64(total)
+	}
+	return stats
+}
+
+// lruCache 容量固定的LRU缓存，不是并发安全的，由调用方加锁
+type lruCache[K comparable, V any] struct {
+	size  int
+	order *list.List // 最近使用的在前，元素值为 *lruEntry
+	items map[K]*list.Element
+}
+
+// lruEntry LRU缓存中的一项
+type lruEntry[K comparable, V any] struct {
+	key   K
+	value V
+}
+
+// newLRUCache 创建最多保存 size 项的LRU缓存
+func newLRUCache[K comparable, V any](size int) *lruCache[K, V] {
+	return &lruCache[K, V]{size: size, order: list.New(), items: make(map[K]*list.Element)}
+}
+
+// get 查询并标记为最近使用
+func (l *lruCache[K, V]) get(key K) (V, bool) {
+	elem, ok := l.items[key]
+	if !ok {
+		var zero V
+		return zero, false
+	}
+	l.order.MoveToFront(elem)
+	return elem.Value.(*lruEntry[K, V]).value, true
+}
+
+// put 写入一项，超过容量时淘汰最久未使用的一项
+func (l *lruCache[K, V]) put(key K, value V) {
+	if elem, ok := l.items[key]; ok {
+		elem.Value.(*lruEntry[K, V]).value = value
+		l.order.MoveToFront(elem)
+		return
+	}
+	l.items[key] = l.order.PushFront(&lruEntry[K, V]{key: key, value: value})
+	if l.order.Len() > l.size {
+		oldest := l.order.Back()
+		l.order.Remove(oldest)
+		delete(l.items, oldest.Value.(*lruEntry[K, V]).key)
+	}
+}
+
+// oldest 返回最久未使用的一项，缓存为空时返回 false
+func (l *lruCache[K, V]) oldest() (K, V, bool) {
+	elem := l.order.Back()
+	if elem == nil {
+		var key K
+		var value V
+		return key, value, false
+	}
+	entry := elem.Value.(*lruEntry[K, V])
+	return entry.key, entry.value, true
+}
+
+// remove 删除一项
+func (l *lruCache[K, V]) remove(key K) {
+	if elem, ok := l.items[key]; ok {
+		l.order.Remove(elem)
+		delete(l.items, key)
+	}
+}
+
+// len 返回缓存项数量
+func (l *lruCache[K, V]) len() int {
+	return l.order.Len()
+}
+
+// clear 清空缓存
+func (l *lruCache[K, V]) clear() {
+	l.order.Init()
+	clear(l.items)
+}
+
+// WithRenderCache 启用渲染结果缓存：随机缺口位置按 cfg.Bucket 取整，按（背景图、形状、位置、拼图块尺寸、干扰缺口数量）
+// 缓存渲染好的图片，最多 cfg.Size 个，重复出现的组合直接复用；适合背景图很少的部署，默认不启用
+// 每个验证码仍使用独立的ID和存储，但同一组合的图片完全相同，答案空间也随取整变小，背景图较多时收益有限
+func WithRenderCache(cfg RenderCacheConfig) Option {
+	return func(s *CaptchaService) {
+		s.renderCache = newRenderCache(cfg)
+	}
+}
+
+// RenderCacheStats 返回渲染缓存统计，未启用时全部为0
+func (s *CaptchaService) RenderCacheStats() RenderCacheStats {
+	return s.renderCache.stats()
+}
@@ -0,0 +1,222 @@
+package captcha
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net/netip"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Reputation 来源IP的信誉等级
+type Reputation int
+
+const (
+	ReputationNeutral    Reputation = iota // 无不良记录
+	ReputationSuspicious                   // 可疑（如数据中心、匿名代理）：生成时至少使用提升难度
+	ReputationMalicious                    // 恶意（如滥用黑名单）：生成时使用高难度，验证时计入风险分
+)
+
+// String 返回信誉等级名称
+func (r Reputation) String() string {
+	switch r {
+	case ReputationNeutral:
+		return "neutral"
+	case ReputationSuspicious:
+		return "suspicious"
+	case ReputationMalicious:
+		return "malicious"
+	default:
+		return "unknown"
+	}
+}
+
+// MinDifficulty 返回该信誉等级至少应使用的难度
+func (r Reputation) MinDifficulty() DifficultyLevel {
+	switch {
+	case r >= ReputationMalicious:
+		return DifficultyHigh
+	case r == ReputationSuspicious:
+		return DifficultyElevated
+	default:
+		return DifficultyNormal
+	}
+}
+
+// IPReputation IP信誉数据源，生成和验证时查询，可接入 MaxMind、滥用情报等数据，对高风险网络强制使用更难的验证码
+type IPReputation interface {
+	// Lookup 返回IP的信誉等级，查询失败时服务按 ReputationNeutral 处理
+	Lookup(ctx context.Context, ip string) (Reputation, error)
+}
+
+// AllowAll 不做任何限制的信誉数据源，所有IP都返回 ReputationNeutral
+type AllowAll struct{}
+
+// Lookup 总是返回 ReputationNeutral
+func (AllowAll) Lookup(ctx context.Context, ip string) (Reputation, error) {
+	return ReputationNeutral, nil
+}
+
+// Blocklist 基于IP和网段列表的信誉数据源，查询时取匹配的条目中最高的等级，未匹配的IP为 ReputationNeutral
+type Blocklist struct {
+	mu sync.RWMutex
+	// prefixes 按前缀长度分组的网段，查询时每种长度只需一次 map 查找
+	prefixes map[int]map[netip.Prefix]Reputation
+	path     string
+}
+
+// NewBlocklist 创建空的黑名单，用 Add 添加条目
+func NewBlocklist() *Blocklist {
+	return &Blocklist{prefixes: make(map[int]map[netip.Prefix]Reputation)}
+}
+
+// NewFileBlocklist 从文件加载黑名单，每行一个IP或CIDR，可在其后用空白分隔指定等级 suspicious 或 malicious（默认 malicious），
+// # 开头的行和空行忽略：
+//
+//	203.0.113.0/24
+//	198.51.100.7 suspicious
+//	2001:db8::/32 malicious
+func NewFileBlocklist(path string) (*Blocklist, error) {
+	b := NewBlocklist()
+	b.path = path
+	if err := b.Reload(); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// Reload 重新读取 NewFileBlocklist 的文件，读取失败时保留原有条目；不是从文件创建的黑名单不做任何操作
+func (b *Blocklist) Reload() error {
+	if b.path == "" {
+		return nil
+	}
+	f, err := os.Open(b.path)
+	if err != nil {
+		return fmt.Errorf("failed to open blocklist: %w", err)
+	}
+	defer f.Close()
+
+	loaded := NewBlocklist()
+	scanner := bufio.NewScanner(f)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		level := ReputationMalicious
+		if len(fields) > 1 {
+			switch fields[1] {
+			case "suspicious":
+				level = ReputationSuspicious
+			case "malicious":
+			default:
+				return fmt.Errorf("invalid blocklist line %d: unknown level %q", lineNo, fields[1])
+			}
+		}
+		if err := loaded.Add(fields[0], level); err != nil {
+			return fmt.Errorf("invalid blocklist line %d: %w", lineNo, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read blocklist: %w", err)
+	}
+
+	b.mu.Lock()
+	b.prefixes = loaded.prefixes
+	b.mu.Unlock()
+	return nil
+}
+
+// Add 添加一个IP或CIDR，同一网段重复添加时保留较高的等级
+func (b *Blocklist) Add(entry string, level Reputation) error {
+	var prefix netip.Prefix
+	if strings.Contains(entry, "/") {
+		p, err := netip.ParsePrefix(entry)
+		if err != nil {
+			return err
+		}
+		prefix = p.Masked()
+	} else {
+		addr, err := netip.ParseAddr(entry)
+		if err != nil {
+			return err
+		}
+		addr = addr.Unmap()
+		prefix = netip.PrefixFrom(addr, addr.BitLen())
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	byBits := b.prefixes[prefix.Bits()]
+	if byBits == nil {
+		byBits = make(map[netip.Prefix]Reputation)
+		b.prefixes[prefix.Bits()] = byBits
+	}
+	byBits[prefix] = max(byBits[prefix], level)
+	return nil
+}
+
+// Len 返回条目数量
+func (b *Blocklist) Len() int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	n := 0
+	for _, byBits := range b.prefixes {
+		n += len(byBits)
+	}
+	return n
+}
+
+// Lookup 返回包含该IP的条目中最高的等级
+func (b *Blocklist) Lookup(ctx context.Context, ip string) (Reputation, error) {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return ReputationNeutral, nil
+	}
+	addr = addr.Unmap()
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	level := ReputationNeutral
+	for bits, byBits := range b.prefixes {
+		if bits > addr.BitLen() {
+			continue
+		}
+		prefix, err := addr.Prefix(bits)
+		if err != nil {
+			continue
+		}
+		if l, found := byBits[prefix]; found && l > level {
+			level = l
+		}
+	}
+	return level, nil
+}
+
+// WithIPReputation 生成和验证时查询来源IP（GenerateParams.RemoteIP、VerifyParams.RemoteIP）的信誉：
+// 生成时难度至少为 Reputation.MinDifficulty()，验证时把结果写入 VerifyParams.Reputation，
+// 默认验证器对 ReputationMalicious 的来源计入风险标记 malicious_ip。默认不查询
+func WithIPReputation(r IPReputation) Option {
+	return func(s *CaptchaService) {
+		s.reputation = r
+	}
+}
+
+// lookupReputation 查询IP信誉，未配置数据源、IP为空或查询失败时返回 ReputationNeutral
+func (s *CaptchaService) lookupReputation(ctx context.Context, ip string) Reputation {
+	if s.reputation == nil || ip == "" {
+		return ReputationNeutral
+	}
+	level, err := s.reputation.Lookup(ctx, ip)
+	if err != nil {
+		s.log().Warn("failed to look up ip reputation", "ip", ip, "error", err)
+		return ReputationNeutral
+	}
+	if level != ReputationNeutral {
+		s.metrics.Count(MetricRiskyIP, 1, Label{"reputation", level.String()})
+	}
+	return level
+}
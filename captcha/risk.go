@@ -0,0 +1,152 @@
+package captcha
+
+import (
+	"sync"
+	"time"
+)
+
+// 风险标记
+const (
+	RiskFlagMissingTrajectory     = "missing_trajectory"     // 未提交拖动轨迹
+	RiskFlagTooFewPoints          = "too_few_points"         // 轨迹采样点过少
+	RiskFlagTooFast               = "too_fast"               // 拖动耗时过短
+	RiskFlagTimeReversed          = "time_reversed"          // 时间戳非递增
+	RiskFlagZeroJitter            = "zero_jitter"            // 完全没有抖动
+	RiskFlagConstantVelocity      = "constant_velocity"      // 匀速拖动
+	RiskFlagMonotonicAcceleration = "monotonic_acceleration" // 加速度单调变化
+	RiskFlagHoneypot              = "honeypot"               // 填写了蜜罐字段
+	RiskFlagFlaggedIP             = "flagged_ip"             // 来源IP近期被标记为可疑
+	RiskFlagMaliciousIP           = "malicious_ip"           // 来源IP在信誉数据中为恶意
+)
+
+// riskWeights 各风险标记对应的分值
+var riskWeights = map[string]float64{
+	RiskFlagMissingTrajectory:     0.3,
+	RiskFlagTooFewPoints:          0.3,
+	RiskFlagTooFast:               0.5,
+	RiskFlagTimeReversed:          0.8,
+	RiskFlagZeroJitter:            0.4,
+	RiskFlagConstantVelocity:      0.3,
+	RiskFlagMonotonicAcceleration: 0.3,
+	RiskFlagHoneypot:              1.0,
+	RiskFlagFlaggedIP:             0.5,
+	RiskFlagMaliciousIP:           0.5,
+}
+
+// MinDragDuration 人类完成拖动的最短耗时（毫秒）
+var MinDragDuration int64 = 200
+
+// RiskRejectThreshold 风险分达到此值时即使位置正确也判定失败（<=0 表示不拒绝）
+var RiskRejectThreshold = 0.8
+
+// RiskAssessment 风险评估结果
+type RiskAssessment struct {
+	Score    float64             // 风险分（0-1，越高越像机器）
+	Flags    []string            // 命中的风险标记
+	Features *TrajectoryFeatures // 轨迹特征（未提交轨迹时为nil）
+}
+
+// AssessRisk 根据拖动轨迹评估风险
+func AssessRisk(points []TrajectoryPoint) *RiskAssessment {
+	risk := &RiskAssessment{}
+
+	if len(points) == 0 {
+		risk.addFlag(RiskFlagMissingTrajectory)
+		return risk
+	}
+
+	features := AnalyzeTrajectory(points)
+	risk.Features = features
+
+	if features.PointCount < minTrajectoryPoints {
+		risk.addFlag(RiskFlagTooFewPoints)
+	}
+	if features.Duration < MinDragDuration {
+		risk.addFlag(RiskFlagTooFast)
+	}
+	if features.TimeReversed {
+		risk.addFlag(RiskFlagTimeReversed)
+	}
+	if features.ZeroJitter {
+		risk.addFlag(RiskFlagZeroJitter)
+	}
+	if features.ConstantVelocity {
+		risk.addFlag(RiskFlagConstantVelocity)
+	}
+	if features.MonotonicAcceleration {
+		risk.addFlag(RiskFlagMonotonicAcceleration)
+	}
+
+	return risk
+}
+
+// HasFlag 判断是否命中某个风险标记
+func (r *RiskAssessment) HasFlag(flag string) bool {
+	for _, f := range r.Flags {
+		if f == flag {
+			return true
+		}
+	}
+	return false
+}
+
+// Rejected 判断风险分是否达到拒绝阈值
+func (r *RiskAssessment) Rejected() bool {
+	return RiskRejectThreshold > 0 && r.Score >= RiskRejectThreshold
+}
+
+// addFlag 记录风险标记并累加分值（上限为1）
+func (r *RiskAssessment) addFlag(flag string) {
+	r.Flags = append(r.Flags, flag)
+	r.Score += riskWeights[flag]
+	if r.Score > 1 {
+		r.Score = 1
+	}
+}
+
+// SuspiciousIPs 被标记为可疑的IP集合（标记在TTL后自动失效）
+type SuspiciousIPs struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	flagged map[string]time.Time
+}
+
+// NewSuspiciousIPs 创建可疑IP集合
+func NewSuspiciousIPs(ttl time.Duration) *SuspiciousIPs {
+	return &SuspiciousIPs{
+		ttl:     ttl,
+		flagged: make(map[string]time.Time),
+	}
+}
+
+// Flag 标记IP为可疑
+func (s *SuspiciousIPs) Flag(ip string) {
+	if ip == "" {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := TimeNow()
+	s.flagged[ip] = now
+
+	// 顺带清理过期标记
+	for k, flaggedAt := range s.flagged {
+		if now.Sub(flaggedAt) > s.ttl {
+			delete(s.flagged, k)
+		}
+	}
+}
+
+// IsFlagged 判断IP是否被标记为可疑
+func (s *SuspiciousIPs) IsFlagged(ip string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	flaggedAt, exists := s.flagged[ip]
+	return exists && TimeNow().Sub(flaggedAt) <= s.ttl
+}
+
+// DefaultSuspiciousIPs 默认的可疑IP集合，标记保留1小时
+var DefaultSuspiciousIPs = NewSuspiciousIPs(time.Hour)
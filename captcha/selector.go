@@ -0,0 +1,102 @@
+package captcha
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// Selector 背景图选择策略，每次生成验证码时从 n 张背景图中选出一张
+// intn 为服务的随机源（受 WithSeed、WithRand 控制），需要随机的策略应使用它以保证结果可复现
+type Selector interface {
+	// Select 返回 [0, n) 范围内的背景图序号，n 总是大于0
+	Select(n int, intn func(int) int) int
+}
+
+// UniformSelector 等概率随机选择（默认策略）
+type UniformSelector struct{}
+
+// Select 等概率随机选择一张背景图
+func (UniformSelector) Select(n int, intn func(int) int) int {
+	return intn(n)
+}
+
+// WeightedSelector 按权重随机选择，Weights[i] 为第 i 张背景图的权重
+// 未配置权重的背景图权重为1，权重为0的背景图不会被选中；全部为0时退化为等概率选择
+type WeightedSelector struct {
+	Weights []int
+}
+
+// Select 按权重随机选择一张背景图
+func (w WeightedSelector) Select(n int, intn func(int) int) int {
+	total := 0
+	for i := 0; i < n; i++ {
+		total += w.weight(i)
+	}
+	if total <= 0 {
+		return intn(n)
+	}
+
+	r := intn(total)
+	for i := 0; i < n; i++ {
+		r -= w.weight(i)
+		if r < 0 {
+			return i
+		}
+	}
+	return n - 1
+}
+
+// weight 第 i 张背景图的权重
+func (w WeightedSelector) weight(i int) int {
+	if i >= len(w.Weights) {
+		return 1
+	}
+	if w.Weights[i] < 0 {
+		return 0
+	}
+	return w.Weights[i]
+}
+
+// RoundRobinSelector 按顺序轮流选择，保证每张背景图的曝光次数一致，零值可直接使用
+type RoundRobinSelector struct {
+	next atomic.Uint64
+}
+
+// Select 选择下一张背景图
+func (r *RoundRobinSelector) Select(n int, _ func(int) int) int {
+	return int((r.next.Add(1) - 1) % uint64(n))
+}
+
+// LRUSelector 选择最久未使用的背景图，多张同样久未使用时随机选择其中一张，零值可直接使用
+// 背景图数量变化（如 SetBackgrounds）后重新计数
+type LRUSelector struct {
+	mu       sync.Mutex
+	seq      uint64
+	lastUsed []uint64
+}
+
+// Select 选择最久未使用的背景图
+func (l *LRUSelector) Select(n int, intn func(int) int) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if len(l.lastUsed) != n {
+		l.lastUsed = make([]uint64, n)
+	}
+
+	oldest := l.lastUsed[0]
+	for _, used := range l.lastUsed[1:] {
+		oldest = min(oldest, used)
+	}
+	var candidates []int
+	for i, used := range l.lastUsed {
+		if used == oldest {
+			candidates = append(candidates, i)
+		}
+	}
+
+	index := candidates[intn(len(candidates))]
+	l.seq++
+	l.lastUsed[index] = l.seq
+	return index
+}
@@ -1,138 +1,729 @@
 package captcha
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"image"
-	"image/color"
-	"image/draw"
+	"image/png"
+	"io/fs"
+	"log/slog"
 	"math/rand"
+	"runtime"
+	"slices"
 	"sync"
+	"sync/atomic"
 	"time"
 
-	"github.com/google/uuid"
+	"github.com/gpencil/photo_captcha/captcha/render"
 )
 
-// CaptchaService 验证码服务（预加载优化版）
+// CaptchaService 验证码服务，持有背景图、存储、令牌等全部状态，同一进程中可以运行多个配置不同的实例
 type CaptchaService struct {
-	// 预加载的背景图片
-	backgroundImages []image.Image
+	// verifyLocks 存储未实现 AtomicStore 时按验证码ID分段的锁，串行化本实例内对同一验证码的状态更新
+	verifyLocks [verifyLockStripes]sync.Mutex
+	// backgrounds 预加载的背景图及其预计算数据，加载或重新加载时整体替换，生成验证码时读取无需加锁
+	backgrounds atomic.Pointer[backgroundSet]
+	// cleanJPEGQuality 预计算无缺口JPEG的质量（0表示不编码）
+	cleanJPEGQuality int
+	// lazyBackgrounds 背景图首次被选中时才下载
+	lazyBackgrounds bool
+	// downloads 合并同一背景图的并发下载
+	downloads flightGroup[*preparedBackground]
+	// diskCache 远程背景图的磁盘缓存（nil表示不启用）
+	diskCache *diskCache
+	// download 下载网络背景图的下载器（nil表示使用默认参数）
+	download *downloader
+	// backgroundHashes 远程背景图URL到期望的 SHA-256（小写十六进制），见 WithBackgroundHashes
+	backgroundHashes map[string]string
+	// imagePolicy 加载背景图和拼图mask的解码策略，创建后不再修改
+	imagePolicy ImagePolicy
+	// backgroundScale 预加载的背景图最大为画布的多少倍（0表示保留原始分辨率）
+	backgroundScale float64
 	// 预生成的拼图mask
 	puzzleMasks map[PuzzleType]*image.Alpha
+	// holeOverlays 按预生成的mask预先计算的缺口效果
+	holeOverlays map[PuzzleType]*render.HoleOverlay
+	// holeBlur、pieceBlur 模糊缺口和拼图块的参数，创建时复制 render.HoleBlur、render.PieceBlur，之后不再修改
+	holeBlur  render.Gaussian
+	pieceBlur render.Gaussian
 	// 背景图片URL列表（OSS或本地）
 	backgroundURLs []string
-	// 读写锁
+	// assets 读取本地背景图和拼图mask的文件系统（nil表示操作系统文件系统）
+	assets fs.FS
+	// store 验证码存储，不使用服务的读写锁，初始化加载背景图时验证、查询状态等也不会等待
+	store atomic.Pointer[storeRef]
+	// ttl 未指定存储时新建内存存储使用的有效期
+	ttl time.Duration
+	// pieceWidth、pieceHeight 拼图块尺寸（像素）
+	pieceWidth  int
+	pieceHeight int
+	// minTexture 缺口区域的最低纹理强度（0表示不检查）
+	minTexture float64
+	// placement 缺口位置的随机范围
+	placement Placement
+	// tolerance 验证时未传入误差时使用的默认误差（像素）
+	tolerance int
+	// maxAttempts 单个验证码允许的最大失败次数（0表示使用 MaxVerifyAttempts）
+	maxAttempts int
+	// verifier 判定验证是否通过（nil表示使用 DefaultVerifier）
+	verifier Verifier
+	// reputation IP信誉数据源（nil表示不查询）
+	reputation IPReputation
+	// maxChallengeAge 验证码签发时间早于受保护操作开始时间的上限（0表示不检查）
+	maxChallengeAge time.Duration
+	// selector 背景图选择策略（nil表示等概率随机）
+	selector Selector
+	// answerSecret 允许 RevealAnswer 披露答案的密钥（为空表示不允许）
+	answerSecret string
+	// idKeys 签名验证码ID的密钥（没有密钥表示不签名）
+	idKeys keyRing
+	// holeVariation 缺口外观的随机范围（nil表示使用固定外观）
+	holeVariation *HoleVariation
+	// pngCompression 验证码图片的PNG压缩级别
+	pngCompression png.CompressionLevel
+	// debugAnswers 是否允许 DebugAnswer 返回答案和预览图（仅用于开发和端到端测试）
+	debugAnswers bool
+	// pregenSize、pregenWorkers 预生成池每种难度保持的验证码数量和补充协程数量（0表示不启用）
+	pregenSize    int
+	pregenWorkers int
+	// pool 预生成验证码池（nil表示不启用）
+	pool *pregenPool
+	// burst 突发流量复用缓存（nil表示不启用）
+	burst *burstCache
+	// renderCache 渲染结果缓存（nil表示不启用）
+	renderCache *renderCache
+	// encodedCache 编码结果缓存（nil表示不启用），encodes 合并同一编码结果的并发编码
+	encodedCache *encodedCache
+	encodes      flightGroup[[]byte]
+	// hooks 生命周期回调
+	hooks hooks
+	// metrics 监控指标
+	metrics Metrics
+	// suspiciousIPs 可疑IP集合（nil表示使用 DefaultSuspiciousIPs）
+	suspiciousIPs *SuspiciousIPs
+	// tokens 验证通过后签发的令牌
+	tokens *tokenStore
+	// clock 时间来源
+	clock Clock
+	// logger 服务内部日志（nil表示使用包级 Logger()，仅默认服务如此）
+	logger *slog.Logger
+	// rng 随机源（nil表示使用 crypto/rand）
+	rng *lockedRand
+	// 读写锁，只在读写背景图列表、预生成的mask等时短暂持有，不在加载背景图期间持有
 	mu sync.RWMutex
 	// 是否已初始化
 	initialized bool
+	// loadMu 串行化 InitContext 的加载；backgroundsVersion 在 SetBackgrounds 更换列表时递增，
+	// 加载期间列表被更换时 InitContext 丢弃加载结果后重新加载
+	loadMu             sync.Mutex
+	backgroundsVersion uint64
+	// initMu 串行化惰性初始化，initErr 记录失败原因，避免每次生成都重新加载背景图
+	initMu  sync.Mutex
+	initErr error
+	// progress 初始化进度，供 InitStatus 在初始化进行中读取
+	progress initProgress
+}
+
+// Option 验证码服务配置项
+type Option func(*CaptchaService)
+
+// WithBackgrounds 设置背景图片URL列表（OSS或本地路径），未设置时使用 BackgroundURLs
+func WithBackgrounds(urls ...string) Option {
+	return func(s *CaptchaService) {
+		s.backgroundURLs = urls
+	}
+}
+
+// WithBackgroundScale 设置预加载背景图的内存预算：加载时把超过画布 scale 倍（如 4 倍即 1400x800）的图片按比例缩小，
+// 默认 DefaultBackgroundScale；高分辨率原图按原尺寸缓存可能占用数百MB，设为0保留原始分辨率
+func WithBackgroundScale(scale float64) Option {
+	return func(s *CaptchaService) {
+		s.backgroundScale = scale
+	}
+}
+
+// WithFS 从 fsys 读取本地背景图（WithBackgrounds 中的非URL路径）和拼图mask（mask/*.png），
+// 可传入 embed.FS 把资源编译进二进制，或传入 zip 包（*zip.Reader）、容器镜像中的目录；默认读取操作系统文件系统
+//
+//	//go:embed images mask
+//	var assets embed.FS
+//	svc := captcha.NewCaptchaService(captcha.WithFS(assets), captcha.WithBackgrounds("images/bg1.jpg", "images/bg2.jpg"))
+func WithFS(fsys fs.FS) Option {
+	return func(s *CaptchaService) {
+		s.assets = fsys
+	}
+}
+
+// WithStore 设置验证码存储（如 Redis），多个服务共用同一存储时验证码ID互通
+func WithStore(store Store) Option {
+	return func(s *CaptchaService) {
+		s.store.Store(&storeRef{store: store})
+	}
+}
+
+// WithTTL 设置验证码有效期，未通过 WithStore 指定存储时为该服务新建对应有效期的内存存储，默认 DefaultTTL
+func WithTTL(ttl time.Duration) Option {
+	return func(s *CaptchaService) {
+		s.ttl = ttl
+	}
+}
+
+// WithPieceSize 设置拼图块尺寸（像素），默认 PuzzleWidth x PuzzleHeight
+func WithPieceSize(width, height int) Option {
+	return func(s *CaptchaService) {
+		s.pieceWidth = width
+		s.pieceHeight = height
+	}
+}
+
+// WithMinTexture 设置缺口区域的最低纹理强度（相邻像素亮度差的平均值，见 render.Texture），默认 DefaultMinTexture
+// 避免把缺口放在天空、墙面等平坦区域：这类区域中缺口边界对用户难以辨认，对程序却很容易识别；
+// 多次随机都找不到足够纹理的区域时使用第一次随机的位置，设为0关闭检查
+func WithMinTexture(texture float64) Option {
+	return func(s *CaptchaService) {
+		s.minTexture = texture
+	}
+}
+
+// WithFastBlur 用三次盒式模糊近似缺口和拼图块的高斯模糊（整数运算、耗时与半径无关），适合小容器、ARM设备等CPU受限的环境，
+// 边缘过渡略生硬；只影响该服务，同一进程中的其他服务不受影响。默认关闭
+func WithFastBlur(enabled bool) Option {
+	return func(s *CaptchaService) {
+		s.holeBlur.Box = enabled
+		s.pieceBlur.Box = enabled
+	}
+}
+
+// WithTolerance 设置验证时的默认误差（像素），默认 DefaultTolerance
+func WithTolerance(tolerance int) Option {
+	return func(s *CaptchaService) {
+		s.tolerance = tolerance
+	}
+}
+
+// WithMaxAttempts 设置单个验证码允许的最大失败次数，默认 MaxVerifyAttempts
+func WithMaxAttempts(n int) Option {
+	return func(s *CaptchaService) {
+		s.maxAttempts = n
+	}
+}
+
+// WithVerifier 设置判定验证是否通过的验证器，默认 DefaultVerifier
+func WithVerifier(verifier Verifier) Option {
+	return func(s *CaptchaService) {
+		s.verifier = verifier
+	}
+}
+
+// WithMaxChallengeAge 验证时提交了 VerifyParams.ActionStartedAt 的，验证码签发时间早于受保护操作开始时间超过 age 则判定失败
+// （ReasonStaleChallenge，验证码作废）：打码平台通常在业务操作开始前批量生成并解出验证码，正常用户的验证码在操作开始后才生成。
+// 配置了 WithIDSecret 时使用ID中签名的签发时间，不访问存储直接拒绝（此时不修改验证码状态）。默认不检查
+func WithMaxChallengeAge(age time.Duration) Option {
+	return func(s *CaptchaService) {
+		s.maxChallengeAge = age
+	}
+}
+
+// staleChallenge 判断签发时间为 issued 的验证码是否在受保护操作开始前过早生成
+func (s *CaptchaService) staleChallenge(issued time.Time, params VerifyParams) bool {
+	return s.maxChallengeAge > 0 && !params.ActionStartedAt.IsZero() && params.ActionStartedAt.Sub(issued) > s.maxChallengeAge
+}
+
+// WithSelector 设置背景图选择策略，默认 UniformSelector
+// 需要均衡各背景图曝光次数时可使用 RoundRobinSelector、LRUSelector 或 WeightedSelector
+func WithSelector(selector Selector) Option {
+	return func(s *CaptchaService) {
+		s.selector = selector
+	}
+}
+
+// WithAnswerSecret 设置披露答案的服务密钥，持有密钥的可信后端可通过 RevealAnswer 获取缺口位置，默认不允许披露
+func WithAnswerSecret(secret string) Option {
+	return func(s *CaptchaService) {
+		s.answerSecret = secret
+	}
+}
+
+// WithPNGCompression 设置验证码图片的PNG压缩级别，默认 png.DefaultCompression
+// PNG编码是生成中最耗时的步骤之一，png.BestSpeed 编码明显更快，代价是图片更大、响应体积增加
+func WithPNGCompression(level png.CompressionLevel) Option {
+	return func(s *CaptchaService) {
+		s.pngCompression = level
+	}
+}
+
+// WithDebugAnswers 开启调试模式，允许 DebugAnswer 直接返回缺口位置和标出答案的预览图，便于前端联调和端到端测试
+// 开启后任何拿到验证码的人都能直接得到答案，绝不能用于生产环境；默认关闭
+func WithDebugAnswers(enabled bool) Option {
+	return func(s *CaptchaService) {
+		s.debugAnswers = enabled
+	}
+}
+
+// WithPregeneration 启用预生成池：workers 个后台协程为每种难度保持 size 个渲染完成的验证码，
+// 生成时直接取出，只需分配ID和写入存储，池为空时退回同步渲染；workers 不大于0时为1
+// 指定背景图、形状、位置或拼图块尺寸的 GenerateWithOptions 不使用预生成池；
+// 与 WithSeed 同时使用时生成顺序取决于后台协程调度，结果不再可复现
+func WithPregeneration(size, workers int) Option {
+	return func(s *CaptchaService) {
+		s.pregenSize = size
+		s.pregenWorkers = workers
+	}
+}
+
+// WithSuspiciousIPs 设置可疑IP集合（蜜罐命中的IP会被标记），默认与其他服务共用 DefaultSuspiciousIPs
+func WithSuspiciousIPs(ips *SuspiciousIPs) Option {
+	return func(s *CaptchaService) {
+		s.suspiciousIPs = ips
+	}
+}
+
+// WithTokenTTL 设置验证通过后签发的令牌有效期，默认 TokenTTL
+func WithTokenTTL(ttl time.Duration) Option {
+	return func(s *CaptchaService) {
+		s.tokens.ttl = ttl
+	}
+}
+
+// WithLogger 设置服务内部日志，服务默认不输出任何日志，嵌入其他服务时不会污染调用方的日志
+//
+//	svc := captcha.NewCaptchaService(captcha.WithLogger(slog.Default()))
+func WithLogger(l *slog.Logger) Option {
+	return func(s *CaptchaService) {
+		if l == nil {
+			l = discardLogger
+		}
+		s.logger = l
+	}
+}
+
+// discardLogger 丢弃所有日志
+var discardLogger = slog.New(slog.DiscardHandler)
+
+// WithClock 设置时间来源，服务创建的内存存储和令牌过期都使用该时钟，测试时无需等待即可验证过期逻辑
+//
+//	now := time.Now()
+//	svc := captcha.NewCaptchaService(captcha.WithClock(captcha.ClockFunc(func() time.Time { return now })))
+//	now = now.Add(captcha.DefaultTTL + time.Second) // 验证码已过期
+func WithClock(clock Clock) Option {
+	return func(s *CaptchaService) {
+		s.clock = clock
+	}
+}
+
+// WithRand 设置生成验证码使用的随机源（背景图、缺口位置、形状和干扰缺口），用于测试和复现问题，默认使用 crypto/rand
+// 固定的随机源是可预测的，不要在生产环境使用；验证码ID和令牌始终使用安全随机数，不受影响
+func WithRand(r *rand.Rand) Option {
+	return func(s *CaptchaService) {
+		s.rng = &lockedRand{r: r}
+	}
+}
+
+// WithSeed 使用固定种子的随机源，相同种子、背景图和调用顺序下生成的验证码完全一致
+func WithSeed(seed int64) Option {
+	return WithRand(rand.New(rand.NewSource(seed)))
 }
 
 // NewCaptchaService 创建验证码服务实例
-func NewCaptchaService() *CaptchaService {
-	return &CaptchaService{
-		backgroundImages: make([]image.Image, 0),
-		puzzleMasks:      make(map[PuzzleType]*image.Alpha),
-		backgroundURLs:   make([]string, 0),
+//
+//	svc := captcha.NewCaptchaService(
+//		captcha.WithBackgrounds("https://oss.example.com/bg1.jpg", "https://oss.example.com/bg2.jpg"),
+//		captcha.WithTTL(2*time.Minute),
+//		captcha.WithTolerance(4),
+//	)
+//
+// 调用 Init 后使用预加载的背景图和mask生成，否则每次生成时按需加载背景图
+func NewCaptchaService(opts ...Option) *CaptchaService {
+	s := &CaptchaService{
+		puzzleMasks:     make(map[PuzzleType]*image.Alpha),
+		backgroundURLs:  make([]string, 0),
+		backgroundScale: DefaultBackgroundScale,
+		imagePolicy:     DefaultImagePolicy(),
+		holeBlur:        render.HoleBlur,
+		pieceBlur:       render.PieceBlur,
+		pieceWidth:      PuzzleWidth,
+		pieceHeight:     PuzzleHeight,
+		minTexture:      DefaultMinTexture,
+		tolerance:       DefaultTolerance,
+		clock:           SystemClock{},
+		logger:          discardLogger,
+		metrics:         nopMetrics{},
+	}
+	s.tokens = newTokenStore(&s.idKeys)
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	if s.clock == nil {
+		s.clock = SystemClock{}
+	}
+	if s.pieceWidth <= 0 || s.pieceHeight <= 0 {
+		s.pieceWidth, s.pieceHeight = PuzzleWidth, PuzzleHeight
+	}
+	if s.store.Load() == nil {
+		ttl := s.ttl
+		if ttl <= 0 {
+			ttl = DefaultTTL
+		}
+		store := NewMemoryStore(ttl)
+		store.SetClock(s.clock)
+		store.onExpire = func(id string) { s.fireExpire(context.Background(), id) }
+		s.store.Store(&storeRef{store: store, own: true})
+	}
+	s.tokens.clock = s.clock
+	if s.cleanJPEGQuality > 0 && s.encodedCache == nil {
+		s.encodedCache = newEncodedCache(EncodedCacheConfig{})
 	}
+	if s.pregenSize > 0 {
+		s.pool = newPregenPool(s, s.pregenSize, s.pregenWorkers)
+	}
+	return s
+}
+
+// defaultService 包级函数使用的默认服务，日志输出到包级 Logger()
+var defaultService = newDefaultService()
+
+// newDefaultService 创建默认服务
+func newDefaultService() *CaptchaService {
+	s := NewCaptchaService()
+	s.logger = nil
+	return s
+}
+
+// Default 返回包级函数使用的默认服务
+func Default() *CaptchaService {
+	return defaultService
 }
 
 // SetBackgroundURLs 设置背景图片URL列表
+//
+// Deprecated: 使用 NewCaptchaService(WithBackgrounds(...)) 或 SetBackgrounds
 func (s *CaptchaService) SetBackgroundURLs(urls []string) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	s.backgroundURLs = urls
 }
 
-// Init 初始化验证码服务（在服务启动时调用）
-func (s *CaptchaService) Init() error {
+// Backgrounds 返回当前背景图列表的副本
+func (s *CaptchaService) Backgrounds() []string {
+	s.mu.RLock()
+	urls := s.backgroundURLs
+	s.mu.RUnlock()
+
+	if len(urls) == 0 {
+		return defaultBackgrounds()
+	}
+	return append([]string(nil), urls...)
+}
+
+// SetBackgrounds 替换背景图列表，可在服务运行中调用（如管理接口重新加载背景图）
+// 已调用 Init 时会重新预加载背景图，加载失败时保持原列表不变
+func (s *CaptchaService) SetBackgrounds(urls []string) error {
+	urls = append([]string(nil), urls...)
+
+	s.mu.RLock()
+	initialized := s.initialized
+	s.mu.RUnlock()
+
+	var images []image.Image
+	var prepared []*preparedBackground
+	if initialized {
+		toLoad := urls
+		if len(toLoad) == 0 {
+			toLoad = defaultBackgrounds()
+		}
+		var err error
+		if images, prepared, err = s.loadPrepared(context.Background(), toLoad); err != nil {
+			return fmt.Errorf("加载背景图片失败: %w", err)
+		}
+	}
+
 	s.mu.Lock()
-	defer s.mu.Unlock()
+	s.backgroundURLs = urls
+	s.backgroundsVersion++
+	if initialized {
+		s.backgrounds.Store(&backgroundSet{images: images, prepared: prepared})
+	}
+	s.mu.Unlock()
 
-	if s.initialized {
-		return nil
+	// 更换背景图后允许重新初始化
+	s.initMu.Lock()
+	s.initErr = nil
+	s.initMu.Unlock()
+	s.progress.setErr(nil)
+
+	// 丢弃使用旧背景图预生成、待复用和缓存的验证码
+	s.pool.drain()
+	s.burst.reset()
+	s.renderCache.reset()
+	s.encodedCache.reset()
+	return nil
+}
+
+// storeRef 服务使用的验证码存储，与是否由服务创建一起原子替换
+type storeRef struct {
+	store Store
+	// own 存储由服务创建，Close 时停止其清理协程
+	own bool
+}
+
+// Store 返回服务使用的验证码存储
+func (s *CaptchaService) Store() Store {
+	return s.store.Load().store
+}
+
+// replaceStore 替换验证码存储，新存储由服务负责停止
+// 原存储为 MemoryStore 时会停止其清理协程
+func (s *CaptchaService) replaceStore(store Store) {
+	old := s.store.Swap(&storeRef{store: store, own: true}).store
+	if m, ok := old.(*MemoryStore); ok && old != store {
+		m.Stop()
 	}
+}
 
-	fmt.Println("[Captcha] 开始初始化验证码服务...")
+// Close 停止预生成池和服务创建的内存存储的后台清理协程（服务停机时调用）
+func (s *CaptchaService) Close() {
+	s.pool.stop()
 
-	// 如果没有设置URL列表，使用全局配置
-	if len(s.backgroundURLs) == 0 {
-		s.backgroundURLs = BackgroundURLs
+	ref := s.store.Load()
+	if m, ok := ref.store.(*MemoryStore); ok && ref.own {
+		m.Stop()
 	}
+}
 
-	// 1. 从OSS/本地预加载所有背景图片（只下载一次）
-	if err := s.loadBackgroundImages(); err != nil {
-		return fmt.Errorf("加载背景图片失败: %w", err)
+// log 返回服务内部日志
+func (s *CaptchaService) log() *slog.Logger {
+	if s.logger != nil {
+		return s.logger
 	}
-	fmt.Printf("[Captcha] 成功加载并缓存 %d 张背景图片\n", len(s.backgroundImages))
+	return Logger()
+}
 
-	// 2. 预生成拼图mask
-	if err := s.generatePuzzleMasks(); err != nil {
-		return fmt.Errorf("生成拼图mask失败: %w", err)
+// maxVerifyAttempts 单个验证码允许的最大失败次数
+func (s *CaptchaService) maxVerifyAttempts() int {
+	if s.maxAttempts > 0 {
+		return s.maxAttempts
 	}
-	fmt.Printf("[Captcha] 成功生成 %d 种拼图mask\n", len(s.puzzleMasks))
+	return MaxVerifyAttempts
+}
 
-	s.initialized = true
-	fmt.Println("[Captcha] 验证码服务初始化完成")
+// getVerifier 返回服务使用的验证器
+func (s *CaptchaService) getVerifier() Verifier {
+	if s.verifier != nil {
+		return s.verifier
+	}
+	// 默认验证器使用服务的可疑IP集合和日志
+	if v, ok := DefaultVerifier.(PositionVerifier); ok {
+		if s.suspiciousIPs != nil {
+			v.SuspiciousIPs = s.suspiciousIPs
+		}
+		if v.Logger == nil {
+			v.Logger = s.log()
+		}
+		return v
+	}
+	return DefaultVerifier
+}
 
-	return nil
+// suspicious 返回服务使用的可疑IP集合
+func (s *CaptchaService) suspicious() *SuspiciousIPs {
+	if s.suspiciousIPs != nil {
+		return s.suspiciousIPs
+	}
+	return DefaultSuspiciousIPs
+}
+
+// Init 预加载背景图片和拼图mask（在服务启动时调用）
+func (s *CaptchaService) Init() error {
+	return s.InitContext(context.Background())
+}
+
+// WarmUp 立即完成初始化，供希望在启动时加载背景图的调用方使用；未调用时首次生成会自动初始化
+// 初始化失败的错误会被记住，之后的生成直接返回该错误，直到 SetBackgrounds 更换背景图；
+// 因 ctx 结束而失败时不记录，下次调用会重试
+func (s *CaptchaService) WarmUp(ctx context.Context) error {
+	s.mu.RLock()
+	initialized := s.initialized
+	s.mu.RUnlock()
+	if initialized {
+		return nil
+	}
+
+	s.initMu.Lock()
+	defer s.initMu.Unlock()
+	if s.initErr != nil {
+		return s.initErr
+	}
+	err := s.InitContext(ctx)
+	if err != nil && ctx.Err() == nil {
+		s.initErr = err
+		s.progress.setErr(err)
+	}
+	return err
 }
 
-// loadBackgroundImages 从OSS或本地预加载所有背景图片（只下载一次，缓存到内存）
-func (s *CaptchaService) loadBackgroundImages() error {
-	for i, imgURL := range s.backgroundURLs {
-		// DownloadImage 会自动判断是本地文件还是OSS URL
-		img, err := DownloadImage(imgURL)
+// InitContext 预加载背景图片和拼图mask，ctx 结束时中止下载
+// 下载和解码不持有服务的读写锁，加载期间验证、查询状态等不受影响，加载完成后一次性替换
+func (s *CaptchaService) InitContext(ctx context.Context) error {
+	s.loadMu.Lock()
+	defer s.loadMu.Unlock()
+
+	s.mu.RLock()
+	initialized := s.initialized
+	s.mu.RUnlock()
+	if initialized {
+		return nil
+	}
+
+	s.log().Info("initializing captcha service")
+
+	// 1. 并发预生成拼图mask（与背景图列表无关，只生成一次）
+	masks, err := s.generatePuzzleMasks(PuzzleTypes)
+	if err != nil {
+		s.log().Warn("failed to load masks, using generated masks", "error", err)
+	}
+	overlays := make(map[PuzzleType]*render.HoleOverlay, len(masks))
+	for shapeType, mask := range masks {
+		overlays[shapeType] = render.NewHoleOverlay(mask, s.holeBlur)
+	}
+	s.log().Info("puzzle masks generated", "count", len(masks))
+
+	for {
+		s.mu.RLock()
+		urls, version := s.backgroundURLs, s.backgroundsVersion
+		s.mu.RUnlock()
+		// 如果没有设置URL列表，使用全局配置
+		if len(urls) == 0 {
+			urls = defaultBackgrounds()
+		}
+		s.progress.begin(len(urls))
+
+		// 2. 从OSS/本地预加载所有背景图片（只下载一次）
+		images, prepared, err := s.loadPrepared(ctx, urls)
 		if err != nil {
-			return fmt.Errorf("加载图片 %s 失败: %w", imgURL, err)
+			return fmt.Errorf("加载背景图片失败: %w", err)
 		}
 
-		// 缓存到内存
-		s.backgroundImages = append(s.backgroundImages, img)
+		s.mu.Lock()
+		if version != s.backgroundsVersion {
+			// 加载期间 SetBackgrounds 更换了列表，按新列表重新加载
+			s.mu.Unlock()
+			continue
+		}
+		s.backgrounds.Store(&backgroundSet{images: images, prepared: prepared})
+		s.puzzleMasks = masks
+		s.holeOverlays = overlays
+		s.initialized = true
+		s.mu.Unlock()
+
+		s.progress.ready.Store(true)
+		s.log().Info("background images loaded", "count", len(images), "bytes", imagesBytes(images)+preparedBytes(prepared))
+		s.log().Info("captcha service initialized")
+		return nil
+	}
+}
 
-		// 判断来源并输出日志
-		source := "本地"
-		if len(imgURL) > 4 && (imgURL[:4] == "http" || imgURL[:5] == "https") {
-			source = "OSS"
+// loadBackgroundImages 从OSS或本地加载所有背景图片（只下载一次，缓存到内存）
+func (s *CaptchaService) loadBackgroundImages(ctx context.Context, urls []string) ([]image.Image, error) {
+	images := make([]image.Image, 0, len(urls))
+	for i, imgURL := range urls {
+		// 网络图片按 WithDownload 的配置下载（配置了 WithDiskCache 时优先读取磁盘缓存），本地路径从 WithFS 的文件系统或磁盘读取
+		var img image.Image
+		var err error
+		switch {
+		case s.assets != nil && !isURL(imgURL):
+			img, err = loadImageFS(s.assets, imgURL, s.imagePolicy)
+		case s.diskCache != nil && isURL(imgURL):
+			img, err = s.downloadCached(ctx, imgURL)
+		case isURL(imgURL):
+			img, err = s.downloadImage(ctx, imgURL)
+		default:
+			img, err = loadImage(ctx, s.downloader(), s.imagePolicy, imgURL)
 		}
+		if err != nil {
+			return nil, fmt.Errorf("加载图片 %s 失败: %w", imgURL, err)
+		}
+		if s.backgroundScale > 0 {
+			img = fitImage(img, int(CanvasWidth*s.backgroundScale), int(CanvasHeight*s.backgroundScale))
+		}
+		images = append(images, img)
+		s.progress.advance()
 
-		fmt.Printf("[Captcha]   - 从%s加载并缓存图片 %d: %s (%dx%d)\n",
-			source, i+1, imgURL, img.Bounds().Dx(), img.Bounds().Dy())
+		s.log().Debug("background image cached",
+			"index", i+1, "url", imgURL, "width", img.Bounds().Dx(), "height", img.Bounds().Dy(), "bytes", imageBytes(img))
 	}
-	return nil
+	return images, nil
 }
 
-// generatePuzzleMasks 预生成所有拼图mask
-func (s *CaptchaService) generatePuzzleMasks() error {
-	shapeTypes := []PuzzleType{
-		PuzzleTypeTriangle,
-		PuzzleTypeHexagon,
-		PuzzleTypeTrapezoid,
-		PuzzleTypeStar,
+// BackgroundMemory 返回预加载背景图及其预计算数据（画布、亮度）占用的内存（字节），未初始化时为0
+func (s *CaptchaService) BackgroundMemory() int64 {
+	set := s.loadedBackgrounds()
+	return imagesBytes(set.images) + preparedBytes(set.prepared)
+}
+
+// newPuzzleMask 生成指定形状的mask并缩放到服务的拼图块尺寸
+func (s *CaptchaService) newPuzzleMask(shapeType PuzzleType) *image.Alpha {
+	return s.sizedPuzzleMask(shapeType, s.pieceWidth, s.pieceHeight)
+}
+
+// sizedPuzzleMask 生成指定尺寸的拼图mask，预制图片加载失败时记录日志并使用程序生成的mask
+func (s *CaptchaService) sizedPuzzleMask(shapeType PuzzleType, width, height int) *image.Alpha {
+	mask, err := s.buildPuzzleMask(shapeType, width, height)
+	if err != nil {
+		s.log().Warn("failed to load mask, using generated mask", "file", shapeType.MaskFile(), "error", err)
 	}
+	return mask
+}
 
-	for _, shapeType := range shapeTypes {
-		shape := &PuzzleShape{Type: shapeType}
-		mask := GeneratePuzzleMask(shape)
-		s.puzzleMasks[shapeType] = mask
+// buildPuzzleMask 生成指定尺寸的拼图mask，预制图片加载失败时返回程序生成的mask和加载错误
+func (s *CaptchaService) buildPuzzleMask(shapeType PuzzleType, width, height int) (*image.Alpha, error) {
+	mask, err := loadPuzzleMask(s.assets, &PuzzleShape{Type: shapeType}, s.imagePolicy)
+	if width != PuzzleWidth || height != PuzzleHeight {
+		mask = render.ScaleMask(mask, width, height)
+	}
+	return mask, err
+}
 
-		shapeName := getShapeName(shapeType)
-		fmt.Printf("[Captcha]   - 生成 %s mask\n", shapeName)
+// generatePuzzleMasks 用最多 GOMAXPROCS 个协程并发生成 types 中每种形状的拼图mask，形状增多时初始化耗时基本不变
+// 预制图片加载失败的形状使用程序生成的mask，各形状的加载错误合并后返回
+func (s *CaptchaService) generatePuzzleMasks(types []PuzzleType) (map[PuzzleType]*image.Alpha, error) {
+	masks := make([]*image.Alpha, len(types))
+	errs := make([]error, len(types))
+
+	var next atomic.Int64
+	var wg sync.WaitGroup
+	for range min(runtime.GOMAXPROCS(0), len(types)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := int(next.Add(1) - 1); i < len(types); i = int(next.Add(1) - 1) {
+				var err error
+				masks[i], err = s.buildPuzzleMask(types[i], s.pieceWidth, s.pieceHeight)
+				if err != nil {
+					errs[i] = fmt.Errorf("%s (%s): %w", types[i], types[i].MaskFile(), err)
+				}
+			}
+		}()
 	}
+	wg.Wait()
 
-	return nil
+	result := make(map[PuzzleType]*image.Alpha, len(types))
+	for i, shapeType := range types {
+		result[shapeType] = masks[i]
+	}
+	return result, errors.Join(errs...)
 }
 
-// GetRandomBackground 随机获取一个预加载的背景图片
+// GetRandomBackground 随机获取一个预加载的背景图片，WithLazyBackgrounds 时选中尚未下载的背景图返回nil
 func (s *CaptchaService) GetRandomBackground() image.Image {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-
-	if len(s.backgroundImages) == 0 {
+	images := s.loadedBackgrounds().images
+	if len(images) == 0 {
 		return nil
 	}
 
 	// 随机选择一个背景图片
-	index := rand.Intn(len(s.backgroundImages))
-	return s.backgroundImages[index]
+	return images[s.intn(len(images))]
 }
 
 // GetPuzzleMask 获取预生成的拼图mask
@@ -143,107 +734,498 @@ func (s *CaptchaService) GetPuzzleMask(shapeType PuzzleType) *image.Alpha {
 	return s.puzzleMasks[shapeType]
 }
 
-// Generate 生成验证码（使用预加载的资源）
+// background 从预加载的图片中选择背景图，首次调用时自动初始化；index 为 nil 时随机选择
+func (s *CaptchaService) background(ctx context.Context, index *int) (*preparedBackground, error) {
+	if err := s.WarmUp(ctx); err != nil {
+		return nil, err
+	}
+
+	prepared := s.loadedBackgrounds().prepared
+	i, err := s.backgroundIndex(len(prepared), index)
+	if err != nil {
+		return nil, err
+	}
+	if !prepared[i].loaded() {
+		return s.loadLazyBackground(ctx, prepared[i])
+	}
+	return prepared[i], nil
+}
+
+// backgroundIndex 校验指定的背景图序号，index 为 nil 时按选择策略选择
+func (s *CaptchaService) backgroundIndex(n int, index *int) (int, error) {
+	if n == 0 {
+		return 0, ErrNoBackgrounds
+	}
+	if index == nil {
+		if s.selector == nil {
+			return s.intn(n), nil
+		}
+		i := s.selector.Select(n, s.intn)
+		if i < 0 || i >= n {
+			return 0, fmt.Errorf("background selector returned index %d out of range [0, %d)", i, n)
+		}
+		return i, nil
+	}
+	if *index < 0 || *index >= n {
+		return 0, fmt.Errorf("%w: background index %d out of range [0, %d)", ErrInvalidOptions, *index, n)
+	}
+	return *index, nil
+}
+
+// puzzleMask 获取拼图mask：已初始化时使用预生成的mask，否则即时生成
+func (s *CaptchaService) puzzleMask(shapeType PuzzleType) *image.Alpha {
+	if mask := s.GetPuzzleMask(shapeType); mask != nil {
+		return mask
+	}
+	return s.newPuzzleMask(shapeType)
+}
+
+// holeOverlay 获取拼图形状的缺口效果：已初始化时使用预先计算的，否则按mask即时计算
+func (s *CaptchaService) holeOverlay(shapeType PuzzleType) *render.HoleOverlay {
+	s.mu.RLock()
+	overlay := s.holeOverlays[shapeType]
+	s.mu.RUnlock()
+	if overlay != nil {
+		return overlay
+	}
+	return render.NewHoleOverlay(s.puzzleMask(shapeType), s.holeBlur)
+}
+
+// intn 从服务的随机源取 [0, n) 的随机数
+func (s *CaptchaService) intn(n int) int {
+	if s.rng == nil {
+		return secureIntn(n)
+	}
+	return s.rng.Intn(n)
+}
+
+// lockedRand 并发安全的随机源（*rand.Rand 本身不能并发使用）
+type lockedRand struct {
+	mu sync.Mutex
+	r  *rand.Rand
+}
+
+// Intn 返回 [0, n) 的随机数
+func (l *lockedRand) Intn(n int) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.r.Intn(n)
+}
+
+// Generate 生成验证码
 func (s *CaptchaService) Generate() (*SliderCaptcha, error) {
-	if !s.initialized {
-		return nil, fmt.Errorf("captcha service not initialized, call Init() first")
+	return s.GenerateContext(context.Background(), GenerateParams{})
+}
+
+// GenerateWithParams 按参数生成验证码
+func (s *CaptchaService) GenerateWithParams(params GenerateParams) (*SliderCaptcha, error) {
+	return s.GenerateContext(context.Background(), params)
+}
+
+// GenerateContext 按参数生成验证码，ctx 用于背景图下载和存储调用的超时和取消
+func (s *CaptchaService) GenerateContext(ctx context.Context, params GenerateParams) (*SliderCaptcha, error) {
+	return s.GenerateWithOptionsContext(ctx, GenerateOptions{GenerateParams: params})
+}
+
+// GenerateOptions 指定背景图、形状、位置和尺寸的生成选项，未指定的项仍随机选择
+// 用于集成测试、演示和可复现的压测，生产环境应使用随机生成
+type GenerateOptions struct {
+	GenerateParams
+	// Background 背景图序号（与 Backgrounds() 的顺序一致），nil 表示随机
+	Background *int
+	// Shape 拼图形状，nil 表示随机
+	Shape *PuzzleType
+	// Position 缺口左上角在画布（CanvasWidth x CanvasHeight）上的坐标，即验证时的正确答案，nil 表示随机
+	Position *image.Point
+	// PieceWidth、PieceHeight 拼图块尺寸（像素），0 表示使用服务配置；宽度不超过 CanvasWidth/2，高度不超过 CanvasHeight
+	PieceWidth  int
+	PieceHeight int
+}
+
+// custom 是否指定了背景图、形状、位置或尺寸（此时必须重新渲染，不能使用预生成或复用的验证码）
+func (o GenerateOptions) custom() bool {
+	return o.Background != nil || o.Shape != nil || o.Position != nil || o.PieceWidth != 0 || o.PieceHeight != 0
+}
+
+// GenerateWithOptions 按指定选项生成验证码
+func (s *CaptchaService) GenerateWithOptions(opts GenerateOptions) (*SliderCaptcha, error) {
+	return s.GenerateWithOptionsContext(context.Background(), opts)
+}
+
+// GenerateWithOptionsContext 按指定选项生成验证码，选项无效时返回 ErrInvalidOptions
+func (s *CaptchaService) GenerateWithOptionsContext(ctx context.Context, opts GenerateOptions) (*SliderCaptcha, error) {
+	store := s.Store()
+	if store == nil {
+		return nil, ErrNotInitialized
+	}
+	params := opts.GenerateParams
+	if level := s.lookupReputation(ctx, params.RemoteIP).MinDifficulty(); level > params.Difficulty {
+		params.Difficulty = level
+	}
+	settings := params.Difficulty.Settings()
+
+	start := time.Now()
+	createdAt := s.clock.Now()
+
+	// 未指定任何渲染选项时，流量高峰优先复用刚渲染的图片，其次使用预生成的验证码
+	source := sourceReused
+	trackOffset := 0
+	challenge, use := s.burst.take(settings.DecoyHoles, opts, createdAt)
+	if challenge != nil {
+		// 复用的图片缺口位置相同，每个ID使用不同的轨道偏移，一个验证码的答案不能通过另一个的验证
+		trackOffset = burstTrackOffset(use, s.intn)
+	} else {
+		source = sourcePregenerated
+		challenge = s.pool.take(settings.DecoyHoles, opts)
+		if challenge == nil {
+			source = sourceRender
+			var err error
+			challenge, err = s.render(ctx, opts, settings.DecoyHoles)
+			if err != nil {
+				return nil, err
+			}
+			if challenge.cached {
+				source = sourceCached
+			}
+		}
+		s.burst.put(settings.DecoyHoles, opts, challenge, createdAt)
 	}
 
-	// 使用预加载的背景图片
-	bgImage := s.GetRandomBackground()
-	if bgImage == nil {
-		return nil, fmt.Errorf("no background images available")
+	// 生成唯一ID
+	id := s.newCaptchaID()
+
+	// 存储验证码数据
+	captchaData := &CaptchaData{
+		ID:          id,
+		Format:      DataFormatVersion,
+		PositionX:   challenge.x,
+		PositionY:   challenge.y,
+		TrackOffset: trackOffset,
+		Fingerprint: params.Fingerprint,
+		ClientIP:    params.ClientIP,
+		Session:     params.Session,
+		Status:      StatusPending,
+		RequestID:   params.RequestID,
+
+		Tolerance:         settings.Tolerance,
+		RequireTrajectory: settings.RequireTrajectory,
+	}
+	timer := newStageTimer(s.metrics)
+	if err := storeSet(ctx, store, id, captchaData); err != nil {
+		return nil, fmt.Errorf("%w: failed to store captcha: %w", ErrUnavailable, err)
+	}
+	timer.mark(stageStore)
+	s.log().Info("captcha generated", "id", id, "requestId", params.RequestID, "difficulty", params.Difficulty.String())
+	s.log().Debug("puzzle shape selected", "shape", challenge.shape.String())
+	s.metrics.Count(MetricGenerated, 1, Label{"difficulty", params.Difficulty.String()}, Label{"source", source})
+	s.metrics.Observe(MetricGenerateDuration, time.Since(start).Seconds(), Label{"source", source})
+
+	captcha := &SliderCaptcha{
+		ID:          id,
+		Type:        ChallengeTypeSlider,
+		Background:  challenge.background,
+		Slider:      challenge.slider,
+		PositionY:   challenge.y,
+		TrackOffset: trackOffset,
+		Width:       CanvasWidth,
+		Height:      CanvasHeight,
+		PieceWidth:  challenge.pieceWidth,
+		PieceHeight: challenge.pieceHeight,
+		Shape:       challenge.shape.String(),
+		ExpiresAt:   createdAt.Add(s.captchaTTL(store)),
+	}
+	s.fireGenerate(ctx, captcha, params)
+	return captcha, nil
+}
+
+// renderedChallenge 渲染完成、尚未分配ID和存储的验证码
+type renderedChallenge struct {
+	background string // 背景图base64
+	slider     string // 滑块图base64
+	x, y       int    // 缺口位置（画布坐标）
+	shape      PuzzleType
+
+	pieceWidth  int
+	pieceHeight int
+	// cached 图片取自渲染缓存
+	cached bool
+}
+
+// render 按选项渲染验证码图片，decoys 为干扰缺口数量
+func (s *CaptchaService) render(ctx context.Context, opts GenerateOptions, decoys int) (*renderedChallenge, error) {
+	pieceWidth, pieceHeight := s.pieceWidth, s.pieceHeight
+	if opts.PieceWidth != 0 || opts.PieceHeight != 0 {
+		// 缺口不能与滑块起始位置重叠，宽度最多为画布的一半
+		if opts.PieceWidth <= 0 || opts.PieceHeight <= 0 || opts.PieceWidth > CanvasWidth/2 || opts.PieceHeight > CanvasHeight {
+			return nil, fmt.Errorf("%w: piece size %dx%d", ErrInvalidOptions, opts.PieceWidth, opts.PieceHeight)
+		}
+		pieceWidth, pieceHeight = opts.PieceWidth, opts.PieceHeight
+	}
+	if opts.Shape != nil && !slices.Contains(PuzzleTypes, *opts.Shape) {
+		return nil, fmt.Errorf("%w: unknown shape %d", ErrInvalidOptions, *opts.Shape)
+	}
+	if opts.Position != nil {
+		if p := *opts.Position; p.X < 0 || p.Y < 0 || p.X > CanvasWidth-pieceWidth || p.Y > CanvasHeight-pieceHeight {
+			return nil, fmt.Errorf("%w: position %v out of canvas", ErrInvalidOptions, p)
+		}
+	}
+
+	timer := newStageTimer(s.metrics)
+	bg, err := s.background(ctx, opts.Background)
+	if err != nil {
+		return nil, err
 	}
 
 	// 获取图片尺寸
-	bounds := bgImage.Bounds()
+	bounds := bg.source.Bounds()
 	imgWidth := bounds.Dx()
 	imgHeight := bounds.Dy()
 
-	// 随机生成缺口位置（在中心区域）
-	centerX := imgWidth / 2
-	centerY := imgHeight / 2
-	offsetRangeX := int(float64(imgWidth) * 0.25)
-	offsetRangeY := int(float64(imgHeight) * 0.15)
+	// 计算缩放后的坐标（用于前端显示和验证），启用渲染缓存时随机位置按步长取整
+	resizedImage := bg.canvas
+	var scaledPositionX, scaledPositionY int
+	if opts.Position != nil {
+		scaledPositionX, scaledPositionY = opts.Position.X, opts.Position.Y
+	} else {
+		scaledPositionX, scaledPositionY = s.renderCache.quantize(s.holePosition(bg.luminance, imgWidth, imgHeight, pieceWidth, pieceHeight))
+	}
+
+	// 选择拼图形状，未指定时随机
+	var shapeType PuzzleType
+	if opts.Shape != nil {
+		shapeType = *opts.Shape
+	} else {
+		shapeType = PuzzleTypes[s.intn(len(PuzzleTypes))]
+	}
+	timer.mark(stageSelectBackground)
 
-	minX := centerX - offsetRangeX
-	maxX := centerX + offsetRangeX - PuzzleWidth
-	if minX < 0 {
-		minX = 0
+	key := renderKey{
+		bg:          bg,
+		shape:       shapeType,
+		x:           scaledPositionX,
+		y:           scaledPositionY,
+		pieceWidth:  pieceWidth,
+		pieceHeight: pieceHeight,
+		decoys:      decoys,
 	}
-	if maxX > imgWidth-PuzzleWidth {
-		maxX = imgWidth - PuzzleWidth
+	if cached := s.renderCache.get(key); cached != nil {
+		return cached, nil
+	}
+
+	var overlay *render.HoleOverlay
+	if pieceWidth == s.pieceWidth && pieceHeight == s.pieceHeight {
+		overlay = s.holeOverlay(shapeType)
+	} else {
+		overlay = render.NewHoleOverlay(s.sizedPuzzleMask(shapeType, pieceWidth, pieceHeight), s.holeBlur)
 	}
-	if maxX < minX {
-		maxX = minX + PuzzleWidth
+	if s.holeVariation != nil {
+		overlay = overlay.WithStyle(s.randomHoleStyle())
 	}
 
-	minY := centerY - offsetRangeY
-	maxY := centerY + offsetRangeY - PuzzleHeight
-	if minY < 0 {
-		minY = 0
+	// 生成验证码图片
+	bgWithHole, sliderPiece, err := renderCaptchaImages(resizedImage, scaledPositionX, scaledPositionY, overlay, s.pieceBlur, decoys, s.intn, s.pngCompression, timer)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate captcha images: %w", err)
 	}
-	if maxY > imgHeight-PuzzleHeight {
-		maxY = imgHeight - PuzzleHeight
+
+	challenge := &renderedChallenge{
+		background:  bgWithHole,
+		slider:      sliderPiece,
+		x:           scaledPositionX,
+		y:           scaledPositionY,
+		shape:       shapeType,
+		pieceWidth:  pieceWidth,
+		pieceHeight: pieceHeight,
 	}
-	if maxY < minY {
-		maxY = minY + PuzzleHeight
+	if s.renderCache != nil {
+		cached := *challenge
+		cached.cached = true
+		s.renderCache.put(key, &cached)
 	}
+	return challenge, nil
+}
 
-	rand.Seed(TimeNow().UnixNano())
-	positionX := rand.Intn(maxX-minX) + minX
-	positionY := rand.Intn(maxY-minY) + minY
+// DefaultMinTexture 默认的缺口区域最低纹理强度
+const DefaultMinTexture = 4.0
 
-	// 随机选择拼图形状
-	shapeType := PuzzleType(rand.Intn(4))
+// placementAttempts 寻找纹理足够且不在排除区域的缺口位置时最多随机的次数
+const placementAttempts = 8
 
-	// 获取预生成的mask
-	mask := s.GetPuzzleMask(shapeType)
-	if mask == nil {
-		return nil, fmt.Errorf("mask not found for shape type %d", shapeType)
+// holePosition 选择缺口位置（画布坐标）：按 placement 随机若干次，取第一个纹理强度不低于 minTexture 的位置，
+// 都不满足时使用第一个可用的位置；纹理按预先计算的画布亮度计算，与用户看到的一致
+// 全画布模式下所有位置都落在排除区域时退回中心附近随机
+func (s *CaptchaService) holePosition(luminance *render.LuminanceMap, imgWidth, imgHeight, pieceWidth, pieceHeight int) (int, int) {
+	scaleX := float64(CanvasWidth) / float64(imgWidth)
+	scaleY := float64(CanvasHeight) / float64(imgHeight)
+	centerPosition := func() (int, int) {
+		x, y := s.randomPosition(imgWidth, imgHeight, pieceWidth, pieceHeight)
+		return int(float64(x) * scaleX), int(float64(y) * scaleY)
+	}
+	next := func() (int, int, bool) {
+		if s.placement.Mode == PlacementFullCanvas {
+			return s.fullCanvasPosition(pieceWidth, pieceHeight)
+		}
+		x, y := centerPosition()
+		return x, y, true
 	}
 
-	// 生成验证码图片
-	bgWithHole, sliderPiece, err := GenerateCaptchaImagesWithMask(bgImage, positionX, positionY, mask)
+	firstX, firstY, found := 0, 0, false
+	for attempt := 0; attempt < placementAttempts; attempt++ {
+		x, y, ok := next()
+		if !ok {
+			continue
+		}
+		if !found {
+			firstX, firstY, found = x, y, true
+		}
+		if s.minTexture <= 0 || luminance.Texture(image.Rect(x, y, x+pieceWidth, y+pieceHeight)) >= s.minTexture {
+			return x, y
+		}
+	}
+
+	if !found {
+		s.log().Debug("no hole position outside excluded zones, using center placement")
+		return centerPosition()
+	}
+	s.log().Debug("no textured region found for hole, using random position")
+	return firstX, firstY
+}
+
+// randomPosition 随机生成缺口位置（原图坐标）：在图片中心线附近浮动，X方向 ±25%，Y方向 ±15%
+// 拼图块相对图片过大、浮动范围为空时取范围的起点
+func (s *CaptchaService) randomPosition(imgWidth, imgHeight, pieceWidth, pieceHeight int) (int, int) {
+	x := s.randomOffset(imgWidth/2, int(float64(imgWidth)*0.25), imgWidth, pieceWidth)
+	y := s.randomOffset(imgHeight/2, int(float64(imgHeight)*0.15), imgHeight, pieceHeight)
+	return x, y
+}
+
+// randomOffset 在 [center-spread, center+spread-piece] 与图片范围的交集内随机一个坐标，交集为空时返回起点
+func (s *CaptchaService) randomOffset(center, spread, size, piece int) int {
+	lo := max(center-spread, 0)
+	hi := min(center+spread-piece, size-piece)
+	if hi <= lo {
+		return max(min(lo, size-piece), 0)
+	}
+	return s.intn(hi-lo) + lo
+}
+
+// captchaTTL 验证码有效期，存储提供 TTL() 时以存储为准
+func (s *CaptchaService) captchaTTL(store Store) time.Duration {
+	if ttlStore, ok := store.(interface{ TTL() time.Duration }); ok {
+		return ttlStore.TTL()
+	}
+	if s.ttl > 0 {
+		return s.ttl
+	}
+	return DefaultTTL
+}
+
+// Refresh 换一张：生成新的验证码并作废旧验证码，生成失败时旧验证码保持可用
+func (s *CaptchaService) Refresh(oldID string, params GenerateParams) (*SliderCaptcha, error) {
+	return s.RefreshContext(context.Background(), oldID, params)
+}
+
+// RefreshContext 换一张，ctx 用于背景图下载和存储调用的超时和取消
+func (s *CaptchaService) RefreshContext(ctx context.Context, oldID string, params GenerateParams) (*SliderCaptcha, error) {
+	sliderCaptcha, err := s.GenerateContext(ctx, params)
 	if err != nil {
-		return nil, fmt.Errorf("failed to generate captcha images: %w", err)
+		return nil, err
 	}
+	if _, err := s.RevokeContext(ctx, oldID); err != nil {
+		s.log().Warn("failed to revoke replaced captcha", "id", oldID, "error", err)
+	}
+	return sliderCaptcha, nil
+}
 
-	// 计算缩放后的坐标
-	targetWidth := 350
-	targetHeight := 200
-	scaleX := float64(targetWidth) / float64(imgWidth)
-	scaleY := float64(targetHeight) / float64(imgHeight)
-	scaledPositionX := int(float64(positionX) * scaleX)
-	scaledPositionY := int(float64(positionY) * scaleY)
+// Verify 验证滑块位置并结合拖动轨迹评估风险，未传入误差时使用服务配置的默认误差
+// 返回的结果总是非nil，其中 Reason 给出失败原因；验证码不可用（不存在、过期、绑定不一致）时同时返回error
+// （ErrNotFound、ErrExpired、ErrAlreadyUsed 等，可通过 errors.Is 判断）
+func (s *CaptchaService) Verify(params VerifyParams) (*VerifyResult, error) {
+	return s.VerifyContext(context.Background(), params)
+}
 
-	// 生成唯一ID
-	id := uuid.New().String()
+// VerifyContext 验证滑块位置，ctx 用于存储调用的超时和取消
+// 存储不可用或 ctx 已结束时返回 ReasonUnavailable
+func (s *CaptchaService) VerifyContext(ctx context.Context, params VerifyParams) (*VerifyResult, error) {
+	if params.Tolerance == 0 {
+		params.Tolerance = s.tolerance
+	}
+	return s.verifyAndLog(ctx, params, nil)
+}
 
-	// 存储验证码数据
-	captchaData := &CaptchaData{
-		ID:        id,
-		PositionX: scaledPositionX,
-		PositionY: scaledPositionY,
+// Status 查询验证码状态（不返回答案），验证码不存在或存储不可用时返回 false
+func (s *CaptchaService) Status(id string) (CaptchaStatus, bool) {
+	status, exists, _ := s.StatusContext(context.Background(), id)
+	return status, exists
+}
+
+// StatusContext 查询验证码状态，验证码不存在时返回 false，存储不可用或 ctx 已结束时返回error
+func (s *CaptchaService) StatusContext(ctx context.Context, id string) (CaptchaStatus, bool, error) {
+	store := s.Store()
+	if store == nil {
+		return "", false, ErrNotInitialized
+	}
+	if err := s.checkID(id, s.captchaTTL(store)); errors.Is(err, ErrExpired) {
+		return StatusExpired, true, nil
+	} else if err != nil {
+		return "", false, nil
+	}
+	return statusIn(ctx, store, id)
+}
+
+// Revoke 主动作废验证码，验证码不存在或存储不可用时返回 false
+func (s *CaptchaService) Revoke(id string) bool {
+	exists, _ := s.RevokeContext(context.Background(), id)
+	return exists
+}
+
+// RevokeContext 主动作废验证码，验证码不存在时返回 false，存储不可用或 ctx 已结束时返回error
+func (s *CaptchaService) RevokeContext(ctx context.Context, id string) (bool, error) {
+	store := s.Store()
+	if store == nil {
+		return false, ErrNotInitialized
+	}
+	if s.checkID(id, s.captchaTTL(store)) != nil {
+		return false, nil
 	}
-	Set(id, captchaData)
+	return revokeIn(ctx, store, id)
+}
 
-	shapeName := getShapeName(shapeType)
-	fmt.Printf("[生成的图形] %s (Type=%d)\n", shapeName, shapeType)
+// ValidateToken 校验该服务签发的一次性令牌，返回对应的验证码ID，存储不可用时判定无效
+// 已兑换的令牌ID（jti）保留到令牌过期，截获的令牌再次提交时判定无效，并计入指标 MetricTokenReplay
+func (s *CaptchaService) ValidateToken(token string) (string, bool) {
+	captchaID, ok, _ := s.ValidateTokenContext(context.Background(), token)
+	return captchaID, ok
+}
 
-	return &SliderCaptcha{
-		ID:         id,
-		Background: bgWithHole,
-		Slider:     sliderPiece,
-		PositionY:  scaledPositionY,
-	}, nil
+// ValidateTokenContext 校验该服务签发的一次性令牌，返回对应的验证码ID；兑换记录保存在验证码存储中（见 TokenRedeemStore），
+// 存储不可用或 ctx 已结束时返回error
+func (s *CaptchaService) ValidateTokenContext(ctx context.Context, token string) (string, bool, error) {
+	captchaID, ok, replayed, err := s.tokens.validate(ctx, s.Store(), token)
+	if err != nil {
+		s.log().Error("failed to redeem captcha token", "captchaId", captchaID, "error", err)
+		return "", false, fmt.Errorf("%w: failed to redeem token: %w", ErrUnavailable, err)
+	}
+	if replayed {
+		s.log().Warn("captcha token replayed", "captchaId", captchaID)
+		s.metrics.Count(MetricTokenReplay, 1)
+	}
+	if !ok {
+		return "", false, nil
+	}
+	return captchaID, true, nil
 }
 
 // GenerateCaptchaImagesWithMask 使用预生成的mask生成验证码图片
 func GenerateCaptchaImagesWithMask(bgImage image.Image, x, y int, mask *image.Alpha) (bgWithHole string, sliderPiece string, err error) {
+	return generateCaptchaImagesWithMask(bgImage, x, y, mask, 0, rand.Intn)
+}
+
+// generateCaptchaImagesWithMask 使用预生成的mask生成验证码图片，decoys 为干扰缺口数量，intn 为干扰缺口位置的随机源
+func generateCaptchaImagesWithMask(bgImage image.Image, x, y int, mask *image.Alpha, decoys int, intn func(int) int) (bgWithHole string, sliderPiece string, err error) {
 	// 缩放到目标尺寸
-	targetWidth := 350
-	targetHeight := 200
+	targetWidth := CanvasWidth
+	targetHeight := CanvasHeight
 	resizedImage := ResizeImage(bgImage, targetWidth, targetHeight)
 
 	// 根据缩放比例调整缺口位置
@@ -252,102 +1234,46 @@ func GenerateCaptchaImagesWithMask(bgImage image.Image, x, y int, mask *image.Al
 	scaledX := int(float64(x) * scaleX)
 	scaledY := int(float64(y) * scaleY)
 
+	return renderCaptchaImages(resizedImage, scaledX, scaledY, render.NewHoleOverlay(mask, render.HoleBlur), render.PieceBlur, decoys, intn, png.DefaultCompression, nil)
+}
+
+// renderCaptchaImages 在已缩放到画布尺寸的背景图上按画布坐标生成验证码图片，overlay 为拼图形状的缺口效果，
+// pieceBlur 为拼图块的模糊参数，level 为PNG压缩级别，timer 记录各阶段耗时（nil 表示不记录）
+func renderCaptchaImages(resizedImage image.Image, scaledX, scaledY int, overlay *render.HoleOverlay, pieceBlur render.Gaussian, decoys int, intn func(int) int, level png.CompressionLevel, timer *stageTimer) (bgWithHole string, sliderPiece string, err error) {
 	// 创建带缺口的背景图
-	holeImage := CreatePuzzleHoleWithMask(resizedImage, scaledX, scaledY, mask)
+	holeImage := overlay.Punch(resizedImage, scaledX, scaledY)
+	if decoys > 0 {
+		addDecoyHoles(holeImage, scaledX, overlay, decoys, intn)
+	}
+	timer.mark(stageRenderHole)
 
 	// 提取拼图块
-	pieceImage := ExtractPuzzlePieceWithMask(resizedImage, scaledX, scaledY, mask)
+	pieceImage := render.CutPieceWithBlur(resizedImage, scaledX, scaledY, overlay.Mask(), pieceBlur)
+	timer.mark(stageRenderPiece)
 
 	// 转换为base64
-	bgBase64, err := ImageToBase64(holeImage, "png")
+	bgBase64, err := imageToBase64(holeImage, "png", level)
 	if err != nil {
 		return "", "", fmt.Errorf("failed to encode background: %w", err)
 	}
 
-	sliderBase64, err := ImageToBase64(pieceImage, "png")
+	sliderBase64, err := imageToBase64(pieceImage, "png", level)
 	if err != nil {
 		return "", "", fmt.Errorf("failed to encode slider: %w", err)
 	}
+	timer.mark(stageEncode)
 
 	return bgBase64, sliderBase64, nil
 }
 
 // CreatePuzzleHoleWithMask 使用预生成的mask创建缺口
 func CreatePuzzleHoleWithMask(bgImage image.Image, x, y int, mask *image.Alpha) image.Image {
-	result := image.NewRGBA(bgImage.Bounds())
-	draw.Draw(result, result.Bounds(), bgImage, image.Point{}, draw.Src)
-
-	for py := 0; py < PuzzleHeight; py++ {
-		for px := 0; px < PuzzleWidth; px++ {
-			targetX := x + px
-			targetY := y + py
-
-			if targetX < 0 || targetX >= result.Bounds().Dx() ||
-				targetY < 0 || targetY >= result.Bounds().Dy() {
-				continue
-			}
-
-			alpha := mask.AlphaAt(px, py).A
-			if alpha > 0 {
-				c := result.RGBAAt(targetX, targetY)
-				result.SetRGBA(targetX, targetY, color.RGBA{
-					R: uint8(float64(c.R)*0.5 + 255*0.5),
-					G: uint8(float64(c.G)*0.6 + 255*0.4),
-					B: uint8(float64(c.B)*0.6 + 255*0.4),
-					A: 255,
-				})
-			}
-		}
-	}
-
-	addHoleBorder(result, mask, x, y)
-	applyGaussianBlurToHole(result, mask, x, y)
-
-	return result
+	return render.PunchHole(bgImage, x, y, mask)
 }
 
 // ExtractPuzzlePieceWithMask 使用预生成的mask提取拼图块
 func ExtractPuzzlePieceWithMask(bgImage image.Image, x, y int, mask *image.Alpha) image.Image {
-	piece := image.NewRGBA(image.Rect(0, 0, PuzzleWidth, PuzzleHeight))
-	draw.Draw(piece, piece.Bounds(), image.Transparent, image.Point{}, draw.Src)
-
-	for py := 0; py < PuzzleHeight; py++ {
-		for px := 0; px < PuzzleWidth; px++ {
-			alpha := mask.AlphaAt(px, py).A
-			if alpha > 0 {
-				srcX := x + px
-				srcY := y + py
-
-				if srcX >= 0 && srcX < bgImage.Bounds().Dx() &&
-					srcY >= 0 && srcY < bgImage.Bounds().Dy() {
-					c := bgImage.At(srcX, srcY)
-					piece.Set(px, py, c)
-				}
-			}
-		}
-	}
-
-	addSimpleBorder(piece, mask)
-	add3DEffect(piece, mask)
-	applyGaussianBlur(piece, mask)
-
-	return piece
-}
-
-// getShapeName 获取形状名称
-func getShapeName(shapeType PuzzleType) string {
-	switch shapeType {
-	case PuzzleTypeTriangle:
-		return "三角形"
-	case PuzzleTypeHexagon:
-		return "六边形"
-	case PuzzleTypeTrapezoid:
-		return "梯形"
-	case PuzzleTypeStar:
-		return "星形"
-	default:
-		return "未知"
-	}
+	return render.CutPiece(bgImage, x, y, mask)
 }
 
 // TimeNow 获取当前时间（方便mock测试）
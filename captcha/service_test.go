@@ -0,0 +1,135 @@
+package captcha
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/png"
+	"io/fs"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/gpencil/photo_captcha/captcha/render"
+)
+
+// TestRandomPosition 拼图块相对图片过大时不能panic，位置仍在图片范围内
+func TestRandomPosition(t *testing.T) {
+	tests := []struct {
+		name                    string
+		imgWidth, imgHeight     int
+		pieceWidth, pieceHeight int
+	}{
+		{"default", CanvasWidth, CanvasHeight, PuzzleWidth, PuzzleHeight},
+		{"large source", 1920, 1080, PuzzleWidth, PuzzleHeight},
+		{"piece fills spread", 200, 200, 100, 60},
+		{"piece larger than spread", 100, 100, 80, 80},
+		{"piece as large as image", 70, 70, 70, 70},
+		{"piece larger than image", 50, 40, 70, 70},
+	}
+	s := NewCaptchaService(WithSeed(1))
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for range 100 {
+				x, y := s.randomPosition(tt.imgWidth, tt.imgHeight, tt.pieceWidth, tt.pieceHeight)
+				if x < 0 || y < 0 || (tt.pieceWidth <= tt.imgWidth && x > tt.imgWidth-tt.pieceWidth) || (tt.pieceHeight <= tt.imgHeight && y > tt.imgHeight-tt.pieceHeight) {
+					t.Fatalf("randomPosition() = (%d, %d) out of %dx%d", x, y, tt.imgWidth, tt.imgHeight)
+				}
+			}
+		})
+	}
+}
+
+func TestGenerateWithOptionsPieceSize(t *testing.T) {
+	tests := []struct {
+		name          string
+		width, height int
+	}{
+		{"zero width", 0, 70},
+		{"zero height", 70, 0},
+		{"negative", -70, -70},
+		{"overlaps slider start", CanvasWidth/2 + 1, 70},
+		{"taller than canvas", 70, CanvasHeight + 1},
+	}
+	s := NewCaptchaService()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := s.GenerateWithOptions(GenerateOptions{PieceWidth: tt.width, PieceHeight: tt.height})
+			if !errors.Is(err, ErrInvalidOptions) {
+				t.Errorf("GenerateWithOptions(%dx%d) error = %v, want %v", tt.width, tt.height, err, ErrInvalidOptions)
+			}
+		})
+	}
+}
+
+// TestWithFastBlur 快速模糊只作用于设置它的服务，不修改 render 包的全局参数
+func TestWithFastBlur(t *testing.T) {
+	fast := NewCaptchaService(WithFastBlur(true))
+	plain := NewCaptchaService()
+	if !fast.holeBlur.Box || !fast.pieceBlur.Box {
+		t.Errorf("WithFastBlur(true) blur = %+v, %+v, want box blur", fast.holeBlur, fast.pieceBlur)
+	}
+	if plain.holeBlur.Box || plain.pieceBlur.Box {
+		t.Errorf("default service blur = %+v, %+v, want gaussian", plain.holeBlur, plain.pieceBlur)
+	}
+	if render.HoleBlur.Box || render.PieceBlur.Box {
+		t.Error("WithFastBlur modified render.HoleBlur/PieceBlur")
+	}
+}
+
+// blockingFS 打开 name 时通知 opened 并等待 release，模拟下载缓慢的背景图
+type blockingFS struct {
+	fstest.MapFS
+	name            string
+	opened, release chan struct{}
+}
+
+func (f blockingFS) Open(name string) (fs.File, error) {
+	if name == f.name {
+		close(f.opened)
+		<-f.release
+	}
+	return f.MapFS.Open(name)
+}
+
+// TestInitDoesNotBlockStore 加载背景图期间验证、查询状态等读取存储的调用不等待初始化
+func TestInitDoesNotBlockStore(t *testing.T) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, CanvasWidth, CanvasHeight))); err != nil {
+		t.Fatal(err)
+	}
+	fsys := blockingFS{
+		MapFS:   fstest.MapFS{"bg.png": {Data: buf.Bytes()}},
+		name:    "bg.png",
+		opened:  make(chan struct{}),
+		release: make(chan struct{}),
+	}
+	s := NewCaptchaService(WithFS(fsys), WithBackgrounds("bg.png"))
+	defer s.Close()
+
+	initDone := make(chan error, 1)
+	go func() { initDone <- s.Init() }()
+	<-fsys.opened
+
+	statusDone := make(chan struct{})
+	go func() {
+		s.Status("missing")
+		close(statusDone)
+	}()
+	select {
+	case <-statusDone:
+	case <-time.After(5 * time.Second):
+		t.Error("Status() blocked while backgrounds were loading")
+	}
+	if s.InitStatus().Ready {
+		t.Error("InitStatus().Ready = true before backgrounds loaded")
+	}
+
+	close(fsys.release)
+	if err := <-initDone; err != nil {
+		t.Fatal(err)
+	}
+	if !s.InitStatus().Ready {
+		t.Error("InitStatus().Ready = false after Init()")
+	}
+}
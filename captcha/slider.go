@@ -1,11 +1,10 @@
 package captcha
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"math/rand"
 	"time"
-
-	"github.com/google/uuid"
 )
 
 // PuzzleShape 拼图形状参数
@@ -31,21 +30,8 @@ type PuzzleShape struct {
 // GenerateRandomPuzzleShape 生成随机拼图形状
 func GenerateRandomPuzzleShape() *PuzzleShape {
 	// 随机选择mask目录下存在的图形
-	shapeType := PuzzleType(rand.Intn(4)) // 0-3 共4种形状
-
-	// 打印日志
-	var shapeName string
-	switch shapeType {
-	case PuzzleTypeTriangle:
-		shapeName = "三角形"
-	case PuzzleTypeHexagon:
-		shapeName = "六边形"
-	case PuzzleTypeTrapezoid:
-		shapeName = "梯形"
-	case PuzzleTypeStar:
-		shapeName = "星形"
-	}
-	fmt.Printf("[生成的图形] %s (Type=%d)\n", shapeName, shapeType)
+	shapeType := PuzzleType(secureIntn(4)) // 0-3 共4种形状
+	Logger().Debug("puzzle shape selected", "shape", shapeType.String())
 
 	return &PuzzleShape{
 		Type: shapeType,
@@ -62,130 +48,334 @@ const (
 	PuzzleTypeStar                        // 星形
 )
 
-// PuzzleShape 拼图形状参数
+// PuzzleTypes 所有已注册的拼图形状
+var PuzzleTypes = []PuzzleType{
+	PuzzleTypeTriangle,
+	PuzzleTypeHexagon,
+	PuzzleTypeTrapezoid,
+	PuzzleTypeStar,
+}
+
+// String 返回形状名称
+func (t PuzzleType) String() string {
+	switch t {
+	case PuzzleTypeTriangle:
+		return "triangle"
+	case PuzzleTypeHexagon:
+		return "hexagon"
+	case PuzzleTypeTrapezoid:
+		return "trapezoid"
+	case PuzzleTypeStar:
+		return "star"
+	default:
+		return "unknown"
+	}
+}
+
+// ChallengeTypeSlider 滑块拼图验证码
+const ChallengeTypeSlider = "slider"
+
+// SliderCaptcha 生成的滑块验证码
 type SliderCaptcha struct {
 	ID         string `json:"id"`
+	Type       string `json:"type"`       // 验证码类型（ChallengeTypeSlider）
 	Background string `json:"background"` // 背景图base64
 	Slider     string `json:"slider"`     // 滑块图base64
 	PositionY  int    `json:"positionY"`  // 滑块Y轴位置
+	Width      int    `json:"width"`      // 背景图宽度（像素）
+	Height     int    `json:"height"`     // 背景图高度（像素）
+	// PieceWidth、PieceHeight 滑块图尺寸（像素）
+	PieceWidth  int    `json:"pieceWidth"`
+	PieceHeight int    `json:"pieceHeight"`
+	Shape       string `json:"shape"` // 拼图形状名称
+	// TrackOffset 滑块轨道偏移（像素），提交的X为滑块移动的距离加上该值；突发流量复用图片时每个ID各不相同，通常为0
+	TrackOffset int `json:"trackOffset"`
+	// ExpiresAt 验证码过期时间，前端可据此显示倒计时
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// GenerateParams 生成参数
+type GenerateParams struct {
+	// Fingerprint 客户端指纹哈希（可选），验证时必须提交相同的指纹
+	Fingerprint string
+	// ClientIP 客户端IP（可选），验证时必须来自相同IP
+	ClientIP string
+	// Session 浏览器会话标识（可选，如 HttpOnly Cookie 的值），验证时必须来自相同会话
+	Session string
+	// Difficulty 难度等级，决定容差、干扰缺口数量和是否必须提交轨迹，配置了 WithIPReputation 时可能被提高
+	Difficulty DifficultyLevel
+	// RemoteIP 请求来源IP，仅用于查询IP信誉，不做绑定校验
+	RemoteIP string
+	// RequestID 生成请求的ID（可选），验证时写入日志以便追溯到生成请求
+	RequestID string
 }
 
-// Generate 生成新的滑块验证码
+// Generate 使用默认服务生成新的滑块验证码
+//
+// Deprecated: 使用 CaptchaService.Generate
 func Generate() (*SliderCaptcha, error) {
-	// 随机选择背景图URL
-	rand.Seed(time.Now().UnixNano())
-	bgIndex := rand.Intn(len(BackgroundURLs))
-	bgURL := BackgroundURLs[bgIndex]
+	return defaultService.Generate()
+}
+
+// GenerateWithParams 使用默认服务按参数生成新的滑块验证码
+//
+// Deprecated: 使用 CaptchaService.GenerateWithParams
+func GenerateWithParams(params GenerateParams) (*SliderCaptcha, error) {
+	return defaultService.GenerateWithParams(params)
+}
+
+// Refresh 使用默认服务换一张：生成新的验证码并作废旧验证码，生成失败时旧验证码保持可用
+//
+// Deprecated: 使用 CaptchaService.Refresh
+func Refresh(oldID string, params GenerateParams) (*SliderCaptcha, error) {
+	return defaultService.Refresh(oldID, params)
+}
+
+// VerifyParams 验证参数
+type VerifyParams struct {
+	ID         string            // 验证码ID
+	X          int               // 用户拖动的X坐标
+	Tolerance  int               // 允许的误差范围（像素）
+	Trajectory []TrajectoryPoint // 拖动轨迹（可选）
+	// Fingerprint 客户端指纹哈希，生成时绑定了指纹则必须一致
+	Fingerprint string
+	// ClientIP 客户端IP，生成时绑定了IP则必须一致
+	ClientIP string
+	// Session 浏览器会话标识，生成时绑定了会话则必须一致
+	Session string
+	// RemoteIP 请求来源IP，仅用于风险评估和可疑标记，不做绑定校验
+	RemoteIP string
+	// Reputation 来源IP的信誉，由服务在调用 Verifier 前按 WithIPReputation 查询填写，调用方无需设置
+	Reputation Reputation
+	// ActionStartedAt 受保护操作开始的时间（如业务方渲染登录表单的时间，可选），配置了 WithMaxChallengeAge 时
+	// 验证码的签发时间早于该时间超过上限则判定失败，应来自业务方可信的记录，而不是客户端自报
+	ActionStartedAt time.Time
+	// HoneypotFilled 隐藏的蜜罐字段是否被填写（正常用户看不到该字段）
+	HoneypotFilled bool
+	// RequestID 验证请求的ID（可选），用于日志关联
+	RequestID string
+}
+
+// VerifyResult 验证结果
+type VerifyResult struct {
+	Success bool            // 是否验证通过
+	Reason  FailureReason   // 失败原因（验证通过时为空）
+	Diff    int             // 与缺口位置的误差（像素）
+	Risk    *RiskAssessment // 轨迹风险评估
+	// SolveTime 从生成到提交验证的耗时
+	SolveTime time.Duration
+	// Token 验证通过后签发的一次性令牌，业务方通过 ValidateToken 校验
+	Token string
+	// GenerateRequestID 生成该验证码的请求ID，用于把验证结果追溯到生成请求
+	GenerateRequestID string
+}
+
+// MaxVerifyAttempts 单个验证码允许的最大失败次数，超过后作废（未通过 WithMaxAttempts 配置的服务使用该值）
+var MaxVerifyAttempts = 5
 
-	// 下载背景图
-	bgImage, err := DownloadImage(bgURL)
+// Verify 使用默认服务验证滑块位置
+// tolerance: 允许的误差范围（像素）
+//
+// Deprecated: 使用 CaptchaService.Verify
+func Verify(id string, userX int, tolerance int) (bool, error) {
+	result, err := VerifyWithParams(VerifyParams{
+		ID:        id,
+		X:         userX,
+		Tolerance: tolerance,
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to download background image: %w", err)
+		return false, err
 	}
+	return result.Success, nil
+}
 
-	// 获取图片尺寸
-	bounds := bgImage.Bounds()
-	imgWidth := bounds.Dx()
-	imgHeight := bounds.Dy()
+// VerifyWithParams 使用默认服务验证滑块位置并结合拖动轨迹评估风险，Tolerance 按传入值使用
+// 返回的结果总是非nil，其中 Reason 给出失败原因；验证码不可用（不存在、过期、绑定不一致）时同时返回error
+//
+// Deprecated: 使用 CaptchaService.Verify
+func VerifyWithParams(params VerifyParams) (*VerifyResult, error) {
+	return defaultService.verifyAndLog(context.Background(), params, nil)
+}
 
-	// 随机生成缺口位置
-	// X坐标: 在图片中间垂直线左右浮动
-	centerX := imgWidth / 2
-	// 在中间线附近浮动，范围为中间线的 ±25%
-	offsetRangeX := int(float64(imgWidth) * 0.25)
-	minX := centerX - offsetRangeX
-	maxX := centerX + offsetRangeX - PuzzleWidth
+// verifyAndLog 执行验证并记录结果日志，pre 为批量预先读取的验证码数据（nil 表示从存储读取）
+func (s *CaptchaService) verifyAndLog(ctx context.Context, params VerifyParams, pre *prefetched) (*VerifyResult, error) {
+	result, err := s.verify(ctx, params, pre)
+	s.log().Info("captcha verified",
+		"id", params.ID,
+		"requestId", params.RequestID,
+		"generateRequestId", result.GenerateRequestID,
+		"success", result.Success,
+		"reason", string(result.Reason),
+	)
+
+	outcome := Label{"result", "failure"}
+	if result.Success {
+		outcome.Value = "success"
+	}
+	s.metrics.Count(MetricVerified, 1, outcome, Label{"reason", string(result.Reason)})
+	if result.SolveTime > 0 {
+		s.metrics.Observe(MetricSolveDuration, result.SolveTime.Seconds(), outcome)
+	}
+	s.fireVerify(ctx, params, result, err)
+	return result, err
+}
 
-	// 确保不超出边界
-	if minX < 0 {
-		minX = 0
+// verify 执行验证，结果总是非nil；pre 不为nil时使用其中预先读取的验证码数据，不再读取存储
+func (s *CaptchaService) verify(ctx context.Context, params VerifyParams, pre *prefetched) (*VerifyResult, error) {
+	store := s.Store()
+	if store == nil {
+		return &VerifyResult{Reason: ReasonUnavailable}, ErrNotInitialized
 	}
-	if maxX > imgWidth-PuzzleWidth {
-		maxX = imgWidth - PuzzleWidth
+	maxAttempts := s.maxVerifyAttempts()
+	load := func() (*CaptchaData, bool, error) {
+		if pre != nil {
+			return pre.data, pre.data != nil, nil
+		}
+		return storeGet(ctx, store, params.ID)
 	}
-	if maxX < minX {
-		maxX = minX + PuzzleWidth
+
+	// 蜜罐字段被填写，直接拒绝并标记来源IP
+	if params.HoneypotFilled {
+		s.suspicious().Flag(params.RemoteIP)
+		result := &VerifyResult{Reason: ReasonRiskRejected, Risk: &RiskAssessment{}}
+		result.Risk.addFlag(RiskFlagHoneypot)
+		if data, exists, err := load(); err == nil && exists {
+			s.markStatusLogged(ctx, store, params.ID, data, StatusFailed)
+			result.GenerateRequestID = data.RequestID
+		}
+
+		s.log().Warn("honeypot field filled", "id", params.ID, "ip", params.RemoteIP)
+		return result, nil
 	}
 
-	positionX := rand.Intn(maxX-minX) + minX
+	// 签名不正确或已过期的ID不访问存储
+	if err := s.checkID(params.ID, s.captchaTTL(store)); err != nil {
+		if errors.Is(err, ErrExpired) {
+			return &VerifyResult{Reason: ReasonExpired}, err
+		}
+		return &VerifyResult{Reason: ReasonNotFound}, err
+	}
 
-	// Y坐标: 在图片中间水平线上下浮动
-	centerY := imgHeight / 2
-	// 在中间线附近浮动，范围为中间线的 ±15%
-	offsetRangeY := int(float64(imgHeight) * 0.15)
-	minY := centerY - offsetRangeY
-	maxY := centerY + offsetRangeY - PuzzleHeight
+	// 签名ID带有签发时间，过早签发的验证码不访问存储直接拒绝
+	if issued, ok := s.idIssuedAt(params.ID); ok && s.staleChallenge(issued, params) {
+		return &VerifyResult{Reason: ReasonStaleChallenge}, ErrStaleChallenge
+	}
 
-	// 确保不超出边界
-	if minY < 0 {
-		minY = 0
+	// 同一验证码的并发请求中只有一个能更新状态或失败次数，其余重新读取后再判定；
+	// 每次冲突都意味着状态或失败次数前进了一步，重试次数有上限只为防御实现有误的存储
+	for range maxAttempts + 2 {
+		result, conflict, err := s.verifyStored(ctx, store, params, load, maxAttempts)
+		if !conflict {
+			return result, err
+		}
+		load = func() (*CaptchaData, bool, error) {
+			return storeGet(ctx, store, params.ID)
+		}
 	}
-	if maxY > imgHeight-PuzzleHeight {
-		maxY = imgHeight - PuzzleHeight
+	return &VerifyResult{Reason: ReasonUnavailable}, fmt.Errorf("%w: captcha modified concurrently", ErrUnavailable)
+}
+
+// verifyStored 读取验证码数据并判定，通过时把状态原子地改为已验证后签发令牌，失败时原子地增加失败次数；
+// conflict 为 true 表示其他请求已先修改了该验证码，需要重新读取后再判定
+func (s *CaptchaService) verifyStored(ctx context.Context, store Store, params VerifyParams, load func() (*CaptchaData, bool, error), maxAttempts int) (result *VerifyResult, conflict bool, err error) {
+	// 获取存储的验证码数据
+	data, exists, err := load()
+	if err != nil {
+		return &VerifyResult{Reason: ReasonUnavailable}, false, fmt.Errorf("%w: failed to load captcha: %w", ErrUnavailable, err)
 	}
-	if maxY < minY {
-		maxY = minY + PuzzleHeight
+	if !exists {
+		expired, err := storeIsExpired(ctx, store, params.ID)
+		if err != nil {
+			return &VerifyResult{Reason: ReasonUnavailable}, false, fmt.Errorf("%w: failed to load captcha: %w", ErrUnavailable, err)
+		}
+		if expired {
+			if err := storeDelete(ctx, store, params.ID); err != nil {
+				s.log().Warn("failed to delete expired captcha", "id", params.ID, "error", err)
+			}
+			s.fireExpire(ctx, params.ID)
+			return &VerifyResult{Reason: ReasonExpired}, false, ErrExpired
+		}
+		return &VerifyResult{Reason: ReasonNotFound}, false, ErrNotFound
 	}
 
-	positionY := rand.Intn(maxY-minY) + minY
-
-	// 生成随机拼图形状参数
-	puzzleShape := GenerateRandomPuzzleShape()
-
-	// 生成验证码图片（内部会进行缩放）
-	bgWithHole, sliderPiece, err := GenerateCaptchaImages(bgImage, positionX, positionY, puzzleShape)
-	if err != nil {
-		return nil, fmt.Errorf("failed to generate captcha images: %w", err)
+	// 更新版本生成的验证码格式无法可靠解析，不做判定也不修改数据，交由新版本实例处理
+	if !data.supportedFormat() {
+		s.log().Warn("unsupported captcha data format", "id", params.ID, "format", data.Format)
+		return &VerifyResult{Reason: ReasonUnsupportedFormat, GenerateRequestID: data.RequestID}, false, ErrUnsupportedFormat
 	}
 
-	// 计算缩放后的坐标（用于前端显示）
-	targetWidth := 350
-	targetHeight := 200
-	scaleX := float64(targetWidth) / float64(imgWidth)
-	scaleY := float64(targetHeight) / float64(imgHeight)
-	scaledPositionX := int(float64(positionX) * scaleX)
-	scaledPositionY := int(float64(positionY) * scaleY)
+	// 已验证通过或已作废的验证码不能再次使用
+	if data.status() != StatusPending {
+		return &VerifyResult{Reason: ReasonAlreadyUsed, GenerateRequestID: data.RequestID}, false, ErrAlreadyUsed
+	}
 
-	// 生成唯一ID
-	id := uuid.New().String()
+	if data.Attempts >= maxAttempts {
+		s.markStatusLogged(ctx, store, params.ID, data, StatusFailed)
+		return &VerifyResult{Reason: ReasonTooManyAttempts, GenerateRequestID: data.RequestID}, false, ErrTooManyAttempts
+	}
 
-	// 存储验证码数据（使用原始坐标用于验证）
-	captchaData := &CaptchaData{
-		ID:        id,
-		PositionX: scaledPositionX, // 使用缩放后的坐标
-		PositionY: scaledPositionY,
+	// 校验客户端指纹，不一致说明验证码可能被转发给打码平台，直接作废
+	if data.Fingerprint != "" && data.Fingerprint != params.Fingerprint {
+		s.markStatusLogged(ctx, store, params.ID, data, StatusFailed)
+		return &VerifyResult{Reason: ReasonFingerprintMismatch, GenerateRequestID: data.RequestID}, false, ErrFingerprintMismatch
 	}
-	Set(id, captchaData)
 
-	return &SliderCaptcha{
-		ID:         id,
-		Background: bgWithHole,
-		Slider:     sliderPiece,
-		PositionY:  scaledPositionY, // 返回缩放后的Y坐标
-	}, nil
-}
+	// 校验客户端IP
+	if data.ClientIP != "" && data.ClientIP != params.ClientIP {
+		s.markStatusLogged(ctx, store, params.ID, data, StatusFailed)
+		return &VerifyResult{Reason: ReasonIPMismatch, GenerateRequestID: data.RequestID}, false, ErrIPMismatch
+	}
 
-// Verify 验证滑块位置
-// tolerance: 允许的误差范围（像素）
-func Verify(id string, userX int, tolerance int) (bool, error) {
-	// 获取存储的验证码数据
-	data, exists := Get(id)
-	if !exists {
-		return false, fmt.Errorf("captcha not found or expired")
+	// 校验浏览器会话，不一致说明验证码被转移到其他浏览器完成
+	if data.Session != "" && data.Session != params.Session {
+		s.markStatusLogged(ctx, store, params.ID, data, StatusFailed)
+		return &VerifyResult{Reason: ReasonSessionMismatch, GenerateRequestID: data.RequestID}, false, ErrSessionMismatch
 	}
 
-	// 计算误差
-	diff := abs(userX - data.PositionX)
+	// 校验签发时间，批量预先打码的验证码在受保护操作开始前很久就已生成，直接作废
+	if s.staleChallenge(data.CreatedAt, params) {
+		s.markStatusLogged(ctx, store, params.ID, data, StatusFailed)
+		return &VerifyResult{Reason: ReasonStaleChallenge, GenerateRequestID: data.RequestID}, false, ErrStaleChallenge
+	}
 
-	// 验证是否在误差范围内
-	success := diff <= tolerance
+	// 判定是否通过
+	params.Reputation = s.lookupReputation(ctx, params.RemoteIP)
+	result = s.getVerifier().Verify(data, params)
+	result.SolveTime = s.clock.Now().Sub(data.CreatedAt)
+	result.GenerateRequestID = data.RequestID
+
+	if result.Success {
+		// 验证成功后标记为已验证，保留到过期供状态查询；只有把状态从等待验证改为已验证的请求才签发令牌，防止验证码被重放
+		swapped, err := s.markStatus(ctx, store, params.ID, data, StatusVerified)
+		if err != nil {
+			return &VerifyResult{Reason: ReasonUnavailable, GenerateRequestID: data.RequestID}, false, fmt.Errorf("%w: failed to update captcha: %w", ErrUnavailable, err)
+		}
+		if !swapped {
+			return nil, true, nil
+		}
+		token, err := s.tokens.issue(params.ID)
+		if err != nil {
+			return result, false, fmt.Errorf("failed to issue token: %w", err)
+		}
+		result.Token = token
+		return result, false, nil
+	}
 
-	// 验证后删除验证码（无论成功还是失败）
-	if success {
-		Delete(id)
+	// 记录失败次数，达到上限后作废验证码
+	updated := *data
+	updated.Attempts++
+	if updated.Attempts >= maxAttempts {
+		updated.Status = StatusFailed
+		result.Reason = ReasonTooManyAttempts
+	}
+	swapped, err := s.swapData(ctx, store, params.ID, data, &updated)
+	if err != nil {
+		s.log().Warn("failed to record verify attempt", "id", params.ID, "error", err)
+	} else if !swapped {
+		return nil, true, nil
 	}
 
-	return success, nil
+	return result, false, nil
 }
 
 // abs 返回绝对值
@@ -196,7 +386,12 @@ func abs(x int) int {
 	return x
 }
 
-// VerifyWithTolerance 使用默认误差(5像素)验证
+// DefaultTolerance 默认允许误差（像素）
+const DefaultTolerance = 5
+
+// VerifyWithTolerance 使用默认服务和默认误差(5像素)验证
+//
+// Deprecated: 使用 CaptchaService.Verify
 func VerifyWithTolerance(id string, userX int) (bool, error) {
-	return Verify(id, userX, 5)
+	return Verify(id, userX, DefaultTolerance)
 }
@@ -0,0 +1,143 @@
+package captcha
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// slowStore 每次读写都增加延迟的存储，放大并发验证时读取和更新之间的时间窗口（未实现 AtomicStore）
+type slowStore struct {
+	Store
+	delay time.Duration
+}
+
+func (s slowStore) Get(id string) (*CaptchaData, bool) {
+	time.Sleep(s.delay)
+	return s.Store.Get(id)
+}
+
+func (s slowStore) Update(id string, data *CaptchaData) {
+	time.Sleep(s.delay)
+	s.Store.(UpdateStore).Update(id, data)
+}
+
+// plainStore 只实现 Store 的基本方法（未实现 UpdateStore、ExpiryStore 等可选接口）
+type plainStore struct {
+	Store
+}
+
+// TestVerifyConcurrent 同一验证码的并发验证只能签发一个令牌，失败次数不能因并发而少计
+func TestVerifyConcurrent(t *testing.T) {
+	tests := []struct {
+		name  string
+		store func() Store
+	}{
+		{"memory", func() Store { return NewMemoryStore(time.Minute) }},
+		{"slow", func() Store { return slowStore{Store: NewMemoryStore(time.Minute), delay: 2 * time.Millisecond} }},
+		{"plain", func() Store { return plainStore{NewMemoryStore(time.Minute)} }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			const maxAttempts = 3
+			s := NewCaptchaService(WithStore(tt.store()), WithMaxAttempts(maxAttempts))
+			s.Store().Set("c1", &CaptchaData{ID: "c1", Format: DataFormatVersion, PositionX: 120, PositionY: 60})
+
+			var mu sync.Mutex
+			var successes, wrong int
+			var wg sync.WaitGroup
+			for i := range 180 {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					x := 120
+					if i%2 == 1 {
+						x = 20
+					}
+					result, _ := s.Verify(VerifyParams{ID: "c1", X: x})
+					mu.Lock()
+					defer mu.Unlock()
+					if result.Success {
+						successes++
+					}
+					if result.Reason == ReasonWrongPosition {
+						wrong++
+					}
+				}()
+			}
+			wg.Wait()
+
+			if successes > 1 {
+				t.Errorf("%d verifies succeeded, want at most 1", successes)
+			}
+			if wrong > maxAttempts {
+				t.Errorf("%d wrong-position failures recorded, want at most %d", wrong, maxAttempts)
+			}
+		})
+	}
+}
+
+// TestVerifyConcurrentAttempts 并发的错误验证逐个计入失败次数，不会互相覆盖
+func TestVerifyConcurrentAttempts(t *testing.T) {
+	tests := []struct {
+		name  string
+		store func() Store
+	}{
+		{"memory", func() Store { return NewMemoryStore(time.Minute) }},
+		{"slow", func() Store { return slowStore{Store: NewMemoryStore(time.Minute), delay: 2 * time.Millisecond} }},
+		{"plain", func() Store { return plainStore{NewMemoryStore(time.Minute)} }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			const failures = 40
+			s := NewCaptchaService(WithStore(tt.store()), WithMaxAttempts(failures+1))
+			s.Store().Set("c1", &CaptchaData{ID: "c1", Format: DataFormatVersion, PositionX: 120, PositionY: 60})
+
+			var wg sync.WaitGroup
+			for range failures {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					s.Verify(VerifyParams{ID: "c1", X: 20})
+				}()
+			}
+			wg.Wait()
+
+			data, ok := s.Store().Get("c1")
+			if !ok {
+				t.Fatal("captcha missing after failed verifies")
+			}
+			if data.Attempts != failures {
+				t.Errorf("Attempts = %d, want %d", data.Attempts, failures)
+			}
+		})
+	}
+}
+
+// TestVerifyExpiredReason 存储实现 ExpiryStore 时已过期的验证码返回 expired，未实现时返回 not_found
+func TestVerifyExpiredReason(t *testing.T) {
+	tests := []struct {
+		name string
+		wrap func(*MemoryStore) Store
+		want FailureReason
+	}{
+		{"memory", func(m *MemoryStore) Store { return m }, ReasonExpired},
+		{"plain", func(m *MemoryStore) Store { return plainStore{m} }, ReasonNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := time.Unix(1700000000, 0)
+			memory := NewMemoryStore(time.Minute)
+			defer memory.Stop()
+			memory.SetClock(ClockFunc(func() time.Time { return now }))
+			s := NewCaptchaService(WithStore(tt.wrap(memory)))
+			s.Store().Set("c1", &CaptchaData{ID: "c1", Format: DataFormatVersion, PositionX: 120, PositionY: 60})
+			now = now.Add(2 * time.Minute)
+
+			result, _ := s.Verify(VerifyParams{ID: "c1", X: 120})
+			if result.Reason != tt.want {
+				t.Errorf("Verify() reason = %q, want %q", result.Reason, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,92 @@
+// Package statsd 通过 UDP 把验证码服务的监控指标发送到 StatsD（DogStatsD 标签格式，Telegraf、Datadog Agent 等均支持）
+//
+//	client, err := statsd.New("127.0.0.1:8125", "myapp")
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	defer client.Close()
+//	svc := captcha.NewCaptchaService(captcha.WithMetrics(client))
+package statsd
+
+import (
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/gpencil/photo_captcha/captcha"
+)
+
+// Client StatsD 客户端，实现 captcha.Metrics，可并发使用
+// 发送失败（如 StatsD 未启动）时静默丢弃，不影响验证码的生成和验证
+type Client struct {
+	conn   net.Conn
+	prefix string
+}
+
+// New 创建 StatsD 客户端，addr 为 StatsD 的 UDP 地址，prefix 不为空时加在指标名称前（以 . 分隔）
+func New(addr, prefix string) (*Client, error) {
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	if prefix != "" && !strings.HasSuffix(prefix, ".") {
+		prefix += "."
+	}
+	return &Client{conn: conn, prefix: prefix}, nil
+}
+
+// Count 发送计数器（|c）
+func (c *Client) Count(name string, delta float64, labels ...captcha.Label) {
+	c.send(name, delta, "c", labels)
+}
+
+// Observe 发送直方图观测值（|h）
+func (c *Client) Observe(name string, value float64, labels ...captcha.Label) {
+	c.send(name, value, "h", labels)
+}
+
+// Gauge 发送仪表盘当前值（|g）
+func (c *Client) Gauge(name string, value float64, labels ...captcha.Label) {
+	c.send(name, value, "g", labels)
+}
+
+// Close 关闭连接
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// send 按 <prefix><name>:<value>|<type>|#<label>:<value>,... 格式发送一条指标
+func (c *Client) send(name string, value float64, kind string, labels []captcha.Label) {
+	var b strings.Builder
+	b.WriteString(c.prefix)
+	b.WriteString(name)
+	b.WriteByte(':')
+	b.WriteString(strconv.FormatFloat(value, 'f', -1, 64))
+	b.WriteByte('|')
+	b.WriteString(kind)
+	for i, label := range labels {
+		if i == 0 {
+			b.WriteString("|#")
+		} else {
+			b.WriteByte(',')
+		}
+		b.WriteString(label.Name)
+		b.WriteByte(':')
+		b.WriteString(sanitize(label.Value))
+	}
+	_, _ = c.conn.Write([]byte(b.String()))
+}
+
+// sanitize 替换标签值中会破坏 StatsD 格式的字符，空值替换为 none
+func sanitize(value string) string {
+	if value == "" {
+		return "none"
+	}
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '|', ',', ':', '#', '\n':
+			return '_'
+		}
+		return r
+	}, value)
+}
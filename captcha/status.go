@@ -0,0 +1,112 @@
+package captcha
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+)
+
+// CaptchaStatus 验证码状态
+type CaptchaStatus string
+
+const (
+	StatusPending  CaptchaStatus = "pending"  // 等待验证
+	StatusVerified CaptchaStatus = "verified" // 已验证通过
+	StatusFailed   CaptchaStatus = "failed"   // 已作废（失败次数过多、绑定不一致等）
+	StatusExpired  CaptchaStatus = "expired"  // 已过期
+)
+
+// Statuses 所有验证码状态
+var Statuses = []CaptchaStatus{StatusPending, StatusVerified, StatusFailed, StatusExpired}
+
+// Status 查询默认服务中的验证码状态（不返回答案），验证码不存在时返回 false
+// 验证通过和作废的验证码会保留到过期，便于多页面流程确认用户已完成验证
+//
+// Deprecated: 使用 CaptchaService.Status
+func Status(id string) (CaptchaStatus, bool) {
+	return defaultService.Status(id)
+}
+
+// statusIn 在指定存储中查询验证码状态
+func statusIn(ctx context.Context, store Store, id string) (CaptchaStatus, bool, error) {
+	data, exists, err := storeGet(ctx, store, id)
+	if err != nil {
+		return "", false, fmt.Errorf("%w: %w", ErrUnavailable, err)
+	}
+	if !exists {
+		expired, err := storeIsExpired(ctx, store, id)
+		if err != nil {
+			return "", false, fmt.Errorf("%w: %w", ErrUnavailable, err)
+		}
+		if expired {
+			return StatusExpired, true, nil
+		}
+		return "", false, nil
+	}
+	return data.status(), true, nil
+}
+
+// status 返回数据中记录的状态，未设置时视为等待验证
+func (d *CaptchaData) status() CaptchaStatus {
+	if d.Status == "" {
+		return StatusPending
+	}
+	return d.Status
+}
+
+// markStatus 把验证码状态从 data 中的状态原子地改为 status，返回 false 表示其他请求已先修改了该验证码
+func (s *CaptchaService) markStatus(ctx context.Context, store Store, id string, data *CaptchaData, status CaptchaStatus) (bool, error) {
+	updated := *data
+	updated.Status = status
+	return s.swapData(ctx, store, id, data, &updated)
+}
+
+// markStatusLogged 更新验证码状态，失败时只记录日志（用于作废验证码，验证结果已确定）
+// 其他请求已先修改了该验证码时不覆盖，验证码已验证通过或已作废，同样不能再使用
+func (s *CaptchaService) markStatusLogged(ctx context.Context, store Store, id string, data *CaptchaData, status CaptchaStatus) {
+	if _, err := s.markStatus(ctx, store, id, data, status); err != nil {
+		s.log().Warn("failed to update captcha status", "id", id, "status", string(status), "error", err)
+	}
+}
+
+// verifyLockStripes 存储未实现 AtomicStore 时按验证码ID分段加锁的锁数量
+const verifyLockStripes = 64
+
+// swapData 存储中的验证码状态和失败次数仍与 old 相同时原子地替换为 new，返回是否替换；
+// 存储未实现 AtomicStore 时在本实例内按ID加锁后读取、比较再更新
+func (s *CaptchaService) swapData(ctx context.Context, store Store, id string, old, new *CaptchaData) (bool, error) {
+	if as, ok := store.(AtomicStore); ok {
+		return as.CompareAndSwap(ctx, id, old, new)
+	}
+
+	h := fnv.New32a()
+	h.Write([]byte(id))
+	mu := &s.verifyLocks[h.Sum32()%verifyLockStripes]
+	mu.Lock()
+	defer mu.Unlock()
+
+	cur, exists, err := storeGet(ctx, store, id)
+	if err != nil || !exists || !cur.sameState(old) {
+		return false, err
+	}
+	return true, storeUpdate(ctx, store, id, new)
+}
+
+// Revoke 主动作废默认服务中的验证码（如用户放弃填写表单），释放存储并防止之后被重放，验证码不存在时返回 false
+//
+// Deprecated: 使用 CaptchaService.Revoke
+func Revoke(id string) bool {
+	return defaultService.Revoke(id)
+}
+
+// revokeIn 在指定存储中作废验证码
+func revokeIn(ctx context.Context, store Store, id string) (bool, error) {
+	_, exists, err := storeGet(ctx, store, id)
+	if err != nil {
+		return false, fmt.Errorf("%w: %w", ErrUnavailable, err)
+	}
+	if err := storeDelete(ctx, store, id); err != nil {
+		return false, fmt.Errorf("%w: %w", ErrUnavailable, err)
+	}
+	return exists, nil
+}
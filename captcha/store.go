@@ -1,18 +1,49 @@
 package captcha
 
 import (
+	"context"
 	"sync"
 	"time"
 )
 
+// DataFormatVersion 当前版本写入的 CaptchaData 格式版本
+// 格式不兼容地变化（如坐标含义、判定规则改变）时递增；多个实例共享存储滚动升级时，
+// 旧版本实例不会按自己的理解去验证新版本生成的验证码
+const DataFormatVersion = 1
+
 // CaptchaData 验证码数据结构
 type CaptchaData struct {
-	ID        string
+	ID string
+	// Format 生成时写入的数据格式版本（DataFormatVersion），0 表示由未记录版本的旧版本写入，按版本1处理
+	Format    int
 	PositionX int // 缺口X坐标
 	PositionY int // 缺口Y坐标
+	// TrackOffset 滑块轨道偏移（像素），验证时提交的X应为 PositionX+TrackOffset；突发流量复用图片时每个ID各不相同，否则为0
+	TrackOffset int
+	// Fingerprint 生成时绑定的客户端指纹（为空表示未绑定）
+	Fingerprint string
+	// ClientIP 生成时绑定的客户端IP（为空表示未绑定）
+	ClientIP string
+	// Session 生成时绑定的浏览器会话标识（为空表示未绑定）
+	Session string
+	// Attempts 已失败的验证次数
+	Attempts int
+	// Tolerance 生成时按难度确定的允许误差（0表示使用验证时传入的值）
+	Tolerance int
+	// RequireTrajectory 生成时按难度确定是否必须提交拖动轨迹
+	RequireTrajectory bool
+	// Status 验证状态（为空视为等待验证）
+	Status CaptchaStatus
+	// RequestID 生成该验证码的请求ID，用于关联生成和验证日志
+	RequestID string
 	CreatedAt time.Time
 }
 
+// supportedFormat 判断当前版本能否解析该数据格式
+func (d *CaptchaData) supportedFormat() bool {
+	return d.Format <= DataFormatVersion
+}
+
 // Store 验证码存储接口
 type Store interface {
 	Set(id string, data *CaptchaData)
@@ -21,19 +52,62 @@ type Store interface {
 	CleanExpired()
 }
 
+// UpdateStore 支持原地更新的验证码存储：记录失败次数、修改验证状态时不刷新创建时间，验证码仍按生成时间过期
+// 存储未实现时服务用 Set 写回，若存储在 Set 时刷新创建时间，验证码的有效期会随每次失败的验证延长（最多 MaxVerifyAttempts 次）
+type UpdateStore interface {
+	Store
+	// Update 更新已存在的验证码数据（不刷新创建时间），验证码不存在时不做任何事
+	Update(id string, data *CaptchaData)
+}
+
+// ExpiryStore 能区分已过期与不存在的验证码存储；存储未实现时已过期的验证码按不存在（ReasonNotFound）报告
+type ExpiryStore interface {
+	Store
+	// IsExpired 判断验证码是否存在但已过期
+	IsExpired(id string) bool
+}
+
+// 编译期检查接口实现
+var (
+	_ UpdateStore = (*MemoryStore)(nil)
+	_ ExpiryStore = (*MemoryStore)(nil)
+)
+
+// AtomicStore 支持原子比较并替换的验证码存储：验证时用它保证同一验证码的并发请求只有一个能从等待验证变为已验证，
+// 失败次数也不会互相覆盖。多实例共享的远程存储应实现该接口（如 Redis 用 Lua 脚本）；
+// 存储未实现时服务在本实例内按验证码ID加锁后读取、比较再更新，只能保证同一实例内的并发请求
+type AtomicStore interface {
+	Store
+	// CompareAndSwap 验证码存在、未过期且状态和失败次数仍与 old 相同时替换为 new，返回是否替换
+	CompareAndSwap(ctx context.Context, id string, old, new *CaptchaData) (bool, error)
+}
+
+// sameState 判断两份数据的验证状态和失败次数是否相同（验证只修改这两项）
+func (d *CaptchaData) sameState(other *CaptchaData) bool {
+	return d.status() == other.status() && d.Attempts == other.Attempts
+}
+
 // MemoryStore 内存存储实现
 type MemoryStore struct {
 	mu       sync.RWMutex
 	data     map[string]*CaptchaData
 	ttl      time.Duration
+	clock    Clock
 	stopChan chan struct{}
+	stopOnce sync.Once
+	// redeemed 已兑换的令牌ID（jti）及其过期时间（实现 TokenRedeemStore）
+	redeemed map[string]time.Time
+	// onExpire 清理时删除未使用的验证码后调用（由创建该存储的服务设置）
+	onExpire func(id string)
 }
 
 // NewMemoryStore 创建新的内存存储
 func NewMemoryStore(ttl time.Duration) *MemoryStore {
 	store := &MemoryStore{
 		data:     make(map[string]*CaptchaData),
+		redeemed: make(map[string]time.Time),
 		ttl:      ttl,
+		clock:    SystemClock{},
 		stopChan: make(chan struct{}),
 	}
 
@@ -48,7 +122,7 @@ func (m *MemoryStore) Set(id string, data *CaptchaData) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	data.CreatedAt = time.Now()
+	data.CreatedAt = m.clock.Now()
 	m.data[id] = data
 }
 
@@ -63,13 +137,82 @@ func (m *MemoryStore) Get(id string) (*CaptchaData, bool) {
 	}
 
 	// 检查是否过期
-	if time.Since(data.CreatedAt) > m.ttl {
+	if m.clock.Now().Sub(data.CreatedAt) > m.ttl {
 		return nil, false
 	}
 
 	return data, true
 }
 
+// GetMulti 按 ids 的顺序返回验证码数据，不存在或已过期的为nil（实现 BatchStore）
+func (m *MemoryStore) GetMulti(ctx context.Context, ids []string) ([]*CaptchaData, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	now := m.clock.Now()
+	result := make([]*CaptchaData, len(ids))
+	for i, id := range ids {
+		if data, exists := m.data[id]; exists && now.Sub(data.CreatedAt) <= m.ttl {
+			result[i] = data
+		}
+	}
+	return result, nil
+}
+
+// Update 更新验证码数据（不刷新创建时间，实现 UpdateStore）
+func (m *MemoryStore) Update(id string, data *CaptchaData) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.data[id]; exists {
+		m.data[id] = data
+	}
+}
+
+// CompareAndSwap 验证码未过期且状态和失败次数仍与 old 相同时替换为 new（实现 AtomicStore）
+func (m *MemoryStore) CompareAndSwap(ctx context.Context, id string, old, new *CaptchaData) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	cur, exists := m.data[id]
+	if !exists || m.clock.Now().Sub(cur.CreatedAt) > m.ttl || !cur.sameState(old) {
+		return false, nil
+	}
+	m.data[id] = new
+	return true, nil
+}
+
+// RedeemToken 记录令牌ID已兑换，ttl 后由清理协程删除（实现 TokenRedeemStore）
+func (m *MemoryStore) RedeemToken(ctx context.Context, jti string, ttl time.Duration) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.clock.Now()
+	if expiresAt, exists := m.redeemed[jti]; exists && !now.After(expiresAt) {
+		return false, nil
+	}
+	m.redeemed[jti] = now.Add(ttl)
+	return true, nil
+}
+
+// IsExpired 判断验证码是否存在但已过期（实现 ExpiryStore）
+func (m *MemoryStore) IsExpired(id string) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	data, exists := m.data[id]
+	return exists && m.clock.Now().Sub(data.CreatedAt) > m.ttl
+}
+
 // Delete 删除验证码数据
 func (m *MemoryStore) Delete(id string) {
 	m.mu.Lock()
@@ -81,14 +224,81 @@ func (m *MemoryStore) Delete(id string) {
 // CleanExpired 清理所有过期数据
 func (m *MemoryStore) CleanExpired() {
 	m.mu.Lock()
-	defer m.mu.Unlock()
-
-	now := time.Now()
+	var unused []string
+	now := m.clock.Now()
 	for id, data := range m.data {
 		if now.Sub(data.CreatedAt) > m.ttl {
 			delete(m.data, id)
+			if m.onExpire != nil && data.status() == StatusPending {
+				unused = append(unused, id)
+			}
 		}
 	}
+	for jti, expiresAt := range m.redeemed {
+		if now.After(expiresAt) {
+			delete(m.redeemed, jti)
+		}
+	}
+	m.mu.Unlock()
+
+	for _, id := range unused {
+		m.onExpire(id)
+	}
+}
+
+// TTL 返回验证码有效期
+func (m *MemoryStore) TTL() time.Duration {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.ttl
+}
+
+// SetTTL 调整验证码有效期，对已存储的验证码同样生效
+func (m *MemoryStore) SetTTL(ttl time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.ttl = ttl
+}
+
+// SetClock 替换时间来源（测试时使用可控的时钟），需在存储验证码之前调用
+func (m *MemoryStore) SetClock(clock Clock) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.clock = clock
+}
+
+// StoreStats 存储统计
+type StoreStats struct {
+	Total    int `json:"total"`
+	Pending  int `json:"pending"`
+	Verified int `json:"verified"`
+	Failed   int `json:"failed"`
+	Expired  int `json:"expired"` // 已过期但尚未清理
+}
+
+// Stats 按状态统计存储中的验证码
+func (m *MemoryStore) Stats() StoreStats {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var stats StoreStats
+	now := m.clock.Now()
+	for _, data := range m.data {
+		stats.Total++
+		if now.Sub(data.CreatedAt) > m.ttl {
+			stats.Expired++
+			continue
+		}
+		switch data.status() {
+		case StatusVerified:
+			stats.Verified++
+		case StatusFailed:
+			stats.Failed++
+		default:
+			stats.Pending++
+		}
+	}
+	return stats
 }
 
 // cleanupLoop 定期清理过期数据
@@ -108,23 +318,68 @@ func (m *MemoryStore) cleanupLoop() {
 
 // Stop 停止存储
 func (m *MemoryStore) Stop() {
-	close(m.stopChan)
+	m.stopOnce.Do(func() {
+		close(m.stopChan)
+	})
 }
 
-// 默认存储实例，5分钟过期
-var defaultStore = NewMemoryStore(5 * time.Minute)
+// DefaultTTL 默认的验证码有效期
+const DefaultTTL = 5 * time.Minute
 
-// Set 使用默认存储存储数据
+// SetDefaultStore 替换默认服务使用的存储，需在服务启动时（生成验证码之前）调用
+// 原默认存储为 MemoryStore 时会停止其清理协程
+//
+// Deprecated: 使用 NewCaptchaService(WithStore(...))
+func SetDefaultStore(store Store) {
+	defaultService.replaceStore(store)
+}
+
+// DefaultStore 返回默认服务使用的存储
+//
+// Deprecated: 使用 CaptchaService.Store
+func DefaultStore() Store {
+	return defaultService.Store()
+}
+
+// StopDefaultStore 停止默认存储的后台清理协程（服务停机时调用）
+//
+// Deprecated: 使用 CaptchaService.Close
+func StopDefaultStore() {
+	defaultService.Close()
+}
+
+// Set 使用默认服务的存储存储数据
+//
+// Deprecated: 使用 CaptchaService.Store
 func Set(id string, data *CaptchaData) {
-	defaultStore.Set(id, data)
+	defaultService.Store().Set(id, data)
 }
 
-// Get 使用默认存储获取数据
+// Get 使用默认服务的存储获取数据
+//
+// Deprecated: 使用 CaptchaService.Store
 func Get(id string) (*CaptchaData, bool) {
-	return defaultStore.Get(id)
+	return defaultService.Store().Get(id)
+}
+
+// Update 使用默认服务的存储更新数据
+//
+// Deprecated: 使用 CaptchaService.Store
+func Update(id string, data *CaptchaData) {
+	_ = storeUpdate(context.Background(), defaultService.Store(), id, data)
+}
+
+// IsExpired 使用默认服务的存储判断数据是否已过期
+//
+// Deprecated: 使用 CaptchaService.Store
+func IsExpired(id string) bool {
+	expired, _ := storeIsExpired(context.Background(), defaultService.Store(), id)
+	return expired
 }
 
-// Delete 使用默认存储删除数据
+// Delete 使用默认服务的存储删除数据
+//
+// Deprecated: 使用 CaptchaService.Store
 func Delete(id string) {
-	defaultStore.Delete(id)
+	defaultService.Store().Delete(id)
 }
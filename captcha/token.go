@@ -0,0 +1,215 @@
+package captcha
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"hash"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TokenTTL 验证通过后签发的令牌有效期（未通过 WithTokenTTL 配置的服务使用该值）
+var TokenTTL = 2 * time.Minute
+
+// TokenFormatVersion 签发的令牌格式版本，令牌格式为 v<版本>.<令牌ID>.<验证码ID>.<签发时间>.<kid>.<HMAC>，令牌ID即 jti
+// 校验时只接受当前版本的令牌，滚动升级时不会把其他版本签发的令牌误判为有效
+const TokenFormatVersion = 2
+
+// tokenPrefix 当前版本令牌的前缀
+var tokenPrefix = "v" + strconv.Itoa(TokenFormatVersion) + "."
+
+// TokenRedeemStore 记录已兑换令牌的存储：令牌ID（jti）在令牌过期前只能兑换一次
+// 验证码存储实现该接口后兑换记录与验证码保存在一起，多实例共享存储时令牌在任一实例兑换后，其他实例再次提交同样判定为重放；
+// MemoryStore 和 redis.CaptchaStore 已实现，存储未实现时兑换记录保存在服务内存中
+type TokenRedeemStore interface {
+	// RedeemToken 记录令牌ID已兑换，ttl 后可以遗忘；返回 false 表示令牌ID在此之前已兑换过
+	RedeemToken(ctx context.Context, jti string, ttl time.Duration) (bool, error)
+}
+
+// tokenStore 签发和校验一次性令牌：令牌用签名验证码ID的密钥（keys）签名，服务端不保存签发的令牌，
+// 持有相同密钥的实例都能校验；未配置密钥时使用随机生成的 fallback 密钥，令牌只能由签发它的服务校验
+type tokenStore struct {
+	mu       sync.Mutex
+	ttl      time.Duration
+	clock    Clock
+	keys     *keyRing
+	fallback []byte
+	// redeemed 验证码存储未实现 TokenRedeemStore 时已兑换的令牌ID（jti）及其过期时间，用于识别重放
+	redeemed map[string]time.Time
+	// cleanedAt 上次清理过期令牌的时间
+	cleanedAt time.Time
+	// macs 复用的 HMAC 状态（*tokenMAC）
+	macs sync.Pool
+}
+
+// newTokenStore 创建令牌存储，keys 为签名验证码ID的密钥
+func newTokenStore(keys *keyRing) *tokenStore {
+	fallback := make([]byte, 32)
+	if _, err := rand.Read(fallback); err != nil {
+		panic("captcha: failed to read random bytes: " + err.Error())
+	}
+	return &tokenStore{
+		clock:    SystemClock{},
+		keys:     keys,
+		fallback: fallback,
+		redeemed: make(map[string]time.Time),
+	}
+}
+
+// tokenTTL 令牌有效期
+func (t *tokenStore) tokenTTL() time.Duration {
+	if t.ttl <= 0 {
+		return TokenTTL
+	}
+	return t.ttl
+}
+
+// signingKey 返回签发时间为 issued 的令牌使用的 kid 和密钥
+func (t *tokenStore) signingKey(issued time.Time) (string, []byte) {
+	if len(t.keys.keys) == 0 {
+		return "", t.fallback
+	}
+	return t.keys.signingKey(issued)
+}
+
+// lookup 返回 kid 对应的密钥
+func (t *tokenStore) lookup(kid string, issued time.Time) ([]byte, bool) {
+	if len(t.keys.keys) == 0 {
+		return t.fallback, kid == ""
+	}
+	return t.keys.lookup(kid, issued)
+}
+
+// issue 为验证通过的验证码签发一次性令牌
+func (t *tokenStore) issue(captchaID string) (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	issued := time.Unix(t.clock.Now().Unix(), 0)
+	kid, secret := t.signingKey(issued)
+
+	// 一次性拼接，减少验证热路径上的内存分配
+	token := make([]byte, 0, len(tokenPrefix)+len(buf)*2+base64.RawURLEncoding.EncodedLen(len(captchaID))+len(kid)+64)
+	token = append(token, tokenPrefix...)
+	token = hex.AppendEncode(token, buf)
+	token = append(token, '.')
+	token = base64.RawURLEncoding.AppendEncode(token, []byte(captchaID))
+	token = append(token, '.')
+	token = strconv.AppendInt(token, issued.Unix(), 36)
+	token = append(token, '.')
+	token = append(token, kid...)
+	token = t.appendSignature(token, kid, secret)
+	return string(token), nil
+}
+
+// tokenMAC 可复用的 HMAC 状态，签发和校验令牌时从池中取用，避免每次重新初始化
+type tokenMAC struct {
+	kid    string
+	secret []byte
+	hash   hash.Hash
+	sum    []byte
+}
+
+// appendSignature 计算 payload 的签名，追加 "." 和签名（URL安全的base64，与验证码ID的签名相同）后返回
+func (t *tokenStore) appendSignature(payload []byte, kid string, secret []byte) []byte {
+	m, _ := t.macs.Get().(*tokenMAC)
+	if m == nil || m.kid != kid || !hmac.Equal(m.secret, secret) {
+		m = &tokenMAC{kid: kid, secret: secret, hash: hmac.New(sha256.New, secret)}
+	}
+	m.hash.Reset()
+	m.hash.Write(payload)
+	m.sum = m.hash.Sum(m.sum[:0])
+	payload = append(payload, '.')
+	payload = base64.RawURLEncoding.AppendEncode(payload, m.sum[:idSignatureBytes])
+	t.macs.Put(m)
+	return payload
+}
+
+// validate 校验并消费令牌，返回对应的验证码ID；replayed 表示令牌在有效期内已被兑换过（重放）
+// 兑换记录保存在 store 中（实现 TokenRedeemStore 时），否则保存在内存中
+func (t *tokenStore) validate(ctx context.Context, store Store, token string) (captchaID string, ok, replayed bool, err error) {
+	payload, _, found := cutLast(token, ".")
+	if !found || !strings.HasPrefix(payload, tokenPrefix) {
+		return "", false, false, nil
+	}
+	// payload 为 v<版本>.<jti>.<验证码ID>.<签发时间>.<kid>
+	parts := strings.Split(strings.TrimPrefix(payload, tokenPrefix), ".")
+	if len(parts) != 4 {
+		return "", false, false, nil
+	}
+	jti, encodedID, issuedAt, kid := parts[0], parts[1], parts[2], parts[3]
+	sec, err := strconv.ParseInt(issuedAt, 36, 64)
+	if err != nil {
+		return "", false, false, nil
+	}
+	issued := time.Unix(sec, 0)
+	secret, found := t.lookup(kid, issued)
+	if !found || !hmac.Equal([]byte(token), t.appendSignature([]byte(payload), kid, secret)) {
+		return "", false, false, nil
+	}
+	id, err := base64.RawURLEncoding.DecodeString(encodedID)
+	if err != nil {
+		return "", false, false, nil
+	}
+
+	now := t.clock.Now()
+	expiresAt := issued.Add(t.tokenTTL())
+	if now.After(expiresAt) {
+		return "", false, false, nil
+	}
+
+	fresh, err := t.redeem(ctx, store, jti, now, expiresAt)
+	if err != nil {
+		return string(id), false, false, err
+	}
+	return string(id), fresh, !fresh, nil
+}
+
+// redeem 记录令牌ID已兑换，返回 false 表示此前已兑换过
+func (t *tokenStore) redeem(ctx context.Context, store Store, jti string, now, expiresAt time.Time) (bool, error) {
+	if rs, ok := store.(TokenRedeemStore); ok {
+		return rs.RedeemToken(ctx, jti, expiresAt.Sub(now))
+	}
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.cleanLocked(now)
+	if redeemedUntil, exists := t.redeemed[jti]; exists && !now.After(redeemedUntil) {
+		return false, nil
+	}
+	t.redeemed[jti] = expiresAt
+	return true, nil
+}
+
+// cleanLocked 每分钟最多清理一次已过期的兑换记录，调用方需持有锁
+func (t *tokenStore) cleanLocked(now time.Time) {
+	if now.Sub(t.cleanedAt) < time.Minute {
+		return
+	}
+	t.cleanedAt = now
+
+	for jti, expiresAt := range t.redeemed {
+		if now.After(expiresAt) {
+			delete(t.redeemed, jti)
+		}
+	}
+}
+
+// ValidateToken 校验默认服务签发的令牌，返回对应的验证码ID
+// 令牌只能使用一次，业务方在处理受保护的请求（登录、注册等）时调用
+//
+// Deprecated: 使用 CaptchaService.ValidateToken
+func ValidateToken(token string) (string, bool) {
+	return defaultService.ValidateToken(token)
+}
@@ -0,0 +1,121 @@
+package captcha
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidateToken(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	clock := ClockFunc(func() time.Time { return now })
+	keys := []SigningKey{{ID: "k1", Secret: "secret-1"}}
+
+	tests := []struct {
+		name      string
+		issuer    []Option
+		validator []Option
+		mutate    func(token string) string
+		advance   time.Duration
+		want      bool
+	}{
+		{name: "valid", want: true},
+		{name: "shared keys across services", issuer: []Option{WithIDKeys(keys...)}, validator: []Option{WithIDKeys(keys...)}, want: true},
+		{name: "other service without keys", validator: []Option{}, want: false},
+		{name: "rotated key still verifies",
+			issuer:    []Option{WithIDKeys(keys...)},
+			validator: []Option{WithIDKeys(SigningKey{ID: "k2", Secret: "secret-2"}, keys[0])},
+			want:      true},
+		{name: "removed key", issuer: []Option{WithIDKeys(keys...)}, validator: []Option{WithIDKeys(SigningKey{ID: "k2", Secret: "secret-2"})}, want: false},
+		{name: "tampered captcha id", issuer: []Option{WithIDKeys(keys...)}, validator: []Option{WithIDKeys(keys...)},
+			mutate: func(token string) string {
+				parts := strings.Split(token, ".")
+				parts[2] = "b3RoZXI"
+				return strings.Join(parts, ".")
+			}, want: false},
+		{name: "old version", mutate: func(token string) string { return "v1." + strings.TrimPrefix(token, tokenPrefix) }, want: false},
+		{name: "garbage", mutate: func(string) string { return "v2.abc" }, want: false},
+		{name: "expired", advance: TokenTTL + time.Second, want: false},
+		{name: "within ttl", advance: TokenTTL - time.Second, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now = time.Unix(1700000000, 0)
+			issuer := NewCaptchaService(append([]Option{WithClock(clock)}, tt.issuer...)...)
+			validator := issuer
+			if tt.validator != nil {
+				validator = NewCaptchaService(append([]Option{WithClock(clock)}, tt.validator...)...)
+			}
+
+			token, err := issuer.tokens.issue("captcha-1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.mutate != nil {
+				token = tt.mutate(token)
+			}
+			now = now.Add(tt.advance)
+
+			captchaID, ok := validator.ValidateToken(token)
+			if ok != tt.want {
+				t.Fatalf("ValidateToken() ok = %v, want %v", ok, tt.want)
+			}
+			if ok && captchaID != "captcha-1" {
+				t.Errorf("ValidateToken() captchaID = %q, want %q", captchaID, "captcha-1")
+			}
+		})
+	}
+}
+
+// TestValidateTokenOnce 令牌只能兑换一次，重放时计入指标
+func TestValidateTokenOnce(t *testing.T) {
+	metrics := &countingMetrics{}
+	s := NewCaptchaService(WithMetrics(metrics))
+	token, err := s.tokens.issue("captcha-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := s.ValidateToken(token); !ok {
+		t.Fatal("first ValidateToken() failed")
+	}
+	if _, ok := s.ValidateToken(token); ok {
+		t.Error("replayed token accepted")
+	}
+	if metrics.counts[MetricTokenReplay] != 1 {
+		t.Errorf("%s = %v, want 1", MetricTokenReplay, metrics.counts[MetricTokenReplay])
+	}
+}
+
+// countingMetrics 按指标名累计计数
+type countingMetrics struct {
+	nopMetrics
+	counts map[string]float64
+}
+
+func (m *countingMetrics) Count(name string, delta float64, labels ...Label) {
+	if m.counts == nil {
+		m.counts = make(map[string]float64)
+	}
+	m.counts[name] += delta
+}
+
+// TestValidateTokenSharedStore 共享存储的多个服务（多实例）中，令牌在任一服务兑换后其他服务判定为重放
+func TestValidateTokenSharedStore(t *testing.T) {
+	store := NewMemoryStore(time.Minute)
+	defer store.Stop()
+	keys := WithIDKeys(SigningKey{ID: "k1", Secret: "secret-1"})
+	a := NewCaptchaService(WithStore(store), keys)
+	b := NewCaptchaService(WithStore(store), keys)
+
+	token, err := a.tokens.issue("captcha-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := b.ValidateToken(token); !ok {
+		t.Fatal("ValidateToken() on other service failed")
+	}
+	if _, ok := a.ValidateToken(token); ok {
+		t.Error("token redeemed on other service accepted again")
+	}
+}
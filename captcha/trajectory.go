@@ -0,0 +1,136 @@
+package captcha
+
+import (
+	"math"
+)
+
+// TrajectoryPoint 拖动轨迹采样点
+type TrajectoryPoint struct {
+	X int   `json:"x"` // 滑块X坐标
+	Y int   `json:"y"` // 指针Y坐标
+	T int64 `json:"t"` // 相对拖动开始的时间（毫秒）
+}
+
+// TrajectoryFeatures 从拖动轨迹中提取的速度/加速度特征
+type TrajectoryFeatures struct {
+	PointCount int   // 有效采样点数
+	Duration   int64 // 拖动总耗时（毫秒）
+
+	Velocities    []float64 // 速度序列（像素/毫秒）
+	Accelerations []float64 // 加速度序列（像素/毫秒²）
+
+	MeanVelocity       float64 // 平均速度
+	MaxVelocity        float64 // 最大速度
+	VelocityStdDev     float64 // 速度标准差
+	MeanAcceleration   float64 // 平均加速度
+	AccelerationStdDev float64 // 加速度标准差
+	YJitter            float64 // Y方向抖动（标准差）
+
+	ZeroJitter            bool // Y方向完全没有抖动且速度恒定
+	ConstantVelocity      bool // 速度几乎恒定
+	MonotonicAcceleration bool // 加速度单调变化（匀变速脚本特征）
+	TimeReversed          bool // 时间戳非递增（伪造轨迹）
+}
+
+// 轨迹分析阈值
+const (
+	minTrajectoryPoints     = 5    // 至少需要的采样点数
+	constantVelocityEpsilon = 0.01 // 速度标准差低于此值视为匀速
+	jitterEpsilon           = 0.01 // Y方向标准差低于此值视为无抖动
+)
+
+// AnalyzeTrajectory 计算轨迹的速度和加速度序列并提取特征
+func AnalyzeTrajectory(points []TrajectoryPoint) *TrajectoryFeatures {
+	features := &TrajectoryFeatures{
+		PointCount: len(points),
+	}
+	if len(points) < 2 {
+		return features
+	}
+
+	features.Duration = points[len(points)-1].T - points[0].T
+
+	// 速度序列：相邻采样点之间的水平位移/时间差
+	velocityTimes := make([]int64, 0, len(points))
+	for i := 1; i < len(points); i++ {
+		dt := points[i].T - points[i-1].T
+		if dt < 0 {
+			features.TimeReversed = true
+			continue
+		}
+		if dt == 0 {
+			// 同一毫秒内的多次事件，跳过避免除零
+			continue
+		}
+		dx := float64(points[i].X - points[i-1].X)
+		features.Velocities = append(features.Velocities, dx/float64(dt))
+		velocityTimes = append(velocityTimes, points[i].T)
+	}
+
+	// 加速度序列：相邻速度之差（按采样点时间间隔归一化）
+	for i := 1; i < len(features.Velocities); i++ {
+		dt := float64(velocityTimes[i] - velocityTimes[i-1])
+		if dt <= 0 {
+			continue
+		}
+		features.Accelerations = append(features.Accelerations,
+			(features.Velocities[i]-features.Velocities[i-1])/dt)
+	}
+
+	features.MeanVelocity, features.VelocityStdDev = meanStdDev(features.Velocities)
+	features.MeanAcceleration, features.AccelerationStdDev = meanStdDev(features.Accelerations)
+	for _, v := range features.Velocities {
+		if math.Abs(v) > features.MaxVelocity {
+			features.MaxVelocity = math.Abs(v)
+		}
+	}
+
+	ys := make([]float64, len(points))
+	for i, p := range points {
+		ys[i] = float64(p.Y)
+	}
+	_, features.YJitter = meanStdDev(ys)
+
+	if len(features.Velocities) >= minTrajectoryPoints-1 {
+		features.ConstantVelocity = features.VelocityStdDev < constantVelocityEpsilon
+		features.ZeroJitter = features.YJitter < jitterEpsilon && features.ConstantVelocity
+	}
+	if len(features.Accelerations) >= minTrajectoryPoints-2 {
+		features.MonotonicAcceleration = isMonotonic(features.Accelerations)
+	}
+
+	return features
+}
+
+// meanStdDev 计算均值和标准差
+func meanStdDev(values []float64) (mean, stdDev float64) {
+	if len(values) == 0 {
+		return 0, 0
+	}
+
+	for _, v := range values {
+		mean += v
+	}
+	mean /= float64(len(values))
+
+	for _, v := range values {
+		stdDev += (v - mean) * (v - mean)
+	}
+	stdDev = math.Sqrt(stdDev / float64(len(values)))
+
+	return mean, stdDev
+}
+
+// isMonotonic 判断序列是否严格单调（人手拖动的加速度总会有反复）
+func isMonotonic(values []float64) bool {
+	increasing, decreasing := true, true
+	for i := 1; i < len(values); i++ {
+		if values[i] <= values[i-1] {
+			increasing = false
+		}
+		if values[i] >= values[i-1] {
+			decreasing = false
+		}
+	}
+	return increasing || decreasing
+}
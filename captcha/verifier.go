@@ -0,0 +1,93 @@
+package captcha
+
+import "log/slog"
+
+// Verifier 判定一次验证是否通过
+// 验证码查找、状态、绑定校验、失败计数和令牌签发由 CaptchaService.Verify 统一处理，
+// Verifier 只负责根据存储的答案和用户提交的参数给出结果，可包装或替换默认实现（如增加基于模型的轨迹分类）
+type Verifier interface {
+	// Verify 返回非nil的结果，Success 为 false 时需给出 Reason
+	Verify(data *CaptchaData, params VerifyParams) *VerifyResult
+}
+
+// VerifierFunc 函数形式的 Verifier
+type VerifierFunc func(data *CaptchaData, params VerifyParams) *VerifyResult
+
+// Verify 调用函数本身
+func (f VerifierFunc) Verify(data *CaptchaData, params VerifyParams) *VerifyResult {
+	return f(data, params)
+}
+
+// PositionVerifier 默认验证器：校验位置误差、拖动耗时和轨迹风险
+type PositionVerifier struct {
+	// SuspiciousIPs 可疑IP集合，为nil时使用 DefaultSuspiciousIPs
+	SuspiciousIPs *SuspiciousIPs
+	// Logger 输出轨迹特征的调试日志，为nil时使用 Logger()
+	Logger *slog.Logger
+}
+
+// DefaultVerifier 未通过 WithVerifier 配置的服务使用的验证器
+var DefaultVerifier Verifier = PositionVerifier{}
+
+// Verify 校验位置误差、拖动耗时和轨迹风险
+func (v PositionVerifier) Verify(data *CaptchaData, params VerifyParams) *VerifyResult {
+	// 计算误差，生成时按难度确定了更严格的容差则以其为准
+	diff := abs(params.X - data.PositionX - data.TrackOffset)
+	tolerance := params.Tolerance
+	if data.Tolerance > 0 && data.Tolerance < tolerance {
+		tolerance = data.Tolerance
+	}
+
+	// 轨迹风险评估
+	risk := AssessRisk(params.Trajectory)
+	suspicious := v.SuspiciousIPs
+	if suspicious == nil {
+		suspicious = DefaultSuspiciousIPs
+	}
+	if params.RemoteIP != "" && suspicious.IsFlagged(params.RemoteIP) {
+		risk.addFlag(RiskFlagFlaggedIP)
+	}
+	if params.Reputation >= ReputationMalicious {
+		risk.addFlag(RiskFlagMaliciousIP)
+	}
+	if risk.Features != nil {
+		log := v.Logger
+		if log == nil {
+			log = Logger()
+		}
+		f := risk.Features
+		log.Debug("trajectory features",
+			"id", params.ID,
+			"points", f.PointCount,
+			"durationMs", f.Duration,
+			"meanVelocity", f.MeanVelocity,
+			"maxVelocity", f.MaxVelocity,
+			"velocityStdDev", f.VelocityStdDev,
+			"meanAcceleration", f.MeanAcceleration,
+			"accelerationStdDev", f.AccelerationStdDev,
+			"yJitter", f.YJitter,
+			"flags", risk.Flags,
+			"score", risk.Score,
+		)
+	}
+
+	result := &VerifyResult{
+		Diff: diff,
+		Risk: risk,
+	}
+
+	// 依次检查位置、拖动耗时和风险分
+	switch {
+	case diff > tolerance:
+		result.Reason = ReasonWrongPosition
+	case risk.HasFlag(RiskFlagTooFast):
+		result.Reason = ReasonTooFast
+	case risk.Rejected():
+		result.Reason = ReasonRiskRejected
+	case data.RequireTrajectory && risk.HasFlag(RiskFlagMissingTrajectory):
+		result.Reason = ReasonRiskRejected
+	default:
+		result.Success = true
+	}
+	return result
+}
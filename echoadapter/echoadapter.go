@@ -0,0 +1,126 @@
+// Package echoadapter 提供 Echo 框架的验证码生成/验证处理器，接口格式与 Gin 服务一致
+//
+//	e := echo.New()
+//	echoadapter.RegisterRoutes(e.Group("/api/v1/captcha"), echoadapter.New())
+package echoadapter
+
+import (
+	"net/http"
+
+	"github.com/gpencil/photo_captcha/captcha"
+	"github.com/gpencil/photo_captcha/httpapi"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Handlers Echo 处理器集合
+type Handlers struct {
+	// Service 生成和验证使用的验证码服务
+	Service *captcha.CaptchaService
+	// Tolerance 验证允许的误差（像素）
+	Tolerance int
+	// BindClientIP 是否将验证码绑定到生成时的客户端IP
+	BindClientIP bool
+	// ActionSecret 校验 actionStart（httpapi.SignActionStart 签名的受保护操作开始时间）的密钥，为空时忽略该字段
+	ActionSecret string
+}
+
+// New 使用默认服务和默认误差创建处理器
+func New() *Handlers {
+	return &Handlers{
+		Service:   captcha.Default(),
+		Tolerance: captcha.DefaultTolerance,
+	}
+}
+
+// RegisterRoutes 在路由组下注册 GET /generate 和 POST /verify
+func RegisterRoutes(g *echo.Group, h *Handlers) {
+	g.GET("/generate", h.Generate)
+	g.POST("/verify", h.Verify)
+}
+
+// Generate 生成验证码处理器
+func (h *Handlers) Generate(c echo.Context) error {
+	sliderCaptcha, err := h.Service.GenerateContext(c.Request().Context(), captcha.GenerateParams{
+		Fingerprint: c.QueryParam("fingerprint"),
+		ClientIP:    h.boundClientIP(c),
+		Difficulty:  captcha.DefaultDifficulty.Level(c.RealIP()),
+		RequestID:   requestID(c),
+	})
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]interface{}{
+			"code":    500,
+			"message": "Failed to generate captcha: " + err.Error(),
+		})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"code":    200,
+		"message": "success",
+		"data":    httpapi.CaptchaData(sliderCaptcha),
+	})
+}
+
+// Verify 验证滑块位置处理器
+func (h *Handlers) Verify(c echo.Context) error {
+	var req httpapi.VerifyRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"code":    400,
+			"message": "Invalid request: " + err.Error(),
+		})
+	}
+	if err := req.Validate(); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"code":    400,
+			"message": "Invalid request: " + err.Error(),
+		})
+	}
+
+	result, err := h.Service.VerifyContext(c.Request().Context(), captcha.VerifyParams{
+		ID:          req.ID,
+		X:           req.X.Int(),
+		Tolerance:   h.Tolerance,
+		Trajectory:  req.Trajectory,
+		Fingerprint: req.Fingerprint,
+		ClientIP:    h.boundClientIP(c),
+		RemoteIP:    c.RealIP(),
+		RequestID:   requestID(c),
+
+		ActionStartedAt: req.ActionStartTime(h.ActionSecret),
+	})
+	if err != nil {
+		return c.JSON(http.StatusOK, map[string]interface{}{
+			"code":    400,
+			"message": err.Error(),
+			"data":    httpapi.VerifyResultData(result),
+		})
+	}
+	captcha.DefaultDifficulty.Record(c.RealIP(), result.Success)
+
+	message := "Verification failed"
+	if result.Success {
+		message = "Verification successful"
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"code":    200,
+		"message": message,
+		"data":    httpapi.VerifyResultData(result),
+	})
+}
+
+// boundClientIP 开启IP绑定时返回客户端IP，否则返回空字符串
+func (h *Handlers) boundClientIP(c echo.Context) string {
+	if !h.BindClientIP {
+		return ""
+	}
+	return c.RealIP()
+}
+
+// requestID 读取请求ID（配合 echo 的 middleware.RequestID 使用）
+func requestID(c echo.Context) string {
+	if id := c.Response().Header().Get(echo.HeaderXRequestID); id != "" {
+		return id
+	}
+	return c.Request().Header.Get(echo.HeaderXRequestID)
+}
@@ -0,0 +1,27 @@
+module github.com/gpencil/photo_captcha/echoadapter
+
+go 1.24.0
+
+require (
+	github.com/gpencil/photo_captcha v0.0.0
+	github.com/gpencil/photo_captcha/captcha v0.0.0
+	github.com/labstack/echo/v4 v4.13.4
+)
+
+require (
+	github.com/google/uuid v1.6.0 // indirect
+	github.com/labstack/gommon v0.4.2 // indirect
+	github.com/mattn/go-colorable v0.1.14 // indirect
+	github.com/mattn/go-isatty v0.0.20 // indirect
+	github.com/valyala/bytebufferpool v1.0.0 // indirect
+	github.com/valyala/fasttemplate v1.2.2 // indirect
+	golang.org/x/crypto v0.40.0 // indirect
+	golang.org/x/net v0.42.0 // indirect
+	golang.org/x/sys v0.35.0 // indirect
+	golang.org/x/text v0.27.0 // indirect
+)
+
+replace (
+	github.com/gpencil/photo_captcha => ../
+	github.com/gpencil/photo_captcha/captcha => ../captcha
+)
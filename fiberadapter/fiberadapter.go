@@ -0,0 +1,127 @@
+// Package fiberadapter 提供 Fiber 框架（基于 fasthttp）的验证码生成/验证处理器，接口格式与 Gin 服务一致
+//
+//	app := fiber.New()
+//	fiberadapter.RegisterRoutes(app.Group("/api/v1/captcha"), fiberadapter.New())
+package fiberadapter
+
+import (
+	"encoding/json"
+
+	"github.com/gpencil/photo_captcha/captcha"
+	"github.com/gpencil/photo_captcha/httpapi"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Handlers Fiber 处理器集合
+type Handlers struct {
+	// Service 生成和验证使用的验证码服务
+	Service *captcha.CaptchaService
+	// Tolerance 验证允许的误差（像素）
+	Tolerance int
+	// BindClientIP 是否将验证码绑定到生成时的客户端IP
+	BindClientIP bool
+	// ActionSecret 校验 actionStart（httpapi.SignActionStart 签名的受保护操作开始时间）的密钥，为空时忽略该字段
+	ActionSecret string
+}
+
+// New 使用默认服务和默认误差创建处理器
+func New() *Handlers {
+	return &Handlers{
+		Service:   captcha.Default(),
+		Tolerance: captcha.DefaultTolerance,
+	}
+}
+
+// RegisterRoutes 在路由组下注册 GET /generate 和 POST /verify
+func RegisterRoutes(router fiber.Router, h *Handlers) {
+	router.Get("/generate", h.Generate)
+	router.Post("/verify", h.Verify)
+}
+
+// Generate 生成验证码处理器
+func (h *Handlers) Generate(c *fiber.Ctx) error {
+	sliderCaptcha, err := h.Service.GenerateContext(c.UserContext(), captcha.GenerateParams{
+		Fingerprint: c.Query("fingerprint"),
+		ClientIP:    h.boundClientIP(c),
+		Difficulty:  captcha.DefaultDifficulty.Level(c.IP()),
+		RequestID:   requestID(c),
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"code":    500,
+			"message": "Failed to generate captcha: " + err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"code":    200,
+		"message": "success",
+		"data":    httpapi.CaptchaData(sliderCaptcha),
+	})
+}
+
+// Verify 验证滑块位置处理器
+func (h *Handlers) Verify(c *fiber.Ctx) error {
+	// 请求体直接按 encoding/json 解析，保证 Coordinate 的兼容解析生效
+	var req httpapi.VerifyRequest
+	if err := json.Unmarshal(c.Body(), &req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"code":    400,
+			"message": "Invalid request: " + err.Error(),
+		})
+	}
+	if err := req.Validate(); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"code":    400,
+			"message": "Invalid request: " + err.Error(),
+		})
+	}
+
+	result, err := h.Service.VerifyContext(c.UserContext(), captcha.VerifyParams{
+		ID:          req.ID,
+		X:           req.X.Int(),
+		Tolerance:   h.Tolerance,
+		Trajectory:  req.Trajectory,
+		Fingerprint: req.Fingerprint,
+		ClientIP:    h.boundClientIP(c),
+		RemoteIP:    c.IP(),
+		RequestID:   requestID(c),
+
+		ActionStartedAt: req.ActionStartTime(h.ActionSecret),
+	})
+	if err != nil {
+		return c.JSON(fiber.Map{
+			"code":    400,
+			"message": err.Error(),
+			"data":    httpapi.VerifyResultData(result),
+		})
+	}
+	captcha.DefaultDifficulty.Record(c.IP(), result.Success)
+
+	message := "Verification failed"
+	if result.Success {
+		message = "Verification successful"
+	}
+	return c.JSON(fiber.Map{
+		"code":    200,
+		"message": message,
+		"data":    httpapi.VerifyResultData(result),
+	})
+}
+
+// boundClientIP 开启IP绑定时返回客户端IP，否则返回空字符串
+func (h *Handlers) boundClientIP(c *fiber.Ctx) string {
+	if !h.BindClientIP {
+		return ""
+	}
+	return c.IP()
+}
+
+// requestID 读取请求ID（配合 fiber 的 requestid 中间件使用）
+func requestID(c *fiber.Ctx) string {
+	if id := c.GetRespHeader(fiber.HeaderXRequestID); id != "" {
+		return id
+	}
+	return c.Get(fiber.HeaderXRequestID)
+}
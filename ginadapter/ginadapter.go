@@ -0,0 +1,45 @@
+// Package ginadapter 提供 Gin 框架的验证码令牌校验中间件
+//
+// captcha 包不依赖任何Web框架，Gin 相关的代码放在这里：
+//
+//	router.POST("/login", ginadapter.RequireToken(svc), LoginHandler)
+package ginadapter
+
+import (
+	"net/http"
+
+	"github.com/gpencil/photo_captcha/captcha"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TokenContextKey 令牌校验通过后，对应的验证码ID保存在 gin.Context 中的键
+const TokenContextKey = "captchaID"
+
+// RequireToken 要求请求携带 svc 签发的令牌，用于保护登录、注册等接口
+// 令牌从 captcha.TokenHeader 请求头或 captcha.TokenField 表单字段读取，校验后即被消费；
+// 缺少令牌或令牌无效、已使用、已过期时返回 403 并中止请求
+func RequireToken(svc *captcha.CaptchaService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := captcha.TokenFromRequest(c.Request)
+		if token == "" {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"code":    403,
+				"message": "captcha token required",
+			})
+			return
+		}
+
+		captchaID, ok, _ := svc.ValidateTokenContext(c.Request.Context(), token)
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"code":    403,
+				"message": "invalid captcha token",
+			})
+			return
+		}
+
+		c.Set(TokenContextKey, captchaID)
+		c.Next()
+	}
+}
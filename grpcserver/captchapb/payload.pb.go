@@ -0,0 +1,367 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.9
+// 	protoc        v5.29.3
+// source: payload.proto
+
+package captchapb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type SliderCaptcha struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Type          string                 `protobuf:"bytes,2,opt,name=type,proto3" json:"type,omitempty"`
+	Background    string                 `protobuf:"bytes,3,opt,name=background,proto3" json:"background,omitempty"`
+	Slider        string                 `protobuf:"bytes,4,opt,name=slider,proto3" json:"slider,omitempty"`
+	PositionY     int32                  `protobuf:"varint,5,opt,name=position_y,json=positionY,proto3" json:"position_y,omitempty"`
+	Width         int32                  `protobuf:"varint,6,opt,name=width,proto3" json:"width,omitempty"`
+	Height        int32                  `protobuf:"varint,7,opt,name=height,proto3" json:"height,omitempty"`
+	PieceWidth    int32                  `protobuf:"varint,8,opt,name=piece_width,json=pieceWidth,proto3" json:"piece_width,omitempty"`
+	PieceHeight   int32                  `protobuf:"varint,9,opt,name=piece_height,json=pieceHeight,proto3" json:"piece_height,omitempty"`
+	Shape         string                 `protobuf:"bytes,10,opt,name=shape,proto3" json:"shape,omitempty"`
+	ExpiresAt     *timestamppb.Timestamp `protobuf:"bytes,11,opt,name=expires_at,json=expiresAt,proto3" json:"expires_at,omitempty"`
+	TrackOffset   int32                  `protobuf:"varint,12,opt,name=track_offset,json=trackOffset,proto3" json:"track_offset,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SliderCaptcha) Reset() {
+	*x = SliderCaptcha{}
+	mi := &file_payload_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SliderCaptcha) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SliderCaptcha) ProtoMessage() {}
+
+func (x *SliderCaptcha) ProtoReflect() protoreflect.Message {
+	mi := &file_payload_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SliderCaptcha.ProtoReflect.Descriptor instead.
+func (*SliderCaptcha) Descriptor() ([]byte, []int) {
+	return file_payload_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *SliderCaptcha) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *SliderCaptcha) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+func (x *SliderCaptcha) GetBackground() string {
+	if x != nil {
+		return x.Background
+	}
+	return ""
+}
+
+func (x *SliderCaptcha) GetSlider() string {
+	if x != nil {
+		return x.Slider
+	}
+	return ""
+}
+
+func (x *SliderCaptcha) GetPositionY() int32 {
+	if x != nil {
+		return x.PositionY
+	}
+	return 0
+}
+
+func (x *SliderCaptcha) GetWidth() int32 {
+	if x != nil {
+		return x.Width
+	}
+	return 0
+}
+
+func (x *SliderCaptcha) GetHeight() int32 {
+	if x != nil {
+		return x.Height
+	}
+	return 0
+}
+
+func (x *SliderCaptcha) GetPieceWidth() int32 {
+	if x != nil {
+		return x.PieceWidth
+	}
+	return 0
+}
+
+func (x *SliderCaptcha) GetPieceHeight() int32 {
+	if x != nil {
+		return x.PieceHeight
+	}
+	return 0
+}
+
+func (x *SliderCaptcha) GetShape() string {
+	if x != nil {
+		return x.Shape
+	}
+	return ""
+}
+
+func (x *SliderCaptcha) GetExpiresAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.ExpiresAt
+	}
+	return nil
+}
+
+func (x *SliderCaptcha) GetTrackOffset() int32 {
+	if x != nil {
+		return x.TrackOffset
+	}
+	return 0
+}
+
+type VerifyCaptchaRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	X             float64                `protobuf:"fixed64,2,opt,name=x,proto3" json:"x,omitempty"`
+	Trajectory    []*TrajectoryPoint     `protobuf:"bytes,3,rep,name=trajectory,proto3" json:"trajectory,omitempty"`
+	Fingerprint   string                 `protobuf:"bytes,4,opt,name=fingerprint,proto3" json:"fingerprint,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *VerifyCaptchaRequest) Reset() {
+	*x = VerifyCaptchaRequest{}
+	mi := &file_payload_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *VerifyCaptchaRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*VerifyCaptchaRequest) ProtoMessage() {}
+
+func (x *VerifyCaptchaRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_payload_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use VerifyCaptchaRequest.ProtoReflect.Descriptor instead.
+func (*VerifyCaptchaRequest) Descriptor() ([]byte, []int) {
+	return file_payload_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *VerifyCaptchaRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *VerifyCaptchaRequest) GetX() float64 {
+	if x != nil {
+		return x.X
+	}
+	return 0
+}
+
+func (x *VerifyCaptchaRequest) GetTrajectory() []*TrajectoryPoint {
+	if x != nil {
+		return x.Trajectory
+	}
+	return nil
+}
+
+func (x *VerifyCaptchaRequest) GetFingerprint() string {
+	if x != nil {
+		return x.Fingerprint
+	}
+	return ""
+}
+
+type SuccessToken struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Token         string                 `protobuf:"bytes,1,opt,name=token,proto3" json:"token,omitempty"`
+	CaptchaId     string                 `protobuf:"bytes,2,opt,name=captcha_id,json=captchaId,proto3" json:"captcha_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SuccessToken) Reset() {
+	*x = SuccessToken{}
+	mi := &file_payload_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SuccessToken) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SuccessToken) ProtoMessage() {}
+
+func (x *SuccessToken) ProtoReflect() protoreflect.Message {
+	mi := &file_payload_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SuccessToken.ProtoReflect.Descriptor instead.
+func (*SuccessToken) Descriptor() ([]byte, []int) {
+	return file_payload_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *SuccessToken) GetToken() string {
+	if x != nil {
+		return x.Token
+	}
+	return ""
+}
+
+func (x *SuccessToken) GetCaptchaId() string {
+	if x != nil {
+		return x.CaptchaId
+	}
+	return ""
+}
+
+var File_payload_proto protoreflect.FileDescriptor
+
+const file_payload_proto_rawDesc = "" +
+	"\n" +
+	"\rpayload.proto\x12\n" +
+	"captcha.v1\x1a\rcaptcha.proto\x1a\x1fgoogle/protobuf/timestamp.proto\"\xf0\x02\n" +
+	"\rSliderCaptcha\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x12\n" +
+	"\x04type\x18\x02 \x01(\tR\x04type\x12\x1e\n" +
+	"\n" +
+	"background\x18\x03 \x01(\tR\n" +
+	"background\x12\x16\n" +
+	"\x06slider\x18\x04 \x01(\tR\x06slider\x12\x1d\n" +
+	"\n" +
+	"position_y\x18\x05 \x01(\x05R\tpositionY\x12\x14\n" +
+	"\x05width\x18\x06 \x01(\x05R\x05width\x12\x16\n" +
+	"\x06height\x18\a \x01(\x05R\x06height\x12\x1f\n" +
+	"\vpiece_width\x18\b \x01(\x05R\n" +
+	"pieceWidth\x12!\n" +
+	"\fpiece_height\x18\t \x01(\x05R\vpieceHeight\x12\x14\n" +
+	"\x05shape\x18\n" +
+	" \x01(\tR\x05shape\x129\n" +
+	"\n" +
+	"expires_at\x18\v \x01(\v2\x1a.google.protobuf.TimestampR\texpiresAt\x12!\n" +
+	"\ftrack_offset\x18\f \x01(\x05R\vtrackOffset\"\x93\x01\n" +
+	"\x14VerifyCaptchaRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\f\n" +
+	"\x01x\x18\x02 \x01(\x01R\x01x\x12;\n" +
+	"\n" +
+	"trajectory\x18\x03 \x03(\v2\x1b.captcha.v1.TrajectoryPointR\n" +
+	"trajectory\x12 \n" +
+	"\vfingerprint\x18\x04 \x01(\tR\vfingerprint\"C\n" +
+	"\fSuccessToken\x12\x14\n" +
+	"\x05token\x18\x01 \x01(\tR\x05token\x12\x1d\n" +
+	"\n" +
+	"captcha_id\x18\x02 \x01(\tR\tcaptchaIdB7Z5github.com/gpencil/photo_captcha/grpcserver/captchapbb\x06proto3"
+
+var (
+	file_payload_proto_rawDescOnce sync.Once
+	file_payload_proto_rawDescData []byte
+)
+
+func file_payload_proto_rawDescGZIP() []byte {
+	file_payload_proto_rawDescOnce.Do(func() {
+		file_payload_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_payload_proto_rawDesc), len(file_payload_proto_rawDesc)))
+	})
+	return file_payload_proto_rawDescData
+}
+
+var file_payload_proto_msgTypes = make([]protoimpl.MessageInfo, 3)
+var file_payload_proto_goTypes = []any{
+	(*SliderCaptcha)(nil),         // 0: captcha.v1.SliderCaptcha
+	(*VerifyCaptchaRequest)(nil),  // 1: captcha.v1.VerifyCaptchaRequest
+	(*SuccessToken)(nil),          // 2: captcha.v1.SuccessToken
+	(*timestamppb.Timestamp)(nil), // 3: google.protobuf.Timestamp
+	(*TrajectoryPoint)(nil),       // 4: captcha.v1.TrajectoryPoint
+}
+var file_payload_proto_depIdxs = []int32{
+	3, // 0: captcha.v1.SliderCaptcha.expires_at:type_name -> google.protobuf.Timestamp
+	4, // 1: captcha.v1.VerifyCaptchaRequest.trajectory:type_name -> captcha.v1.TrajectoryPoint
+	2, // [2:2] is the sub-list for method output_type
+	2, // [2:2] is the sub-list for method input_type
+	2, // [2:2] is the sub-list for extension type_name
+	2, // [2:2] is the sub-list for extension extendee
+	0, // [0:2] is the sub-list for field type_name
+}
+
+func init() { file_payload_proto_init() }
+func file_payload_proto_init() {
+	if File_payload_proto != nil {
+		return
+	}
+	file_captcha_proto_init()
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_payload_proto_rawDesc), len(file_payload_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   3,
+			NumExtensions: 0,
+			NumServices:   0,
+		},
+		GoTypes:           file_payload_proto_goTypes,
+		DependencyIndexes: file_payload_proto_depIdxs,
+		MessageInfos:      file_payload_proto_msgTypes,
+	}.Build()
+	File_payload_proto = out.File
+	file_payload_proto_goTypes = nil
+	file_payload_proto_depIdxs = nil
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"flag"
+	"log/slog"
+	"net"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/gpencil/photo_captcha/grpcserver"
+	"github.com/gpencil/photo_captcha/grpcserver/captchapb"
+
+	"google.golang.org/grpc"
+)
+
+func main() {
+	addr := flag.String("addr", ":9087", "gRPC监听地址")
+	flag.Parse()
+
+	lis, err := net.Listen("tcp", *addr)
+	if err != nil {
+		slog.Error("failed to listen", "addr", *addr, "error", err)
+		os.Exit(1)
+	}
+
+	srv := grpc.NewServer()
+	captchapb.RegisterCaptchaServiceServer(srv, grpcserver.NewServer())
+
+	// 收到 SIGINT/SIGTERM 后等待进行中的调用完成
+	go func() {
+		sig := make(chan os.Signal, 1)
+		signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
+		<-sig
+		slog.Info("shutting down grpc server")
+		srv.GracefulStop()
+	}()
+
+	slog.Info("grpc server starting", "addr", *addr)
+	if err := srv.Serve(lis); err != nil {
+		slog.Error("failed to serve", "error", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,22 @@
+module github.com/gpencil/photo_captcha/grpcserver
+
+go 1.24.0
+
+require (
+	github.com/gpencil/photo_captcha/captcha v0.0.0
+	google.golang.org/grpc v1.75.0
+	google.golang.org/protobuf v1.36.9
+)
+
+require (
+	github.com/google/uuid v1.6.0 // indirect
+	golang.org/x/net v0.42.0 // indirect
+	golang.org/x/sys v0.35.0 // indirect
+	golang.org/x/text v0.27.0 // indirect
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20250707201910-8d1bb00bc6a7 // indirect
+)
+
+replace (
+	github.com/gpencil/photo_captcha => ../
+	github.com/gpencil/photo_captcha/captcha => ../captcha
+)
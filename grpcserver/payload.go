@@ -0,0 +1,61 @@
+package grpcserver
+
+import (
+	"math"
+
+	"github.com/gpencil/photo_captcha/captcha"
+	"github.com/gpencil/photo_captcha/grpcserver/captchapb"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// SliderCaptchaMessage 把生成的验证码转换为 proto 载荷，字段与HTTP生成接口的 data 一致
+func SliderCaptchaMessage(sliderCaptcha *captcha.SliderCaptcha) *captchapb.SliderCaptcha {
+	msg := &captchapb.SliderCaptcha{
+		Id:          sliderCaptcha.ID,
+		Type:        sliderCaptcha.Type,
+		Background:  sliderCaptcha.Background,
+		Slider:      sliderCaptcha.Slider,
+		PositionY:   int32(sliderCaptcha.PositionY),
+		Width:       int32(sliderCaptcha.Width),
+		Height:      int32(sliderCaptcha.Height),
+		PieceWidth:  int32(sliderCaptcha.PieceWidth),
+		PieceHeight: int32(sliderCaptcha.PieceHeight),
+		Shape:       sliderCaptcha.Shape,
+		TrackOffset: int32(sliderCaptcha.TrackOffset),
+	}
+	if !sliderCaptcha.ExpiresAt.IsZero() {
+		msg.ExpiresAt = timestamppb.New(sliderCaptcha.ExpiresAt)
+	}
+	return msg
+}
+
+// VerifyParams 把 proto 验证请求转换为验证参数，X 四舍五入；容差、IP 等绑定信息由调用方补充
+func VerifyParams(req *captchapb.VerifyCaptchaRequest) captcha.VerifyParams {
+	return captcha.VerifyParams{
+		ID:          req.GetId(),
+		X:           int(math.Round(req.GetX())),
+		Trajectory:  trajectoryPoints(req.GetTrajectory()),
+		Fingerprint: req.GetFingerprint(),
+	}
+}
+
+// SuccessTokenMessage 验证通过时返回令牌载荷，未通过时返回 nil
+func SuccessTokenMessage(captchaID string, result *captcha.VerifyResult) *captchapb.SuccessToken {
+	if result == nil || !result.Success || result.Token == "" {
+		return nil
+	}
+	return &captchapb.SuccessToken{
+		Token:     result.Token,
+		CaptchaId: captchaID,
+	}
+}
+
+// trajectoryPoints 转换拖动轨迹
+func trajectoryPoints(points []*captchapb.TrajectoryPoint) []captcha.TrajectoryPoint {
+	trajectory := make([]captcha.TrajectoryPoint, len(points))
+	for i, p := range points {
+		trajectory[i] = captcha.TrajectoryPoint{X: int(p.GetX()), Y: int(p.GetY()), T: p.GetT()}
+	}
+	return trajectory
+}
@@ -0,0 +1,131 @@
+// Package grpcserver 提供验证码的 gRPC 服务，与 HTTP API 共用同一套生成和验证逻辑
+//
+// 图片以原始字节返回，内部微服务调用时没有 JSON/base64 的开销。
+// proto/payload.proto 定义与HTTP API一致的验证码、验证请求和令牌载荷，供消息队列等集成共用。
+// 修改 proto 后执行 go generate 重新生成 captchapb。
+package grpcserver
+
+//go:generate protoc -I proto --go_out=captchapb --go_opt=paths=source_relative --go-grpc_out=captchapb --go-grpc_opt=paths=source_relative proto/captcha.proto proto/payload.proto
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/gpencil/photo_captcha/captcha"
+	"github.com/gpencil/photo_captcha/grpcserver/captchapb"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Server 验证码 gRPC 服务实现
+type Server struct {
+	captchapb.UnimplementedCaptchaServiceServer
+
+	// Service 生成和验证使用的验证码服务
+	Service *captcha.CaptchaService
+	// Tolerance 验证允许的误差（像素）
+	Tolerance int
+}
+
+// NewServer 使用默认服务创建 gRPC 服务
+func NewServer() *Server {
+	return &Server{
+		Service:   captcha.Default(),
+		Tolerance: captcha.DefaultTolerance,
+	}
+}
+
+// Generate 生成验证码
+func (s *Server) Generate(ctx context.Context, req *captchapb.GenerateRequest) (*captchapb.GenerateResponse, error) {
+	sliderCaptcha, err := s.Service.GenerateContext(ctx, captcha.GenerateParams{
+		Fingerprint: req.GetFingerprint(),
+		ClientIP:    req.GetClientIp(),
+		Difficulty:  captcha.DefaultDifficulty.Level(req.GetClientIp()),
+		RequestID:   req.GetRequestId(),
+	})
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to generate captcha: %v", err)
+	}
+
+	bgType, background, err := decodeDataURL(sliderCaptcha.Background)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "invalid background image: %v", err)
+	}
+	sliderType, slider, err := decodeDataURL(sliderCaptcha.Slider)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "invalid slider image: %v", err)
+	}
+
+	return &captchapb.GenerateResponse{
+		Id:                    sliderCaptcha.ID,
+		Background:            background,
+		BackgroundContentType: bgType,
+		Slider:                slider,
+		SliderContentType:     sliderType,
+		PositionY:             int32(sliderCaptcha.PositionY),
+		TrackOffset:           int32(sliderCaptcha.TrackOffset),
+	}, nil
+}
+
+// Verify 验证滑块位置，验证失败不作为gRPC错误返回，通过 reason 区分原因
+func (s *Server) Verify(ctx context.Context, req *captchapb.VerifyRequest) (*captchapb.VerifyResponse, error) {
+	if req.GetId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "id is required")
+	}
+	if math.IsNaN(req.GetX()) || math.IsInf(req.GetX(), 0) {
+		return nil, status.Error(codes.InvalidArgument, "invalid x coordinate")
+	}
+
+	result, err := s.Service.VerifyContext(ctx, captcha.VerifyParams{
+		ID:          req.GetId(),
+		X:           int(math.Round(req.GetX())),
+		Tolerance:   s.Tolerance,
+		Trajectory:  trajectoryPoints(req.GetTrajectory()),
+		Fingerprint: req.GetFingerprint(),
+		ClientIP:    req.GetClientIp(),
+		RemoteIP:    req.GetClientIp(),
+		RequestID:   req.GetRequestId(),
+	})
+	if err == nil {
+		// 记录通过率，用于自适应调整难度（验证码不可用时不计入）
+		captcha.DefaultDifficulty.Record(req.GetClientIp(), result.Success)
+	}
+
+	return &captchapb.VerifyResponse{
+		Success:   result.Success,
+		Reason:    string(result.Reason),
+		Retryable: result.Reason.Retryable(),
+		Token:     result.Token,
+	}, nil
+}
+
+// ValidateToken 校验验证通过后签发的一次性令牌
+func (s *Server) ValidateToken(ctx context.Context, req *captchapb.ValidateTokenRequest) (*captchapb.ValidateTokenResponse, error) {
+	captchaID, valid, err := s.Service.ValidateTokenContext(ctx, req.GetToken())
+	if err != nil {
+		return nil, status.Errorf(codes.Unavailable, "failed to validate token: %v", err)
+	}
+	return &captchapb.ValidateTokenResponse{
+		Valid:     valid,
+		CaptchaId: captchaID,
+	}, nil
+}
+
+// decodeDataURL 解析 data:<type>;base64,<data> 格式的图片
+func decodeDataURL(dataURL string) (string, []byte, error) {
+	header, payload, ok := strings.Cut(dataURL, ",")
+	if !ok || !strings.HasPrefix(header, "data:") || !strings.HasSuffix(header, ";base64") {
+		return "", nil, fmt.Errorf("not a base64 data url")
+	}
+
+	data, err := base64.StdEncoding.DecodeString(payload)
+	if err != nil {
+		return "", nil, err
+	}
+	contentType := strings.TrimSuffix(strings.TrimPrefix(header, "data:"), ";base64")
+	return contentType, data, nil
+}
@@ -0,0 +1,201 @@
+// Package httpapi 与Web框架无关的HTTP接口请求/响应结构
+//
+// Gin 服务（server 包）以及 Echo、Fiber 等框架的适配器共用这些结构，保证各框架下的接口格式一致。
+package httpapi
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"math"
+	"mime/multipart"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gpencil/photo_captcha/captcha"
+)
+
+// VerifyRequest 验证请求结构
+type VerifyRequest struct {
+	ID          string                    `json:"id" binding:"required"`
+	X           *Coordinate               `json:"x" binding:"required"`
+	Trajectory  []captcha.TrajectoryPoint `json:"trajectory"`  // 拖动轨迹（可选）
+	Fingerprint string                    `json:"fingerprint"` // 客户端指纹（生成时提交过则必填）
+	// ActionStart 业务后端渲染表单时用 SignActionStart 签名的受保护操作开始时间（可选），
+	// 服务配置了最大签发提前量时用于拒绝预先打码的验证码；客户端无法伪造，签名无效时视为未提交
+	ActionStart string `json:"actionStart"`
+}
+
+// ActionStartTime 校验 ActionStart 的签名并返回受保护操作开始的时间，未提交、secret 为空或签名无效时返回零值
+func (r *VerifyRequest) ActionStartTime(secret string) time.Time {
+	if secret == "" || r.ActionStart == "" {
+		return time.Time{}
+	}
+	ms, sig, found := strings.Cut(r.ActionStart, ".")
+	if !found || !hmac.Equal([]byte(sig), []byte(actionStartMAC(secret, ms))) {
+		return time.Time{}
+	}
+	started, err := strconv.ParseInt(ms, 10, 64)
+	if err != nil || started <= 0 {
+		return time.Time{}
+	}
+	return time.UnixMilli(started)
+}
+
+// SignActionStart 签名受保护操作开始的时间，格式为 <Unix毫秒>.<hex(HMAC-SHA256(secret, Unix毫秒))>
+// 业务后端渲染表单时调用，结果随表单下发，客户端验证时原样放在 actionStart 中提交
+func SignActionStart(secret string, t time.Time) string {
+	ms := strconv.FormatInt(t.UnixMilli(), 10)
+	return ms + "." + actionStartMAC(secret, ms)
+}
+
+// actionStartMAC 计算受保护操作开始时间的签名
+func actionStartMAC(secret, ms string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(ms))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Validate 校验必填字段（不使用 gin binding 的框架调用）
+func (r *VerifyRequest) Validate() error {
+	if r.ID == "" {
+		return fmt.Errorf("id is required")
+	}
+	if r.X == nil {
+		return fmt.Errorf("x is required")
+	}
+	return nil
+}
+
+// VerifyBatchRequest 批量验证请求结构
+type VerifyBatchRequest struct {
+	Items []VerifyRequest `json:"items" binding:"required,min=1,dive"` // 最多 captcha.MaxVerifyBatch 项
+}
+
+// Validate 校验必填字段和数量上限（不使用 gin binding 的框架调用）
+func (r *VerifyBatchRequest) Validate() error {
+	if len(r.Items) == 0 {
+		return fmt.Errorf("items is required")
+	}
+	if len(r.Items) > captcha.MaxVerifyBatch {
+		return fmt.Errorf("at most %d items are allowed", captcha.MaxVerifyBatch)
+	}
+	for i := range r.Items {
+		if err := r.Items[i].Validate(); err != nil {
+			return fmt.Errorf("items[%d]: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// Coordinate 坐标值，兼容JSON字符串（"150"）、整数（150）和浮点数（150.6）
+type Coordinate struct {
+	value float64
+}
+
+// UnmarshalJSON 解析字符串或数字形式的坐标
+func (c *Coordinate) UnmarshalJSON(data []byte) error {
+	var raw interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	var value float64
+	switch v := raw.(type) {
+	case float64:
+		value = v
+	case string:
+		parsed, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		if err != nil {
+			return fmt.Errorf("invalid x coordinate %q", v)
+		}
+		value = parsed
+	default:
+		return fmt.Errorf("invalid x coordinate %s", string(data))
+	}
+
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return fmt.Errorf("invalid x coordinate %s", string(data))
+	}
+
+	c.value = value
+	return nil
+}
+
+// Int 返回四舍五入后的整数坐标
+func (c Coordinate) Int() int {
+	return int(math.Round(c.value))
+}
+
+// CaptchaData 生成接口的响应数据
+func CaptchaData(sliderCaptcha *captcha.SliderCaptcha) map[string]interface{} {
+	return map[string]interface{}{
+		"id":          sliderCaptcha.ID,
+		"type":        sliderCaptcha.Type,
+		"background":  sliderCaptcha.Background,
+		"slider":      sliderCaptcha.Slider,
+		"positionY":   sliderCaptcha.PositionY,
+		"width":       sliderCaptcha.Width,
+		"height":      sliderCaptcha.Height,
+		"pieceWidth":  sliderCaptcha.PieceWidth,
+		"pieceHeight": sliderCaptcha.PieceHeight,
+		"shape":       sliderCaptcha.Shape,
+		"trackOffset": sliderCaptcha.TrackOffset,
+		"expiresAt":   sliderCaptcha.ExpiresAt,
+	}
+}
+
+// CaptchaMetadata 去掉 background、slider 两张图片后的生成接口响应数据，作为 multipart 响应的JSON部分
+func CaptchaMetadata(data map[string]interface{}) map[string]interface{} {
+	meta := maps.Clone(data)
+	delete(meta, "background")
+	delete(meta, "slider")
+	return meta
+}
+
+// WriteCaptchaImages 把验证码的背景图和滑块图（base64 data URI）解码为原始图片，依次写入 mw 的 background、slider 两个部分
+func WriteCaptchaImages(mw *multipart.Writer, sliderCaptcha *captcha.SliderCaptcha) error {
+	for _, img := range []struct{ name, uri string }{
+		{"background", sliderCaptcha.Background},
+		{"slider", sliderCaptcha.Slider},
+	} {
+		header, payload, ok := strings.Cut(img.uri, ",")
+		mediaType, isBase64 := strings.CutSuffix(strings.TrimPrefix(header, "data:"), ";base64")
+		if !ok || !strings.HasPrefix(header, "data:") || !isBase64 {
+			return fmt.Errorf("%s is not a base64 data uri", img.name)
+		}
+		part, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":        {mediaType},
+			"Content-Disposition": {fmt.Sprintf("inline; name=%q", img.name)},
+		})
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(part, base64.NewDecoder(base64.StdEncoding, strings.NewReader(payload))); err != nil {
+			return fmt.Errorf("failed to decode %s: %w", img.name, err)
+		}
+	}
+	return nil
+}
+
+// VerifyResultData 验证接口的响应数据
+func VerifyResultData(result *captcha.VerifyResult) map[string]interface{} {
+	if result.Success {
+		return map[string]interface{}{
+			"success": true,
+			"token":   result.Token,
+		}
+	}
+	return map[string]interface{}{
+		"success":   false,
+		"reason":    result.Reason,
+		"retryable": result.Reason.Retryable(),
+	}
+}
@@ -1,21 +1,142 @@
 package main
 
 import (
-	"log"
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
 
 	"github.com/gpencil/photo_captcha/server"
 )
 
 func main() {
+	// 加载配置：命令行参数 > 环境变量 > 配置文件 > 默认值
+	cfg, err := server.LoadConfig(os.Args[1:])
+	if err != nil {
+		fatal("failed to load config", err)
+	}
+	if cfg.AssetsPath == "" {
+		cfg.Assets = embeddedAssets
+	}
+
+	// 结构化日志（验证码库默认也使用该日志）
+	logger, err := server.NewLogger(cfg, os.Stderr)
+	if err != nil {
+		fatal("failed to setup logger", err)
+	}
+	slog.SetDefault(logger)
+
 	// 初始化路由
-	router := server.SetupRouter()
+	router, err := server.SetupRouterWithConfig(cfg)
+	if err != nil {
+		fatal("failed to setup router", err)
+	}
+
+	tlsConfig, err := server.NewTLSConfig(cfg)
+	if err != nil {
+		fatal("failed to setup tls", err)
+	}
 
 	// 启动服务
-	addr := ":8087"
-	log.Printf("Server starting on %s", addr)
-	log.Printf("Visit http://localhost%s to see the demo", addr)
+	ln, err := server.Listen(cfg)
+	if err != nil {
+		fatal("failed to listen", err)
+	}
+	srv := &http.Server{
+		Handler:           router,
+		TLSConfig:         tlsConfig,
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+	}
+	scheme := "http"
+	if tlsConfig != nil {
+		scheme = "https"
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	errChan := make(chan error, 2)
+	go func() {
+		if cfg.UnixSocket != "" {
+			slog.Info("server starting", "socket", cfg.UnixSocket)
+		} else {
+			slog.Info("server starting", "addr", ln.Addr().String(), "demo", scheme+"://"+demoHost(ln.Addr()))
+		}
+		var err error
+		if tlsConfig != nil {
+			// 证书已在 TLSConfig 中配置
+			err = srv.ServeTLS(ln, "", "")
+		} else {
+			err = srv.Serve(ln)
+		}
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			errChan <- err
+		}
+	}()
 
-	if err := router.Run(addr); err != nil {
-		log.Fatalf("Failed to start server: %v", err)
+	// 管理接口和 pprof 使用独立的内网端口
+	var adminSrv *http.Server
+	if cfg.AdminAddr != "" {
+		adminRouter, err := server.SetupAdminRouter(cfg)
+		if err != nil {
+			fatal("failed to setup admin router", err)
+		}
+		adminSrv = &http.Server{
+			Addr:              cfg.AdminAddr,
+			Handler:           adminRouter,
+			ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+		}
+		go func() {
+			slog.Info("admin server starting", "addr", cfg.AdminAddr)
+			if err := adminSrv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				errChan <- err
+			}
+		}()
 	}
+
+	select {
+	case err := <-errChan:
+		fatal("failed to start server", err)
+	case <-ctx.Done():
+	}
+	stop()
+
+	// 停止接收新请求，等待进行中的请求完成
+	slog.Info("shutting down server", "timeout", cfg.ShutdownTimeout.String())
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		slog.Error("server forced to shutdown", "error", err)
+	}
+	if adminSrv != nil {
+		if err := adminSrv.Shutdown(shutdownCtx); err != nil {
+			slog.Error("admin server forced to shutdown", "error", err)
+		}
+	}
+
+	// 停止后台协程并刷新审计记录
+	if err := server.Shutdown(); err != nil {
+		slog.Error("failed to release resources", "error", err)
+	}
+	slog.Info("server exited")
+}
+
+// demoHost 演示页面地址：监听所有地址时使用 localhost
+func demoHost(addr net.Addr) string {
+	tcpAddr, ok := addr.(*net.TCPAddr)
+	if ok && tcpAddr.IP.IsUnspecified() {
+		return "localhost:" + strconv.Itoa(tcpAddr.Port)
+	}
+	return addr.String()
+}
+
+// fatal 记录错误并退出
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
 }
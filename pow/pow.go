@@ -0,0 +1,224 @@
+// Package pow 实现 hashcash 风格的工作量证明挑战
+//
+// 客户端需要找到一个 nonce，使 sha256(challenge + ":" + nonce) 的前 difficulty 位均为0。
+// 用于在触发限流时代替直接拒绝：正常用户多花一两秒计算即可继续，批量请求的成本则成倍增加。
+package pow
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"math/bits"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 难度范围（前导零位数）
+const (
+	MinDifficulty = 8
+	MaxDifficulty = 32
+)
+
+// DefaultMaxUsed 内存重放记录默认最多保存的挑战数量
+const DefaultMaxUsed = 100000
+
+// 验证错误
+var (
+	ErrUnknownChallenge = errors.New("unknown or already used pow challenge")
+	ErrExpired          = errors.New("pow challenge expired")
+	ErrInvalidSolution  = errors.New("invalid pow solution")
+	ErrReplayFull       = errors.New("pow replay store is full")
+)
+
+// Challenge 工作量证明挑战
+type Challenge struct {
+	Challenge  string `json:"challenge"`  // 挑战串
+	Difficulty int    `json:"difficulty"` // 要求的前导零位数
+	Algorithm  string `json:"algorithm"`  // 哈希算法
+	ExpiresAt  int64  `json:"expiresAt"`  // 过期时间（Unix毫秒）
+}
+
+// ReplayStore 记录已通过验证的挑战，防止同一个解被重复使用
+type ReplayStore interface {
+	// Use 标记挑战已使用，ttl 后可以遗忘；返回 false 表示挑战已经使用过
+	Use(key string, ttl time.Duration) (bool, error)
+}
+
+// Manager 工作量证明挑战管理器，挑战只能使用一次
+//
+// 挑战是无状态的：难度、过期时间和绑定的客户端IP由 HMAC 签名后编码在挑战串中，签发时不保存任何状态，
+// 只有通过验证的挑战才写入 ReplayStore。多实例使用相同密钥和共享的 ReplayStore（如 Redis）时挑战在实例间通用
+type Manager struct {
+	ttl    time.Duration
+	secret []byte
+	used   ReplayStore
+}
+
+// NewManager 创建挑战管理器，ttl 为挑战有效期
+// secret 为空时随机生成（挑战只在本实例有效），used 为nil时使用内存重放记录
+func NewManager(ttl time.Duration, secret []byte, used ReplayStore) *Manager {
+	if len(secret) == 0 {
+		secret = make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			panic("pow: failed to read random bytes: " + err.Error())
+		}
+	}
+	if used == nil {
+		used = NewMemoryReplayStore(DefaultMaxUsed)
+	}
+	return &Manager{
+		ttl:    ttl,
+		secret: secret,
+		used:   used,
+	}
+}
+
+// Issue 签发一个指定难度的挑战（难度会被限制在 MinDifficulty-MaxDifficulty 之间），
+// binding 为挑战绑定的客户端标识（如IP），验证时必须相同
+// 挑战串格式为 <随机数>.<难度>.<过期时间>.<签名>
+func (m *Manager) Issue(difficulty int, binding string) *Challenge {
+	difficulty = ClampDifficulty(difficulty)
+
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		panic("pow: failed to read random bytes: " + err.Error())
+	}
+	expiresAt := time.Now().Add(m.ttl).UnixMilli()
+	payload := hex.EncodeToString(buf) + "." + strconv.Itoa(difficulty) + "." + strconv.FormatInt(expiresAt, 10)
+
+	return &Challenge{
+		Challenge:  payload + "." + m.sign(payload, binding),
+		Difficulty: difficulty,
+		Algorithm:  "sha256",
+		ExpiresAt:  expiresAt,
+	}
+}
+
+// Verify 校验挑战的解，通过后挑战即作废
+// 签名、过期时间和解都校验通过后才写入重放记录，无效的请求不会占用存储
+func (m *Manager) Verify(challenge, nonce, binding string) error {
+	parts := strings.Split(challenge, ".")
+	if len(parts) != 4 {
+		return ErrUnknownChallenge
+	}
+	payload := strings.Join(parts[:3], ".")
+	if !hmac.Equal([]byte(parts[3]), []byte(m.sign(payload, binding))) {
+		return ErrUnknownChallenge
+	}
+	difficulty, err1 := strconv.Atoi(parts[1])
+	expiresAt, err2 := strconv.ParseInt(parts[2], 10, 64)
+	if err1 != nil || err2 != nil {
+		return ErrUnknownChallenge
+	}
+
+	ttl := time.Until(time.UnixMilli(expiresAt))
+	if ttl <= 0 {
+		return ErrExpired
+	}
+	if LeadingZeroBits(Hash(challenge, nonce)) < difficulty {
+		return ErrInvalidSolution
+	}
+
+	fresh, err := m.used.Use(parts[3], ttl)
+	if err != nil {
+		return err
+	}
+	if !fresh {
+		return ErrUnknownChallenge
+	}
+	return nil
+}
+
+// sign 计算挑战内容和绑定标识的 HMAC-SHA256 签名（取前16字节）
+func (m *Manager) sign(payload, binding string) string {
+	mac := hmac.New(sha256.New, m.secret)
+	mac.Write([]byte(payload + "|" + binding))
+	return hex.EncodeToString(mac.Sum(nil)[:16])
+}
+
+// MemoryReplayStore 内存重放记录，最多保存 max 个挑战
+// 只在记录已满时清理过期挑战（每秒最多一次），签发挑战不再需要持锁扫描
+type MemoryReplayStore struct {
+	mu        sync.Mutex
+	max       int
+	used      map[string]time.Time
+	lastSweep time.Time
+}
+
+// NewMemoryReplayStore 创建内存重放记录，max 为最多保存的挑战数量
+func NewMemoryReplayStore(max int) *MemoryReplayStore {
+	return &MemoryReplayStore{
+		max:  max,
+		used: make(map[string]time.Time),
+	}
+}
+
+// Use 标记挑战已使用，记录已满且没有可清理的过期挑战时返回 ErrReplayFull
+func (s *MemoryReplayStore) Use(key string, ttl time.Duration) (bool, error) {
+	now := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if expiresAt, exists := s.used[key]; exists && now.Before(expiresAt) {
+		return false, nil
+	}
+	if len(s.used) >= s.max && now.Sub(s.lastSweep) >= time.Second {
+		s.lastSweep = now
+		for k, expiresAt := range s.used {
+			if !now.Before(expiresAt) {
+				delete(s.used, k)
+			}
+		}
+	}
+	if len(s.used) >= s.max {
+		return false, ErrReplayFull
+	}
+	s.used[key] = now.Add(ttl)
+	return true, nil
+}
+
+// Hash 计算 sha256(challenge + ":" + nonce)
+func Hash(challenge, nonce string) []byte {
+	sum := sha256.Sum256([]byte(challenge + ":" + nonce))
+	return sum[:]
+}
+
+// LeadingZeroBits 计算哈希的前导零位数
+func LeadingZeroBits(hash []byte) int {
+	count := 0
+	for _, b := range hash {
+		if b == 0 {
+			count += 8
+			continue
+		}
+		count += bits.LeadingZeros8(b)
+		break
+	}
+	return count
+}
+
+// Solve 暴力求解挑战（参考实现，供Go客户端和调试使用）
+func Solve(challenge string, difficulty int) string {
+	for i := 0; ; i++ {
+		nonce := strconv.Itoa(i)
+		if LeadingZeroBits(Hash(challenge, nonce)) >= difficulty {
+			return nonce
+		}
+	}
+}
+
+// ClampDifficulty 将难度限制在允许范围内
+func ClampDifficulty(difficulty int) int {
+	if difficulty < MinDifficulty {
+		return MinDifficulty
+	}
+	if difficulty > MaxDifficulty {
+		return MaxDifficulty
+	}
+	return difficulty
+}
@@ -0,0 +1,124 @@
+package pow
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVerify(t *testing.T) {
+	m := NewManager(time.Minute, []byte("secret"), nil)
+
+	tests := []struct {
+		name    string
+		mutate  func(challenge, nonce string) (string, string, string)
+		wantErr error
+	}{
+		{"valid", func(c, n string) (string, string, string) { return c, n, "1.2.3.4" }, nil},
+		{"other client", func(c, n string) (string, string, string) { return c, n, "5.6.7.8" }, ErrUnknownChallenge},
+		{"wrong nonce", func(c, n string) (string, string, string) { return c, badNonce(c, MinDifficulty), "1.2.3.4" }, ErrInvalidSolution},
+		{"malformed", func(c, n string) (string, string, string) { return "abc", n, "1.2.3.4" }, ErrUnknownChallenge},
+		{"lowered difficulty", func(c, n string) (string, string, string) {
+			parts := strings.Split(c, ".")
+			parts[1] = "1"
+			return strings.Join(parts, "."), n, "1.2.3.4"
+		}, ErrUnknownChallenge},
+		{"other secret", func(c, n string) (string, string, string) {
+			other := NewManager(time.Minute, []byte("other"), nil).Issue(MinDifficulty, "1.2.3.4")
+			return other.Challenge, Solve(other.Challenge, other.Difficulty), "1.2.3.4"
+		}, ErrUnknownChallenge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := m.Issue(MinDifficulty, "1.2.3.4")
+			challenge, nonce, binding := tt.mutate(c.Challenge, Solve(c.Challenge, c.Difficulty))
+			if err := m.Verify(challenge, nonce, binding); !errors.Is(err, tt.wantErr) {
+				t.Errorf("Verify() = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// badNonce 返回第一个不满足难度的 nonce
+func badNonce(challenge string, difficulty int) string {
+	for i := 0; ; i++ {
+		nonce := strconv.Itoa(i)
+		if LeadingZeroBits(Hash(challenge, nonce)) < difficulty {
+			return nonce
+		}
+	}
+}
+
+func TestVerifyReplay(t *testing.T) {
+	m := NewManager(time.Minute, []byte("secret"), nil)
+	c := m.Issue(MinDifficulty, "ip")
+	nonce := Solve(c.Challenge, c.Difficulty)
+
+	if err := m.Verify(c.Challenge, nonce, "ip"); err != nil {
+		t.Fatalf("first Verify() = %v", err)
+	}
+	if err := m.Verify(c.Challenge, nonce, "ip"); !errors.Is(err, ErrUnknownChallenge) {
+		t.Errorf("replayed Verify() = %v, want %v", err, ErrUnknownChallenge)
+	}
+}
+
+func TestVerifyExpired(t *testing.T) {
+	m := NewManager(-time.Second, []byte("secret"), nil)
+	c := m.Issue(MinDifficulty, "ip")
+	if err := m.Verify(c.Challenge, Solve(c.Challenge, c.Difficulty), "ip"); !errors.Is(err, ErrExpired) {
+		t.Errorf("Verify() = %v, want %v", err, ErrExpired)
+	}
+}
+
+// TestSharedSecret 使用相同密钥和重放记录的管理器（多实例）互认挑战，且挑战只能使用一次
+func TestSharedSecret(t *testing.T) {
+	used := NewMemoryReplayStore(10)
+	a := NewManager(time.Minute, []byte("secret"), used)
+	b := NewManager(time.Minute, []byte("secret"), used)
+
+	c := a.Issue(MinDifficulty, "ip")
+	nonce := Solve(c.Challenge, c.Difficulty)
+	if err := b.Verify(c.Challenge, nonce, "ip"); err != nil {
+		t.Fatalf("Verify() on other instance = %v", err)
+	}
+	if err := a.Verify(c.Challenge, nonce, "ip"); !errors.Is(err, ErrUnknownChallenge) {
+		t.Errorf("replayed Verify() = %v, want %v", err, ErrUnknownChallenge)
+	}
+}
+
+func TestMemoryReplayStoreFull(t *testing.T) {
+	s := NewMemoryReplayStore(2)
+	for _, key := range []string{"a", "b"} {
+		if ok, err := s.Use(key, time.Minute); !ok || err != nil {
+			t.Fatalf("Use(%q) = %v, %v", key, ok, err)
+		}
+	}
+	if _, err := s.Use("c", time.Minute); !errors.Is(err, ErrReplayFull) {
+		t.Errorf("Use() on full store = %v, want %v", err, ErrReplayFull)
+	}
+
+	// 过期的记录在记录已满时被清理
+	s = NewMemoryReplayStore(1)
+	s.Use("a", -time.Second)
+	if ok, err := s.Use("b", time.Minute); !ok || err != nil {
+		t.Errorf("Use() after expiry = %v, %v, want true", ok, err)
+	}
+}
+
+func TestClampDifficulty(t *testing.T) {
+	tests := []struct {
+		in, want int
+	}{
+		{0, MinDifficulty},
+		{MinDifficulty, MinDifficulty},
+		{20, 20},
+		{MaxDifficulty + 1, MaxDifficulty},
+	}
+	for _, tt := range tests {
+		if got := ClampDifficulty(tt.in); got != tt.want {
+			t.Errorf("ClampDifficulty(%d) = %d, want %d", tt.in, got, tt.want)
+		}
+	}
+}
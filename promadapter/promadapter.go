@@ -0,0 +1,136 @@
+// Package promadapter 把验证码服务的监控指标注册到 Prometheus，与应用已有的指标一起暴露
+//
+//	svc := captcha.NewCaptchaService(captcha.WithMetrics(promadapter.New(prometheus.DefaultRegisterer)))
+//	http.Handle("/metrics", promhttp.Handler())
+package promadapter
+
+import (
+	"sync"
+
+	"github.com/gpencil/photo_captcha/captcha"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Metrics Prometheus 指标实现，每个指标在首次上报时按标签名创建并注册
+type Metrics struct {
+	registerer prometheus.Registerer
+	// Buckets 直方图的分桶，默认 prometheus.DefBuckets，需在首次上报前设置
+	Buckets []float64
+
+	mu         sync.Mutex
+	counters   map[string]*prometheus.CounterVec
+	histograms map[string]*prometheus.HistogramVec
+	gauges     map[string]*prometheus.GaugeVec
+}
+
+// New 创建使用 registerer 注册指标的实现
+func New(registerer prometheus.Registerer) *Metrics {
+	return &Metrics{
+		registerer: registerer,
+		Buckets:    prometheus.DefBuckets,
+		counters:   make(map[string]*prometheus.CounterVec),
+		histograms: make(map[string]*prometheus.HistogramVec),
+		gauges:     make(map[string]*prometheus.GaugeVec),
+	}
+}
+
+// Count 计数器增加 delta
+func (m *Metrics) Count(name string, delta float64, labels ...captcha.Label) {
+	m.mu.Lock()
+	vec, exists := m.counters[name]
+	if !exists {
+		vec = prometheus.NewCounterVec(prometheus.CounterOpts{Name: name, Help: help(name)}, labelNames(labels))
+		vec = register(m.registerer, vec)
+		m.counters[name] = vec
+	}
+	m.mu.Unlock()
+	vec.With(labelValues(labels)).Add(delta)
+}
+
+// Observe 记录一次直方图观测值
+func (m *Metrics) Observe(name string, value float64, labels ...captcha.Label) {
+	m.mu.Lock()
+	vec, exists := m.histograms[name]
+	if !exists {
+		vec = prometheus.NewHistogramVec(prometheus.HistogramOpts{Name: name, Help: help(name), Buckets: m.Buckets}, labelNames(labels))
+		vec = register(m.registerer, vec)
+		m.histograms[name] = vec
+	}
+	m.mu.Unlock()
+	vec.With(labelValues(labels)).Observe(value)
+}
+
+// Gauge 设置仪表盘当前值
+func (m *Metrics) Gauge(name string, value float64, labels ...captcha.Label) {
+	m.mu.Lock()
+	vec, exists := m.gauges[name]
+	if !exists {
+		vec = prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: name, Help: help(name)}, labelNames(labels))
+		vec = register(m.registerer, vec)
+		m.gauges[name] = vec
+	}
+	m.mu.Unlock()
+	vec.With(labelValues(labels)).Set(value)
+}
+
+// register 注册指标，已注册过同名指标（如同一进程中有多个验证码服务）时复用已注册的指标
+func register[T prometheus.Collector](registerer prometheus.Registerer, collector T) T {
+	if err := registerer.Register(collector); err != nil {
+		if already, ok := err.(prometheus.AlreadyRegisteredError); ok {
+			if existing, ok := already.ExistingCollector.(T); ok {
+				return existing
+			}
+		}
+		panic(err)
+	}
+	return collector
+}
+
+// labelNames 标签名列表
+func labelNames(labels []captcha.Label) []string {
+	names := make([]string, len(labels))
+	for i, label := range labels {
+		names[i] = label.Name
+	}
+	return names
+}
+
+// labelValues 标签名到值的映射
+func labelValues(labels []captcha.Label) prometheus.Labels {
+	values := make(prometheus.Labels, len(labels))
+	for _, label := range labels {
+		values[label.Name] = label.Value
+	}
+	return values
+}
+
+// help 指标说明
+func help(name string) string {
+	switch name {
+	case captcha.MetricGenerated:
+		return "Number of generated captchas."
+	case captcha.MetricGenerateDuration:
+		return "Time spent generating a captcha in seconds."
+	case captcha.MetricGenerateStageDuration:
+		return "Time spent in each captcha generation stage in seconds."
+	case captcha.MetricVerified:
+		return "Number of captcha verifications."
+	case captcha.MetricSolveDuration:
+		return "Time from generation to verification in seconds."
+	case captcha.MetricExpired:
+		return "Number of captchas that expired unused."
+	case captcha.MetricIDRejected:
+		return "Number of captcha IDs rejected without a store lookup."
+	case captcha.MetricTokenReplay:
+		return "Number of already redeemed success tokens submitted again before expiry."
+	case captcha.MetricRiskyIP:
+		return "Number of IP reputation lookups that found a suspicious or malicious source."
+	case captcha.MetricEncodedCache:
+		return "Number of encoded image cache lookups."
+	case captcha.MetricPregeneratedReady:
+		return "Number of pregenerated captchas ready to serve."
+	default:
+		return name
+	}
+}
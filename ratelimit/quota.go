@@ -0,0 +1,41 @@
+package ratelimit
+
+import (
+	"fmt"
+	"time"
+)
+
+// CounterStore 配额计数存储
+type CounterStore interface {
+	// Incr 将 key 的计数加一并返回加一后的值，新建的计数在 ttl 后过期
+	Incr(key string, ttl time.Duration) (int64, error)
+}
+
+// Quota 按自然日（UTC）计数的配额，与令牌桶不同，不会随时间逐渐恢复，每天0点（UTC）重置
+// 适合限制一天内的总量：长期保持在突发限流以下、但全天持续请求的来源也会在达到上限后被拒绝
+type Quota struct {
+	store CounterStore
+	name  string
+	limit int
+}
+
+// NewDailyQuota 创建每日配额，name 作为键前缀区分不同用途的配额，limit 为每个 key 每天允许的次数
+func NewDailyQuota(store CounterStore, name string, limit int) *Quota {
+	return &Quota{
+		store: store,
+		name:  name,
+		limit: limit,
+	}
+}
+
+// Allow 为 key（如客户端IP）记录一次，返回是否仍在当天配额内以及配额重置的时间
+func (q *Quota) Allow(key string) (bool, time.Time, error) {
+	now := time.Now().UTC()
+	resetAt := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, time.UTC)
+	// 计数多保留一分钟，各实例时钟略有偏差时不会提前过期
+	count, err := q.store.Incr(q.name+":"+now.Format("20060102")+":"+key, resetAt.Sub(now)+time.Minute)
+	if err != nil {
+		return false, resetAt, fmt.Errorf("failed to count quota: %w", err)
+	}
+	return count <= int64(q.limit), resetAt, nil
+}
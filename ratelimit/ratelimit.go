@@ -0,0 +1,150 @@
+// Package ratelimit 令牌桶限流和按自然日的配额，状态可保存在内存（单实例）或 Redis（多实例共享）中
+package ratelimit
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Store 令牌桶状态存储
+type Store interface {
+	// Take 从 key 对应的令牌桶取出一个令牌，桶容量为 burst，每秒补充 rate 个
+	// 令牌不足时返回 false 以及下一个令牌可用前需要等待的时间
+	Take(key string, rate float64, burst int) (bool, time.Duration, error)
+}
+
+// Limit 限流速率
+type Limit struct {
+	// Rate 每秒补充的令牌数
+	Rate float64
+	// Burst 桶容量，即允许的突发请求数
+	Burst int
+}
+
+// PerMinute 每分钟 n 个请求，允许 n 个突发请求
+func PerMinute(n int) Limit {
+	return Limit{Rate: float64(n) / 60, Burst: n}
+}
+
+// Limiter 令牌桶限流器
+type Limiter struct {
+	store Store
+	name  string
+	limit Limit
+}
+
+// New 创建限流器，name 作为键前缀区分不同用途的限流器
+func New(store Store, name string, limit Limit) *Limiter {
+	return &Limiter{
+		store: store,
+		name:  name,
+		limit: limit,
+	}
+}
+
+// Allow 为 key（如客户端IP）消耗一个令牌，被限流时返回需要等待的时间
+func (l *Limiter) Allow(key string) (bool, time.Duration, error) {
+	allowed, retryAfter, err := l.store.Take(l.name+":"+key, l.limit.Rate, l.limit.Burst)
+	if err != nil {
+		return false, 0, fmt.Errorf("failed to take rate limit token: %w", err)
+	}
+	return allowed, retryAfter, nil
+}
+
+// bucket 单个令牌桶
+type bucket struct {
+	tokens    float64
+	updatedAt time.Time
+	rate      float64
+	burst     int
+}
+
+// refill 返回补充到 now 时的令牌数
+func (b *bucket) refill(now time.Time) float64 {
+	return min(float64(b.burst), b.tokens+now.Sub(b.updatedAt).Seconds()*b.rate)
+}
+
+// counter 配额计数
+type counter struct {
+	value     int64
+	expiresAt time.Time
+}
+
+// MemoryStore 内存令牌桶和计数存储（仅单实例有效）
+type MemoryStore struct {
+	mu       sync.Mutex
+	buckets  map[string]*bucket
+	counters map[string]*counter
+	// lastCleanup 上次清理已装满的令牌桶和过期计数的时间
+	lastCleanup time.Time
+}
+
+// NewMemoryStore 创建内存令牌桶和计数存储
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		buckets:  make(map[string]*bucket),
+		counters: make(map[string]*counter),
+	}
+}
+
+// Take 从令牌桶取出一个令牌
+func (s *MemoryStore) Take(key string, rate float64, burst int) (bool, time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	s.cleanupLocked(now)
+
+	b, exists := s.buckets[key]
+	if !exists {
+		b = &bucket{tokens: float64(burst), updatedAt: now}
+		s.buckets[key] = b
+	}
+	b.rate, b.burst = rate, burst
+	b.tokens = b.refill(now)
+	b.updatedAt = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0, nil
+	}
+	wait := time.Duration((1 - b.tokens) / rate * float64(time.Second))
+	return false, wait, nil
+}
+
+// Incr 将计数加一
+func (s *MemoryStore) Incr(key string, ttl time.Duration) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	s.cleanupLocked(now)
+
+	c, exists := s.counters[key]
+	if !exists || !now.Before(c.expiresAt) {
+		c = &counter{expiresAt: now.Add(ttl)}
+		s.counters[key] = c
+	}
+	c.value++
+	return c.value, nil
+}
+
+// cleanupLocked 每分钟最多清理一次已补满的令牌桶（与新建的桶等价）和过期的计数，调用方需持有锁
+func (s *MemoryStore) cleanupLocked(now time.Time) {
+	if now.Sub(s.lastCleanup) < time.Minute {
+		return
+	}
+	s.lastCleanup = now
+
+	for key, b := range s.buckets {
+		if b.refill(now) >= float64(b.burst) {
+			delete(s.buckets, key)
+		}
+	}
+	for key, c := range s.counters {
+		if !now.Before(c.expiresAt) {
+			delete(s.counters, key)
+		}
+	}
+}
@@ -0,0 +1,271 @@
+// Package redis 提供一个依赖极少的 Redis 客户端（RESP2协议）以及验证码相关存储的 Redis 实现
+//
+// 只实现了验证码服务用到的命令，足以让封禁、限流等状态在多实例之间共享。
+package redis
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrNil 键不存在
+var ErrNil = errors.New("redis: nil")
+
+// Error Redis 服务端返回的错误
+type Error string
+
+func (e Error) Error() string { return string(e) }
+
+// Client Redis 客户端（并发安全，内置简单连接池）
+type Client struct {
+	addr     string
+	password string
+	db       int
+
+	DialTimeout time.Duration // 建立连接超时
+	IOTimeout   time.Duration // 单条命令读写超时
+
+	pool chan *conn
+}
+
+// conn 带缓冲读取器的连接
+type conn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+// NewClient 根据 URL 创建客户端，格式：redis://[:password@]host:port[/db]
+func NewClient(rawURL string) (*Client, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid redis url: %w", err)
+	}
+	if u.Scheme != "redis" {
+		return nil, fmt.Errorf("unsupported redis url scheme: %s", u.Scheme)
+	}
+
+	addr := u.Host
+	if u.Port() == "" {
+		addr = net.JoinHostPort(u.Hostname(), "6379")
+	}
+
+	client := &Client{
+		addr:        addr,
+		DialTimeout: 3 * time.Second,
+		IOTimeout:   3 * time.Second,
+		pool:        make(chan *conn, 16),
+	}
+
+	if u.User != nil {
+		if password, ok := u.User.Password(); ok {
+			client.password = password
+		} else {
+			client.password = u.User.Username()
+		}
+	}
+
+	if db := strings.TrimPrefix(u.Path, "/"); db != "" {
+		client.db, err = strconv.Atoi(db)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redis db: %s", db)
+		}
+	}
+
+	return client, nil
+}
+
+// Do 执行一条命令，返回值类型为 string、int64、[]interface{}，键不存在时返回 ErrNil；
+// 数组中的错误回复以 Error 类型的元素返回。读取回复出现协议或网络错误时关闭连接，不会把读了一半的连接放回连接池
+func (c *Client) Do(args ...string) (interface{}, error) {
+	cn, err := c.get()
+	if err != nil {
+		return nil, err
+	}
+
+	reply, err := cn.do(c.IOTimeout, args...)
+	if err != nil {
+		var redisErr Error
+		if errors.Is(err, ErrNil) || errors.As(err, &redisErr) {
+			// 协议层面正常的回复，连接仍可复用
+			c.put(cn)
+		} else {
+			cn.Close()
+		}
+		return nil, err
+	}
+
+	c.put(cn)
+	return reply, nil
+}
+
+// Int 执行命令并将结果转换为整数
+func (c *Client) Int(args ...string) (int64, error) {
+	reply, err := c.Do(args...)
+	if err != nil {
+		return 0, err
+	}
+	switch v := reply.(type) {
+	case int64:
+		return v, nil
+	case string:
+		return strconv.ParseInt(v, 10, 64)
+	default:
+		return 0, fmt.Errorf("redis: unexpected reply type %T", reply)
+	}
+}
+
+// String 执行命令并将结果转换为字符串
+func (c *Client) String(args ...string) (string, error) {
+	reply, err := c.Do(args...)
+	if err != nil {
+		return "", err
+	}
+	switch v := reply.(type) {
+	case string:
+		return v, nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	default:
+		return "", fmt.Errorf("redis: unexpected reply type %T", reply)
+	}
+}
+
+// Ping 检查连接
+func (c *Client) Ping() error {
+	_, err := c.Do("PING")
+	return err
+}
+
+// Close 关闭连接池中的所有连接
+func (c *Client) Close() error {
+	for {
+		select {
+		case cn := <-c.pool:
+			cn.Close()
+		default:
+			return nil
+		}
+	}
+}
+
+// get 从连接池获取连接，没有空闲连接时新建
+func (c *Client) get() (*conn, error) {
+	select {
+	case cn := <-c.pool:
+		return cn, nil
+	default:
+	}
+
+	nc, err := net.DialTimeout("tcp", c.addr, c.DialTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("redis: failed to connect %s: %w", c.addr, err)
+	}
+	cn := &conn{Conn: nc, r: bufio.NewReader(nc)}
+
+	if c.password != "" {
+		if _, err := cn.do(c.IOTimeout, "AUTH", c.password); err != nil {
+			cn.Close()
+			return nil, fmt.Errorf("redis: auth failed: %w", err)
+		}
+	}
+	if c.db != 0 {
+		if _, err := cn.do(c.IOTimeout, "SELECT", strconv.Itoa(c.db)); err != nil {
+			cn.Close()
+			return nil, fmt.Errorf("redis: select db failed: %w", err)
+		}
+	}
+
+	return cn, nil
+}
+
+// put 归还连接，连接池已满时关闭
+func (c *Client) put(cn *conn) {
+	select {
+	case c.pool <- cn:
+	default:
+		cn.Close()
+	}
+}
+
+// do 发送命令并读取回复
+func (cn *conn) do(timeout time.Duration, args ...string) (interface{}, error) {
+	if timeout > 0 {
+		cn.SetDeadline(time.Now().Add(timeout))
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if _, err := io.WriteString(cn.Conn, b.String()); err != nil {
+		return nil, err
+	}
+
+	return readReply(cn.r)
+}
+
+// readReply 读取一条完整的 RESP2 回复，数组中的错误回复作为 Error 类型的元素继续读取，保证整条回复都被读完
+func readReply(r *bufio.Reader) (interface{}, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if len(line) == 0 {
+		return nil, errors.New("redis: empty reply")
+	}
+
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, Error(line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, err
+		}
+		if n < 0 {
+			return nil, ErrNil
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		return string(buf[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, err
+		}
+		if n < 0 {
+			return nil, ErrNil
+		}
+		items := make([]interface{}, n)
+		for i := range items {
+			item, err := readReply(r)
+			var redisErr Error
+			switch {
+			case err == nil, errors.Is(err, ErrNil):
+				items[i] = item
+			case errors.As(err, &redisErr):
+				items[i] = redisErr
+			default:
+				return nil, err
+			}
+		}
+		return items, nil
+	default:
+		return nil, fmt.Errorf("redis: unexpected reply %q", line)
+	}
+}
@@ -0,0 +1,55 @@
+package redis
+
+import (
+	"bufio"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadReply(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    interface{}
+		wantErr error
+	}{
+		{"status", "+OK\r\n", "OK", nil},
+		{"error", "-ERR bad\r\n", nil, Error("ERR bad")},
+		{"integer", ":42\r\n", int64(42), nil},
+		{"bulk", "$5\r\nhello\r\n", "hello", nil},
+		{"nil bulk", "$-1\r\n", nil, ErrNil},
+		{"array", "*2\r\n:1\r\n$1\r\na\r\n", []interface{}{int64(1), "a"}, nil},
+		{"array with nil", "*2\r\n$-1\r\n:1\r\n", []interface{}{nil, int64(1)}, nil},
+		{"array with error", "*3\r\n:1\r\n-ERR nested\r\n$1\r\nb\r\n", []interface{}{int64(1), Error("ERR nested"), "b"}, nil},
+		{"nested array with error", "*2\r\n*1\r\n-ERR deep\r\n:2\r\n", []interface{}{[]interface{}{Error("ERR deep")}, int64(2)}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// 每条回复后跟一条哨兵回复，检查回复被完整读取，连接可以继续使用
+			r := bufio.NewReader(strings.NewReader(tt.input + "+NEXT\r\n"))
+			got, err := readReply(r)
+			if !errors.Is(err, tt.wantErr) && !reflect.DeepEqual(err, tt.wantErr) {
+				t.Fatalf("readReply() error = %v, want %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("readReply() = %#v, want %#v", got, tt.want)
+			}
+			if next, err := readReply(r); err != nil || next != "NEXT" {
+				t.Errorf("reply not fully consumed: next = %#v, %v", next, err)
+			}
+		})
+	}
+}
+
+func TestReadReplyProtocolError(t *testing.T) {
+	for _, input := range []string{"?\r\n", "*2\r\n:1\r\n", ":abc\r\n", "$5\r\nab"} {
+		r := bufio.NewReader(strings.NewReader(input))
+		if _, err := readReply(r); err == nil {
+			t.Errorf("readReply(%q) succeeded, want error", input)
+		} else if errors.Is(err, ErrNil) || errors.As(err, new(Error)) {
+			t.Errorf("readReply(%q) = %v, want a protocol error that discards the connection", input, err)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package redis
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gpencil/photo_captcha/captcha"
+)
+
+// LockoutStore 基于 Redis 的封禁存储，封禁在所有实例间共享
+type LockoutStore struct {
+	client *Client
+	prefix string
+}
+
+// NewLockoutStore 创建 Redis 封禁存储，prefix 为键前缀
+func NewLockoutStore(client *Client, prefix string) *LockoutStore {
+	return &LockoutStore{
+		client: client,
+		prefix: prefix,
+	}
+}
+
+// 编译期检查接口实现
+var _ captcha.LockoutBanStore = (*LockoutStore)(nil)
+
+// IncrFailures 增加失败计数，第一次失败时设置窗口过期时间
+// 计数和设置过期时间在同一个脚本中原子执行，不会因为两条命令之间连接中断而留下永不过期的计数
+func (s *LockoutStore) IncrFailures(key string, window time.Duration) (int, error) {
+	count, err := s.client.Int("EVAL", incrScript, "1", s.prefix+key, strconv.FormatInt(window.Milliseconds(), 10))
+	if err != nil {
+		return 0, err
+	}
+	return int(count), nil
+}
+
+// ResetFailures 清空失败计数
+func (s *LockoutStore) ResetFailures(key string) error {
+	_, err := s.client.Do("DEL", s.prefix+key)
+	return err
+}
+
+// Ban 封禁到指定时间
+func (s *LockoutStore) Ban(key string, until time.Time) error {
+	ttl := time.Until(until)
+	if ttl <= 0 {
+		return nil
+	}
+	_, err := s.client.Do("SET", s.prefix+key, strconv.FormatInt(until.UnixMilli(), 10),
+		"PX", strconv.FormatInt(ttl.Milliseconds(), 10))
+	return err
+}
+
+// BannedUntil 返回封禁截止时间
+func (s *LockoutStore) BannedUntil(key string) (time.Time, bool, error) {
+	value, err := s.client.String("GET", s.prefix+key)
+	if errors.Is(err, ErrNil) {
+		return time.Time{}, false, nil
+	}
+	if err != nil {
+		return time.Time{}, false, err
+	}
+
+	ms, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	return time.UnixMilli(ms), true, nil
+}
+
+// Bans 用 SCAN 遍历键以 prefix 开头的封禁，返回未过期的封禁截止时间
+func (s *LockoutStore) Bans(prefix string) (map[string]time.Time, error) {
+	bans := make(map[string]time.Time)
+	pattern := globEscape(s.prefix+prefix) + "*"
+	cursor := "0"
+	for {
+		reply, err := s.client.Do("SCAN", cursor, "MATCH", pattern, "COUNT", "100")
+		if err != nil {
+			return nil, err
+		}
+		page, ok := reply.([]interface{})
+		if !ok || len(page) != 2 {
+			return nil, fmt.Errorf("redis: unexpected SCAN reply %T", reply)
+		}
+		cursor, _ = page[0].(string)
+		keys, _ := page[1].([]interface{})
+		for _, k := range keys {
+			key, _ := k.(string)
+			key = strings.TrimPrefix(key, s.prefix)
+			until, banned, err := s.BannedUntil(key)
+			if err != nil {
+				return nil, err
+			}
+			if banned {
+				bans[key] = until
+			}
+		}
+		if cursor == "0" || cursor == "" {
+			return bans, nil
+		}
+	}
+}
+
+// Unban 解除封禁
+func (s *LockoutStore) Unban(key string) (bool, error) {
+	n, err := s.client.Int("DEL", s.prefix+key)
+	return n > 0, err
+}
+
+// globEscape 转义 SCAN MATCH 模式中的特殊字符
+func globEscape(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if strings.ContainsRune(`*?[]\`, r) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,38 @@
+package redis
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/gpencil/photo_captcha/pow"
+)
+
+// PoWReplayStore 基于 Redis 的工作量证明重放记录，同一个挑战在所有实例中只能使用一次
+type PoWReplayStore struct {
+	client *Client
+	prefix string
+}
+
+// NewPoWReplayStore 创建 Redis 重放记录，prefix 为键前缀
+func NewPoWReplayStore(client *Client, prefix string) *PoWReplayStore {
+	return &PoWReplayStore{
+		client: client,
+		prefix: prefix,
+	}
+}
+
+// 编译期检查接口实现
+var _ pow.ReplayStore = (*PoWReplayStore)(nil)
+
+// Use 用 SET NX 标记挑战已使用，键在挑战过期后自动删除
+func (s *PoWReplayStore) Use(key string, ttl time.Duration) (bool, error) {
+	_, err := s.client.Do("SET", s.prefix+key, "1", "NX", "PX", strconv.FormatInt(max(ttl.Milliseconds(), 1), 10))
+	if errors.Is(err, ErrNil) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,91 @@
+package redis
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/gpencil/photo_captcha/ratelimit"
+)
+
+// takeScript 原子地补充并取出令牌，返回 {是否允许, 需等待的毫秒数}
+// 令牌数和更新时间保存在 hash 中，桶补满所需时间后自动过期
+const takeScript = `
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local tokens = tonumber(redis.call('HGET', KEYS[1], 'tokens'))
+local updated = tonumber(redis.call('HGET', KEYS[1], 'updated'))
+if tokens == nil or updated == nil then
+  tokens = burst
+  updated = now
+end
+tokens = math.min(burst, tokens + math.max(0, now - updated) * rate)
+local allowed = 0
+local wait = 0
+if tokens >= 1 then
+  tokens = tokens - 1
+  allowed = 1
+else
+  wait = math.ceil((1 - tokens) / rate)
+end
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'updated', tostring(now))
+redis.call('PEXPIRE', KEYS[1], math.ceil(burst / rate))
+return {allowed, wait}
+`
+
+// incrScript 原子地增加计数，新建的计数设置过期时间，返回增加后的值
+const incrScript = `
+local count = redis.call('INCR', KEYS[1])
+if count == 1 then
+  redis.call('PEXPIRE', KEYS[1], ARGV[1])
+end
+return count
+`
+
+// RateLimitStore 基于 Redis 的令牌桶和配额计数存储，限流和配额在所有实例间共享
+type RateLimitStore struct {
+	client *Client
+	prefix string
+}
+
+// NewRateLimitStore 创建 Redis 令牌桶存储，prefix 为键前缀
+func NewRateLimitStore(client *Client, prefix string) *RateLimitStore {
+	return &RateLimitStore{
+		client: client,
+		prefix: prefix,
+	}
+}
+
+// 编译期检查接口实现
+var (
+	_ ratelimit.Store        = (*RateLimitStore)(nil)
+	_ ratelimit.CounterStore = (*RateLimitStore)(nil)
+)
+
+// Take 从令牌桶取出一个令牌（速率在脚本中换算为每毫秒）
+func (s *RateLimitStore) Take(key string, rate float64, burst int) (bool, time.Duration, error) {
+	reply, err := s.client.Do("EVAL", takeScript, "1", s.prefix+key,
+		strconv.FormatFloat(rate/1000, 'g', -1, 64),
+		strconv.Itoa(burst),
+		strconv.FormatInt(time.Now().UnixMilli(), 10))
+	if err != nil {
+		return false, 0, err
+	}
+
+	values, ok := reply.([]interface{})
+	if !ok || len(values) != 2 {
+		return false, 0, fmt.Errorf("redis: unexpected rate limit reply %v", reply)
+	}
+	allowed, ok1 := values[0].(int64)
+	wait, ok2 := values[1].(int64)
+	if !ok1 || !ok2 {
+		return false, 0, fmt.Errorf("redis: unexpected rate limit reply %v", reply)
+	}
+	return allowed == 1, time.Duration(wait) * time.Millisecond, nil
+}
+
+// Incr 增加配额计数，第一次计数时设置过期时间
+func (s *RateLimitStore) Incr(key string, ttl time.Duration) (int64, error) {
+	return s.client.Int("EVAL", incrScript, "1", s.prefix+key, strconv.FormatInt(ttl.Milliseconds(), 10))
+}
@@ -0,0 +1,270 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/gpencil/photo_captcha/captcha"
+)
+
+// updateScript 验证码仍存在时替换数据，保持剩余过期时间
+const updateScript = `
+local ttl = redis.call('PTTL', KEYS[1])
+if ttl <= 0 then
+  return 0
+end
+redis.call('SET', KEYS[1], ARGV[1], 'PX', ttl)
+return 1
+`
+
+// casScript 验证码未过期且状态和失败次数与期望相同时替换数据，返回是否替换
+// 键保留两个有效期，剩余时间不足一个有效期说明验证码已过期；状态为空视为等待验证
+const casScript = `
+local ttl = redis.call('PTTL', KEYS[1])
+if ttl < tonumber(ARGV[1]) then
+  return 0
+end
+local cur = cjson.decode(redis.call('GET', KEYS[1]))
+local status = cur['Status']
+if status == nil or status == '' then
+  status = ARGV[2]
+end
+if status ~= ARGV[3] or tonumber(cur['Attempts']) ~= tonumber(ARGV[4]) then
+  return 0
+end
+redis.call('SET', KEYS[1], ARGV[5], 'PX', ttl)
+return 1
+`
+
+// CaptchaStore 基于 Redis 的验证码存储，验证码和验证状态在所有实例间共享
+// 过期的验证码再保留一个有效期，以便区分“已过期”和“不存在”，之后由 Redis 自动删除
+type CaptchaStore struct {
+	client *Client
+	prefix string
+	ttl    time.Duration
+}
+
+// NewCaptchaStore 创建 Redis 验证码存储，prefix 为键前缀，ttl 为验证码有效期
+func NewCaptchaStore(client *Client, prefix string, ttl time.Duration) *CaptchaStore {
+	if ttl <= 0 {
+		ttl = captcha.DefaultTTL
+	}
+	return &CaptchaStore{
+		client: client,
+		prefix: prefix,
+		ttl:    ttl,
+	}
+}
+
+// 编译期检查接口实现
+var (
+	_ captcha.ContextStore = (*CaptchaStore)(nil)
+	_ captcha.BatchStore   = (*CaptchaStore)(nil)
+	_ captcha.AtomicStore  = (*CaptchaStore)(nil)
+	_ captcha.UpdateStore  = (*CaptchaStore)(nil)
+	_ captcha.ExpiryStore  = (*CaptchaStore)(nil)
+
+	_ captcha.TokenRedeemStore = (*CaptchaStore)(nil)
+)
+
+// TTL 返回验证码有效期
+func (s *CaptchaStore) TTL() time.Duration {
+	return s.ttl
+}
+
+// Set 存储验证码数据
+func (s *CaptchaStore) Set(id string, data *captcha.CaptchaData) {
+	s.SetContext(context.Background(), id, data)
+}
+
+// Get 获取验证码数据，不存在、已过期或读取失败时返回 false
+func (s *CaptchaStore) Get(id string) (*captcha.CaptchaData, bool) {
+	data, exists, err := s.GetContext(context.Background(), id)
+	return data, exists && err == nil
+}
+
+// Update 更新验证码数据（不刷新创建时间和过期时间）
+func (s *CaptchaStore) Update(id string, data *captcha.CaptchaData) {
+	s.UpdateContext(context.Background(), id, data)
+}
+
+// IsExpired 判断验证码是否存在但已过期
+func (s *CaptchaStore) IsExpired(id string) bool {
+	expired, _ := s.IsExpiredContext(context.Background(), id)
+	return expired
+}
+
+// Delete 删除验证码数据
+func (s *CaptchaStore) Delete(id string) {
+	s.DeleteContext(context.Background(), id)
+}
+
+// CleanExpired 过期数据由 Redis 自动删除，无需清理
+func (s *CaptchaStore) CleanExpired() {}
+
+// SetContext 存储验证码数据，键保留两个有效期
+func (s *CaptchaStore) SetContext(ctx context.Context, id string, data *captcha.CaptchaData) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	data.CreatedAt = time.Now()
+	value, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("redis: failed to encode captcha: %w", err)
+	}
+	_, err = s.client.Do("SET", s.prefix+id, string(value), "PX", strconv.FormatInt((2*s.ttl).Milliseconds(), 10))
+	return err
+}
+
+// GetContext 获取验证码数据，不存在或已过期时返回 false
+func (s *CaptchaStore) GetContext(ctx context.Context, id string) (*captcha.CaptchaData, bool, error) {
+	data, err := s.load(ctx, id)
+	if err != nil || data == nil || s.expired(data) {
+		return nil, false, err
+	}
+	return data, true, nil
+}
+
+// UpdateContext 更新验证码数据，验证码不存在时忽略
+func (s *CaptchaStore) UpdateContext(ctx context.Context, id string, data *captcha.CaptchaData) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	value, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("redis: failed to encode captcha: %w", err)
+	}
+	_, err = s.client.Do("EVAL", updateScript, "1", s.prefix+id, string(value))
+	return err
+}
+
+// IsExpiredContext 判断验证码是否存在但已过期
+func (s *CaptchaStore) IsExpiredContext(ctx context.Context, id string) (bool, error) {
+	data, err := s.load(ctx, id)
+	if err != nil || data == nil {
+		return false, err
+	}
+	return s.expired(data), nil
+}
+
+// DeleteContext 删除验证码数据
+func (s *CaptchaStore) DeleteContext(ctx context.Context, id string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	_, err := s.client.Do("DEL", s.prefix+id)
+	return err
+}
+
+// GetMulti 用一条 MGET 按 ids 的顺序读取验证码数据，不存在或已过期的为nil（实现 BatchStore）
+func (s *CaptchaStore) GetMulti(ctx context.Context, ids []string) ([]*captcha.CaptchaData, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	result := make([]*captcha.CaptchaData, len(ids))
+	if len(ids) == 0 {
+		return result, nil
+	}
+
+	args := make([]string, 0, len(ids)+1)
+	args = append(args, "MGET")
+	for _, id := range ids {
+		args = append(args, s.prefix+id)
+	}
+	reply, err := s.client.Do(args...)
+	if err != nil {
+		return nil, err
+	}
+	values, ok := reply.([]interface{})
+	if !ok || len(values) != len(ids) {
+		return nil, fmt.Errorf("redis: unexpected mget reply %v", reply)
+	}
+	for i, value := range values {
+		raw, ok := value.(string)
+		if !ok {
+			continue
+		}
+		data, err := decodeCaptcha(raw)
+		if err != nil {
+			return nil, err
+		}
+		if !s.expired(data) {
+			result[i] = data
+		}
+	}
+	return result, nil
+}
+
+// CompareAndSwap 验证码未过期且状态和失败次数仍与 old 相同时替换为 new（实现 AtomicStore）
+// 比较和替换在同一个 Lua 脚本中执行，多个实例并发验证同一验证码时只有一个能成功
+func (s *CaptchaStore) CompareAndSwap(ctx context.Context, id string, old, new *captcha.CaptchaData) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	value, err := json.Marshal(new)
+	if err != nil {
+		return false, fmt.Errorf("redis: failed to encode captcha: %w", err)
+	}
+	status := old.Status
+	if status == "" {
+		status = captcha.StatusPending
+	}
+	swapped, err := s.client.Int("EVAL", casScript, "1", s.prefix+id,
+		strconv.FormatInt(s.ttl.Milliseconds(), 10),
+		string(captcha.StatusPending),
+		string(status),
+		strconv.Itoa(old.Attempts),
+		string(value))
+	if err != nil {
+		return false, err
+	}
+	return swapped == 1, nil
+}
+
+// RedeemToken 用 SET NX 记录令牌ID已兑换，键在令牌过期后自动删除，令牌在所有实例中只能兑换一次（实现 TokenRedeemStore）
+func (s *CaptchaStore) RedeemToken(ctx context.Context, jti string, ttl time.Duration) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	_, err := s.client.Do("SET", s.prefix+"token:"+jti, "1", "NX", "PX", strconv.FormatInt(max(ttl.Milliseconds(), 1), 10))
+	if errors.Is(err, ErrNil) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// load 读取并解析验证码数据，不存在时返回nil
+func (s *CaptchaStore) load(ctx context.Context, id string) (*captcha.CaptchaData, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	raw, err := s.client.String("GET", s.prefix+id)
+	if errors.Is(err, ErrNil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return decodeCaptcha(raw)
+}
+
+// expired 判断验证码是否已超过有效期
+func (s *CaptchaStore) expired(data *captcha.CaptchaData) bool {
+	return time.Since(data.CreatedAt) > s.ttl
+}
+
+// decodeCaptcha 解析 JSON 编码的验证码数据
+func decodeCaptcha(raw string) (*captcha.CaptchaData, error) {
+	var data captcha.CaptchaData
+	if err := json.Unmarshal([]byte(raw), &data); err != nil {
+		return nil, fmt.Errorf("redis: failed to decode captcha: %w", err)
+	}
+	return &data, nil
+}
@@ -0,0 +1,89 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// accessLogEntry 一条访问日志
+type accessLogEntry struct {
+	Time      time.Time `json:"time"`
+	Method    string    `json:"method"`
+	Path      string    `json:"path"`
+	Status    int       `json:"status"`
+	LatencyMs float64   `json:"latencyMs"`
+	IP        string    `json:"ip"`
+	RequestID string    `json:"requestId,omitempty"`
+	Size      int       `json:"size"`
+	UserAgent string    `json:"userAgent,omitempty"`
+}
+
+// AccessLogMiddleware 以JSON行记录每个请求（方法、路径、状态码、耗时、客户端IP、请求ID）
+func AccessLogMiddleware(w io.Writer) gin.HandlerFunc {
+	var mu sync.Mutex
+	return func(c *gin.Context) {
+		start := time.Now()
+		path := c.Request.URL.Path
+
+		c.Next()
+
+		line, err := json.Marshal(&accessLogEntry{
+			Time:      start,
+			Method:    c.Request.Method,
+			Path:      path,
+			Status:    c.Writer.Status(),
+			LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+			IP:        c.ClientIP(),
+			RequestID: requestID(c),
+			Size:      max(c.Writer.Size(), 0),
+			UserAgent: c.Request.UserAgent(),
+		})
+		if err != nil {
+			return
+		}
+		line = append(line, '\n')
+
+		mu.Lock()
+		defer mu.Unlock()
+		if _, err := w.Write(line); err != nil {
+			requestLogger(c).Error("failed to write access log", "error", err)
+		}
+	}
+}
+
+// openAccessLog 按配置打开访问日志输出：stdout 或 file:/path（按大小轮转），为空时返回nil
+func openAccessLog(cfg *Config) (io.Writer, io.Closer, error) {
+	switch {
+	case cfg.AccessLog == "":
+		return nil, nil, nil
+	case cfg.AccessLog == "stdout":
+		return os.Stdout, nil, nil
+	case strings.HasPrefix(cfg.AccessLog, "file:"):
+		file, err := openRotatingFile(strings.TrimPrefix(cfg.AccessLog, "file:"), cfg.AccessLogMaxSize<<20, cfg.AccessLogMaxBackups)
+		if err != nil {
+			return nil, nil, err
+		}
+		return file, file, nil
+	default:
+		return nil, nil, fmt.Errorf("unsupported access log target: %s", cfg.AccessLog)
+	}
+}
+
+// newEngine 创建 gin 引擎：配置了访问日志时使用JSON访问日志，否则使用 gin 默认的文本日志
+func newEngine() *gin.Engine {
+	router := gin.New()
+	if accessLogWriter != nil {
+		router.Use(AccessLogMiddleware(accessLogWriter))
+	} else {
+		router.Use(gin.Logger())
+	}
+	router.Use(gin.Recovery())
+	return router
+}
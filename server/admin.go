@@ -0,0 +1,376 @@
+package server
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/gpencil/photo_captcha/captcha"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AdminAPIKeyHeader 管理接口API Key请求头（也可使用 Authorization: Bearer <key>）
+const AdminAPIKeyHeader = "X-API-Key"
+
+// tolerance 当前生效的验证误差，可通过管理接口在运行时调整
+var tolerance atomic.Int64
+
+// adminEnabled 是否配置了管理接口的认证方式
+func (c *Config) adminEnabled() bool {
+	return c.AdminAPIKey != "" || (c.AdminUsername != "" && c.AdminPassword != "")
+}
+
+// AdminAuthMiddleware 管理接口认证：API Key 或 Basic Auth 任一通过即可
+func AdminAuthMiddleware(cfg *Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if cfg.AdminAPIKey != "" {
+			key := c.GetHeader(AdminAPIKeyHeader)
+			if key == "" {
+				key = strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+			}
+			if secureEqual(key, cfg.AdminAPIKey) {
+				c.Next()
+				return
+			}
+		}
+
+		if cfg.AdminUsername != "" && cfg.AdminPassword != "" {
+			username, password, ok := c.Request.BasicAuth()
+			if ok && secureEqual(username, cfg.AdminUsername) && secureEqual(password, cfg.AdminPassword) {
+				c.Next()
+				return
+			}
+			c.Header("WWW-Authenticate", `Basic realm="captcha admin"`)
+		}
+
+		respond(c, http.StatusUnauthorized, gin.H{
+			"code":      401,
+			"message":   msg(c, MsgUnauthorized),
+			"requestId": requestID(c),
+		})
+		c.Abort()
+	}
+}
+
+// registerAdminRoutes 注册管理接口
+func registerAdminRoutes(admin *gin.RouterGroup) {
+	admin.GET("/backgrounds", AdminListBackgroundsHandler)
+	admin.POST("/backgrounds/reload", AdminReloadBackgroundsHandler)
+	admin.GET("/shapes", AdminListShapesHandler)
+	admin.POST("/blocklist/reload", AdminReloadBlocklistHandler)
+	admin.GET("/bans", AdminListBansHandler)
+	admin.DELETE("/bans", AdminLiftBanHandler)
+	admin.GET("/stats", AdminStatsHandler)
+	admin.GET("/analytics", AdminAnalyticsHandler)
+	admin.GET("/settings", AdminSettingsHandler)
+	admin.PUT("/settings", AdminUpdateSettingsHandler)
+	admin.DELETE("/captcha/:id", RevokeCaptchaHandler)
+}
+
+// AdminListBackgroundsHandler 背景图列表
+func AdminListBackgroundsHandler(c *gin.Context) {
+	respond(c, http.StatusOK, gin.H{
+		"code":    200,
+		"message": msg(c, MsgSuccess),
+		"data": gin.H{
+			"backgrounds": captchaSvc.Backgrounds(),
+			"memoryBytes": captchaSvc.BackgroundMemory(),
+		},
+	})
+}
+
+// AdminReloadBackgroundsHandler 从 BackgroundDir（或资源文件系统的 images 目录）重新加载背景图
+func AdminReloadBackgroundsHandler(c *gin.Context) {
+	dir := backgroundDir()
+	if dir == "" {
+		respond(c, http.StatusBadRequest, gin.H{
+			"code":      400,
+			"message":   msg(c, MsgInvalidRequest, "background dir not configured"),
+			"requestId": requestID(c),
+		})
+		return
+	}
+
+	urls, err := backgroundImages(assetsFS, dir)
+	if err != nil {
+		respond(c, http.StatusInternalServerError, gin.H{
+			"code":      500,
+			"message":   err.Error(),
+			"requestId": requestID(c),
+		})
+		return
+	}
+	if err := captchaSvc.SetBackgrounds(urls); err != nil {
+		respond(c, http.StatusInternalServerError, gin.H{
+			"code":      500,
+			"message":   err.Error(),
+			"requestId": requestID(c),
+		})
+		return
+	}
+	requestLogger(c).Info("backgrounds reloaded", "dir", dir, "count", len(urls))
+
+	respond(c, http.StatusOK, gin.H{
+		"code":    200,
+		"message": msg(c, MsgSuccess),
+		"data": gin.H{
+			"backgrounds": urls,
+		},
+	})
+}
+
+// AdminListShapesHandler 已注册的拼图形状
+func AdminListShapesHandler(c *gin.Context) {
+	shapes := make([]gin.H, 0, len(captcha.PuzzleTypes))
+	for _, t := range captcha.PuzzleTypes {
+		shapes = append(shapes, gin.H{
+			"type":     int(t),
+			"name":     t.String(),
+			"maskFile": t.MaskFile(),
+		})
+	}
+
+	respond(c, http.StatusOK, gin.H{
+		"code":    200,
+		"message": msg(c, MsgSuccess),
+		"data": gin.H{
+			"shapes": shapes,
+		},
+	})
+}
+
+// AdminReloadBlocklistHandler 重新加载 IPBlocklist 文件，加载失败时保留原有条目
+func AdminReloadBlocklistHandler(c *gin.Context) {
+	if ipBlocklist == nil {
+		respond(c, http.StatusBadRequest, gin.H{
+			"code":      400,
+			"message":   msg(c, MsgInvalidRequest, "ip blocklist not configured"),
+			"requestId": requestID(c),
+		})
+		return
+	}
+	if err := ipBlocklist.Reload(); err != nil {
+		respond(c, http.StatusInternalServerError, gin.H{
+			"code":      500,
+			"message":   err.Error(),
+			"requestId": requestID(c),
+		})
+		return
+	}
+	requestLogger(c).Info("ip blocklist reloaded", "entries", ipBlocklist.Len())
+
+	respond(c, http.StatusOK, gin.H{
+		"code":    200,
+		"message": msg(c, MsgSuccess),
+		"data": gin.H{
+			"entries": ipBlocklist.Len(),
+		},
+	})
+}
+
+// AdminListBansHandler 生效中的暴力破解封禁（IP和指纹）及其截止时间
+func AdminListBansHandler(c *gin.Context) {
+	if !lockoutEnabled(c) {
+		return
+	}
+	bans, err := lockout.Bans()
+	if err != nil {
+		respond(c, http.StatusInternalServerError, gin.H{
+			"code":      500,
+			"message":   err.Error(),
+			"requestId": requestID(c),
+		})
+		return
+	}
+
+	list := make([]gin.H, 0, len(bans))
+	for _, ban := range bans {
+		list = append(list, gin.H{
+			"kind":  ban.Kind,
+			"value": ban.Value,
+			"until": ban.Until,
+		})
+	}
+	respond(c, http.StatusOK, gin.H{
+		"code":    200,
+		"message": msg(c, MsgSuccess),
+		"data": gin.H{
+			"bans": list,
+		},
+	})
+}
+
+// AdminLiftBanHandler 提前解除封禁：?kind=ip|fingerprint&value=<IP或指纹>，同时清空其失败计数
+func AdminLiftBanHandler(c *gin.Context) {
+	if !lockoutEnabled(c) {
+		return
+	}
+	kind, value := c.Query("kind"), c.Query("value")
+	if kind != captcha.LockoutKindIP && kind != captcha.LockoutKindFingerprint || value == "" {
+		respond(c, http.StatusBadRequest, gin.H{
+			"code":      400,
+			"message":   msg(c, MsgInvalidRequest, "kind must be ip or fingerprint and value is required"),
+			"requestId": requestID(c),
+		})
+		return
+	}
+
+	existed, err := lockout.Unban(kind, value)
+	if err != nil {
+		respond(c, http.StatusInternalServerError, gin.H{
+			"code":      500,
+			"message":   err.Error(),
+			"requestId": requestID(c),
+		})
+		return
+	}
+	if !existed {
+		respond(c, http.StatusNotFound, gin.H{
+			"code":      404,
+			"message":   msg(c, MsgInvalidRequest, "ban not found"),
+			"requestId": requestID(c),
+		})
+		return
+	}
+	requestLogger(c).Info("lockout ban lifted by admin", "kind", kind, "value", value)
+
+	respond(c, http.StatusOK, gin.H{
+		"code":    200,
+		"message": msg(c, MsgSuccess),
+	})
+}
+
+// lockoutEnabled 未启用暴力破解封禁时返回错误响应
+func lockoutEnabled(c *gin.Context) bool {
+	if lockout != nil {
+		return true
+	}
+	respond(c, http.StatusBadRequest, gin.H{
+		"code":      400,
+		"message":   msg(c, MsgInvalidRequest, "lockout not enabled"),
+		"requestId": requestID(c),
+	})
+	return false
+}
+
+// AdminStatsHandler 存储和通过率统计
+func AdminStatsHandler(c *gin.Context) {
+	data := gin.H{
+		"difficulty": gin.H{
+			"level": captcha.DefaultDifficulty.GlobalLevel().String(),
+			"stats": captcha.DefaultDifficulty.GlobalStats(),
+		},
+	}
+	if store, ok := captchaSvc.Store().(*captcha.MemoryStore); ok {
+		data["store"] = store.Stats()
+	}
+	if config != nil && config.BurstThreshold > 0 {
+		data["burst"] = captchaSvc.BurstStats()
+	}
+	if config != nil && config.RenderCacheSize > 0 {
+		data["renderCache"] = captchaSvc.RenderCacheStats()
+	}
+
+	respond(c, http.StatusOK, gin.H{
+		"code":    200,
+		"message": msg(c, MsgSuccess),
+		"data":    data,
+	})
+}
+
+// AdminSettingsHandler 当前运行时设置
+func AdminSettingsHandler(c *gin.Context) {
+	respond(c, http.StatusOK, gin.H{
+		"code":    200,
+		"message": msg(c, MsgSuccess),
+		"data":    runtimeSettings(),
+	})
+}
+
+// AdminSettingsRequest 运行时设置调整请求，未提供的字段保持不变
+type AdminSettingsRequest struct {
+	Tolerance *int    `json:"tolerance"` // 验证允许的误差（像素）
+	TTL       *string `json:"ttl"`       // 验证码有效期，如 "5m"
+}
+
+// AdminUpdateSettingsHandler 运行时调整容差和有效期
+func AdminUpdateSettingsHandler(c *gin.Context) {
+	var req AdminSettingsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respond(c, http.StatusBadRequest, gin.H{
+			"code":      400,
+			"message":   msg(c, MsgInvalidRequest, err),
+			"requestId": requestID(c),
+		})
+		return
+	}
+	if err := applyRuntimeSettings(&req); err != nil {
+		respond(c, http.StatusBadRequest, gin.H{
+			"code":      400,
+			"message":   msg(c, MsgInvalidRequest, err),
+			"requestId": requestID(c),
+		})
+		return
+	}
+	requestLogger(c).Info("runtime settings updated", "settings", runtimeSettings())
+
+	respond(c, http.StatusOK, gin.H{
+		"code":    200,
+		"message": msg(c, MsgSuccess),
+		"data":    runtimeSettings(),
+	})
+}
+
+// applyRuntimeSettings 校验并应用运行时设置
+func applyRuntimeSettings(req *AdminSettingsRequest) error {
+	var ttl time.Duration
+	if req.TTL != nil {
+		parsed, err := time.ParseDuration(*req.TTL)
+		if err != nil || parsed <= 0 {
+			return fmt.Errorf("invalid ttl %q", *req.TTL)
+		}
+		if _, ok := captchaSvc.Store().(*captcha.MemoryStore); !ok {
+			return fmt.Errorf("ttl can only be adjusted for the memory store")
+		}
+		ttl = parsed
+	}
+	if req.Tolerance != nil && *req.Tolerance <= 0 {
+		return fmt.Errorf("invalid tolerance %d", *req.Tolerance)
+	}
+
+	if req.Tolerance != nil {
+		tolerance.Store(int64(*req.Tolerance))
+	}
+	if ttl > 0 {
+		captchaSvc.Store().(*captcha.MemoryStore).SetTTL(ttl)
+	}
+	return nil
+}
+
+// runtimeSettings 当前运行时设置
+func runtimeSettings() gin.H {
+	return gin.H{
+		"tolerance": tolerance.Load(),
+		"ttl":       captchaTTL().String(),
+	}
+}
+
+// captchaTTL 当前验证码有效期
+func captchaTTL() time.Duration {
+	if store, ok := captchaSvc.Store().(*captcha.MemoryStore); ok {
+		return store.TTL()
+	}
+	if config.CaptchaTTL > 0 {
+		return config.CaptchaTTL
+	}
+	return captcha.DefaultTTL
+}
+
+// secureEqual 常量时间比较，避免计时攻击
+func secureEqual(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
@@ -0,0 +1,322 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/netip"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gpencil/photo_captcha/captcha"
+
+	"github.com/gin-gonic/gin"
+)
+
+// analyticsTopN 分析接口返回的IP段和失败指纹数量
+const analyticsTopN = 20
+
+// analyticsMaxKeys 每小时最多单独统计的IP段和指纹数量，超出后新来源计入 analyticsOther，避免大量伪造来源耗尽内存
+const analyticsMaxKeys = 10000
+
+// analyticsOther 超出 analyticsMaxKeys 的来源的汇总键
+const analyticsOther = "other"
+
+// solveTimeBounds 解题耗时分布各区间的上限，最后一个区间没有上限
+var solveTimeBounds = []time.Duration{time.Second, 2 * time.Second, 5 * time.Second, 10 * time.Second, 30 * time.Second}
+
+// analyticsRecorder 生成和验证事件的按小时统计（未启用时为nil）
+var analyticsRecorder *analytics
+
+// passCount 验证次数和通过次数
+type passCount struct {
+	verified int
+	passed   int
+}
+
+// analyticsHour 一小时内的统计
+type analyticsHour struct {
+	start        time.Time
+	generated    int
+	total        passCount
+	solveCount   int
+	solveSum     time.Duration
+	solveTimes   []int
+	prefixes     map[string]*passCount
+	fingerprints map[string]int
+}
+
+// analytics 保留最近若干小时的生成量、验证量、按IP段的通过率、解题耗时分布和验证失败的指纹，
+// 由验证码服务的生成和验证回调驱动，只保存在本实例内存中
+type analytics struct {
+	mu    sync.Mutex
+	hours []analyticsHour
+}
+
+// newAnalytics 创建保留最近 hours 小时统计的记录器
+func newAnalytics(hours int) *analytics {
+	return &analytics{hours: make([]analyticsHour, hours)}
+}
+
+// attach 注册验证码服务的生成和验证回调
+func (a *analytics) attach(svc *captcha.CaptchaService) {
+	svc.OnGenerate(func(ctx context.Context, _ *captcha.SliderCaptcha, _ captcha.GenerateParams) {
+		a.recordGenerate(time.Now())
+	})
+	svc.OnVerify(func(ctx context.Context, params captcha.VerifyParams, result *captcha.VerifyResult, _ error) {
+		a.recordVerify(time.Now(), params, result)
+	})
+}
+
+// hourLocked 返回 now 所在小时的统计，复用已超出保留时间的槽位，调用方需持有锁
+func (a *analytics) hourLocked(now time.Time) *analyticsHour {
+	start := now.Truncate(time.Hour)
+	h := &a.hours[start.Unix()/3600%int64(len(a.hours))]
+	if !h.start.Equal(start) {
+		*h = analyticsHour{
+			start:        start,
+			solveTimes:   make([]int, len(solveTimeBounds)+1),
+			prefixes:     make(map[string]*passCount),
+			fingerprints: make(map[string]int),
+		}
+	}
+	return h
+}
+
+// recordGenerate 记录一次生成
+func (a *analytics) recordGenerate(now time.Time) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.hourLocked(now).generated++
+}
+
+// recordVerify 记录一次验证：按来源IP段统计通过率，验证码不存在、过期或存储不可用以外的失败计入指纹
+func (a *analytics) recordVerify(now time.Time, params captcha.VerifyParams, result *captcha.VerifyResult) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	h := a.hourLocked(now)
+	h.total.verified++
+	key := analyticsKey(h.prefixes, ipPrefix(params.RemoteIP))
+	prefix := h.prefixes[key]
+	if prefix == nil {
+		prefix = &passCount{}
+		h.prefixes[key] = prefix
+	}
+	prefix.verified++
+	if result.Success {
+		h.total.passed++
+		prefix.passed++
+	}
+
+	if result.SolveTime > 0 {
+		h.solveCount++
+		h.solveSum += result.SolveTime
+		i := sort.Search(len(solveTimeBounds), func(i int) bool { return result.SolveTime <= solveTimeBounds[i] })
+		h.solveTimes[i]++
+	}
+
+	switch result.Reason {
+	case captcha.ReasonNotFound, captcha.ReasonExpired, captcha.ReasonUnavailable:
+	default:
+		if !result.Success && params.Fingerprint != "" {
+			h.fingerprints[analyticsKey(h.fingerprints, params.Fingerprint)]++
+		}
+	}
+}
+
+// analyticsKey 已统计的键数量达到上限时把新键归入 analyticsOther
+func analyticsKey[V any](m map[string]V, key string) string {
+	if _, exists := m[key]; exists || len(m) < analyticsMaxKeys {
+		return key
+	}
+	return analyticsOther
+}
+
+// ipPrefix 返回IP所在的网段：IPv4 取 /24，IPv6 取 /48；无法解析时返回 "unknown"
+func ipPrefix(ip string) string {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return "unknown"
+	}
+	addr = addr.Unmap()
+	bits := 48
+	if addr.Is4() {
+		bits = 24
+	}
+	prefix, _ := addr.Prefix(bits)
+	return prefix.String()
+}
+
+// AnalyticsSummary 最近若干小时的生成和验证统计
+type AnalyticsSummary struct {
+	Since     time.Time `json:"since"`
+	Hours     int       `json:"hours"`
+	Generated int       `json:"generated"`
+	Verified  int       `json:"verified"`
+	Passed    int       `json:"passed"`
+	PassRate  float64   `json:"passRate"`
+	// SolveTime 从生成到提交验证的耗时分布，耗时集中在极短区间通常意味着自动化工具
+	SolveTime SolveTimeSummary `json:"solveTime"`
+	// IPPrefixes 验证量最多的IP段（IPv4 /24，IPv6 /48）及其通过率
+	IPPrefixes []PrefixSummary `json:"ipPrefixes"`
+	// FailingFingerprints 验证失败次数最多的客户端指纹（不含验证码不存在或已过期）
+	FailingFingerprints []FingerprintSummary `json:"failingFingerprints"`
+	// Hourly 每小时的生成和验证量，按时间先后排列
+	Hourly []HourlySummary `json:"hourly"`
+}
+
+// SolveTimeSummary 解题耗时分布
+type SolveTimeSummary struct {
+	AverageMs int64             `json:"averageMs"`
+	Buckets   []SolveTimeBucket `json:"buckets"`
+}
+
+// SolveTimeBucket 解题耗时不超过 LE（如 "5s"，最后一个区间为 "+Inf"）的验证次数
+type SolveTimeBucket struct {
+	LE    string `json:"le"`
+	Count int    `json:"count"`
+}
+
+// PrefixSummary 一个IP段的验证次数和通过率
+type PrefixSummary struct {
+	Prefix   string  `json:"prefix"`
+	Verified int     `json:"verified"`
+	Passed   int     `json:"passed"`
+	PassRate float64 `json:"passRate"`
+}
+
+// FingerprintSummary 一个客户端指纹的验证失败次数
+type FingerprintSummary struct {
+	Fingerprint string `json:"fingerprint"`
+	Failures    int    `json:"failures"`
+}
+
+// HourlySummary 一小时内的生成和验证量
+type HourlySummary struct {
+	Hour      time.Time `json:"hour"`
+	Generated int       `json:"generated"`
+	Verified  int       `json:"verified"`
+	Passed    int       `json:"passed"`
+}
+
+// summary 汇总截至 now 最近 hours 小时（含当前小时）的统计
+func (a *analytics) summary(now time.Time, hours int) *AnalyticsSummary {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	current := now.Truncate(time.Hour)
+	since := current.Add(-time.Duration(hours-1) * time.Hour)
+	s := &AnalyticsSummary{
+		Since:               since,
+		Hours:               hours,
+		IPPrefixes:          []PrefixSummary{},
+		FailingFingerprints: []FingerprintSummary{},
+		Hourly:              []HourlySummary{},
+	}
+	solveTimes := make([]int, len(solveTimeBounds)+1)
+	var solveCount int
+	var solveSum time.Duration
+	prefixes := make(map[string]*passCount)
+	fingerprints := make(map[string]int)
+
+	for i := range a.hours {
+		h := &a.hours[i]
+		if h.start.IsZero() || h.start.Before(since) || h.start.After(current) {
+			continue
+		}
+		s.Generated += h.generated
+		s.Verified += h.total.verified
+		s.Passed += h.total.passed
+		s.Hourly = append(s.Hourly, HourlySummary{Hour: h.start, Generated: h.generated, Verified: h.total.verified, Passed: h.total.passed})
+		solveCount += h.solveCount
+		solveSum += h.solveSum
+		for j, n := range h.solveTimes {
+			solveTimes[j] += n
+		}
+		for prefix, c := range h.prefixes {
+			total := prefixes[prefix]
+			if total == nil {
+				total = &passCount{}
+				prefixes[prefix] = total
+			}
+			total.verified += c.verified
+			total.passed += c.passed
+		}
+		for fp, n := range h.fingerprints {
+			fingerprints[fp] += n
+		}
+	}
+
+	s.PassRate = passRate(s.Passed, s.Verified)
+	sort.Slice(s.Hourly, func(i, j int) bool { return s.Hourly[i].Hour.Before(s.Hourly[j].Hour) })
+	if solveCount > 0 {
+		s.SolveTime.AverageMs = (solveSum / time.Duration(solveCount)).Milliseconds()
+	}
+	for i, n := range solveTimes {
+		le := "+Inf"
+		if i < len(solveTimeBounds) {
+			le = solveTimeBounds[i].String()
+		}
+		s.SolveTime.Buckets = append(s.SolveTime.Buckets, SolveTimeBucket{LE: le, Count: n})
+	}
+	for prefix, c := range prefixes {
+		s.IPPrefixes = append(s.IPPrefixes, PrefixSummary{Prefix: prefix, Verified: c.verified, Passed: c.passed, PassRate: passRate(c.passed, c.verified)})
+	}
+	sort.Slice(s.IPPrefixes, func(i, j int) bool {
+		a, b := s.IPPrefixes[i], s.IPPrefixes[j]
+		return a.Verified > b.Verified || a.Verified == b.Verified && a.Prefix < b.Prefix
+	})
+	s.IPPrefixes = s.IPPrefixes[:min(len(s.IPPrefixes), analyticsTopN)]
+	for fp, n := range fingerprints {
+		s.FailingFingerprints = append(s.FailingFingerprints, FingerprintSummary{Fingerprint: fp, Failures: n})
+	}
+	sort.Slice(s.FailingFingerprints, func(i, j int) bool {
+		a, b := s.FailingFingerprints[i], s.FailingFingerprints[j]
+		return a.Failures > b.Failures || a.Failures == b.Failures && a.Fingerprint < b.Fingerprint
+	})
+	s.FailingFingerprints = s.FailingFingerprints[:min(len(s.FailingFingerprints), analyticsTopN)]
+	return s
+}
+
+// passRate 通过率，没有验证时为0
+func passRate(passed, verified int) float64 {
+	if verified == 0 {
+		return 0
+	}
+	return float64(passed) / float64(verified)
+}
+
+// AdminAnalyticsHandler 最近 N 小时（?hours=N，默认为全部保留时长）的滥用分析：生成和验证量、按IP段的通过率、
+// 解题耗时分布和验证失败最多的指纹，便于运维不借助外部工具发现攻击
+func AdminAnalyticsHandler(c *gin.Context) {
+	if analyticsRecorder == nil {
+		respond(c, http.StatusBadRequest, gin.H{
+			"code":      400,
+			"message":   msg(c, MsgInvalidRequest, "analytics not enabled"),
+			"requestId": requestID(c),
+		})
+		return
+	}
+
+	hours := len(analyticsRecorder.hours)
+	if v := c.Query("hours"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > hours {
+			respond(c, http.StatusBadRequest, gin.H{
+				"code":      400,
+				"message":   msg(c, MsgInvalidRequest, "hours must be between 1 and "+strconv.Itoa(hours)),
+				"requestId": requestID(c),
+			})
+			return
+		}
+		hours = n
+	}
+
+	respond(c, http.StatusOK, gin.H{
+		"code":    200,
+		"message": msg(c, MsgSuccess),
+		"data":    analyticsRecorder.summary(time.Now(), hours),
+	})
+}
@@ -0,0 +1,95 @@
+package server
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// 资源文件系统中的默认目录
+const (
+	assetsBackgroundDir = "images"
+	assetsWebDir        = "web"
+)
+
+// openAssets 打开资源文件系统：Config.Assets 优先，其次 AssetsPath（目录或 .zip 文件），都未配置时返回nil
+// 返回的 io.Closer 在不再使用时关闭（只有 zip 文件需要关闭）
+func openAssets(cfg *Config) (fs.FS, io.Closer, error) {
+	if cfg.Assets != nil {
+		return cfg.Assets, nil, nil
+	}
+	if cfg.AssetsPath == "" {
+		return nil, nil, nil
+	}
+
+	info, err := os.Stat(cfg.AssetsPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open assets: %w", err)
+	}
+	if info.IsDir() {
+		return os.DirFS(cfg.AssetsPath), nil, nil
+	}
+	if strings.ToLower(filepath.Ext(cfg.AssetsPath)) != ".zip" {
+		return nil, nil, fmt.Errorf("assets must be a directory or a .zip file: %s", cfg.AssetsPath)
+	}
+	reader, err := zip.OpenReader(cfg.AssetsPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open assets: %w", err)
+	}
+	return reader, reader, nil
+}
+
+// isDirFS fsys 中是否存在目录 name
+func isDirFS(fsys fs.FS, name string) bool {
+	info, err := fs.Stat(fsys, name)
+	return err == nil && info.IsDir()
+}
+
+// backgroundDir 生效的背景图目录：BackgroundDir 优先，配置了资源文件系统时默认使用其中的 images 目录
+func backgroundDir() string {
+	if config.BackgroundDir != "" {
+		return config.BackgroundDir
+	}
+	if assetsFS != nil && isDirFS(assetsFS, assetsBackgroundDir) {
+		return assetsBackgroundDir
+	}
+	return ""
+}
+
+// backgroundImages 返回目录下的 jpg/png 图片路径（按文件名排序），fsys 为nil时读取磁盘目录
+func backgroundImages(fsys fs.FS, dir string) ([]string, error) {
+	var entries []fs.DirEntry
+	var err error
+	join := filepath.Join
+	if fsys != nil {
+		entries, err = fs.ReadDir(fsys, dir)
+		join = path.Join
+	} else {
+		entries, err = os.ReadDir(dir)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read background dir: %w", err)
+	}
+
+	var paths []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		switch strings.ToLower(filepath.Ext(entry.Name())) {
+		case ".jpg", ".jpeg", ".png":
+			paths = append(paths, join(dir, entry.Name()))
+		}
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no background images found in %s", dir)
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
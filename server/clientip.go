@@ -0,0 +1,33 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DefaultClientIPHeaders 默认读取客户端IP的请求头，依次尝试
+var DefaultClientIPHeaders = []string{"X-Forwarded-For", "X-Real-IP"}
+
+// configureClientIP 按配置设置客户端IP的解析方式
+// 只有连接来自 TrustedProxies 时才读取 ClientIPHeaders，X-Forwarded-For 从右向左跳过受信任的代理，
+// 取第一个不受信任的地址作为客户端IP；限流、IP绑定、封禁和审计都使用该地址
+func configureClientIP(router *gin.Engine, cfg *Config) error {
+	if err := router.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		return fmt.Errorf("invalid trusted proxies: %w", err)
+	}
+
+	headers := cfg.ClientIPHeaders
+	if len(headers) == 0 {
+		headers = DefaultClientIPHeaders
+	}
+	router.RemoteIPHeaders = make([]string, len(headers))
+	for i, header := range headers {
+		router.RemoteIPHeaders[i] = http.CanonicalHeaderKey(header)
+	}
+
+	// 不使用 TrustedPlatform：它无条件信任请求头，直连源站的请求可以伪造IP
+	router.TrustedPlatform = ""
+	return nil
+}
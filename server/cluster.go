@@ -0,0 +1,105 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// clusterLimiter 按客户端特征簇的生成限流器（未启用时为nil）
+var clusterLimiter *fixedWindowLimiter
+
+// uaFamilies 识别的User-Agent家族，按顺序匹配，前面的优先（如 Edge 的UA中同时含有 Chrome）
+var uaFamilies = []struct {
+	family string
+	token  string
+}{
+	{"headlesschrome", "HeadlessChrome/"},
+	{"edge", "Edg/"},
+	{"opera", "OPR/"},
+	{"samsung", "SamsungBrowser/"},
+	{"firefox", "Firefox/"},
+	{"chrome", "Chrome/"},
+	{"safari", "Version/"},
+	{"curl", "curl/"},
+	{"wget", "Wget/"},
+	{"python", "python-requests/"},
+	{"go", "Go-http-client/"},
+	{"okhttp", "okhttp/"},
+}
+
+// clusterHeaders 参与客户端特征簇的标准请求头，按是否出现组成签名
+// 真实浏览器总会发送其中大部分（Sec-Fetch-*、Sec-CH-UA 等由浏览器自动添加，页面脚本无法修改），
+// 自动化工具和HTTP库通常缺少其中一部分；net/http 不保留请求头顺序，因此只比较出现与否
+var clusterHeaders = []string{
+	"Accept",
+	"Accept-Encoding",
+	"Accept-Language",
+	"Sec-Fetch-Site",
+	"Sec-Fetch-Mode",
+	"Sec-Fetch-Dest",
+	"Sec-Ch-Ua",
+	"Sec-Ch-Ua-Mobile",
+	"Sec-Ch-Ua-Platform",
+	"Upgrade-Insecure-Requests",
+}
+
+// maxLanguageLen 语言标签的最大长度，超出时按未提供处理，避免任意长的字符串成为限流键
+const maxLanguageLen = 16
+
+// uaFamily 返回User-Agent的家族和主版本号（如 chrome/120），无法识别时返回 other
+func uaFamily(ua string) string {
+	for _, f := range uaFamilies {
+		i := strings.Index(ua, f.token)
+		if i < 0 {
+			continue
+		}
+		version := ua[i+len(f.token):]
+		if end := strings.IndexFunc(version, func(r rune) bool { return r < '0' || r > '9' }); end >= 0 {
+			version = version[:end]
+		}
+		if version == "" {
+			return f.family
+		}
+		return f.family + "/" + version
+	}
+	if ua == "" {
+		return "none"
+	}
+	return "other"
+}
+
+// clientCluster 返回请求的粗粒度客户端特征簇：User-Agent家族、Accept-Language 的首选语言和标准请求头的出现签名，
+// 只使用服务端观察到的请求头，不信任客户端自报的参数；轮换IP但复用同一套自动化环境的僵尸网络落在同一个簇中
+func clientCluster(c *gin.Context) string {
+	return uaFamily(c.Request.UserAgent()) + "|" + primaryLanguage(c.GetHeader("Accept-Language")) + "|" + headerSignature(c.Request.Header)
+}
+
+// primaryLanguage 返回 Accept-Language 中的首选语言标签（小写，如 zh-cn），不合法时返回空串
+func primaryLanguage(header string) string {
+	tag, _, _ := strings.Cut(header, ",")
+	tag, _, _ = strings.Cut(tag, ";")
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	if len(tag) > maxLanguageLen {
+		return ""
+	}
+	for _, r := range tag {
+		if (r < 'a' || r > 'z') && (r < '0' || r > '9') && r != '-' && r != '*' {
+			return ""
+		}
+	}
+	return tag
+}
+
+// headerSignature 按 clusterHeaders 的顺序返回各请求头是否出现的签名，如 1110000001
+func headerSignature(h http.Header) string {
+	sig := make([]byte, len(clusterHeaders))
+	for i, name := range clusterHeaders {
+		sig[i] = '0'
+		if len(h[name]) > 0 {
+			sig[i] = '1'
+		}
+	}
+	return string(sig)
+}
@@ -0,0 +1,295 @@
+package server
+
+import (
+	"image/png"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gpencil/photo_captcha/audit"
+	"github.com/gpencil/photo_captcha/captcha"
+	"github.com/gpencil/photo_captcha/captcha/statsd"
+	"github.com/gpencil/photo_captcha/redis"
+	"github.com/gpencil/photo_captcha/web"
+)
+
+// Config 服务配置
+type Config struct {
+	// Port 监听端口
+	Port int
+	// Addr 监听地址（如 127.0.0.1:8087），配置后忽略 Port
+	Addr string
+	// BasePath 所有路由的路径前缀（如 /captcha-svc），用于按路径转发的网关，为空表示挂载在根路径
+	BasePath string
+	// UnixSocket Unix 套接字路径，配置后监听该套接字而不是TCP端口（适合本机 Nginx 反向代理）
+	UnixSocket string
+	// UnixSocketMode Unix 套接字文件权限
+	UnixSocketMode os.FileMode
+	// CaptchaTTL 验证码有效期
+	CaptchaTTL time.Duration
+	// Tolerance 验证允许的误差（像素），按难度生成的更严格容差优先
+	Tolerance int
+	// Placement 缺口位置的随机范围
+	Placement captcha.PlacementMode
+	// PlacementMargin 全画布模式下缺口与画布边缘的最小距离（像素）
+	PlacementMargin int
+	// Pregenerate 每种难度预生成的验证码数量，0表示不启用预生成
+	Pregenerate int
+	// PregenerateWorkers 预生成验证码的后台协程数量
+	PregenerateWorkers int
+	// AsyncWorkers 处理异步生成任务（POST /generate?async=1）的后台协程数量，0表示不启用（异步请求按同步生成处理）
+	AsyncWorkers int
+	// AsyncQueueSize 最多排队的异步生成任务数，队列已满时按同步生成处理
+	AsyncQueueSize int
+	// BurstThreshold 每秒生成数量超过该值时复用已渲染的图片（各验证码ID和答案独立存储），0表示不启用
+	BurstThreshold int
+	// BurstWindow 同一图片可被复用的时间窗口
+	BurstWindow time.Duration
+	// BurstMaxReuse 同一图片最多被复用的次数
+	BurstMaxReuse int
+	// RenderCacheSize 缓存的渲染结果数量（背景图很少时重复的组合直接复用），0表示不启用
+	RenderCacheSize int
+	// RenderCacheBucket 启用渲染缓存时随机缺口位置取整的步长（像素）
+	RenderCacheBucket int
+	// FastBlur 用三次盒式模糊近似缺口和拼图块的高斯模糊（整数运算、耗时与半径无关），适合小容器、ARM设备等CPU受限的环境
+	FastBlur bool
+	// PNGCompression 验证码图片的PNG压缩级别，BestSpeed 编码更快但图片更大
+	PNGCompression png.CompressionLevel
+	// AsyncInit 启动时在后台加载背景图，HTTP服务立即开始监听；加载完成前生成接口返回503和 Retry-After，/readyz 报告进度
+	AsyncInit bool
+	// LazyBackgrounds 启动时不下载背景图，每张第一次被选中时才下载（同一张的并发下载会合并）
+	LazyBackgrounds bool
+	// RandomHoleStyle 每个验证码随机选择缺口的遮罩强度、是否描边和模糊半径（范围为 captcha.DefaultHoleVariation）
+	RandomHoleStyle bool
+	// ImageFormats 允许解码的图片格式（jpeg、png、gif），对背景图和拼图mask都生效；服务不带 webp 解码器，不支持 webp
+	ImageFormats []string
+	// MaxImageBytes 背景图和拼图mask文件的最大字节数
+	MaxImageBytes int64
+	// MaxImagePixels 背景图和拼图mask的最大像素数（宽x高），解码前检查，防止解压炸弹耗尽内存
+	MaxImagePixels int
+	// MaxImageDimension 背景图和拼图mask的最大宽度和高度（像素），解码前检查
+	MaxImageDimension int
+	// DownloadTimeout 下载远程背景图的单次请求超时
+	DownloadTimeout time.Duration
+	// DownloadRetries 下载远程背景图遇到网络错误、429 和 5xx 时的重试次数，-1表示不重试
+	DownloadRetries int
+	// DownloadHeader 下载远程背景图时附加的请求头（如私有OSS的鉴权头）
+	DownloadHeader http.Header
+	// ImageCacheDir 远程背景图的本地缓存目录，重启时不再重新下载，为空表示不缓存
+	ImageCacheDir string
+	// ImageCacheMaxBytes 本地缓存目录的大小上限（字节），超过后删除最久未使用的图片
+	ImageCacheMaxBytes int64
+	// WebDir 演示页面目录，配置后从磁盘读取（开发时修改页面无需重新编译），默认使用编译进二进制的页面
+	WebDir string
+	// BackgroundDir 背景图目录，配置后使用目录下的 jpg/png 图片替代 captcha.BackgroundURLs
+	BackgroundDir string
+	// Assets 资源文件系统（背景图 images/、拼图mask mask/、演示页面 web/），由代码设置（如 embed.FS），优先于 AssetsPath
+	Assets fs.FS
+	// AssetsPath 资源目录或 .zip 文件，配置后背景图、mask和演示页面从其中读取，便于打包成单个制品分发
+	AssetsPath string
+	// BackgroundHashes 远程背景图的 SHA-256 清单文件（sha256sum 格式，每行哈希和URL），内容不一致的背景图拒绝加载，为空表示不校验
+	BackgroundHashes string
+	// BackgroundScale 预加载背景图最大为画布的倍数（控制背景图缓存的内存占用），0表示保留原始分辨率
+	BackgroundScale float64
+
+	// BindClientIP 是否将验证码绑定到生成时的客户端IP
+	// 部分移动运营商会频繁切换出口IP，默认关闭
+	BindClientIP bool
+	// TrustedProxies 受信任的反向代理地址/CIDR，只有来自这些地址的请求才读取 ClientIPHeaders
+	// 为空表示不信任任何代理，直接使用连接的远端地址
+	TrustedProxies []string
+	// ClientIPHeaders 读取客户端IP的请求头，依次尝试，默认 X-Forwarded-For、X-Real-IP
+	// 位于 CDN 之后时可配置为 CDN 的请求头（如 CF-Connecting-IP），并将 CDN 回源地址段加入 TrustedProxies
+	ClientIPHeaders []string
+	// HoneypotField 验证请求中的蜜罐字段名（前端隐藏，正常用户不会填写），为空表示不启用
+	HoneypotField string
+	// BindSession 生成时下发 HttpOnly 会话Cookie，验证时必须携带同一Cookie，防止验证码被转移到其他浏览器完成
+	BindSession bool
+	// SessionCookieName 会话Cookie名称
+	SessionCookieName string
+
+	// GenerateRateLimit 每个IP每分钟允许生成的验证码数量，超出后需要先完成工作量证明，0表示不限制
+	GenerateRateLimit int
+	// GenerateClusterRateLimit 每个客户端特征簇（User-Agent家族和主版本、首选语言、标准请求头的出现签名）每分钟允许生成的验证码数量，
+	// 超出后同样需要先完成工作量证明，用于发现轮换IP但复用同一套自动化环境的僵尸网络，0表示不限制
+	GenerateClusterRateLimit int
+	// PoWDifficulty 工作量证明的基础难度（前导零位数），每超出限额一倍再增加1位
+	PoWDifficulty int
+	// PoWSecret 签名工作量证明挑战的密钥，挑战无状态地绑定难度、过期时间和客户端IP，多实例必须相同；
+	// 为空时随机生成，挑战只在本实例有效
+	PoWSecret string
+
+	// GenerateIPRateLimit、GenerateGlobalRateLimit 生成接口（含换一张）每个IP和全局每分钟允许的请求数
+	// 按令牌桶限流，超出后直接返回 429，0表示不限制
+	GenerateIPRateLimit     int
+	GenerateGlobalRateLimit int
+	// VerifyIPRateLimit、VerifyGlobalRateLimit 验证接口每个IP和全局每分钟允许的请求数，0表示不限制
+	VerifyIPRateLimit     int
+	VerifyGlobalRateLimit int
+	// GenerateDailyQuota 每个IP和每个指纹每天（UTC）允许生成的验证码数量，分别计数，超出后返回 429 直到次日，0表示不限制
+	GenerateDailyQuota int
+
+	// LockoutMaxFailures 同一IP或指纹在 LockoutWindow 内验证失败达到此次数后封禁，0表示不启用
+	LockoutMaxFailures int
+	// LockoutWindow 失败计数窗口
+	LockoutWindow time.Duration
+	// LockoutDuration 封禁时长，封禁期间禁止生成和验证
+	LockoutDuration time.Duration
+	// MaxChallengeAge 验证请求提交了有效的 actionStart 时，验证码签发时间早于受保护操作开始时间的上限，0表示不检查
+	MaxChallengeAge time.Duration
+	// ActionSecret 业务后端签名受保护操作开始时间（actionStart）的密钥，为空时忽略验证请求中的 actionStart
+	ActionSecret string
+	// IPBlocklist IP信誉黑名单文件，命中的来源生成时使用更高难度、验证时计入风险分，为空表示不启用
+	IPBlocklist string
+
+	// AuditSink 验证审计记录输出目标：stdout、file:/path 或 Webhook URL，多个用逗号分隔，为空表示不记录
+	AuditSink string
+	// AuditSalt 审计记录中IP哈希的盐值
+	AuditSalt string
+	// AnalyticsHours 管理接口 /api/admin/analytics 保留的统计时长（小时），0表示不统计
+	AnalyticsHours int
+
+	// TLSCertFile、TLSKeyFile HTTPS证书和私钥文件路径，配置后直接以HTTPS提供服务
+	TLSCertFile string
+	TLSKeyFile  string
+	// AutocertDomains 通过 Let's Encrypt 自动申请证书的域名，配置后忽略证书文件
+	AutocertDomains []string
+	// AutocertCacheDir 自动申请的证书缓存目录
+	AutocertCacheDir string
+	// AutocertEmail 证书到期等通知的联系邮箱（可选）
+	AutocertEmail string
+
+	// AdminAddr 管理端口监听地址（如 127.0.0.1:9087），配置后管理接口和 pprof 只在该地址提供，不在公开端口注册
+	AdminAddr string
+	// AdminAPIKey 管理接口API Key（X-API-Key 或 Authorization: Bearer）
+	AdminAPIKey string
+	// AdminUsername、AdminPassword 管理接口 Basic Auth 账号，与 API Key 任一配置即开放 /api/admin
+	AdminUsername string
+	AdminPassword string
+	// AnswerSecret 答案披露密钥，配置后开放 /api/internal/captcha/:id/answer 供可信后端获取缺口位置
+	// 配置了 AdminAddr 时该接口只在管理端口注册
+	AnswerSecret string
+	// IDSecret 签名验证码ID的密钥，配置后伪造或过期的ID在验证时不访问存储直接拒绝，多实例必须相同
+	IDSecret string
+	// IDKeys 带ID的签名密钥（<kid>:<密钥>），第一把用于签发，全部用于校验，配置后忽略 IDSecret；轮换时把新密钥放在最前，
+	// 旧密钥保留到它签发的验证码过期后再移除
+	IDKeys []string
+	// IDKeyRotation 自动轮换签名密钥的周期，每个周期使用从配置的密钥派生的新密钥，0表示不轮换
+	IDKeyRotation time.Duration
+	// DebugAnswers 调试模式：生成接口返回缺口位置和标出答案的预览图（debug 字段），只用于前端联调和端到端测试，绝不能在生产环境开启
+	DebugAnswers bool
+
+	// StatsdAddr StatsD 的 UDP 地址，配置后上报生成、验证等监控指标
+	StatsdAddr string
+	// StatsdPrefix 指标名称前缀
+	StatsdPrefix string
+
+	// LogLevel 日志级别：debug、info、warn、error
+	LogLevel string
+	// LogFormat 日志格式：text 或 json
+	LogFormat string
+	// AccessLog JSON访问日志输出目标：stdout 或 file:/path，配置后替代 gin 默认的文本访问日志
+	AccessLog string
+	// AccessLogMaxSize 访问日志文件轮转大小（MB）
+	AccessLogMaxSize int64
+	// AccessLogMaxBackups 访问日志保留的轮转文件数
+	AccessLogMaxBackups int
+	// GinMode gin 运行模式：debug、release 或 test，为空时沿用 GIN_MODE 环境变量（默认 debug）
+	GinMode string
+
+	// ShutdownTimeout 优雅停机时等待进行中请求完成的最长时间
+	ShutdownTimeout time.Duration
+	// ReadHeaderTimeout 读取请求头的超时时间，防止慢速连接（slow-loris）耗尽连接数
+	ReadHeaderTimeout time.Duration
+	// BodyReadTimeout 带请求体的接口（验证、换一张）读取请求体的超时时间，超时返回 408
+	BodyReadTimeout time.Duration
+	// MaxBodySize 带请求体的接口允许的最大请求体（字节），轨迹数据可能较大，超出返回 413
+	MaxBodySize int64
+
+	// RedisURL Redis地址（redis://[:password@]host:port[/db]），配置后验证码、封禁、限流等状态在多实例间共享
+	RedisURL string
+}
+
+// basePath 返回规范化的路径前缀：以 / 开头、不以 / 结尾，根路径返回空字符串
+func (c *Config) basePath() string {
+	path := strings.Trim(c.BasePath, "/")
+	if path == "" {
+		return ""
+	}
+	return "/" + path
+}
+
+// DefaultConfig 返回默认配置
+func DefaultConfig() *Config {
+	return &Config{
+		Port:               8087,
+		UnixSocketMode:     0660,
+		CaptchaTTL:         captcha.DefaultTTL,
+		Tolerance:          captcha.DefaultTolerance,
+		PregenerateWorkers: 1,
+		AsyncWorkers:       2,
+		AsyncQueueSize:     64,
+		AsyncInit:          true,
+		BackgroundScale:    captcha.DefaultBackgroundScale,
+		BurstWindow:        captcha.DefaultBurstWindow,
+		BurstMaxReuse:      captcha.DefaultBurstMaxReuse,
+		RenderCacheBucket:  captcha.DefaultRenderCacheBucket,
+		ImageCacheMaxBytes: captcha.DefaultDiskCacheMaxBytes,
+		DownloadTimeout:    captcha.DefaultDownloadTimeout,
+		ImageFormats:       captcha.DefaultImagePolicy().Formats,
+		MaxImageBytes:      captcha.DefaultMaxImageBytes,
+		MaxImagePixels:     captcha.DefaultMaxImagePixels,
+		MaxImageDimension:  captcha.DefaultMaxImageDimension,
+		DownloadRetries:    captcha.DefaultDownloadRetries,
+
+		BindClientIP:      false,
+		TrustedProxies:    nil,
+		SessionCookieName: "captcha_session",
+
+		GenerateRateLimit: 0,
+		PoWDifficulty:     16,
+
+		LockoutMaxFailures: 0,
+		LockoutWindow:      10 * time.Minute,
+		LockoutDuration:    30 * time.Minute,
+
+		AutocertCacheDir: "autocert-cache",
+
+		LogLevel:  "info",
+		LogFormat: "text",
+
+		AccessLogMaxSize:    100,
+		AccessLogMaxBackups: 5,
+
+		AnalyticsHours: 24,
+
+		ShutdownTimeout:   10 * time.Second,
+		ReadHeaderTimeout: 5 * time.Second,
+		BodyReadTimeout:   10 * time.Second,
+		MaxBodySize:       64 << 10,
+	}
+}
+
+var (
+	// config 当前生效的服务配置
+	config = DefaultConfig()
+	// captchaSvc 生成和验证使用的验证码服务
+	captchaSvc *captcha.CaptchaService
+	// webFS 演示页面及静态资源
+	webFS fs.FS = web.FS
+	// assetsFS 资源文件系统（未配置时为nil，从磁盘读取），assetsCloser 关闭 zip 资源包
+	assetsFS     fs.FS
+	assetsCloser io.Closer
+	// auditSink 验证审计记录输出目标（未启用时为nil）
+	auditSink audit.Sink
+	// redisClient 共享状态使用的Redis客户端（未配置时为nil）
+	redisClient *redis.Client
+	// accessLogWriter、accessLogCloser JSON访问日志输出（未配置时为nil）
+	accessLogWriter io.Writer
+	accessLogCloser io.Closer
+	// statsdClient 监控指标输出（未配置时为nil）
+	statsdClient *statsd.Client
+)
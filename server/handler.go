@@ -1,97 +1,574 @@
 package server
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"mime/multipart"
 	"net/http"
-	"strconv"
+	"net/textproto"
+	"time"
 
+	"github.com/gpencil/photo_captcha/audit"
 	"github.com/gpencil/photo_captcha/captcha"
+	"github.com/gpencil/photo_captcha/httpapi"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
 )
 
-// GenerateCaptchaHandler 生成验证码处理器
+// GenerateCaptchaHandler 生成验证码处理器，POST ?async=1 时提交异步生成任务
 func GenerateCaptchaHandler(c *gin.Context) {
-	sliderCaptcha, err := captcha.Generate()
+	if !checkLockout(c, c.Query("fingerprint")) {
+		return
+	}
+	if !checkGenerateRate(c) {
+		return
+	}
+	if !checkDailyQuota(c, c.Query("fingerprint")) {
+		return
+	}
+	if asyncRequested(c) {
+		generateAsync(c, c.Query("fingerprint"))
+		return
+	}
+
+	sliderCaptcha, err := generateCaptcha(c, c.Query("fingerprint"))
+	respondCaptcha(c, sliderCaptcha, err)
+}
+
+// GenerateCaptchaBinaryHandler 生成验证码，成功时以 multipart/mixed 返回：第一部分为JSON响应（data 中不含图片），
+// 随后是 background、slider 两张原始PNG，比 JSON+base64 小约25%，移动端也无需解析大段字符串；
+// 失败和工作量证明挑战与 /generate 相同，返回JSON
+func GenerateCaptchaBinaryHandler(c *gin.Context) {
+	if !checkLockout(c, c.Query("fingerprint")) {
+		return
+	}
+	if !checkGenerateRate(c) {
+		return
+	}
+	if !checkDailyQuota(c, c.Query("fingerprint")) {
+		return
+	}
+
+	sliderCaptcha, err := generateCaptcha(c, c.Query("fingerprint"))
+	if err != nil {
+		respondCaptcha(c, nil, err)
+		return
+	}
+	respondCaptchaMultipart(c, sliderCaptcha)
+}
+
+// RefreshCaptchaRequest 换一张请求结构
+type RefreshCaptchaRequest struct {
+	ID          string `json:"id" binding:"required"` // 要作废的验证码ID
+	Fingerprint string `json:"fingerprint"`           // 客户端指纹（可选），绑定到新验证码
+}
+
+// RefreshCaptchaHandler 换一张处理器：作废旧验证码并返回新验证码，避免旧验证码残留在存储中
+func RefreshCaptchaHandler(c *gin.Context) {
+	var req RefreshCaptchaRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		if bodyError(c, err) {
+			return
+		}
+		respond(c, http.StatusBadRequest, gin.H{
+			"code":      400,
+			"message":   msg(c, MsgInvalidRequest, err),
+			"requestId": requestID(c),
+		})
+		return
+	}
+
+	if !checkLockout(c, req.Fingerprint) {
+		return
+	}
+	if !checkGenerateRate(c) {
+		return
+	}
+	if !checkDailyQuota(c, req.Fingerprint) {
+		return
+	}
+
+	sliderCaptcha, err := refreshCaptcha(c, req)
+	respondCaptcha(c, sliderCaptcha, err)
+}
+
+// respondCaptcha 输出生成的验证码
+func respondCaptcha(c *gin.Context, sliderCaptcha *captcha.SliderCaptcha, err error) {
+	if errors.Is(err, errNotReady) {
+		respondNotReady(c)
+		return
+	}
+	if errors.Is(err, captcha.ErrUnavailable) {
+		respondUnavailable(c, err)
+		return
+	}
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"code":    500,
-			"message": "Failed to generate captcha: " + err.Error(),
+		respond(c, http.StatusInternalServerError, gin.H{
+			"code":      500,
+			"message":   msg(c, MsgGenerateFailed, err),
+			"requestId": requestID(c),
 		})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
+	respond(c, http.StatusOK, gin.H{
 		"code":    200,
-		"message": "success",
-		"data": gin.H{
-			"id":         sliderCaptcha.ID,
-			"background": sliderCaptcha.Background,
-			"slider":     sliderCaptcha.Slider,
-			"positionY":  sliderCaptcha.PositionY,
-		},
+		"message": msg(c, MsgSuccess),
+		"data":    captchaData(c.Request.Context(), sliderCaptcha),
 	})
 }
 
-// VerifyCaptchaRequest 验证请求结构
-type VerifyCaptchaRequest struct {
-	ID string `json:"id" binding:"required"`
-	X  string `json:"x" binding:"required"`
+// respondCaptchaMultipart 以 multipart/mixed 输出生成的验证码：JSON响应和两张原始图片
+func respondCaptchaMultipart(c *gin.Context, sliderCaptcha *captcha.SliderCaptcha) {
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	part, err := mw.CreatePart(textproto.MIMEHeader{
+		"Content-Type":        {"application/json"},
+		"Content-Disposition": {`inline; name="response"`},
+	})
+	if err == nil {
+		err = json.NewEncoder(part).Encode(versioned(c, gin.H{
+			"code":    200,
+			"message": msg(c, MsgSuccess),
+			"data":    httpapi.CaptchaMetadata(captchaData(c.Request.Context(), sliderCaptcha)),
+		}))
+	}
+	if err == nil {
+		err = httpapi.WriteCaptchaImages(mw, sliderCaptcha)
+	}
+	if err == nil {
+		err = mw.Close()
+	}
+	if err != nil {
+		respond(c, http.StatusInternalServerError, gin.H{
+			"code":      500,
+			"message":   msg(c, MsgGenerateFailed, err),
+			"requestId": requestID(c),
+		})
+		return
+	}
+	c.Data(http.StatusOK, "multipart/mixed; boundary="+mw.Boundary(), buf.Bytes())
 }
 
+// captchaData 验证码响应数据，开启调试模式时附带答案和预览图（debug 字段）
+func captchaData(ctx context.Context, sliderCaptcha *captcha.SliderCaptcha) map[string]interface{} {
+	data := httpapi.CaptchaData(sliderCaptcha)
+	if !config.DebugAnswers {
+		return data
+	}
+
+	answer, err := captchaSvc.DebugAnswer(ctx, sliderCaptcha)
+	if err != nil {
+		slog.Warn("failed to build debug answer", "id", sliderCaptcha.ID, "error", err)
+		return data
+	}
+	data["debug"] = answer
+	return data
+}
+
+// VerifyCaptchaRequest 验证请求结构
+type VerifyCaptchaRequest = httpapi.VerifyRequest
+
+// VerifyBatchRequest 批量验证请求结构
+type VerifyBatchRequest = httpapi.VerifyBatchRequest
+
+// Coordinate 坐标值，兼容JSON字符串（"150"）、整数（150）和浮点数（150.6）
+type Coordinate = httpapi.Coordinate
+
 // VerifyCaptchaHandler 验证滑块位置处理器
 func VerifyCaptchaHandler(c *gin.Context) {
+	start := time.Now()
+
 	var req VerifyCaptchaRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"code":    400,
-			"message": "Invalid request: " + err.Error(),
+	if err := c.ShouldBindBodyWith(&req, binding.JSON); err != nil {
+		if bodyError(c, err) {
+			return
+		}
+		respond(c, http.StatusBadRequest, gin.H{
+			"code":      400,
+			"message":   msg(c, MsgInvalidRequest, err),
+			"requestId": requestID(c),
 		})
 		return
 	}
 
-	// 将X坐标字符串转换为整数
-	userX, err := strconv.Atoi(req.X)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"code":    400,
-			"message": "Invalid x coordinate",
-		})
+	if !checkLockout(c, req.Fingerprint) {
 		return
 	}
 
-	// 验证
-	success, err := captcha.VerifyWithTolerance(req.ID, userX)
+	result, err := verifyCaptcha(c, &req, honeypotFilled(c), start)
+	if errors.Is(err, captcha.ErrUnavailable) {
+		respondUnavailable(c, err)
+		return
+	}
 	if err != nil {
-		c.JSON(http.StatusOK, gin.H{
-			"code":    400,
-			"message": err.Error(),
-			"data": gin.H{
-				"success": false,
-			},
+		respond(c, http.StatusOK, gin.H{
+			"code":      400,
+			"message":   reasonMsg(c, string(result.Reason)),
+			"requestId": requestID(c),
+			"data":      httpapi.VerifyResultData(result),
 		})
 		return
 	}
 
-	if success {
-		c.JSON(http.StatusOK, gin.H{
+	if result.Success {
+		respond(c, http.StatusOK, gin.H{
 			"code":    200,
-			"message": "Verification successful",
-			"data": gin.H{
-				"success": true,
-			},
+			"message": msg(c, MsgVerifySuccess),
+			"data":    httpapi.VerifyResultData(result),
 		})
 	} else {
-		c.JSON(http.StatusOK, gin.H{
+		respond(c, http.StatusOK, gin.H{
 			"code":    200,
-			"message": "Verification failed",
-			"data": gin.H{
-				"success": false,
+			"message": msg(c, MsgVerifyFailed),
+			"data":    httpapi.VerifyResultData(result),
+		})
+	}
+}
+
+// VerifyCaptchaBatchHandler 批量验证处理器：供缓冲了多个验证请求的网关一次提交，data.results 与 items 一一对应，
+// 每项的 code 与单独验证时相同（200 已判定、400 验证码不可用、403 指纹被封禁、503 存储不可用）；
+// 网关IP被封禁时整批拒绝，每项再按自己的指纹检查封禁，被封禁的项不做验证，失败计数按每项的指纹记录
+func VerifyCaptchaBatchHandler(c *gin.Context) {
+	start := time.Now()
+
+	var req VerifyBatchRequest
+	err := c.ShouldBindBodyWith(&req, binding.JSON)
+	if err == nil {
+		err = req.Validate()
+	}
+	if err != nil {
+		if bodyError(c, err) {
+			return
+		}
+		respond(c, http.StatusBadRequest, gin.H{
+			"code":      400,
+			"message":   msg(c, MsgInvalidRequest, err),
+			"requestId": requestID(c),
+		})
+		return
+	}
+
+	if !checkLockout(c, "") {
+		return
+	}
+
+	// 被封禁的指纹对应的项不做验证
+	items := make([]gin.H, len(req.Items))
+	type ban struct {
+		banned     bool
+		retryAfter int
+	}
+	bans := make(map[string]ban)
+	params := make([]captcha.VerifyParams, 0, len(req.Items))
+	indexes := make([]int, 0, len(req.Items))
+	for i := range req.Items {
+		fingerprint := req.Items[i].Fingerprint
+		b, checked := bans[fingerprint]
+		if !checked {
+			b.banned, b.retryAfter = sourceLockedOut(c, "", fingerprint)
+			bans[fingerprint] = b
+		}
+		if b.banned {
+			items[i] = gin.H{
+				"id":      req.Items[i].ID,
+				"code":    403,
+				"message": msg(c, MsgBanned),
+				"data":    gin.H{"retryAfter": b.retryAfter},
+			}
+			continue
+		}
+		params = append(params, verifyParams(c, &req.Items[i], false))
+		indexes = append(indexes, i)
+	}
+	var results []captcha.BatchVerifyResult
+	if len(params) > 0 {
+		if results, err = captchaSvc.VerifyBatch(c.Request.Context(), params); err != nil {
+			respondUnavailable(c, err)
+			return
+		}
+	}
+
+	for j, r := range results {
+		i := indexes[j]
+		result, err := finishVerify(c, &req.Items[i], r.Result, r.Err, start)
+		item := gin.H{"id": req.Items[i].ID, "data": httpapi.VerifyResultData(result)}
+		switch {
+		case errors.Is(err, captcha.ErrUnavailable):
+			item["code"], item["message"] = 503, reasonMsg(c, string(captcha.ReasonUnavailable))
+		case err != nil:
+			item["code"], item["message"] = 400, reasonMsg(c, string(result.Reason))
+		case result.Success:
+			item["code"], item["message"] = 200, msg(c, MsgVerifySuccess)
+		default:
+			item["code"], item["message"] = 200, msg(c, MsgVerifyFailed)
+		}
+		items[i] = item
+	}
+
+	respond(c, http.StatusOK, gin.H{
+		"code":    200,
+		"message": msg(c, MsgSuccess),
+		"data":    gin.H{"results": items},
+	})
+}
+
+// generateCaptcha 按请求来源生成验证码
+func generateCaptcha(c *gin.Context, fingerprint string) (*captcha.SliderCaptcha, error) {
+	if !serviceReady() {
+		return nil, errNotReady
+	}
+	return captchaSvc.GenerateContext(c.Request.Context(), generateParams(c, fingerprint))
+}
+
+// refreshCaptcha 作废旧验证码并按请求来源生成新验证码，后台初始化完成前不作废旧验证码
+func refreshCaptcha(c *gin.Context, req RefreshCaptchaRequest) (*captcha.SliderCaptcha, error) {
+	if !serviceReady() {
+		return nil, errNotReady
+	}
+	return captchaSvc.RefreshContext(c.Request.Context(), req.ID, generateParams(c, req.Fingerprint))
+}
+
+// generateParams 按请求来源确定生成参数
+func generateParams(c *gin.Context, fingerprint string) captcha.GenerateParams {
+	return captcha.GenerateParams{
+		Fingerprint: fingerprint,
+		ClientIP:    boundClientIP(c),
+		Session:     boundSession(c, !c.IsWebsocket()),
+		Difficulty:  captcha.DefaultDifficulty.Level(c.ClientIP()),
+		RemoteIP:    c.ClientIP(),
+		RequestID:   requestID(c),
+	}
+}
+
+// verifyCaptcha 执行验证并完成失败封禁计数、审计、通过率统计和结果推送
+func verifyCaptcha(c *gin.Context, req *VerifyCaptchaRequest, honeypot bool, start time.Time) (*captcha.VerifyResult, error) {
+	result, err := captchaSvc.VerifyContext(c.Request.Context(), verifyParams(c, req, honeypot))
+	return finishVerify(c, req, result, err, start)
+}
+
+// verifyParams 按请求来源确定验证参数（结合拖动轨迹评估风险），亚像素坐标四舍五入为整数
+func verifyParams(c *gin.Context, req *VerifyCaptchaRequest, honeypot bool) captcha.VerifyParams {
+	return captcha.VerifyParams{
+		ID:          req.ID,
+		X:           req.X.Int(),
+		Tolerance:   int(tolerance.Load()),
+		Trajectory:  req.Trajectory,
+		Fingerprint: req.Fingerprint,
+		ClientIP:    boundClientIP(c),
+		Session:     boundSession(c, false),
+		RemoteIP:    c.ClientIP(),
+
+		HoneypotFilled:  honeypot,
+		RequestID:       requestID(c),
+		ActionStartedAt: req.ActionStartTime(config.ActionSecret),
+	}
+}
+
+// finishVerify 完成一次验证的失败封禁计数、审计、通过率统计和结果推送
+func finishVerify(c *gin.Context, req *VerifyCaptchaRequest, result *captcha.VerifyResult, err error, start time.Time) (*captcha.VerifyResult, error) {
+	if !result.Success {
+		recordLockoutFailure(c, req.Fingerprint, result.Reason)
+	}
+	writeAudit(c, req.ID, result, time.Since(start))
+	verifyResults.Publish(req.ID, result)
+	if err != nil {
+		return result, err
+	}
+
+	// 记录通过率，用于自适应调整难度
+	captcha.DefaultDifficulty.Record(c.ClientIP(), result.Success)
+	return result, nil
+}
+
+// DifficultyStatusHandler 当前难度等级查询处理器
+func DifficultyStatusHandler(c *gin.Context) {
+	clientIP := c.ClientIP()
+	difficulty := captcha.DefaultDifficulty
+
+	respond(c, http.StatusOK, gin.H{
+		"code":    200,
+		"message": msg(c, MsgSuccess),
+		"data": gin.H{
+			"global": gin.H{
+				"level": difficulty.GlobalLevel().String(),
+				"stats": difficulty.GlobalStats(),
 			},
+			"client": gin.H{
+				"level": difficulty.Level(clientIP).String(),
+				"stats": difficulty.IPStats(clientIP),
+			},
+		},
+	})
+}
+
+// CaptchaStatusHandler 验证码状态查询处理器（不返回答案）
+func CaptchaStatusHandler(c *gin.Context) {
+	id := c.Param("id")
+	status, exists, err := captchaSvc.StatusContext(c.Request.Context(), id)
+	if err != nil {
+		respondUnavailable(c, err)
+		return
+	}
+	if !exists {
+		respond(c, http.StatusNotFound, gin.H{
+			"code":      404,
+			"message":   reasonMsg(c, string(captcha.ReasonNotFound)),
+			"requestId": requestID(c),
 		})
+		return
+	}
+
+	respond(c, http.StatusOK, gin.H{
+		"code":    200,
+		"message": msg(c, MsgSuccess),
+		"data": gin.H{
+			"id":     id,
+			"status": status,
+		},
+	})
+}
+
+// RevokeCaptchaHandler 主动作废验证码处理器
+func RevokeCaptchaHandler(c *gin.Context) {
+	exists, err := captchaSvc.RevokeContext(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		respondUnavailable(c, err)
+		return
+	}
+	if !exists {
+		respond(c, http.StatusNotFound, gin.H{
+			"code":      404,
+			"message":   reasonMsg(c, string(captcha.ReasonNotFound)),
+			"requestId": requestID(c),
+		})
+		return
+	}
+
+	respond(c, http.StatusOK, gin.H{
+		"code":    200,
+		"message": msg(c, MsgSuccess),
+	})
+}
+
+// respondUnavailable 验证码存储不可用或请求已取消时返回 503
+func respondUnavailable(c *gin.Context, err error) {
+	requestLogger(c).Error("captcha store unavailable", "error", err)
+	respond(c, http.StatusServiceUnavailable, gin.H{
+		"code":      503,
+		"message":   reasonMsg(c, string(captcha.ReasonUnavailable)),
+		"requestId": requestID(c),
+	})
+}
+
+// checkGenerateRate 检查IP和客户端特征簇的生成频率，任一超限且未提交有效的工作量证明时返回挑战并中止请求
+func checkGenerateRate(c *gin.Context) bool {
+	over := generateRateExceeded(c)
+	if over == 0 {
+		return true
+	}
+
+	// 超限后允许通过工作量证明继续生成
+	if challenge := c.Query("pow_challenge"); challenge != "" {
+		if err := powManager.Verify(challenge, c.Query("pow_nonce"), c.ClientIP()); err == nil {
+			return true
+		}
+	}
+
+	// 每超出限额一倍，难度增加1位
+	difficulty := config.PoWDifficulty + over - 1
+	respond(c, http.StatusOK, gin.H{
+		"code":      429,
+		"message":   msg(c, MsgPoWRequired),
+		"requestId": requestID(c),
+		"data": gin.H{
+			"pow": powManager.Issue(difficulty, c.ClientIP()),
+		},
+	})
+	return false
+}
+
+// generateRateExceeded 为来源IP和客户端特征簇各记录一次生成，返回超出限额的倍数（向下取整，未超限时为0），取两者中较大的
+func generateRateExceeded(c *gin.Context) int {
+	over := 0
+	if generateLimiter != nil {
+		if allowed, count := generateLimiter.Allow(c.ClientIP()); !allowed {
+			over = max(over, count/config.GenerateRateLimit)
+		}
+	}
+	if clusterLimiter != nil {
+		if allowed, count := clusterLimiter.Allow(clientCluster(c)); !allowed {
+			over = max(over, count/config.GenerateClusterRateLimit)
+		}
+	}
+	return over
+}
+
+// writeAudit 写入验证审计记录
+func writeAudit(c *gin.Context, id string, result *captcha.VerifyResult, latency time.Duration) {
+	if auditSink == nil {
+		return
+	}
+
+	record := &audit.Record{
+		Time:      time.Now(),
+		CaptchaID: id,
+		Success:   result.Success,
+		Reason:    string(result.Reason),
+		Diff:      result.Diff,
+		LatencyMs: float64(latency.Microseconds()) / 1000,
+		SolveMs:   result.SolveTime.Milliseconds(),
+		IPHash:    audit.HashIP(c.ClientIP(), config.AuditSalt),
+
+		RequestID:         requestID(c),
+		GenerateRequestID: result.GenerateRequestID,
+	}
+	if result.Risk != nil {
+		record.RiskScore = result.Risk.Score
+		record.RiskFlags = result.Risk.Flags
+	}
+
+	if err := auditSink.Write(record); err != nil {
+		requestLogger(c).Error("failed to write audit record", "error", err)
+	}
+}
+
+// boundClientIP 开启IP绑定时返回客户端IP，否则返回空字符串
+func boundClientIP(c *gin.Context) string {
+	if !config.BindClientIP {
+		return ""
+	}
+	return c.ClientIP()
+}
+
+// honeypotFilled 检查请求体中的蜜罐字段是否被填写
+func honeypotFilled(c *gin.Context) bool {
+	if config.HoneypotField == "" {
+		return false
+	}
+
+	var body map[string]interface{}
+	if err := c.ShouldBindBodyWith(&body, binding.JSON); err != nil {
+		return false
+	}
+
+	value, exists := body[config.HoneypotField]
+	if !exists || value == nil {
+		return false
+	}
+	if str, ok := value.(string); ok {
+		return str != ""
 	}
+	return true
 }
 
 // IndexHandler 首页处理器
 func IndexHandler(c *gin.Context) {
-	c.File("./web/index.html")
+	// 统一按目录根路径交给 FileServer 返回 index.html（直接请求 /index.html 会被重定向）
+	c.Request.URL.Path = "/"
+	http.FileServer(http.FS(webFS)).ServeHTTP(c.Writer, c.Request)
 }
@@ -0,0 +1,227 @@
+package server
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 消息键
+const (
+	MsgSuccess        = "success"
+	MsgGenerateFailed = "generate_failed"
+	MsgInvalidRequest = "invalid_request"
+	MsgVerifySuccess  = "verify_success"
+	MsgVerifyFailed   = "verify_failed"
+	MsgPoWRequired    = "pow_required"
+	MsgBanned         = "banned"
+	MsgUnauthorized   = "unauthorized"
+	MsgRateLimited    = "rate_limited"
+	MsgDailyQuota     = "daily_quota"
+	MsgBodyTooLarge   = "body_too_large"
+	MsgRequestTimeout = "request_timeout"
+	MsgJobNotFound    = "job_not_found"
+	MsgInitializing   = "initializing"
+	// MsgReasonPrefix 验证失败原因消息键前缀，完整键为 "reason.<失败原因>"
+	MsgReasonPrefix = "reason."
+)
+
+// DefaultLanguage 无法匹配请求语言时使用的语言
+const DefaultLanguage = "en-US"
+
+// LanguageQueryParam 指定语言的查询参数，优先级高于 Accept-Language
+const LanguageQueryParam = "lang"
+
+var (
+	catalogsMu sync.RWMutex
+	// catalogs 语言 -> 消息键 -> 消息模板（fmt格式）
+	catalogs = map[string]map[string]string{
+		"en-US": {
+			MsgSuccess:        "success",
+			MsgGenerateFailed: "Failed to generate captcha: %v",
+			MsgInvalidRequest: "Invalid request: %v",
+			MsgVerifySuccess:  "Verification successful",
+			MsgVerifyFailed:   "Verification failed",
+			MsgPoWRequired:    "Too many requests, proof of work required",
+			MsgBanned:         "Too many failed attempts, please try again later",
+			MsgUnauthorized:   "Unauthorized",
+			MsgRateLimited:    "Too many requests, please try again later",
+			MsgDailyQuota:     "Daily captcha limit reached, please try again tomorrow",
+			MsgBodyTooLarge:   "Request body too large",
+			MsgRequestTimeout: "Timed out reading request body",
+			MsgJobNotFound:    "Generate job not found or expired",
+			MsgInitializing:   "Captcha service is starting, please retry shortly",
+
+			MsgReasonPrefix + "not_found":            "captcha not found",
+			MsgReasonPrefix + "expired":              "captcha expired",
+			MsgReasonPrefix + "wrong_position":       "wrong position",
+			MsgReasonPrefix + "too_fast":             "dragged too fast",
+			MsgReasonPrefix + "too_many_attempts":    "too many verify attempts",
+			MsgReasonPrefix + "fingerprint_mismatch": "captcha fingerprint mismatch",
+			MsgReasonPrefix + "ip_mismatch":          "captcha client ip mismatch",
+			MsgReasonPrefix + "risk_rejected":        "suspicious behavior detected",
+			MsgReasonPrefix + "already_used":         "captcha already used",
+			MsgReasonPrefix + "session_mismatch":     "captcha session mismatch",
+			MsgReasonPrefix + "unavailable":          "captcha service temporarily unavailable",
+			MsgReasonPrefix + "unsupported_format":   "captcha created by a newer server version, please refresh",
+			MsgReasonPrefix + "stale_challenge":      "captcha issued too long before this action, please refresh",
+		},
+		"zh-CN": {
+			MsgSuccess:        "成功",
+			MsgGenerateFailed: "验证码生成失败: %v",
+			MsgInvalidRequest: "请求参数错误: %v",
+			MsgVerifySuccess:  "验证成功",
+			MsgVerifyFailed:   "验证失败",
+			MsgPoWRequired:    "请求过于频繁，请先完成计算验证",
+			MsgBanned:         "失败次数过多，请稍后再试",
+			MsgUnauthorized:   "未授权",
+			MsgRateLimited:    "请求过于频繁，请稍后再试",
+			MsgDailyQuota:     "今日验证码次数已达上限，请明天再试",
+			MsgBodyTooLarge:   "请求体过大",
+			MsgRequestTimeout: "读取请求体超时",
+			MsgJobNotFound:    "生成任务不存在或已过期",
+			MsgInitializing:   "验证码服务正在启动，请稍后重试",
+
+			MsgReasonPrefix + "not_found":            "验证码不存在",
+			MsgReasonPrefix + "expired":              "验证码已过期",
+			MsgReasonPrefix + "wrong_position":       "位置不正确",
+			MsgReasonPrefix + "too_fast":             "拖动过快",
+			MsgReasonPrefix + "too_many_attempts":    "尝试次数过多",
+			MsgReasonPrefix + "fingerprint_mismatch": "客户端指纹不一致",
+			MsgReasonPrefix + "ip_mismatch":          "客户端IP不一致",
+			MsgReasonPrefix + "risk_rejected":        "检测到异常操作",
+			MsgReasonPrefix + "already_used":         "验证码已使用",
+			MsgReasonPrefix + "session_mismatch":     "浏览器会话不一致",
+			MsgReasonPrefix + "unavailable":          "验证码服务暂不可用",
+			MsgReasonPrefix + "unsupported_format":   "验证码版本不兼容，请刷新",
+			MsgReasonPrefix + "stale_challenge":      "验证码生成时间过早，请刷新",
+		},
+	}
+)
+
+// RegisterLanguage 注册（或补充）一种语言的消息
+// 未提供的消息键会回退到 DefaultLanguage
+func RegisterLanguage(lang string, messages map[string]string) {
+	catalogsMu.Lock()
+	defer catalogsMu.Unlock()
+
+	catalog, exists := catalogs[lang]
+	if !exists {
+		catalog = make(map[string]string, len(messages))
+		catalogs[lang] = catalog
+	}
+	for key, msg := range messages {
+		catalog[key] = msg
+	}
+}
+
+// Translate 翻译消息，找不到时依次回退到默认语言和消息键本身
+func Translate(lang, key string, args ...interface{}) string {
+	catalogsMu.RLock()
+	msg, ok := catalogs[lang][key]
+	if !ok {
+		msg, ok = catalogs[DefaultLanguage][key]
+	}
+	catalogsMu.RUnlock()
+
+	if !ok {
+		msg = key
+	}
+	if len(args) > 0 {
+		return fmt.Sprintf(msg, args...)
+	}
+	return msg
+}
+
+// requestLanguage 确定请求使用的语言：查询参数 > Accept-Language > 默认语言
+func requestLanguage(c *gin.Context) string {
+	if lang := matchLanguage(c.Query(LanguageQueryParam)); lang != "" {
+		return lang
+	}
+	for _, tag := range parseAcceptLanguage(c.GetHeader("Accept-Language")) {
+		if lang := matchLanguage(tag); lang != "" {
+			return lang
+		}
+	}
+	return DefaultLanguage
+}
+
+// matchLanguage 将语言标签匹配到已注册的语言（先精确匹配，再按主语言匹配，如 zh-TW -> zh-CN）
+func matchLanguage(tag string) string {
+	tag = strings.TrimSpace(strings.ReplaceAll(tag, "_", "-"))
+	if tag == "" || tag == "*" {
+		return ""
+	}
+
+	catalogsMu.RLock()
+	defer catalogsMu.RUnlock()
+
+	base := strings.ToLower(strings.SplitN(tag, "-", 2)[0])
+	var baseMatch string
+	for lang := range catalogs {
+		if strings.EqualFold(lang, tag) {
+			return lang
+		}
+		if strings.ToLower(strings.SplitN(lang, "-", 2)[0]) == base {
+			// 多个候选时取字典序最小者，保证结果稳定
+			if baseMatch == "" || lang < baseMatch {
+				baseMatch = lang
+			}
+		}
+	}
+	return baseMatch
+}
+
+// parseAcceptLanguage 解析 Accept-Language 请求头，按权重从高到低返回语言标签
+func parseAcceptLanguage(header string) []string {
+	type weighted struct {
+		tag string
+		q   float64
+	}
+
+	var tags []weighted
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(strings.TrimSpace(part), ";")
+		tag := strings.TrimSpace(fields[0])
+		if tag == "" {
+			continue
+		}
+
+		q := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if v, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					q = v
+				}
+			}
+		}
+		if q > 0 {
+			tags = append(tags, weighted{tag: tag, q: q})
+		}
+	}
+
+	sort.SliceStable(tags, func(i, j int) bool {
+		return tags[i].q > tags[j].q
+	})
+
+	result := make([]string, len(tags))
+	for i, t := range tags {
+		result[i] = t.tag
+	}
+	return result
+}
+
+// msg 按请求语言翻译消息
+func msg(c *gin.Context, key string, args ...interface{}) string {
+	return Translate(requestLanguage(c), key, args...)
+}
+
+// reasonMsg 按请求语言翻译验证失败原因
+func reasonMsg(c *gin.Context, reason string) string {
+	return msg(c, MsgReasonPrefix+reason)
+}
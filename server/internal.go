@@ -0,0 +1,75 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"net/http/pprof"
+
+	"github.com/gpencil/photo_captcha/captcha"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AnswerSecretHeader 答案披露接口读取服务密钥的请求头
+const AnswerSecretHeader = "X-Captcha-Secret"
+
+// SetupAdminRouter 配置管理端口的路由：管理接口和 pprof
+// 需在 SetupRouterWithConfig 之后调用，且仅在配置了 AdminAddr 时使用，
+// 此时公开端口不再注册管理接口；管理接口和 pprof 同样需要认证，未配置 API Key 或 Basic Auth 账号时拒绝启动
+func SetupAdminRouter(cfg *Config) (*gin.Engine, error) {
+	if !cfg.adminEnabled() {
+		return nil, errors.New("admin addr requires admin api key or basic auth credentials")
+	}
+	router := newEngine()
+	if err := configureClientIP(router, cfg); err != nil {
+		return nil, err
+	}
+	router.Use(RequestIDMiddleware())
+
+	registerAdminRoutes(router.Group("/api/admin", AdminAuthMiddleware(cfg)))
+
+	// 答案披露接口只面向内网的可信后端，不在公开端口暴露
+	if cfg.AnswerSecret != "" {
+		router.GET("/api/internal/captcha/:id/answer", InternalAnswerHandler)
+	}
+
+	// 性能分析
+	debug := router.Group("/debug/pprof", AdminAuthMiddleware(cfg))
+	debug.GET("/", gin.WrapF(pprof.Index))
+	debug.GET("/cmdline", gin.WrapF(pprof.Cmdline))
+	debug.GET("/profile", gin.WrapF(pprof.Profile))
+	debug.GET("/symbol", gin.WrapF(pprof.Symbol))
+	debug.POST("/symbol", gin.WrapF(pprof.Symbol))
+	debug.GET("/trace", gin.WrapF(pprof.Trace))
+	debug.GET("/:name", gin.WrapF(pprof.Index))
+
+	return router, nil
+}
+
+// InternalAnswerHandler 答案披露处理器：持有服务密钥的可信后端获取缺口位置，自行实现验证逻辑
+func InternalAnswerHandler(c *gin.Context) {
+	answer, err := captchaSvc.RevealAnswer(c.Request.Context(), c.Param("id"), c.GetHeader(AnswerSecretHeader))
+	switch {
+	case errors.Is(err, captcha.ErrAnswerForbidden):
+		requestLogger(c).Warn("captcha answer requested with invalid secret", "id", c.Param("id"))
+		respond(c, http.StatusForbidden, gin.H{
+			"code":      403,
+			"message":   msg(c, MsgUnauthorized),
+			"requestId": requestID(c),
+		})
+	case errors.Is(err, captcha.ErrNotFound):
+		respond(c, http.StatusNotFound, gin.H{
+			"code":      404,
+			"message":   reasonMsg(c, string(captcha.ReasonNotFound)),
+			"requestId": requestID(c),
+		})
+	case err != nil:
+		respondUnavailable(c, err)
+	default:
+		respond(c, http.StatusOK, gin.H{
+			"code":    200,
+			"message": msg(c, MsgSuccess),
+			"data":    answer,
+		})
+	}
+}
@@ -0,0 +1,260 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gpencil/photo_captcha/captcha"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"golang.org/x/net/websocket"
+)
+
+// 异步生成任务状态
+const (
+	JobPending = "pending" // 排队或生成中
+	JobReady   = "ready"   // 已生成，data.captcha 为验证码
+	JobFailed  = "failed"  // 生成失败
+)
+
+// generateJobs 异步生成任务队列（未启用时为nil）
+var generateJobs *jobQueue
+
+// generateJob 一个异步生成任务
+type generateJob struct {
+	id        string
+	params    captcha.GenerateParams
+	createdAt time.Time
+	// done 生成结束后关闭，之后 captcha、err 只读
+	done    chan struct{}
+	captcha *captcha.SliderCaptcha
+	err     error
+}
+
+// status 返回任务当前状态
+func (j *generateJob) status() string {
+	select {
+	case <-j.done:
+	default:
+		return JobPending
+	}
+	if j.err != nil {
+		return JobFailed
+	}
+	return JobReady
+}
+
+// jobQueue 异步生成任务队列，由固定数量的后台协程依次生成
+// 任务只保存在当前实例的内存中，保留到验证码有效期结束
+type jobQueue struct {
+	svc   *captcha.CaptchaService
+	ttl   time.Duration
+	queue chan *generateJob
+
+	mu     sync.Mutex
+	jobs   map[string]*generateJob
+	closed bool
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// newJobQueue 创建任务队列并启动 workers 个后台协程，size 为最多排队的任务数
+func newJobQueue(svc *captcha.CaptchaService, workers, size int, ttl time.Duration) *jobQueue {
+	ctx, cancel := context.WithCancel(context.Background())
+	q := &jobQueue{
+		svc:    svc,
+		ttl:    ttl,
+		queue:  make(chan *generateJob, size),
+		jobs:   make(map[string]*generateJob),
+		ctx:    ctx,
+		cancel: cancel,
+	}
+	for i := 0; i < workers; i++ {
+		q.wg.Add(1)
+		go q.run()
+	}
+	return q
+}
+
+// submit 提交生成任务，队列已满时返回 false
+func (q *jobQueue) submit(params captcha.GenerateParams) (*generateJob, bool) {
+	job := &generateJob{
+		id:        uuid.New().String(),
+		params:    params,
+		createdAt: time.Now(),
+		done:      make(chan struct{}),
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return nil, false
+	}
+	q.cleanLocked(job.createdAt)
+
+	select {
+	case q.queue <- job:
+	default:
+		return nil, false
+	}
+	q.jobs[job.id] = job
+	return job, true
+}
+
+// get 查询任务
+func (q *jobQueue) get(id string) (*generateJob, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	job, ok := q.jobs[id]
+	return job, ok
+}
+
+// run 后台协程：依次执行排队的任务，队列关闭后退出
+func (q *jobQueue) run() {
+	defer q.wg.Done()
+	for job := range q.queue {
+		job.captcha, job.err = q.svc.GenerateContext(q.ctx, job.params)
+		close(job.done)
+	}
+}
+
+// cleanLocked 删除超过有效期的任务，调用方需持有锁
+func (q *jobQueue) cleanLocked(now time.Time) {
+	for id, job := range q.jobs {
+		if now.Sub(job.createdAt) > q.ttl {
+			delete(q.jobs, id)
+		}
+	}
+}
+
+// close 取消进行中的生成并等待后台协程退出，排队的任务以失败结束
+func (q *jobQueue) close() {
+	q.cancel()
+	q.mu.Lock()
+	q.closed = true
+	close(q.queue)
+	q.mu.Unlock()
+	q.wg.Wait()
+}
+
+// asyncRequested 判断是否请求异步生成（POST 且 async 为真）
+func asyncRequested(c *gin.Context) bool {
+	if c.Request.Method != http.MethodPost {
+		return false
+	}
+	async, _ := strconv.ParseBool(c.Query("async"))
+	return async
+}
+
+// generateAsync 提交异步生成任务并立即返回任务ID，未启用或队列已满时同步生成；
+// 后台初始化完成前与同步生成一样返回 503，不提交注定要等待初始化的任务
+func generateAsync(c *gin.Context, fingerprint string) {
+	if !serviceReady() {
+		respondNotReady(c)
+		return
+	}
+	if generateJobs == nil {
+		sliderCaptcha, err := generateCaptcha(c, fingerprint)
+		respondCaptcha(c, sliderCaptcha, err)
+		return
+	}
+
+	job, ok := generateJobs.submit(generateParams(c, fingerprint))
+	if !ok {
+		requestLogger(c).Warn("async generate queue full, generating synchronously")
+		sliderCaptcha, err := generateCaptcha(c, fingerprint)
+		respondCaptcha(c, sliderCaptcha, err)
+		return
+	}
+
+	respond(c, http.StatusAccepted, gin.H{
+		"code":    202,
+		"message": msg(c, MsgSuccess),
+		"data":    jobData(c.Request.Context(), job),
+	})
+}
+
+// jobData 任务查询的响应数据
+func jobData(ctx context.Context, job *generateJob) gin.H {
+	data := gin.H{
+		"jobId":  job.id,
+		"status": job.status(),
+	}
+	if data["status"] == JobReady {
+		data["captcha"] = captchaData(ctx, job.captcha)
+	}
+	return data
+}
+
+// lookupJob 查询任务，不存在时返回 404
+func lookupJob(c *gin.Context) (*generateJob, bool) {
+	var job *generateJob
+	var ok bool
+	if generateJobs != nil {
+		job, ok = generateJobs.get(c.Param("id"))
+	}
+	if !ok {
+		respond(c, http.StatusNotFound, gin.H{
+			"code":      404,
+			"message":   msg(c, MsgJobNotFound),
+			"requestId": requestID(c),
+		})
+	}
+	return job, ok
+}
+
+// GenerateJobHandler 异步生成任务查询处理器，生成完成后 data.captcha 为验证码
+func GenerateJobHandler(c *gin.Context) {
+	job, ok := lookupJob(c)
+	if !ok {
+		return
+	}
+
+	if job.status() == JobFailed {
+		respondCaptcha(c, nil, job.err)
+		return
+	}
+	respond(c, http.StatusOK, gin.H{
+		"code":    200,
+		"message": msg(c, MsgSuccess),
+		"data":    jobData(c.Request.Context(), job),
+	})
+}
+
+// GenerateJobWebSocketHandler 异步生成任务 WebSocket 处理器：生成完成后推送验证码并关闭连接
+func GenerateJobWebSocketHandler(c *gin.Context) {
+	job, ok := lookupJob(c)
+	if !ok {
+		return
+	}
+
+	server := websocket.Server{
+		Handler: func(ws *websocket.Conn) {
+			session := &wsSession{c: c, ws: ws}
+			timeout := time.NewTimer(captchaTTL())
+			defer timeout.Stop()
+
+			select {
+			case <-job.done:
+			case <-timeout.C:
+				session.sendError(http.StatusGatewayTimeout, msg(c, MsgGenerateFailed, "timed out"))
+				return
+			}
+			if job.err != nil {
+				session.sendError(http.StatusInternalServerError, msg(c, MsgGenerateFailed, job.err))
+				return
+			}
+			session.send(gin.H{
+				"type": "challenge",
+				"data": captchaData(c.Request.Context(), job.captcha),
+			})
+		},
+	}
+	server.ServeHTTP(c.Writer, c.Request)
+}
@@ -0,0 +1,70 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MaxBodySizeMiddleware 限制请求体大小，Content-Length 超限时直接返回 413，
+// 未声明长度的请求在读取超过限制时由 bodyError 返回 413
+func MaxBodySizeMiddleware(maxBytes int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if maxBytes <= 0 {
+			c.Next()
+			return
+		}
+		if c.Request.ContentLength > maxBytes {
+			respondBodyTooLarge(c)
+			c.Abort()
+			return
+		}
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxBytes)
+		c.Next()
+	}
+}
+
+// ReadTimeoutMiddleware 设置读取请求体的截止时间，防止慢速发送请求体长期占用连接
+// 超时后读取请求体失败，由 bodyError 返回 408
+func ReadTimeoutMiddleware(timeout time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if timeout > 0 {
+			// 底层连接不支持设置截止时间（如测试用的 ResponseRecorder）时忽略
+			_ = http.NewResponseController(c.Writer).SetReadDeadline(time.Now().Add(timeout))
+		}
+		c.Next()
+	}
+}
+
+// bodyError 读取请求体时超限或超时则返回 413/408 并返回 true，其他错误由调用方处理
+func bodyError(c *gin.Context, err error) bool {
+	var maxBytesErr *http.MaxBytesError
+	switch {
+	case errors.As(err, &maxBytesErr):
+		respondBodyTooLarge(c)
+		return true
+	case errors.Is(err, os.ErrDeadlineExceeded):
+		// 读取已超时，关闭连接以免继续等待剩余请求体
+		c.Header("Connection", "close")
+		respond(c, http.StatusRequestTimeout, gin.H{
+			"code":      408,
+			"message":   msg(c, MsgRequestTimeout),
+			"requestId": requestID(c),
+		})
+		return true
+	}
+	return false
+}
+
+// respondBodyTooLarge 返回 413
+func respondBodyTooLarge(c *gin.Context) {
+	c.Header("Connection", "close")
+	respond(c, http.StatusRequestEntityTooLarge, gin.H{
+		"code":      413,
+		"message":   msg(c, MsgBodyTooLarge),
+		"requestId": requestID(c),
+	})
+}
@@ -0,0 +1,76 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+)
+
+// ListenAddr 返回TCP监听地址：优先使用 Addr，否则监听所有地址的 Port 端口
+func (c *Config) ListenAddr() string {
+	if c.Addr != "" {
+		return c.Addr
+	}
+	return ":" + strconv.Itoa(c.Port)
+}
+
+// Listen 按配置创建监听：配置了 UnixSocket 时监听 Unix 套接字，否则监听 TCP 地址
+func Listen(cfg *Config) (net.Listener, error) {
+	if cfg.UnixSocket == "" {
+		addr := cfg.ListenAddr()
+		ln, err := net.Listen("tcp", addr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to listen on %s: %w", addr, err)
+		}
+		return ln, nil
+	}
+
+	// 清理上次异常退出残留的套接字文件
+	if info, err := os.Lstat(cfg.UnixSocket); err == nil {
+		if info.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("unix socket path %s exists and is not a socket", cfg.UnixSocket)
+		}
+		if err := os.Remove(cfg.UnixSocket); err != nil {
+			return nil, fmt.Errorf("failed to remove stale unix socket: %w", err)
+		}
+	}
+
+	ln, err := net.Listen("unix", cfg.UnixSocket)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on unix socket %s: %w", cfg.UnixSocket, err)
+	}
+	if err := os.Chmod(cfg.UnixSocket, cfg.UnixSocketMode); err != nil {
+		ln.Close()
+		return nil, fmt.Errorf("failed to chmod unix socket: %w", err)
+	}
+	return unixListener{ln}, nil
+}
+
+// localAddr Unix 套接字连接对外报告的远端地址
+// Unix 套接字没有IP，报告为本机回环地址后，可通过 TrustedProxies 信任本机代理转发的 X-Forwarded-For
+var localAddr = &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1)}
+
+// unixListener 将连接的远端地址报告为本机回环地址
+type unixListener struct {
+	net.Listener
+}
+
+// Accept 接受连接
+func (l unixListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return unixConn{conn}, nil
+}
+
+// unixConn 远端地址为本机回环地址的连接
+type unixConn struct {
+	net.Conn
+}
+
+// RemoteAddr 返回本机回环地址
+func (c unixConn) RemoteAddr() net.Addr {
+	return localAddr
+}
@@ -0,0 +1,358 @@
+package server
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"image/png"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gpencil/photo_captcha/captcha"
+)
+
+// ConfigFileEnv 指定配置文件路径的环境变量（也可使用 -config 参数）
+const ConfigFileEnv = "CAPTCHA_CONFIG"
+
+// setting 一项可通过配置文件、环境变量和命令行参数设置的配置
+type setting struct {
+	env   string // 环境变量名，也是配置文件中的键名
+	flag  string // 命令行参数名
+	usage string
+	apply func(cfg *Config, value string) error
+}
+
+// settings 所有可外部配置的项
+var settings = []setting{
+	{"PORT", "port", "监听端口", intSetting(func(c *Config) *int { return &c.Port })},
+	{"CAPTCHA_ADDR", "addr", "监听地址（如 127.0.0.1:8087），配置后忽略端口", stringSetting(func(c *Config) *string { return &c.Addr })},
+	{"BASE_PATH", "base-path", "所有路由的路径前缀（如 /captcha-svc）", stringSetting(func(c *Config) *string { return &c.BasePath })},
+	{"UNIX_SOCKET", "unix-socket", "Unix 套接字路径（配置后不监听TCP端口）", stringSetting(func(c *Config) *string { return &c.UnixSocket })},
+	{"UNIX_SOCKET_MODE", "unix-socket-mode", "Unix 套接字文件权限（八进制，如 0660）", fileModeSetting(func(c *Config) *os.FileMode { return &c.UnixSocketMode })},
+	{"CAPTCHA_TTL", "ttl", "验证码有效期（如 5m）", durationSetting(func(c *Config) *time.Duration { return &c.CaptchaTTL })},
+	{"CAPTCHA_TOLERANCE", "tolerance", "验证允许的误差（像素）", intSetting(func(c *Config) *int { return &c.Tolerance })},
+	{"CAPTCHA_PLACEMENT", "placement", "缺口位置随机范围：center（图片中心附近）或 full（整个画布）", placementSetting},
+	{"CAPTCHA_PLACEMENT_MARGIN", "placement-margin", "full 模式下缺口与画布边缘的最小距离（像素）", intSetting(func(c *Config) *int { return &c.PlacementMargin })},
+	{"CAPTCHA_PREGENERATE", "pregenerate", "每种难度预生成的验证码数量，0表示不启用", intSetting(func(c *Config) *int { return &c.Pregenerate })},
+	{"CAPTCHA_PREGENERATE_WORKERS", "pregenerate-workers", "预生成验证码的后台协程数量", intSetting(func(c *Config) *int { return &c.PregenerateWorkers })},
+	{"CAPTCHA_ASYNC_WORKERS", "async-workers", "处理异步生成任务的后台协程数量，0表示不启用", intSetting(func(c *Config) *int { return &c.AsyncWorkers })},
+	{"CAPTCHA_ASYNC_QUEUE_SIZE", "async-queue-size", "最多排队的异步生成任务数", intSetting(func(c *Config) *int { return &c.AsyncQueueSize })},
+	{"CAPTCHA_BURST_THRESHOLD", "burst-threshold", "每秒生成数量超过该值时复用已渲染的图片，0表示不启用", intSetting(func(c *Config) *int { return &c.BurstThreshold })},
+	{"CAPTCHA_BURST_WINDOW", "burst-window", "同一图片可被复用的时间窗口", durationSetting(func(c *Config) *time.Duration { return &c.BurstWindow })},
+	{"CAPTCHA_BURST_MAX_REUSE", "burst-max-reuse", "同一图片最多被复用的次数", intSetting(func(c *Config) *int { return &c.BurstMaxReuse })},
+	{"CAPTCHA_RENDER_CACHE_SIZE", "render-cache-size", "缓存的渲染结果数量，0表示不启用", intSetting(func(c *Config) *int { return &c.RenderCacheSize })},
+	{"CAPTCHA_RENDER_CACHE_BUCKET", "render-cache-bucket", "启用渲染缓存时随机缺口位置取整的步长（像素）", intSetting(func(c *Config) *int { return &c.RenderCacheBucket })},
+	{"CAPTCHA_FAST_BLUR", "fast-blur", "用盒式模糊近似高斯模糊，适合CPU受限的环境", boolSetting(func(c *Config) *bool { return &c.FastBlur })},
+	{"CAPTCHA_PNG_COMPRESSION", "png-compression", "验证码图片的PNG压缩级别：default、speed、best 或 none", pngCompressionSetting},
+	{"CAPTCHA_ASYNC_INIT", "async-init", "启动时在后台加载背景图，加载完成前生成接口返回503", boolSetting(func(c *Config) *bool { return &c.AsyncInit })},
+	{"CAPTCHA_LAZY_BACKGROUNDS", "lazy-backgrounds", "背景图第一次被选中时才下载，缩短启动时间", boolSetting(func(c *Config) *bool { return &c.LazyBackgrounds })},
+	{"CAPTCHA_RANDOM_HOLE_STYLE", "random-hole-style", "每个验证码随机选择缺口外观", boolSetting(func(c *Config) *bool { return &c.RandomHoleStyle })},
+	{"CAPTCHA_IMAGE_FORMATS", "image-formats", "允许解码的图片格式（jpeg、png、gif），逗号分隔", listSetting(func(c *Config) *[]string { return &c.ImageFormats })},
+	{"CAPTCHA_MAX_IMAGE_BYTES", "max-image-bytes", "背景图和拼图mask文件的最大字节数", int64Setting(func(c *Config) *int64 { return &c.MaxImageBytes })},
+	{"CAPTCHA_MAX_IMAGE_PIXELS", "max-image-pixels", "背景图和拼图mask的最大像素数（宽x高），解码前检查", intSetting(func(c *Config) *int { return &c.MaxImagePixels })},
+	{"CAPTCHA_MAX_IMAGE_DIMENSION", "max-image-dimension", "背景图和拼图mask的最大宽度和高度（像素），解码前检查", intSetting(func(c *Config) *int { return &c.MaxImageDimension })},
+	{"CAPTCHA_DOWNLOAD_TIMEOUT", "download-timeout", "下载远程背景图的单次请求超时（如 10s）", durationSetting(func(c *Config) *time.Duration { return &c.DownloadTimeout })},
+	{"CAPTCHA_DOWNLOAD_RETRIES", "download-retries", "下载远程背景图失败后的重试次数，-1表示不重试", intSetting(func(c *Config) *int { return &c.DownloadRetries })},
+	{"CAPTCHA_DOWNLOAD_HEADERS", "download-headers", "下载远程背景图时附加的请求头，格式为 Name: value，逗号分隔", downloadHeaderSetting},
+	{"CAPTCHA_IMAGE_CACHE_DIR", "image-cache-dir", "远程背景图的本地缓存目录，为空表示不缓存", stringSetting(func(c *Config) *string { return &c.ImageCacheDir })},
+	{"CAPTCHA_IMAGE_CACHE_MAX_BYTES", "image-cache-max-bytes", "本地缓存目录的大小上限（字节）", int64Setting(func(c *Config) *int64 { return &c.ImageCacheMaxBytes })},
+	{"WEB_DIR", "web-dir", "演示页面目录（开发时从磁盘读取）", stringSetting(func(c *Config) *string { return &c.WebDir })},
+	{"CAPTCHA_ASSETS", "assets", "资源目录或 .zip 文件（背景图 images/、mask/、演示页面 web/）", stringSetting(func(c *Config) *string { return &c.AssetsPath })},
+	{"BACKGROUND_DIR", "background-dir", "背景图目录", stringSetting(func(c *Config) *string { return &c.BackgroundDir })},
+	{"CAPTCHA_BACKGROUND_HASHES", "background-hashes", "远程背景图的 SHA-256 清单文件（sha256sum 格式）", stringSetting(func(c *Config) *string { return &c.BackgroundHashes })},
+	{"CAPTCHA_BACKGROUND_SCALE", "background-scale", "预加载背景图最大为画布的倍数，0表示保留原始分辨率", floatSetting(func(c *Config) *float64 { return &c.BackgroundScale })},
+	{"REDIS_URL", "redis-url", "Redis地址", stringSetting(func(c *Config) *string { return &c.RedisURL })},
+
+	{"CAPTCHA_BIND_CLIENT_IP", "bind-client-ip", "是否将验证码绑定到客户端IP", boolSetting(func(c *Config) *bool { return &c.BindClientIP })},
+	{"CAPTCHA_TRUSTED_PROXIES", "trusted-proxies", "受信任的代理地址/CIDR，逗号分隔", listSetting(func(c *Config) *[]string { return &c.TrustedProxies })},
+	{"CAPTCHA_CLIENT_IP_HEADERS", "client-ip-headers", "读取客户端IP的请求头，逗号分隔", listSetting(func(c *Config) *[]string { return &c.ClientIPHeaders })},
+	{"CAPTCHA_BIND_SESSION", "bind-session", "是否将验证码绑定到浏览器会话Cookie", boolSetting(func(c *Config) *bool { return &c.BindSession })},
+	{"CAPTCHA_SESSION_COOKIE", "session-cookie", "会话Cookie名称", stringSetting(func(c *Config) *string { return &c.SessionCookieName })},
+	{"CAPTCHA_HONEYPOT_FIELD", "honeypot-field", "蜜罐字段名", stringSetting(func(c *Config) *string { return &c.HoneypotField })},
+
+	{"CAPTCHA_GENERATE_RATE_LIMIT", "generate-rate-limit", "每个IP每分钟允许生成的数量，0表示不限制", intSetting(func(c *Config) *int { return &c.GenerateRateLimit })},
+	{"CAPTCHA_GENERATE_CLUSTER_RATE_LIMIT", "generate-cluster-rate-limit", "每个客户端特征簇每分钟允许生成的数量，0表示不限制", intSetting(func(c *Config) *int { return &c.GenerateClusterRateLimit })},
+	{"CAPTCHA_POW_DIFFICULTY", "pow-difficulty", "工作量证明基础难度", intSetting(func(c *Config) *int { return &c.PoWDifficulty })},
+	{"CAPTCHA_POW_SECRET", "pow-secret", "签名工作量证明挑战的密钥", stringSetting(func(c *Config) *string { return &c.PoWSecret })},
+	{"CAPTCHA_GENERATE_IP_RATE_LIMIT", "generate-ip-rate-limit", "生成接口每个IP每分钟请求数，0表示不限制", intSetting(func(c *Config) *int { return &c.GenerateIPRateLimit })},
+	{"CAPTCHA_GENERATE_GLOBAL_RATE_LIMIT", "generate-global-rate-limit", "生成接口全局每分钟请求数，0表示不限制", intSetting(func(c *Config) *int { return &c.GenerateGlobalRateLimit })},
+	{"CAPTCHA_VERIFY_IP_RATE_LIMIT", "verify-ip-rate-limit", "验证接口每个IP每分钟请求数，0表示不限制", intSetting(func(c *Config) *int { return &c.VerifyIPRateLimit })},
+	{"CAPTCHA_VERIFY_GLOBAL_RATE_LIMIT", "verify-global-rate-limit", "验证接口全局每分钟请求数，0表示不限制", intSetting(func(c *Config) *int { return &c.VerifyGlobalRateLimit })},
+	{"CAPTCHA_GENERATE_DAILY_QUOTA", "generate-daily-quota", "每个IP和指纹每天允许生成的数量，0表示不限制", intSetting(func(c *Config) *int { return &c.GenerateDailyQuota })},
+
+	{"CAPTCHA_LOCKOUT_MAX_FAILURES", "lockout-max-failures", "封禁前允许的失败次数，0表示不启用", intSetting(func(c *Config) *int { return &c.LockoutMaxFailures })},
+	{"CAPTCHA_LOCKOUT_WINDOW", "lockout-window", "失败计数窗口", durationSetting(func(c *Config) *time.Duration { return &c.LockoutWindow })},
+	{"CAPTCHA_LOCKOUT_DURATION", "lockout-duration", "封禁时长", durationSetting(func(c *Config) *time.Duration { return &c.LockoutDuration })},
+	{"CAPTCHA_MAX_CHALLENGE_AGE", "max-challenge-age", "验证码签发时间早于受保护操作开始时间的上限，0表示不检查", durationSetting(func(c *Config) *time.Duration { return &c.MaxChallengeAge })},
+	{"CAPTCHA_ACTION_SECRET", "action-secret", "签名受保护操作开始时间的密钥", stringSetting(func(c *Config) *string { return &c.ActionSecret })},
+	{"CAPTCHA_IP_BLOCKLIST", "ip-blocklist", "IP信誉黑名单文件（每行一个IP或CIDR）", stringSetting(func(c *Config) *string { return &c.IPBlocklist })},
+
+	{"TLS_CERT_FILE", "tls-cert", "HTTPS证书文件", stringSetting(func(c *Config) *string { return &c.TLSCertFile })},
+	{"TLS_KEY_FILE", "tls-key", "HTTPS私钥文件", stringSetting(func(c *Config) *string { return &c.TLSKeyFile })},
+	{"AUTOCERT_DOMAINS", "autocert-domains", "自动申请证书的域名，逗号分隔", listSetting(func(c *Config) *[]string { return &c.AutocertDomains })},
+	{"AUTOCERT_CACHE_DIR", "autocert-cache-dir", "自动证书缓存目录", stringSetting(func(c *Config) *string { return &c.AutocertCacheDir })},
+	{"AUTOCERT_EMAIL", "autocert-email", "自动证书联系邮箱", stringSetting(func(c *Config) *string { return &c.AutocertEmail })},
+
+	{"ADMIN_ADDR", "admin-addr", "管理接口和 pprof 的独立监听地址", stringSetting(func(c *Config) *string { return &c.AdminAddr })},
+	{"ADMIN_API_KEY", "admin-api-key", "管理接口API Key", stringSetting(func(c *Config) *string { return &c.AdminAPIKey })},
+	{"ADMIN_USERNAME", "admin-username", "管理接口 Basic Auth 用户名", stringSetting(func(c *Config) *string { return &c.AdminUsername })},
+	{"ADMIN_PASSWORD", "admin-password", "管理接口 Basic Auth 密码", stringSetting(func(c *Config) *string { return &c.AdminPassword })},
+	{"CAPTCHA_ANSWER_SECRET", "answer-secret", "答案披露接口的服务密钥", stringSetting(func(c *Config) *string { return &c.AnswerSecret })},
+	{"CAPTCHA_ID_SECRET", "id-secret", "签名验证码ID的密钥", stringSetting(func(c *Config) *string { return &c.IDSecret })},
+	{"CAPTCHA_ID_KEYS", "id-keys", "签名验证码ID的密钥（kid:密钥），逗号分隔，第一把用于签发", listSetting(func(c *Config) *[]string { return &c.IDKeys })},
+	{"CAPTCHA_ID_KEY_ROTATION", "id-key-rotation", "自动轮换签名密钥的周期（如 24h），0表示不轮换", durationSetting(func(c *Config) *time.Duration { return &c.IDKeyRotation })},
+	{"CAPTCHA_DEBUG_ANSWERS", "debug-answers", "调试模式：生成接口返回答案和预览图（绝不能在生产环境开启）", boolSetting(func(c *Config) *bool { return &c.DebugAnswers })},
+
+	{"STATSD_ADDR", "statsd-addr", "StatsD 的 UDP 地址（如 127.0.0.1:8125）", stringSetting(func(c *Config) *string { return &c.StatsdAddr })},
+	{"STATSD_PREFIX", "statsd-prefix", "StatsD 指标名称前缀", stringSetting(func(c *Config) *string { return &c.StatsdPrefix })},
+
+	{"LOG_LEVEL", "log-level", "日志级别：debug、info、warn、error", stringSetting(func(c *Config) *string { return &c.LogLevel })},
+	{"LOG_FORMAT", "log-format", "日志格式：text 或 json", stringSetting(func(c *Config) *string { return &c.LogFormat })},
+	{"ACCESS_LOG", "access-log", "JSON访问日志输出：stdout 或 file:/path", stringSetting(func(c *Config) *string { return &c.AccessLog })},
+	{"ACCESS_LOG_MAX_SIZE", "access-log-max-size", "访问日志文件轮转大小（MB）", int64Setting(func(c *Config) *int64 { return &c.AccessLogMaxSize })},
+	{"ACCESS_LOG_MAX_BACKUPS", "access-log-max-backups", "访问日志保留的轮转文件数", intSetting(func(c *Config) *int { return &c.AccessLogMaxBackups })},
+	{"GIN_MODE", "gin-mode", "gin 运行模式：debug、release 或 test", ginModeSetting},
+
+	{"CAPTCHA_SHUTDOWN_TIMEOUT", "shutdown-timeout", "优雅停机等待时间", durationSetting(func(c *Config) *time.Duration { return &c.ShutdownTimeout })},
+	{"CAPTCHA_READ_HEADER_TIMEOUT", "read-header-timeout", "读取请求头超时时间", durationSetting(func(c *Config) *time.Duration { return &c.ReadHeaderTimeout })},
+	{"CAPTCHA_BODY_READ_TIMEOUT", "body-read-timeout", "读取请求体超时时间", durationSetting(func(c *Config) *time.Duration { return &c.BodyReadTimeout })},
+	{"CAPTCHA_MAX_BODY_SIZE", "max-body-size", "最大请求体字节数", int64Setting(func(c *Config) *int64 { return &c.MaxBodySize })},
+
+	{"AUDIT_SINK", "audit-sink", "审计记录输出目标", stringSetting(func(c *Config) *string { return &c.AuditSink })},
+	{"AUDIT_SALT", "audit-salt", "审计记录IP哈希盐值", stringSetting(func(c *Config) *string { return &c.AuditSalt })},
+	{"CAPTCHA_ANALYTICS_HOURS", "analytics-hours", "滥用分析保留的小时数，0表示不统计", intSetting(func(c *Config) *int { return &c.AnalyticsHours })},
+}
+
+// LoadConfig 加载服务配置，优先级：命令行参数 > 环境变量 > 配置文件 > 默认值
+// 配置文件通过 -config 参数或 CAPTCHA_CONFIG 环境变量指定，格式为每行一个 KEY=VALUE（键名同环境变量）
+func LoadConfig(args []string) (*Config, error) {
+	fs := flag.NewFlagSet("photo_captcha", flag.ContinueOnError)
+	configFile := fs.String("config", os.Getenv(ConfigFileEnv), "配置文件路径")
+	flagValues := make(map[string]*string, len(settings))
+	for _, s := range settings {
+		flagValues[s.flag] = fs.String(s.flag, "", fmt.Sprintf("%s（环境变量 %s）", s.usage, s.env))
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	cfg := DefaultConfig()
+
+	// 配置文件
+	if *configFile != "" {
+		values, err := readConfigFile(*configFile)
+		if err != nil {
+			return nil, err
+		}
+		for _, s := range settings {
+			if value, ok := values[s.env]; ok {
+				if err := applySetting(cfg, s, s.env, value); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	// 环境变量
+	for _, s := range settings {
+		if value, ok := os.LookupEnv(s.env); ok {
+			if err := applySetting(cfg, s, s.env, value); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// 命令行参数（只应用显式传入的参数）
+	var flagErr error
+	fs.Visit(func(f *flag.Flag) {
+		for _, s := range settings {
+			if s.flag == f.Name && flagErr == nil {
+				flagErr = applySetting(cfg, s, "-"+s.flag, *flagValues[s.flag])
+			}
+		}
+	})
+	if flagErr != nil {
+		return nil, flagErr
+	}
+
+	return cfg, nil
+}
+
+// applySetting 应用一项配置，source 用于错误信息
+func applySetting(cfg *Config, s setting, source, value string) error {
+	if err := s.apply(cfg, strings.TrimSpace(value)); err != nil {
+		return fmt.Errorf("invalid %s: %w", source, err)
+	}
+	return nil
+}
+
+// readConfigFile 读取 KEY=VALUE 格式的配置文件，忽略空行和 # 开头的注释
+func readConfigFile(path string) (map[string]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open config file: %w", err)
+	}
+	defer file.Close()
+
+	values := make(map[string]string)
+	scanner := bufio.NewScanner(file)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid config file line %d: %q", lineNo, line)
+		}
+		values[strings.TrimSpace(key)] = strings.Trim(strings.TrimSpace(value), `"'`)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+	return values, nil
+}
+
+func stringSetting(field func(*Config) *string) func(*Config, string) error {
+	return func(cfg *Config, value string) error {
+		*field(cfg) = value
+		return nil
+	}
+}
+
+func intSetting(field func(*Config) *int) func(*Config, string) error {
+	return func(cfg *Config, value string) error {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return err
+		}
+		*field(cfg) = n
+		return nil
+	}
+}
+
+func floatSetting(field func(*Config) *float64) func(*Config, string) error {
+	return func(cfg *Config, value string) error {
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return err
+		}
+		*field(cfg) = f
+		return nil
+	}
+}
+
+func int64Setting(field func(*Config) *int64) func(*Config, string) error {
+	return func(cfg *Config, value string) error {
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return err
+		}
+		*field(cfg) = n
+		return nil
+	}
+}
+
+func fileModeSetting(field func(*Config) *os.FileMode) func(*Config, string) error {
+	return func(cfg *Config, value string) error {
+		mode, err := strconv.ParseUint(value, 8, 32)
+		if err != nil {
+			return err
+		}
+		*field(cfg) = os.FileMode(mode) & os.ModePerm
+		return nil
+	}
+}
+
+func ginModeSetting(cfg *Config, value string) error {
+	switch value {
+	case gin.DebugMode, gin.ReleaseMode, gin.TestMode:
+		cfg.GinMode = value
+		return nil
+	default:
+		return fmt.Errorf("unknown gin mode %q", value)
+	}
+}
+
+func placementSetting(cfg *Config, value string) error {
+	switch value {
+	case captcha.PlacementCenter.String():
+		cfg.Placement = captcha.PlacementCenter
+	case captcha.PlacementFullCanvas.String():
+		cfg.Placement = captcha.PlacementFullCanvas
+	default:
+		return fmt.Errorf("unknown placement %q", value)
+	}
+	return nil
+}
+
+func pngCompressionSetting(cfg *Config, value string) error {
+	switch value {
+	case "default":
+		cfg.PNGCompression = png.DefaultCompression
+	case "speed":
+		cfg.PNGCompression = png.BestSpeed
+	case "best":
+		cfg.PNGCompression = png.BestCompression
+	case "none":
+		cfg.PNGCompression = png.NoCompression
+	default:
+		return fmt.Errorf("unknown png compression %q", value)
+	}
+	return nil
+}
+
+func downloadHeaderSetting(cfg *Config, value string) error {
+	header := http.Header{}
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item == "" {
+			continue
+		}
+		name, v, ok := strings.Cut(item, ":")
+		if name = strings.TrimSpace(name); !ok || name == "" {
+			return fmt.Errorf("invalid header %q, want Name: value", item)
+		}
+		header.Add(name, strings.TrimSpace(v))
+	}
+	cfg.DownloadHeader = header
+	return nil
+}
+
+func boolSetting(field func(*Config) *bool) func(*Config, string) error {
+	return func(cfg *Config, value string) error {
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		*field(cfg) = b
+		return nil
+	}
+}
+
+func durationSetting(field func(*Config) *time.Duration) func(*Config, string) error {
+	return func(cfg *Config, value string) error {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		*field(cfg) = d
+		return nil
+	}
+}
+
+func listSetting(field func(*Config) *[]string) func(*Config, string) error {
+	return func(cfg *Config, value string) error {
+		var list []string
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+		*field(cfg) = list
+		return nil
+	}
+}
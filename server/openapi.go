@@ -0,0 +1,467 @@
+package server
+
+import (
+	"maps"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gpencil/photo_captcha/captcha"
+	"github.com/gpencil/photo_captcha/pow"
+
+	"github.com/gin-gonic/gin"
+)
+
+// OpenAPIPath OpenAPI 文档地址
+const OpenAPIPath = "/api/openapi.json"
+
+// schema OpenAPI schema 对象
+type schema = map[string]interface{}
+
+// apiParam 查询参数或路径参数
+type apiParam struct {
+	name        string
+	in          string // query 或 path
+	description string
+}
+
+// captchaRoute 验证码接口定义，同时用于注册路由和生成 OpenAPI 文档，保证两者一致
+type captchaRoute struct {
+	method  string
+	path    string // 相对 /captcha 分组的 gin 路径
+	handler gin.HandlerFunc
+	limit   string // 限流类别：generate、verify，为空表示不限流
+	id      string // OpenAPI operationId
+	summary string
+	params  []apiParam
+	body    interface{} // 请求体结构（nil 表示无请求体）
+	data    interface{} // 成功响应的 data 字段：Go 结构（按 json 标签反射）或 schema（nil 表示无 data）
+	binary  bool        // 成功时以 multipart/mixed 返回JSON响应和 background、slider 两张原始图片
+	errors  []int       // 可能返回的错误HTTP状态码
+}
+
+// powParams 生成类接口超限后提交工作量证明的参数
+var powParams = []apiParam{
+	{"pow_challenge", "query", "工作量证明挑战串（生成频率超限后提交）"},
+	{"pow_nonce", "query", "工作量证明结果"},
+}
+
+// captchaRoutes 所有验证码接口
+var captchaRoutes = []captchaRoute{
+	{
+		method: http.MethodGet, path: "/generate", handler: GenerateCaptchaHandler, limit: "generate",
+		id:      "generateCaptcha",
+		summary: "生成验证码",
+		params:  append([]apiParam{{"fingerprint", "query", "客户端指纹（可选），验证时必须提交相同的指纹"}}, powParams...),
+		data:    generateDataSchema(),
+		errors:  []int{http.StatusForbidden, http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusServiceUnavailable},
+	},
+	{
+		method: http.MethodPost, path: "/generate", handler: GenerateCaptchaHandler, limit: "generate",
+		id:      "generateCaptchaAsync",
+		summary: "生成验证码，async=1 时立即返回异步生成任务（HTTP 202），通过 /jobs/{id} 或 WebSocket /ws/captcha/jobs/{id} 获取结果",
+		params: append([]apiParam{
+			{"fingerprint", "query", "客户端指纹（可选），验证时必须提交相同的指纹"},
+			{"async", "query", "为 1 时异步生成；未启用异步生成或队列已满时同步返回验证码"},
+		}, powParams...),
+		data:   asyncGenerateDataSchema(),
+		errors: []int{http.StatusAccepted, http.StatusForbidden, http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusServiceUnavailable},
+	},
+	{
+		method: http.MethodGet, path: "/generate/binary", handler: GenerateCaptchaBinaryHandler, limit: "generate",
+		id:      "generateCaptchaBinary",
+		summary: "生成验证码，成功时以 multipart/mixed 返回JSON响应（data 不含图片）和 background、slider 两张原始PNG，比JSON+base64小约25%",
+		params:  append([]apiParam{{"fingerprint", "query", "客户端指纹（可选），验证时必须提交相同的指纹"}}, powParams...),
+		data:    generateDataSchema(),
+		binary:  true,
+		errors:  []int{http.StatusForbidden, http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusServiceUnavailable},
+	},
+	{
+		method: http.MethodGet, path: "/jobs/:id", handler: GenerateJobHandler,
+		id:      "getGenerateJob",
+		summary: "查询异步生成任务，完成后 data.captcha 为验证码",
+		params:  []apiParam{{"id", "path", "任务ID"}},
+		data:    generateJobSchema(),
+		errors:  []int{http.StatusNotFound, http.StatusInternalServerError, http.StatusServiceUnavailable},
+	},
+	{
+		method: http.MethodPost, path: "/verify", handler: VerifyCaptchaHandler, limit: "verify",
+		id:      "verifyCaptcha",
+		summary: "验证滑块位置",
+		body:    VerifyCaptchaRequest{},
+		data:    verifyResultSchema(),
+		errors:  []int{http.StatusBadRequest, http.StatusForbidden, http.StatusRequestTimeout, http.StatusRequestEntityTooLarge, http.StatusTooManyRequests, http.StatusServiceUnavailable},
+	},
+	{
+		method: http.MethodPost, path: "/verify/batch", handler: VerifyCaptchaBatchHandler, limit: "verify",
+		id:      "verifyCaptchaBatch",
+		summary: "批量验证（供缓冲了多个验证请求的网关使用），最多" + strconv.Itoa(captcha.MaxVerifyBatch) + "项，results 与 items 一一对应",
+		body:    VerifyBatchRequest{},
+		data: schema{
+			"type": "object",
+			"properties": schema{
+				"results": schema{
+					"type": "array",
+					"items": schema{
+						"type": "object",
+						"properties": schema{
+							"id":      schema{"type": "string"},
+							"code":    schema{"type": "integer", "description": "与单独验证时相同：200 已判定、400 验证码不可用、503 存储不可用"},
+							"message": schema{"type": "string"},
+							"data":    verifyResultSchema(),
+						},
+					},
+				},
+			},
+		},
+		errors: []int{http.StatusBadRequest, http.StatusForbidden, http.StatusRequestTimeout, http.StatusRequestEntityTooLarge, http.StatusTooManyRequests, http.StatusServiceUnavailable},
+	},
+	{
+		method: http.MethodPost, path: "/refresh", handler: RefreshCaptchaHandler, limit: "generate",
+		id:      "refreshCaptcha",
+		summary: "换一张：作废旧验证码并返回新验证码",
+		params:  powParams,
+		body:    RefreshCaptchaRequest{},
+		data:    generateDataSchema(),
+		errors:  []int{http.StatusBadRequest, http.StatusForbidden, http.StatusRequestTimeout, http.StatusRequestEntityTooLarge, http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusServiceUnavailable},
+	},
+	{
+		method: http.MethodGet, path: "/difficulty", handler: DifficultyStatusHandler,
+		id:      "getDifficulty",
+		summary: "查询当前难度等级",
+		data: schema{
+			"type": "object",
+			"properties": schema{
+				"global": difficultySchema(),
+				"client": difficultySchema(),
+			},
+		},
+	},
+	{
+		method: http.MethodGet, path: "/status/:id", handler: CaptchaStatusHandler,
+		id:      "getCaptchaStatus",
+		summary: "查询验证码状态（不返回答案）",
+		params:  []apiParam{{"id", "path", "验证码ID"}},
+		data: schema{
+			"type": "object",
+			"properties": schema{
+				"id":     schema{"type": "string"},
+				"status": enumSchema(captcha.Statuses),
+			},
+		},
+		errors: []int{http.StatusNotFound, http.StatusServiceUnavailable},
+	},
+	{
+		method: http.MethodDelete, path: "/:id", handler: RevokeCaptchaHandler,
+		id:      "revokeCaptcha",
+		summary: "作废验证码",
+		params:  []apiParam{{"id", "path", "验证码ID"}},
+		errors:  []int{http.StatusNotFound, http.StatusServiceUnavailable},
+	},
+}
+
+// errorDescriptions 错误HTTP状态码说明
+var errorDescriptions = map[int]string{
+	http.StatusAccepted:              "已提交异步生成任务（data 为任务ID和状态）",
+	http.StatusBadRequest:            "请求参数错误",
+	http.StatusForbidden:             "失败次数过多，来源已被封禁（data.retryAfter 为剩余秒数）",
+	http.StatusNotFound:              "验证码不存在",
+	http.StatusRequestTimeout:        "读取请求体超时",
+	http.StatusRequestEntityTooLarge: "请求体过大",
+	http.StatusTooManyRequests:       "超出限流或每日生成配额（Retry-After 为需等待的秒数）",
+	http.StatusInternalServerError:   "服务内部错误",
+	http.StatusServiceUnavailable:    "验证码存储暂不可用，或服务启动后仍在加载背景图（Retry-After 为建议等待的秒数）",
+}
+
+var (
+	openAPIOnce sync.Once
+	openAPIDoc  schema
+)
+
+// OpenAPIHandler 输出描述验证码接口的 OpenAPI 3 文档
+func OpenAPIHandler(c *gin.Context) {
+	openAPIOnce.Do(func() {
+		openAPIDoc = openAPIDocument()
+	})
+
+	// 路径前缀随配置变化，不缓存
+	doc := maps.Clone(openAPIDoc)
+	if base := config.basePath(); base != "" {
+		doc["servers"] = []schema{{"url": base}}
+	}
+	c.JSON(http.StatusOK, doc)
+}
+
+// openAPIDocument 根据 captchaRoutes 生成 OpenAPI 文档
+func openAPIDocument() schema {
+	paths := schema{}
+	for _, route := range captchaRoutes {
+		path := "/api/" + APIVersion + "/captcha" + openAPIPath(route.path)
+		item, ok := paths[path].(schema)
+		if !ok {
+			item = schema{}
+			paths[path] = item
+		}
+		item[strings.ToLower(route.method)] = route.operation()
+	}
+
+	return schema{
+		"openapi": "3.0.3",
+		"info": schema{
+			"title":   "Photo Captcha API",
+			"version": APIVersion,
+			"description": "滑块拼图验证码接口。响应统一为 {code, message, data}：code 为 200 表示成功；" +
+				"验证未通过时 HTTP 状态为 200、code 为 400，失败原因见 data.reason；" +
+				"生成频率超限时 code 为 429，需按 data.pow 完成工作量证明后重试。",
+		},
+		"paths": paths,
+		"components": schema{
+			"schemas": schema{
+				"Response": schema{
+					"type":     "object",
+					"required": []string{"code", "message"},
+					"properties": schema{
+						"code":       schema{"type": "integer", "description": "业务状态码"},
+						"message":    schema{"type": "string", "description": "按 Accept-Language 或 lang 参数本地化的消息"},
+						"requestId":  schema{"type": "string", "description": "请求ID（出错时返回）"},
+						"apiVersion": schema{"type": "string"},
+						"data":       schema{},
+					},
+				},
+				"PoWChallenge":  typeSchema(reflect.TypeOf(pow.Challenge{})),
+				"FailureReason": enumSchema(captcha.FailureReasons),
+			},
+		},
+	}
+}
+
+// operation 生成单个接口的 OpenAPI operation
+func (r captchaRoute) operation() schema {
+	params := []schema{{
+		"name":        RequestIDHeader,
+		"in":          "header",
+		"description": "请求ID（可选），未提供时由服务端生成",
+		"schema":      schema{"type": "string"},
+	}, {
+		"name":        LanguageQueryParam,
+		"in":          "query",
+		"description": "响应消息语言，优先于 Accept-Language",
+		"schema":      schema{"type": "string"},
+	}}
+	for _, p := range r.params {
+		params = append(params, schema{
+			"name":        p.name,
+			"in":          p.in,
+			"required":    p.in == "path",
+			"description": p.description,
+			"schema":      schema{"type": "string"},
+		})
+	}
+
+	success := schema{"$ref": "#/components/schemas/Response"}
+	if r.data != nil {
+		success = schema{
+			"allOf": []schema{success, {
+				"type":       "object",
+				"properties": schema{"data": schemaOf(r.data)},
+			}},
+		}
+	}
+	content := schema{"application/json": schema{"schema": success}}
+	if r.binary {
+		image := schema{"type": "string", "format": "binary"}
+		content = schema{"multipart/mixed": schema{"schema": schema{
+			"type":       "object",
+			"properties": schema{"response": success, "background": image, "slider": image},
+		}}}
+	}
+	responses := schema{
+		"200": schema{
+			"description": "成功（code 为 200），或业务错误（见文档说明）",
+			"content":     content,
+		},
+	}
+	for _, status := range r.errors {
+		responses[strconv.Itoa(status)] = schema{
+			"description": errorDescriptions[status],
+			"content": schema{"application/json": schema{
+				"schema": schema{"$ref": "#/components/schemas/Response"},
+			}},
+		}
+	}
+
+	op := schema{
+		"operationId": r.id,
+		"summary":     r.summary,
+		"tags":        []string{"captcha"},
+		"parameters":  params,
+		"responses":   responses,
+	}
+	if r.body != nil {
+		op["requestBody"] = schema{
+			"required": true,
+			"content":  schema{"application/json": schema{"schema": schemaOf(r.body)}},
+		}
+	}
+	return op
+}
+
+// openAPIPath 将 gin 路径参数 :id 转换为 OpenAPI 的 {id}
+func openAPIPath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if strings.HasPrefix(segment, ":") {
+			segments[i] = "{" + segment[1:] + "}"
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// generateDataSchema 生成结果 data 字段：验证码，或频率超限时的工作量证明挑战（code 为 429）
+func generateDataSchema() schema {
+	return schema{
+		"oneOf": []schema{
+			captchaSchema(),
+			{
+				"type":       "object",
+				"properties": schema{"pow": schema{"$ref": "#/components/schemas/PoWChallenge"}},
+			},
+		},
+	}
+}
+
+// captchaSchema 生成的验证码，调试模式下附带 debug 字段
+func captchaSchema() schema {
+	s := typeSchema(reflect.TypeOf(captcha.SliderCaptcha{}))
+	debug := typeSchema(reflect.TypeOf(captcha.DebugAnswer{}))
+	debug["description"] = "答案和预览图，仅在开启 CAPTCHA_DEBUG_ANSWERS 调试模式时返回"
+	s["properties"].(schema)["debug"] = debug
+	return s
+}
+
+// asyncGenerateDataSchema 可异步生成时的 data 字段：验证码、工作量证明挑战或异步生成任务
+func asyncGenerateDataSchema() schema {
+	data := generateDataSchema()
+	data["oneOf"] = append(data["oneOf"].([]schema), generateJobSchema())
+	return data
+}
+
+// generateJobSchema 异步生成任务 data 字段（见 jobData）
+func generateJobSchema() schema {
+	return schema{
+		"type": "object",
+		"properties": schema{
+			"jobId":   schema{"type": "string"},
+			"status":  enumSchema([]string{JobPending, JobReady, JobFailed}),
+			"captcha": captchaSchema(),
+		},
+		"required": []string{"jobId", "status"},
+	}
+}
+
+// verifyResultSchema 验证结果 data 字段（见 httpapi.VerifyResultData）
+func verifyResultSchema() schema {
+	return schema{
+		"type": "object",
+		"properties": schema{
+			"success":   schema{"type": "boolean"},
+			"token":     schema{"type": "string", "description": "验证通过后签发的一次性令牌"},
+			"reason":    schema{"$ref": "#/components/schemas/FailureReason"},
+			"retryable": schema{"type": "boolean", "description": "是否可以使用同一个验证码重试"},
+		},
+		"required": []string{"success"},
+	}
+}
+
+// difficultySchema 难度等级及通过率统计
+func difficultySchema() schema {
+	return schema{
+		"type": "object",
+		"properties": schema{
+			"level": schema{"type": "string"},
+			"stats": typeSchema(reflect.TypeOf(captcha.PassRateStats{})),
+		},
+	}
+}
+
+// enumSchema 字符串枚举
+func enumSchema[T ~string](values []T) schema {
+	enum := make([]string, len(values))
+	for i, v := range values {
+		enum[i] = string(v)
+	}
+	return schema{"type": "string", "enum": enum}
+}
+
+// schemaOf 返回 schema，Go 结构按 json 标签反射生成
+func schemaOf(v interface{}) schema {
+	if s, ok := v.(schema); ok {
+		return s
+	}
+	return typeSchema(reflect.TypeOf(v))
+}
+
+// coordinateType 坐标兼容字符串和数字，需要单独描述
+var coordinateType = reflect.TypeOf(Coordinate{})
+
+// timeType 时间按 RFC 3339 字符串输出
+var timeType = reflect.TypeOf(time.Time{})
+
+// typeSchema 按类型生成 schema，结构体字段使用 json 标签名，binding:"required" 的字段为必填
+func typeSchema(t reflect.Type) schema {
+	if t == coordinateType {
+		return schema{
+			"oneOf":       []schema{{"type": "number"}, {"type": "string"}},
+			"description": "滑块X坐标，支持整数、小数和数字字符串",
+		}
+	}
+	if t == timeType {
+		return schema{"type": "string", "format": "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		return typeSchema(t.Elem())
+	case reflect.String:
+		return schema{"type": "string"}
+	case reflect.Bool:
+		return schema{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return schema{"type": "integer"}
+	case reflect.Int64, reflect.Uint64:
+		return schema{"type": "integer", "format": "int64"}
+	case reflect.Float32, reflect.Float64:
+		return schema{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return schema{"type": "array", "items": typeSchema(t.Elem())}
+	case reflect.Map:
+		return schema{"type": "object", "additionalProperties": typeSchema(t.Elem())}
+	case reflect.Struct:
+		properties := schema{}
+		var required []string
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+			if !field.IsExported() || name == "-" {
+				continue
+			}
+			if name == "" {
+				name = field.Name
+			}
+			properties[name] = typeSchema(field.Type)
+			if strings.Contains(field.Tag.Get("binding"), "required") {
+				required = append(required, name)
+			}
+		}
+		s := schema{"type": "object", "properties": properties}
+		if len(required) > 0 {
+			s["required"] = required
+		}
+		return s
+	default:
+		return schema{}
+	}
+}
@@ -0,0 +1,235 @@
+package server
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gpencil/photo_captcha/captcha"
+	"github.com/gpencil/photo_captcha/pow"
+	"github.com/gpencil/photo_captcha/ratelimit"
+
+	"github.com/gin-gonic/gin"
+)
+
+// powTTL 工作量证明挑战的有效期
+const powTTL = 2 * time.Minute
+
+var (
+	// generateLimiter 生成接口的限流器（未启用时为nil）
+	generateLimiter *fixedWindowLimiter
+	// powManager 限流触发后签发的工作量证明挑战
+	powManager = pow.NewManager(powTTL, nil, nil)
+	// verifyIPLimiter、verifyGlobalLimiter 验证接口按IP和全局的令牌桶限流器（未启用时为nil），WebSocket 验证同样使用
+	verifyIPLimiter, verifyGlobalLimiter *ratelimit.Limiter
+	// lockout 暴力破解封禁（未启用时为nil）
+	lockout *captcha.Lockout
+	// generateQuota 每个IP和指纹的每日生成配额（未启用时为nil）
+	generateQuota *ratelimit.Quota
+	// ipBlocklist IP信誉黑名单（未启用时为nil）
+	ipBlocklist *captcha.Blocklist
+)
+
+// windowCounter 单个IP在当前窗口内的计数
+type windowCounter struct {
+	start time.Time
+	count int
+}
+
+// fixedWindowLimiter 按IP的固定窗口计数限流器
+type fixedWindowLimiter struct {
+	mu       sync.Mutex
+	limit    int
+	window   time.Duration
+	counters map[string]*windowCounter
+	// lastCleanup 上次清理过期计数的时间
+	lastCleanup time.Time
+}
+
+// newFixedWindowLimiter 创建限流器，limit 为每个窗口允许的请求数
+func newFixedWindowLimiter(limit int, window time.Duration) *fixedWindowLimiter {
+	return &fixedWindowLimiter{
+		limit:    limit,
+		window:   window,
+		counters: make(map[string]*windowCounter),
+	}
+}
+
+// Allow 记录一次请求，返回是否允许以及当前窗口内的请求数
+func (l *fixedWindowLimiter) Allow(key string) (bool, int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(l.lastCleanup) >= l.window {
+		l.lastCleanup = now
+		for k, c := range l.counters {
+			if now.Sub(c.start) >= l.window {
+				delete(l.counters, k)
+			}
+		}
+	}
+
+	counter, exists := l.counters[key]
+	if !exists || now.Sub(counter.start) >= l.window {
+		counter = &windowCounter{start: now}
+		l.counters[key] = counter
+	}
+
+	counter.count++
+	return counter.count <= l.limit, counter.count
+}
+
+// checkLockout 检查来源是否处于封禁中，封禁时返回错误响应并中止请求
+func checkLockout(c *gin.Context, fingerprint string) bool {
+	banned, retryAfter := lockedOut(c, fingerprint)
+	if !banned {
+		return true
+	}
+
+	c.Header("Retry-After", strconv.Itoa(retryAfter))
+	respond(c, http.StatusForbidden, gin.H{
+		"code":      403,
+		"message":   msg(c, MsgBanned),
+		"requestId": requestID(c),
+		"data": gin.H{
+			"retryAfter": retryAfter,
+		},
+	})
+	return false
+}
+
+// lockedOut 判断来源IP或指纹是否被封禁，封禁时同时返回距解封的秒数
+func lockedOut(c *gin.Context, fingerprint string) (bool, int) {
+	return sourceLockedOut(c, c.ClientIP(), fingerprint)
+}
+
+// sourceLockedOut 判断IP或指纹（为空的不检查）是否被封禁，封禁时同时返回距解封的秒数
+func sourceLockedOut(c *gin.Context, ip, fingerprint string) (bool, int) {
+	if lockout == nil {
+		return false, 0
+	}
+
+	banned, until, err := lockout.Banned(ip, fingerprint)
+	if err != nil {
+		// 封禁存储不可用时放行，避免Redis故障导致验证码整体不可用
+		requestLogger(c).Error("failed to check lockout", "error", err)
+		return false, 0
+	}
+	if !banned {
+		return false, 0
+	}
+	return true, int(math.Ceil(time.Until(until).Seconds()))
+}
+
+// dailyQuotaExceeded 为来源的IP和指纹（非空时）各记录一次生成，任一超过每日配额时返回 true 和配额重置时间
+// 配额存储不可用时放行
+func dailyQuotaExceeded(c *gin.Context, fingerprint string) (bool, time.Time) {
+	if generateQuota == nil {
+		return false, time.Time{}
+	}
+
+	keys := []string{"ip:" + c.ClientIP()}
+	if fingerprint != "" {
+		keys = append(keys, "fp:"+fingerprint)
+	}
+	for _, key := range keys {
+		allowed, resetAt, err := generateQuota.Allow(key)
+		if err != nil {
+			requestLogger(c).Error("failed to check daily quota", "error", err)
+			continue
+		}
+		if !allowed {
+			return true, resetAt
+		}
+	}
+	return false, time.Time{}
+}
+
+// checkDailyQuota 检查每日生成配额，超出时返回 429 和 Retry-After（到次日0点UTC）并中止请求
+func checkDailyQuota(c *gin.Context, fingerprint string) bool {
+	exceeded, resetAt := dailyQuotaExceeded(c, fingerprint)
+	if !exceeded {
+		return true
+	}
+
+	retryAfter := int(math.Ceil(time.Until(resetAt).Seconds()))
+	c.Header("Retry-After", strconv.Itoa(retryAfter))
+	respond(c, http.StatusTooManyRequests, gin.H{
+		"code":      429,
+		"message":   msg(c, MsgDailyQuota),
+		"requestId": requestID(c),
+		"data": gin.H{
+			"retryAfter": retryAfter,
+		},
+	})
+	return false
+}
+
+// recordLockoutFailure 记录一次验证失败（验证码不存在或已过期不计入）
+func recordLockoutFailure(c *gin.Context, fingerprint string, reason captcha.FailureReason) {
+	if lockout == nil || reason == captcha.ReasonNotFound || reason == captcha.ReasonExpired || reason == captcha.ReasonUnavailable {
+		return
+	}
+	if err := lockout.RecordFailure(c.ClientIP(), fingerprint); err != nil {
+		requestLogger(c).Error("failed to record lockout failure", "error", err)
+	}
+}
+
+// RateLimitMiddleware 令牌桶限流中间件，依次检查每个IP和全局的限额（为nil的限流器跳过）
+// 超限时返回 429 和 Retry-After；限流存储不可用时放行
+func RateLimitMiddleware(perIP, global *ratelimit.Limiter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !allowRate(c, perIP, c.ClientIP()) || !allowRate(c, global, "global") {
+			return
+		}
+		c.Next()
+	}
+}
+
+// allowRate 从限流器取令牌，超限时返回错误响应并中止请求
+func allowRate(c *gin.Context, limiter *ratelimit.Limiter, key string) bool {
+	allowed, retryAfter := takeRate(c, limiter, key)
+	if allowed {
+		return true
+	}
+
+	c.Header("Retry-After", strconv.Itoa(retryAfter))
+	respond(c, http.StatusTooManyRequests, gin.H{
+		"code":      429,
+		"message":   msg(c, MsgRateLimited),
+		"requestId": requestID(c),
+		"data": gin.H{
+			"retryAfter": retryAfter,
+		},
+	})
+	c.Abort()
+	return false
+}
+
+// takeRate 从限流器取令牌，超限时同时返回需等待的秒数；限流器为nil或存储不可用时放行
+func takeRate(c *gin.Context, limiter *ratelimit.Limiter, key string) (bool, int) {
+	if limiter == nil {
+		return true, 0
+	}
+
+	allowed, wait, err := limiter.Allow(key)
+	if err != nil {
+		requestLogger(c).Error("failed to check rate limit", "error", err)
+		return true, 0
+	}
+	if allowed {
+		return true, 0
+	}
+	return false, int(math.Ceil(wait.Seconds()))
+}
+
+// verifyRateAllowed 按来源IP和全局的验证限流器各取一个令牌（WebSocket 验证使用，HTTP 接口由中间件限流）
+func verifyRateAllowed(c *gin.Context) (bool, int) {
+	if allowed, retryAfter := takeRate(c, verifyIPLimiter, c.ClientIP()); !allowed {
+		return false, retryAfter
+	}
+	return takeRate(c, verifyGlobalLimiter, "global")
+}
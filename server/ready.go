@@ -0,0 +1,97 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gpencil/photo_captcha/captcha"
+
+	"github.com/gin-gonic/gin"
+)
+
+// initRetryAfter 后台初始化完成前，生成接口建议客户端等待的秒数（Retry-After）
+const initRetryAfter = 2
+
+// 就绪检查返回的状态
+const (
+	ReadyStatusReady        = "ready"        // 已完成初始化
+	ReadyStatusInitializing = "initializing" // 正在加载背景图
+	ReadyStatusFailed       = "failed"       // 初始化失败（如背景图无法下载）
+)
+
+// errNotReady 后台初始化尚未完成
+var errNotReady = errors.New("captcha service is still initializing")
+
+// initCancel 中止进行中的后台初始化
+var initCancel context.CancelFunc
+
+// startInit 在后台协程中初始化验证码服务（下载并预计算背景图），HTTP服务无需等待即可开始监听
+func startInit(svc *captcha.CaptchaService) {
+	ctx, cancel := context.WithCancel(context.Background())
+	initCancel = cancel
+	go func() {
+		start := time.Now()
+		if err := svc.WarmUp(ctx); err != nil {
+			if ctx.Err() == nil {
+				slog.Error("captcha service initialization failed", "error", err)
+			}
+			return
+		}
+		slog.Info("captcha service ready", "duration", time.Since(start).String())
+	}()
+}
+
+// stopInit 中止进行中的后台初始化（重新加载配置或停机时调用）
+func stopInit() {
+	if initCancel != nil {
+		initCancel()
+		initCancel = nil
+	}
+}
+
+// serviceReady 判断是否可以生成验证码：未启用后台初始化时首次生成会自动初始化，始终可以生成；
+// 初始化失败时也返回 true，由生成返回记录的错误
+func serviceReady() bool {
+	if !config.AsyncInit {
+		return true
+	}
+	status := captchaSvc.InitStatus()
+	return status.Ready || status.Err != nil
+}
+
+// respondNotReady 后台初始化完成前返回 503 和 Retry-After
+func respondNotReady(c *gin.Context) {
+	c.Header("Retry-After", strconv.Itoa(initRetryAfter))
+	respond(c, http.StatusServiceUnavailable, gin.H{
+		"code":      503,
+		"message":   msg(c, MsgInitializing),
+		"requestId": requestID(c),
+	})
+}
+
+// HealthzHandler 存活检查：进程能处理请求即返回200，不受初始化进度影响
+func HealthzHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// ReadyzHandler 就绪检查：初始化完成返回200，加载中或失败返回503，并报告背景图加载进度（loaded/total）
+// 未启用后台初始化时首次生成才加载背景图，始终返回200
+func ReadyzHandler(c *gin.Context) {
+	status := captchaSvc.InitStatus()
+	body := gin.H{"status": ReadyStatusReady, "loaded": status.Loaded, "total": status.Total}
+	switch {
+	case status.Ready || !config.AsyncInit:
+		c.JSON(http.StatusOK, body)
+	case status.Err != nil:
+		body["status"] = ReadyStatusFailed
+		body["error"] = status.Err.Error()
+		c.JSON(http.StatusServiceUnavailable, body)
+	default:
+		body["status"] = ReadyStatusInitializing
+		c.JSON(http.StatusServiceUnavailable, body)
+	}
+}
@@ -0,0 +1,53 @@
+package server
+
+import (
+	"log/slog"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// RequestIDHeader 请求ID请求头/响应头
+const RequestIDHeader = "X-Request-ID"
+
+// requestIDKey 请求ID在 gin.Context 中的键
+const requestIDKey = "requestId"
+
+// maxRequestIDLength 接受的客户端请求ID最大长度
+const maxRequestIDLength = 128
+
+// RequestIDMiddleware 为每个请求生成或沿用 X-Request-ID，写入响应头并用于日志关联
+func RequestIDMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(RequestIDHeader)
+		if !validRequestID(id) {
+			id = uuid.New().String()
+		}
+		c.Set(requestIDKey, id)
+		c.Header(RequestIDHeader, id)
+		c.Next()
+	}
+}
+
+// requestID 返回当前请求的ID
+func requestID(c *gin.Context) string {
+	return c.GetString(requestIDKey)
+}
+
+// requestLogger 返回带请求ID的日志
+func requestLogger(c *gin.Context) *slog.Logger {
+	return slog.With("requestId", requestID(c))
+}
+
+// validRequestID 只沿用长度合理且由可见ASCII字符组成的请求ID，避免日志注入
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < '!' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
@@ -1,31 +1,432 @@
 package server
 
 import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gpencil/photo_captcha/audit"
+	"github.com/gpencil/photo_captcha/captcha"
+	"github.com/gpencil/photo_captcha/captcha/statsd"
+	"github.com/gpencil/photo_captcha/pow"
+	"github.com/gpencil/photo_captcha/ratelimit"
+	"github.com/gpencil/photo_captcha/redis"
+	"github.com/gpencil/photo_captcha/web"
+
 	"github.com/gin-gonic/gin"
 )
 
-// SetupRouter 配置路由
+// SetupRouter 使用默认配置配置路由
 func SetupRouter() *gin.Engine {
-	router := gin.Default()
+	router, _ := SetupRouterWithConfig(DefaultConfig())
+	return router
+}
+
+// SetupRouterWithConfig 按配置配置路由
+func SetupRouterWithConfig(cfg *Config) (*gin.Engine, error) {
+	config = cfg
+	if cfg.GinMode != "" {
+		gin.SetMode(cfg.GinMode)
+	}
+	tolerance.Store(int64(cfg.Tolerance))
+	formats, err := imageFormats(cfg.ImageFormats)
+	if err != nil {
+		return nil, err
+	}
+
+	// 配置了Redis时验证码、封禁和限流状态在多实例间共享
+	redisClient = nil
+	if cfg.RedisURL != "" {
+		client, err := redis.NewClient(cfg.RedisURL)
+		if err != nil {
+			return nil, err
+		}
+		redisClient = client
+	}
+
+	// 验证码服务：有效期和背景图目录
+	opts := []captcha.Option{
+		captcha.WithTTL(cfg.CaptchaTTL),
+		captcha.WithTolerance(cfg.Tolerance),
+		captcha.WithLogger(slog.Default()),
+		captcha.WithAnswerSecret(cfg.AnswerSecret),
+		captcha.WithIDSecret(cfg.IDSecret),
+		captcha.WithIDKeyRotation(cfg.IDKeyRotation),
+		captcha.WithMaxChallengeAge(cfg.MaxChallengeAge),
+		captcha.WithDebugAnswers(cfg.DebugAnswers),
+		captcha.WithBackgroundScale(cfg.BackgroundScale),
+		captcha.WithPNGCompression(cfg.PNGCompression),
+		captcha.WithFastBlur(cfg.FastBlur),
+		captcha.WithPlacement(captcha.Placement{Mode: cfg.Placement, Margin: cfg.PlacementMargin}),
+		captcha.WithPregeneration(cfg.Pregenerate, cfg.PregenerateWorkers),
+		captcha.WithBurstCache(captcha.BurstConfig{
+			Threshold: cfg.BurstThreshold,
+			Window:    cfg.BurstWindow,
+			MaxReuse:  cfg.BurstMaxReuse,
+		}),
+		captcha.WithRenderCache(captcha.RenderCacheConfig{
+			Size:   cfg.RenderCacheSize,
+			Bucket: cfg.RenderCacheBucket,
+		}),
+		captcha.WithDownload(captcha.DownloadConfig{
+			Timeout: cfg.DownloadTimeout,
+			Retries: cfg.DownloadRetries,
+			Header:  cfg.DownloadHeader,
+		}),
+		captcha.WithDiskCache(captcha.DiskCacheConfig{
+			Dir:      cfg.ImageCacheDir,
+			MaxBytes: cfg.ImageCacheMaxBytes,
+		}),
+		captcha.WithImagePolicy(captcha.ImagePolicy{
+			MaxBytes:     cfg.MaxImageBytes,
+			MaxPixels:    cfg.MaxImagePixels,
+			MaxDimension: cfg.MaxImageDimension,
+			Formats:      formats,
+		}),
+	}
+	ipBlocklist = nil
+	if cfg.IPBlocklist != "" {
+		blocklist, err := captcha.NewFileBlocklist(cfg.IPBlocklist)
+		if err != nil {
+			return nil, err
+		}
+		ipBlocklist = blocklist
+		opts = append(opts, captcha.WithIPReputation(blocklist))
+	}
+	if redisClient != nil {
+		opts = append(opts, captcha.WithStore(redis.NewCaptchaStore(redisClient, "captcha:data:", cfg.CaptchaTTL)))
+	}
+	if cfg.BackgroundHashes != "" {
+		hashes, err := captcha.LoadBackgroundHashes(cfg.BackgroundHashes)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, captcha.WithBackgroundHashes(hashes))
+	}
+	if len(cfg.IDKeys) > 0 {
+		keys, err := parseSigningKeys(cfg.IDKeys)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, captcha.WithIDKeys(keys...))
+	}
+	if assetsCloser != nil {
+		assetsCloser.Close()
+	}
+	assets, assetsClose, err := openAssets(cfg)
+	if err != nil {
+		return nil, err
+	}
+	assetsFS, assetsCloser = assets, assetsClose
+	if assetsFS != nil {
+		opts = append(opts, captcha.WithFS(assetsFS))
+	}
+	if cfg.LazyBackgrounds {
+		opts = append(opts, captcha.WithLazyBackgrounds())
+	}
+	if cfg.RandomHoleStyle {
+		opts = append(opts, captcha.WithHoleVariation(captcha.DefaultHoleVariation))
+	}
+	if dir := backgroundDir(); dir != "" {
+		urls, err := backgroundImages(assetsFS, dir)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, captcha.WithBackgrounds(urls...))
+	}
+	if statsdClient != nil {
+		statsdClient.Close()
+		statsdClient = nil
+	}
+	if cfg.StatsdAddr != "" {
+		client, err := statsd.New(cfg.StatsdAddr, cfg.StatsdPrefix)
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect statsd: %w", err)
+		}
+		statsdClient = client
+		opts = append(opts, captcha.WithMetrics(client))
+	}
+	stopInit()
+	if captchaSvc != nil {
+		captchaSvc.Close()
+	}
+	captchaSvc = captcha.NewCaptchaService(opts...)
+	analyticsRecorder = nil
+	if cfg.AnalyticsHours > 0 {
+		analyticsRecorder = newAnalytics(cfg.AnalyticsHours)
+		analyticsRecorder.attach(captchaSvc)
+	}
+	if cfg.AsyncInit {
+		startInit(captchaSvc)
+	}
+	if cfg.DebugAnswers {
+		slog.Warn("captcha debug mode enabled: generate responses reveal the answer, never use in production")
+	}
+
+	// 异步生成任务队列
+	if generateJobs != nil {
+		generateJobs.close()
+		generateJobs = nil
+	}
+	if cfg.AsyncWorkers > 0 {
+		generateJobs = newJobQueue(captchaSvc, cfg.AsyncWorkers, cfg.AsyncQueueSize, captchaTTL())
+	}
+
+	// 演示页面，开发时可从磁盘读取
+	webFS = web.FS
+	if assetsFS != nil && isDirFS(assetsFS, assetsWebDir) {
+		sub, err := fs.Sub(assetsFS, assetsWebDir)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open web assets: %w", err)
+		}
+		webFS = sub
+	}
+	if cfg.WebDir != "" {
+		webFS = os.DirFS(cfg.WebDir)
+	}
 
-	// CORS中间件
+	// 生成接口限流，超限后要求完成工作量证明
+	generateLimiter = nil
+	if cfg.GenerateRateLimit > 0 {
+		generateLimiter = newFixedWindowLimiter(cfg.GenerateRateLimit, time.Minute)
+	}
+	clusterLimiter = nil
+	if cfg.GenerateClusterRateLimit > 0 {
+		clusterLimiter = newFixedWindowLimiter(cfg.GenerateClusterRateLimit, time.Minute)
+	}
+	// 工作量证明挑战无状态签发，已使用的挑战记录在 Redis（配置时）中
+	var powUsed pow.ReplayStore
+	if redisClient != nil {
+		powUsed = redis.NewPoWReplayStore(redisClient, "captcha:pow:")
+	}
+	powManager = pow.NewManager(powTTL, []byte(cfg.PoWSecret), powUsed)
+
+	// 访问日志，未配置时使用 gin 默认的文本日志
+	if accessLogCloser != nil {
+		accessLogCloser.Close()
+	}
+	writer, closer, err := openAccessLog(cfg)
+	if err != nil {
+		return nil, err
+	}
+	accessLogWriter, accessLogCloser = writer, closer
+
+	router := newEngine()
+
+	// 暴力破解封禁
+	lockout = nil
+	if cfg.LockoutMaxFailures > 0 {
+		var store captcha.LockoutStore = captcha.NewMemoryLockoutStore()
+		if redisClient != nil {
+			store = redis.NewLockoutStore(redisClient, "captcha:")
+		}
+		lockout = captcha.NewLockout(store, cfg.LockoutMaxFailures, cfg.LockoutWindow, cfg.LockoutDuration)
+	}
+
+	// 生成和验证接口分别按IP和全局限流
+	var rateStore interface {
+		ratelimit.Store
+		ratelimit.CounterStore
+	} = ratelimit.NewMemoryStore()
+	if redisClient != nil {
+		rateStore = redis.NewRateLimitStore(redisClient, "captcha:ratelimit:")
+	}
+	generateQuota = nil
+	if cfg.GenerateDailyQuota > 0 {
+		generateQuota = ratelimit.NewDailyQuota(rateStore, "generate:daily", cfg.GenerateDailyQuota)
+	}
+	verifyIPLimiter = newLimiter(rateStore, "verify:ip", cfg.VerifyIPRateLimit)
+	verifyGlobalLimiter = newLimiter(rateStore, "verify", cfg.VerifyGlobalRateLimit)
+	mw := captchaMiddlewares{
+		generate: RateLimitMiddleware(
+			newLimiter(rateStore, "generate:ip", cfg.GenerateIPRateLimit),
+			newLimiter(rateStore, "generate", cfg.GenerateGlobalRateLimit)),
+		verify: RateLimitMiddleware(verifyIPLimiter, verifyGlobalLimiter),
+		body: []gin.HandlerFunc{
+			ReadTimeoutMiddleware(cfg.BodyReadTimeout),
+			MaxBodySizeMiddleware(cfg.MaxBodySize),
+		},
+	}
+
+	// 验证审计记录
+	auditSink = nil
+	if cfg.AuditSink != "" {
+		sink, err := audit.NewSink(cfg.AuditSink)
+		if err != nil {
+			return nil, err
+		}
+		auditSink = sink
+	}
+
+	// 只信任配置的代理转发的客户端IP请求头
+	if err := configureClientIP(router, cfg); err != nil {
+		return nil, err
+	}
+
+	// 请求ID和CORS中间件
+	router.Use(RequestIDMiddleware())
 	router.Use(CORSMiddleware())
 
-	// API路由
-	api := router.Group("/api")
+	// 所有路由挂载在 BasePath 下
+	root := router.Group(cfg.basePath())
+
+	// API路由：/api/v1 为版本化路由，/api/captcha 作为旧路径别名保留
+	api := root.Group("/api")
 	{
-		captchaGroup := api.Group("/captcha")
-		{
-			captchaGroup.GET("/generate", GenerateCaptchaHandler)
-			captchaGroup.POST("/verify", VerifyCaptchaHandler)
+		registerCaptchaRoutes(api.Group("/v1", APIVersionMiddleware(APIVersion)).Group("/captcha"), mw)
+		registerCaptchaRoutes(api.Group("/captcha"), mw)
+
+		// OpenAPI 文档，根据 captchaRoutes 生成
+		root.GET(OpenAPIPath, OpenAPIHandler)
+
+		// 答案披露接口，仅供持有服务密钥的可信后端调用，使用独立管理端口时只在管理端口注册
+		if cfg.AnswerSecret != "" && cfg.AdminAddr == "" {
+			api.GET("/internal/captcha/:id/answer", InternalAnswerHandler)
+		}
+
+		// 管理接口，未配置认证方式或使用独立管理端口时不在此注册
+		if cfg.adminEnabled() && cfg.AdminAddr == "" {
+			registerAdminRoutes(api.Group("/admin", AdminAuthMiddleware(cfg)))
 		}
 	}
 
+	// 存活和就绪检查
+	root.GET("/healthz", HealthzHandler)
+	root.GET("/readyz", ReadyzHandler)
+
+	// WebSocket：推送验证码刷新和验证结果
+	root.GET("/ws/captcha", CaptchaWebSocketHandler)
+	root.GET("/ws/captcha/jobs/:id", GenerateJobWebSocketHandler)
+
 	// 首页
-	router.GET("/", IndexHandler)
-	router.GET("/index.html", IndexHandler)
+	root.GET("/", IndexHandler)
+	root.GET("/index.html", IndexHandler)
+	root.StaticFS("/static", http.FS(webFS))
 
-	return router
+	return router, nil
+}
+
+// captchaMiddlewares 验证码接口按路由附加的中间件
+type captchaMiddlewares struct {
+	generate gin.HandlerFunc   // 生成类接口限流
+	verify   gin.HandlerFunc   // 验证接口限流
+	body     []gin.HandlerFunc // 带请求体接口的大小和读取超时限制
+}
+
+// newLimiter 创建每分钟 perMinute 个请求的限流器，0表示不限制（返回nil）
+func newLimiter(store ratelimit.Store, name string, perMinute int) *ratelimit.Limiter {
+	if perMinute <= 0 {
+		return nil
+	}
+	return ratelimit.New(store, name, ratelimit.PerMinute(perMinute))
+}
+
+// parseSigningKeys 解析 <kid>:<密钥> 格式的签名密钥，kid 只能包含字母、数字、- 和 _
+func parseSigningKeys(values []string) ([]captcha.SigningKey, error) {
+	keys := make([]captcha.SigningKey, 0, len(values))
+	for _, value := range values {
+		id, secret, found := strings.Cut(value, ":")
+		if !found || id == "" || secret == "" {
+			return nil, errors.New("invalid id key: want <kid>:<secret>")
+		}
+		if strings.ContainsFunc(id, func(r rune) bool {
+			return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_')
+		}) {
+			return nil, fmt.Errorf("invalid id key id %q: only letters, digits, - and _ are allowed", id)
+		}
+		keys = append(keys, captcha.SigningKey{ID: id, Secret: secret})
+	}
+	return keys, nil
+}
+
+// imageFormats 校验允许解码的图片格式，只能是服务注册了解码器的 jpeg、png、gif
+// webp 的解码器不在标准库中（golang.org/x/image/webp），服务保持只依赖标准库解码图片，不注册 webp；
+// 需要 webp 背景图时应预先转换为 jpeg 或 png，或在嵌入 captcha 包的程序中注册解码器后通过 captcha.WithImagePolicy 允许
+func imageFormats(values []string) ([]string, error) {
+	formats := make([]string, 0, len(values))
+	for _, value := range values {
+		format := strings.ToLower(strings.TrimSpace(value))
+		switch format {
+		case "jpeg", "png", "gif":
+			formats = append(formats, format)
+		case "webp":
+			return nil, errors.New("unsupported image format \"webp\": this server does not bundle a webp decoder, convert backgrounds to jpeg or png")
+		default:
+			return nil, fmt.Errorf("unsupported image format %q: want jpeg, png or gif", value)
+		}
+	}
+	if len(formats) == 0 {
+		return nil, errors.New("at least one image format is required")
+	}
+	return formats, nil
+}
+
+// registerCaptchaRoutes 注册验证码接口（定义见 captchaRoutes）
+func registerCaptchaRoutes(captchaGroup *gin.RouterGroup, mw captchaMiddlewares) {
+	for _, route := range captchaRoutes {
+		var handlers []gin.HandlerFunc
+		switch route.limit {
+		case "generate":
+			handlers = append(handlers, mw.generate)
+		case "verify":
+			handlers = append(handlers, mw.verify)
+		}
+		if route.body != nil {
+			handlers = append(handlers, mw.body...)
+		}
+		captchaGroup.Handle(route.method, route.path, append(handlers, route.handler)...)
+	}
+}
+
+// Shutdown 释放服务持有的资源：停止验证码存储的清理协程，刷新并关闭审计输出和访问日志，关闭Redis和StatsD连接
+// 应在 http.Server 停止接收请求并处理完进行中的请求之后调用
+func Shutdown() error {
+	if generateJobs != nil {
+		generateJobs.close()
+		generateJobs = nil
+	}
+	stopInit()
+	if captchaSvc != nil {
+		captchaSvc.Close()
+	}
+
+	var firstErr error
+	if auditSink != nil {
+		if err := auditSink.Close(); err != nil {
+			firstErr = fmt.Errorf("failed to close audit sink: %w", err)
+		}
+		auditSink = nil
+	}
+	if redisClient != nil {
+		if err := redisClient.Close(); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("failed to close redis client: %w", err)
+		}
+		redisClient = nil
+	}
+	if accessLogCloser != nil {
+		if err := accessLogCloser.Close(); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("failed to close access log: %w", err)
+		}
+		accessLogWriter, accessLogCloser = nil, nil
+	}
+	if assetsCloser != nil {
+		if err := assetsCloser.Close(); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("failed to close assets: %w", err)
+		}
+		assetsFS, assetsCloser = nil, nil
+	}
+	if statsdClient != nil {
+		if err := statsdClient.Close(); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("failed to close statsd client: %w", err)
+		}
+		statsdClient = nil
+	}
+	return firstErr
 }
 
 // CORSMiddleware CORS中间件
@@ -33,7 +434,8 @@ func CORSMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
 		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With")
+		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With, X-Request-ID")
+		c.Writer.Header().Set("Access-Control-Expose-Headers", "X-Request-ID")
 		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, DELETE")
 
 		if c.Request.Method == "OPTIONS" {
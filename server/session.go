@@ -0,0 +1,54 @@
+package server
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// sessionIDLength 会话标识长度（十六进制字符数）
+const sessionIDLength = 32
+
+// boundSession 开启会话绑定时返回请求携带的会话Cookie值，否则返回空字符串
+// issue 为 true 且请求未携带有效Cookie时生成新的会话并下发 HttpOnly Cookie（WebSocket 握手无法设置Cookie）
+func boundSession(c *gin.Context, issue bool) string {
+	if !config.BindSession {
+		return ""
+	}
+
+	if value, err := c.Cookie(config.SessionCookieName); err == nil && validSessionID(value) {
+		return value
+	}
+	if !issue {
+		return ""
+	}
+
+	buf := make([]byte, sessionIDLength/2)
+	if _, err := rand.Read(buf); err != nil {
+		requestLogger(c).Error("failed to generate session id", "error", err)
+		return ""
+	}
+	value := hex.EncodeToString(buf)
+
+	// 不设置过期时间，浏览器关闭后失效
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     config.SessionCookieName,
+		Value:    value,
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https",
+		SameSite: http.SameSiteLaxMode,
+	})
+	return value
+}
+
+// validSessionID 检查会话标识格式，忽略被篡改的Cookie
+func validSessionID(value string) bool {
+	if len(value) != sessionIDLength {
+		return false
+	}
+	_, err := hex.DecodeString(value)
+	return err == nil
+}
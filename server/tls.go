@@ -0,0 +1,43 @@
+package server
+
+import (
+	"crypto/tls"
+	"fmt"
+
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// TLSEnabled 是否配置了HTTPS（证书文件或自动证书）
+func (c *Config) TLSEnabled() bool {
+	return c.TLSCertFile != "" || len(c.AutocertDomains) > 0
+}
+
+// NewTLSConfig 按配置创建HTTPS使用的TLS配置，未配置HTTPS时返回nil
+// 配置了 AutocertDomains 时通过 Let's Encrypt 自动申请证书（TLS-ALPN-01 验证，需监听443端口），否则加载证书文件
+func NewTLSConfig(cfg *Config) (*tls.Config, error) {
+	if len(cfg.AutocertDomains) > 0 {
+		manager := &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			HostPolicy: autocert.HostWhitelist(cfg.AutocertDomains...),
+			Cache:      autocert.DirCache(cfg.AutocertCacheDir),
+			Email:      cfg.AutocertEmail,
+		}
+		return manager.TLSConfig(), nil
+	}
+
+	if cfg.TLSCertFile == "" {
+		return nil, nil
+	}
+	if cfg.TLSKeyFile == "" {
+		return nil, fmt.Errorf("tls key file is required when tls cert file is set")
+	}
+
+	cert, err := tls.LoadX509KeyPair(cfg.TLSCertFile, cfg.TLSKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load tls certificate: %w", err)
+	}
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}, nil
+}
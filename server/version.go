@@ -0,0 +1,30 @@
+package server
+
+import "github.com/gin-gonic/gin"
+
+// APIVersion 当前API版本
+const APIVersion = "v1"
+
+// apiVersionKey API版本在 gin.Context 中的键
+const apiVersionKey = "apiVersion"
+
+// APIVersionMiddleware 标记请求所属的API版本，版本化路由的响应中会带上 apiVersion 字段
+func APIVersionMiddleware(version string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(apiVersionKey, version)
+		c.Next()
+	}
+}
+
+// respond 输出JSON响应，版本化路由的响应额外带上 apiVersion 字段（旧路径保持原有格式）
+func respond(c *gin.Context, status int, body gin.H) {
+	c.JSON(status, versioned(c, body))
+}
+
+// versioned 版本化路由的响应体带上 apiVersion 字段
+func versioned(c *gin.Context, body gin.H) gin.H {
+	if version := c.GetString(apiVersionKey); version != "" {
+		body["apiVersion"] = version
+	}
+	return body
+}
@@ -0,0 +1,292 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/gpencil/photo_captcha/captcha"
+	"github.com/gpencil/photo_captcha/httpapi"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/net/websocket"
+)
+
+// verifyResults 验证结果广播，WebSocket 连接订阅当前验证码的结果
+var verifyResults = newResultHub()
+
+// resultHub 按验证码ID分发验证结果
+type resultHub struct {
+	mu          sync.Mutex
+	subscribers map[string]map[chan *captcha.VerifyResult]struct{}
+}
+
+func newResultHub() *resultHub {
+	return &resultHub{
+		subscribers: make(map[string]map[chan *captcha.VerifyResult]struct{}),
+	}
+}
+
+// Subscribe 订阅验证码的验证结果，返回的函数用于取消订阅
+func (h *resultHub) Subscribe(id string) (<-chan *captcha.VerifyResult, func()) {
+	ch := make(chan *captcha.VerifyResult, 1)
+
+	h.mu.Lock()
+	if h.subscribers[id] == nil {
+		h.subscribers[id] = make(map[chan *captcha.VerifyResult]struct{})
+	}
+	h.subscribers[id][ch] = struct{}{}
+	h.mu.Unlock()
+
+	return ch, func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		delete(h.subscribers[id], ch)
+		if len(h.subscribers[id]) == 0 {
+			delete(h.subscribers, id)
+		}
+	}
+}
+
+// Publish 推送验证结果，订阅者未及时读取时丢弃旧结果
+func (h *resultHub) Publish(id string, result *captcha.VerifyResult) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for ch := range h.subscribers[id] {
+		select {
+		case ch <- result:
+		default:
+			select {
+			case <-ch:
+			default:
+			}
+			ch <- result
+		}
+	}
+}
+
+// wsMessage 客户端发来的 WebSocket 消息
+type wsMessage struct {
+	// Type 消息类型：verify 提交验证，refresh 换一张
+	Type string `json:"type"`
+	// Data verify 消息的验证参数，格式同 POST /verify 的请求体（id 可省略，默认为当前验证码）
+	Data json.RawMessage `json:"data,omitempty"`
+}
+
+// wsSession 一个 WebSocket 连接
+type wsSession struct {
+	c           *gin.Context
+	ws          *websocket.Conn
+	fingerprint string
+
+	writeMu sync.Mutex
+
+	// 当前验证码及其结果订阅
+	currentID   string
+	results     <-chan *captcha.VerifyResult
+	unsubscribe func()
+	expiry      *time.Timer
+}
+
+// CaptchaWebSocketHandler 验证码 WebSocket 处理器
+// 连接后推送验证码，过期时自动推送新的验证码，并推送验证结果（无论通过 WebSocket 还是 HTTP 接口提交），
+// 长时间停留的表单无需轮询
+func CaptchaWebSocketHandler(c *gin.Context) {
+	if !checkLockout(c, c.Query("fingerprint")) {
+		return
+	}
+
+	// 不校验 Origin，与 CORS 配置保持一致
+	server := websocket.Server{
+		Handler: func(ws *websocket.Conn) {
+			// 与 HTTP 接口相同的请求体大小上限，超出时断开连接
+			if config != nil && config.MaxBodySize > 0 {
+				ws.MaxPayloadBytes = int(config.MaxBodySize)
+			}
+			session := &wsSession{
+				c:           c,
+				ws:          ws,
+				fingerprint: c.Query("fingerprint"),
+			}
+			session.serve()
+		},
+	}
+	server.ServeHTTP(c.Writer, c.Request)
+}
+
+// serve 处理连接直到客户端断开
+func (s *wsSession) serve() {
+	defer s.resetChallenge()
+
+	messages := make(chan wsMessage)
+	done := make(chan struct{})
+	quit := make(chan struct{})
+	defer close(quit)
+	go func() {
+		defer close(done)
+		for {
+			var m wsMessage
+			if err := websocket.JSON.Receive(s.ws, &m); err != nil {
+				return
+			}
+			select {
+			case messages <- m:
+			case <-quit:
+				return
+			}
+		}
+	}()
+
+	if !s.pushChallenge() {
+		return
+	}
+
+	for {
+		var expired <-chan time.Time
+		if s.expiry != nil {
+			expired = s.expiry.C
+		}
+
+		select {
+		case <-done:
+			return
+		case m := <-messages:
+			if !s.handleMessage(m) {
+				return
+			}
+		case result := <-s.results:
+			s.send(gin.H{"type": "result", "data": httpapi.VerifyResultData(result)})
+			if !result.Success && !result.Reason.Retryable() {
+				// 验证码已作废，推送新的验证码
+				if !s.pushChallenge() {
+					return
+				}
+			}
+		case <-expired:
+			if !s.pushChallenge() {
+				return
+			}
+		}
+	}
+}
+
+// handleMessage 处理客户端消息，返回 false 时关闭连接
+func (s *wsSession) handleMessage(m wsMessage) bool {
+	switch m.Type {
+	case "refresh":
+		oldID := s.currentID
+		if !s.pushChallenge() {
+			return false
+		}
+		captchaSvc.Revoke(oldID)
+		return true
+	case "verify":
+		// 与 HTTP 验证接口相同的限流和封禁检查
+		if allowed, retryAfter := verifyRateAllowed(s.c); !allowed {
+			s.sendRetryError(429, msg(s.c, MsgRateLimited), retryAfter)
+			return true
+		}
+		var req VerifyCaptchaRequest
+		if err := json.Unmarshal(m.Data, &req); err != nil || req.X == nil {
+			s.sendError(400, msg(s.c, MsgInvalidRequest, "invalid verify data"))
+			return true
+		}
+		if req.ID == "" {
+			req.ID = s.currentID
+		}
+		if req.Fingerprint == "" {
+			req.Fingerprint = s.fingerprint
+		}
+		if banned, retryAfter := lockedOut(s.c, req.Fingerprint); banned {
+			s.sendRetryError(403, msg(s.c, MsgBanned), retryAfter)
+			return true
+		}
+		// 结果通过订阅推送
+		verifyCaptcha(s.c, &req, false, time.Now())
+		return true
+	default:
+		s.sendError(400, msg(s.c, MsgInvalidRequest, "unknown message type"))
+		return true
+	}
+}
+
+// pushChallenge 生成新的验证码并推送，订阅其验证结果，返回 false 时关闭连接
+func (s *wsSession) pushChallenge() bool {
+	s.resetChallenge()
+
+	if generateRateExceeded(s.c) > 0 {
+		s.sendError(429, msg(s.c, MsgPoWRequired))
+		return false
+	}
+	if exceeded, _ := dailyQuotaExceeded(s.c, s.fingerprint); exceeded {
+		s.sendError(429, msg(s.c, MsgDailyQuota))
+		return false
+	}
+
+	sliderCaptcha, err := generateCaptcha(s.c, s.fingerprint)
+	if errors.Is(err, errNotReady) {
+		s.sendError(503, msg(s.c, MsgInitializing))
+		return false
+	}
+	if err != nil {
+		s.sendError(500, msg(s.c, MsgGenerateFailed, err))
+		return false
+	}
+
+	s.currentID = sliderCaptcha.ID
+	s.results, s.unsubscribe = verifyResults.Subscribe(sliderCaptcha.ID)
+	ttl := captchaTTL()
+	s.expiry = time.NewTimer(ttl)
+
+	data := captchaData(s.c.Request.Context(), sliderCaptcha)
+	data["expiresIn"] = int(ttl.Seconds())
+	return s.send(gin.H{
+		"type": "challenge",
+		"data": data,
+	})
+}
+
+// resetChallenge 取消当前验证码的结果订阅和过期计时
+func (s *wsSession) resetChallenge() {
+	if s.unsubscribe != nil {
+		s.unsubscribe()
+		s.unsubscribe = nil
+	}
+	if s.expiry != nil {
+		s.expiry.Stop()
+		s.expiry = nil
+	}
+	s.results = nil
+}
+
+// sendError 推送错误消息
+func (s *wsSession) sendError(code int, message string) {
+	s.send(gin.H{
+		"type":      "error",
+		"code":      code,
+		"message":   message,
+		"requestId": requestID(s.c),
+	})
+}
+
+// sendRetryError 发送带重试等待秒数的错误消息（限流、封禁）
+func (s *wsSession) sendRetryError(code int, message string, retryAfter int) {
+	s.send(gin.H{
+		"type":      "error",
+		"code":      code,
+		"message":   message,
+		"requestId": requestID(s.c),
+		"data": gin.H{
+			"retryAfter": retryAfter,
+		},
+	})
+}
+
+// send 发送一条消息，失败时返回 false
+func (s *wsSession) send(v interface{}) bool {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	return websocket.JSON.Send(s.ws, v) == nil
+}
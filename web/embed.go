@@ -0,0 +1,9 @@
+// Package web 内置的验证码演示页面，编译进二进制，无需依赖运行目录
+package web
+
+import "embed"
+
+// FS 演示页面及静态资源（新增资源文件时需同步修改 go:embed 模式）
+//
+//go:embed *.html
+var FS embed.FS